@@ -0,0 +1,160 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Songmu/prompter"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	isatty "github.com/mattn/go-isatty"
+)
+
+var approvalPollInterval = 10 * time.Second
+
+// ApprovalSource waits for an approval of a deployment.
+type ApprovalSource interface {
+	// Wait blocks until the deployment is approved.
+	// It returns an error when the deployment is rejected or ctx is done.
+	Wait(ctx context.Context) error
+	String() string
+}
+
+var errApprovalRejected = errors.New("deployment is rejected")
+
+// parseApproval parses an approval value.
+// It returns true when approved, errApprovalRejected when rejected, and false when undecided.
+func parseApproval(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "approve", "approved", "yes":
+		return true, nil
+	case "reject", "rejected", "no":
+		return false, errApprovalRejected
+	default:
+		return false, nil
+	}
+}
+
+type interactiveApproval struct{}
+
+func (a interactiveApproval) String() string {
+	return "interactive prompt"
+}
+
+func (a interactiveApproval) Wait(ctx context.Context) error {
+	ans := prompter.Prompt(`Enter "yes" to continue the deployment`, "")
+	if ans != "yes" {
+		return errApprovalRejected
+	}
+	return nil
+}
+
+type ssmParameterApproval struct {
+	client *ssm.Client
+	name   string
+}
+
+func (a *ssmParameterApproval) String() string {
+	return fmt.Sprintf("SSM parameter %s", a.name)
+}
+
+func (a *ssmParameterApproval) Wait(ctx context.Context) error {
+	return pollApproval(ctx, func(ctx context.Context) (bool, error) {
+		out, err := a.client.GetParameter(ctx, &ssm.GetParameterInput{
+			Name:           aws.String(a.name),
+			WithDecryption: aws.Bool(true),
+		})
+		if err != nil {
+			var nf *ssmTypes.ParameterNotFound
+			if errors.As(err, &nf) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to get ssm parameter %s: %w", a.name, err)
+		}
+		return parseApproval(aws.ToString(out.Parameter.Value))
+	})
+}
+
+type fileApproval struct {
+	path string
+}
+
+func (a *fileApproval) String() string {
+	return fmt.Sprintf("file %s", a.path)
+}
+
+func (a *fileApproval) Wait(ctx context.Context) error {
+	return pollApproval(ctx, func(ctx context.Context) (bool, error) {
+		b, err := os.ReadFile(a.path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to read approval file %s: %w", a.path, err)
+		}
+		// the file appeared. approved unless it says rejected.
+		if _, err := parseApproval(string(b)); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+func pollApproval(ctx context.Context, check func(context.Context) (bool, error)) error {
+	for {
+		ok, err := check(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("approval is not given: %w", ctx.Err())
+		case <-time.After(approvalPollInterval):
+		}
+	}
+}
+
+func (d *App) newApprovalSource(opt DeployOption) (ApprovalSource, error) {
+	switch {
+	case opt.ApprovalSSMParameter != "" && opt.ApprovalFile != "":
+		return nil, ErrConflictOptions("approval-ssm-parameter and approval-file are exclusive")
+	case opt.ApprovalSSMParameter != "":
+		return &ssmParameterApproval{
+			client: ssm.NewFromConfig(d.config.awsv2Config),
+			name:   opt.ApprovalSSMParameter,
+		}, nil
+	case opt.ApprovalFile != "":
+		return &fileApproval{path: opt.ApprovalFile}, nil
+	case isatty.IsTerminal(os.Stdin.Fd()):
+		return interactiveApproval{}, nil
+	default:
+		return nil, errors.New("--require-approval requires a terminal, --approval-ssm-parameter or --approval-file")
+	}
+}
+
+func (d *App) approveDeploy(ctx context.Context, opt DeployOption) error {
+	if !opt.RequireApproval || opt.DryRun {
+		return nil
+	}
+	src, err := d.newApprovalSource(opt)
+	if err != nil {
+		return err
+	}
+	if err := d.Diff(ctx, DiffOption{Unified: true}); err != nil {
+		return fmt.Errorf("failed to show diff: %w", err)
+	}
+	d.Log("Waiting for approval by %s", src)
+	if err := src.Wait(ctx); err != nil {
+		return err
+	}
+	d.Log("Deployment is approved")
+	return nil
+}
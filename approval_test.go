@@ -0,0 +1,64 @@
+package ecspresso_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestParseApproval(t *testing.T) {
+	for _, tt := range []struct {
+		value    string
+		approved bool
+		rejected bool
+	}{
+		{value: "approved", approved: true},
+		{value: " Yes\n", approved: true},
+		{value: "rejected", rejected: true},
+		{value: "no", rejected: true},
+		{value: "pending"},
+		{value: ""},
+	} {
+		ok, err := ecspresso.ParseApproval(tt.value)
+		if ok != tt.approved {
+			t.Errorf("%q: unexpected approved %v", tt.value, ok)
+		}
+		if (err != nil) != tt.rejected {
+			t.Errorf("%q: unexpected error %v", tt.value, err)
+		}
+	}
+}
+
+func TestFileApproval(t *testing.T) {
+	ecspresso.SetApprovalPollInterval(10 * time.Millisecond)
+	defer ecspresso.SetApprovalPollInterval(10 * time.Second)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "approval")
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		os.WriteFile(path, nil, 0644)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := ecspresso.NewFileApproval(path).Wait(ctx); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("rejected"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ecspresso.NewFileApproval(path).Wait(ctx); err == nil {
+		t.Error("expected rejected error")
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := ecspresso.NewFileApproval(filepath.Join(dir, "none")).Wait(ctx); err == nil {
+		t.Error("expected timeout error")
+	}
+}
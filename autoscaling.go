@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
 	aasTypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
 )
@@ -43,6 +44,60 @@ func (p *modifyAutoScalingParams) SuspendState() *aasTypes.SuspendedState {
 	}
 }
 
+// suspendAction returns "suspend" or "resume" for the logs.
+func (p *modifyAutoScalingParams) suspendAction() string {
+	if p.Suspend != nil && *p.Suspend {
+		return "suspend"
+	}
+	return "resume"
+}
+
+// onlySuspendedStateOf reports whether the params change only the suspended state, and the state is already s.
+func (p *modifyAutoScalingParams) onlySuspendedStateOf(s *aasTypes.SuspendedState) bool {
+	if p.Suspend == nil || p.MinCapacity != nil || p.MaxCapacity != nil {
+		return false
+	}
+	if s == nil {
+		s = &aasTypes.SuspendedState{}
+	}
+	return aws.ToBool(s.DynamicScalingInSuspended) == *p.Suspend &&
+		aws.ToBool(s.DynamicScalingOutSuspended) == *p.Suspend &&
+		aws.ToBool(s.ScheduledScalingSuspended) == *p.Suspend
+}
+
+func suspendedStateString(s *aasTypes.SuspendedState) string {
+	if s == nil {
+		s = &aasTypes.SuspendedState{}
+	}
+	return map2str(map[string]string{
+		"DynamicScalingInSuspended":  fmt.Sprintf("%v", aws.ToBool(s.DynamicScalingInSuspended)),
+		"DynamicScalingOutSuspended": fmt.Sprintf("%v", aws.ToBool(s.DynamicScalingOutSuspended)),
+		"ScheduledScalingSuspended":  fmt.Sprintf("%v", aws.ToBool(s.ScheduledScalingSuspended)),
+	})
+}
+
+// validateAutoScaling validates the options to modify auto scaling. The capacities must be min <= desired <= max of the options given.
+func (opt DeployOption) validateAutoScaling() error {
+	if aws.ToBool(opt.SuspendAutoScaling) && aws.ToBool(opt.ResumeAutoScaling) {
+		return ErrConflictOptions("suspend-auto-scaling and resume-auto-scaling are exclusive")
+	}
+	min, max := opt.AutoScalingMin, opt.AutoScalingMax
+	if min != nil && max != nil && *min > *max {
+		return fmt.Errorf("auto-scaling-min %d must be less than or equal to auto-scaling-max %d", *min, *max)
+	}
+	desired := opt.DesiredCount
+	if desired == nil || *desired == DefaultDesiredCount {
+		return nil
+	}
+	if min != nil && *desired < *min {
+		return fmt.Errorf("desired count %d must be greater than or equal to auto-scaling-min %d", *desired, *min)
+	}
+	if max != nil && *desired > *max {
+		return fmt.Errorf("desired count %d must be less than or equal to auto-scaling-max %d", *desired, *max)
+	}
+	return nil
+}
+
 func (d *App) modifyAutoScaling(ctx context.Context, opt DeployOption) error {
 	p := opt.ModifyAutoScalingParams()
 	if p.isEmpty() {
@@ -63,15 +118,24 @@ func (d *App) modifyAutoScaling(ctx context.Context, opt DeployOption) error {
 		return fmt.Errorf("failed to describe scalable targets: %w", err)
 	}
 	if len(out.ScalableTargets) == 0 {
-		d.Log("[WARNING] No scalable target for %s", resourceId)
-		d.Log("[INFO] Skip modifying auto scaling settings")
+		if p.MinCapacity != nil || p.MaxCapacity != nil {
+			return ErrNotFound(fmt.Sprintf("no scalable target for %s. register a scalable target of Application Auto Scaling for the service before setting the capacities", resourceId))
+		}
+		d.Log("[INFO] No scalable target for %s. Nothing to %s", resourceId, p.suspendAction())
 		return nil
 	}
 
-	if opt.DryRun {
-		return nil
-	}
 	for _, target := range out.ScalableTargets {
+		if p.Suspend != nil {
+			d.Log("[INFO] Suspended state of %s was %s", *target.ResourceId, suspendedStateString(target.SuspendedState))
+		}
+		if p.onlySuspendedStateOf(target.SuspendedState) {
+			d.Log("[INFO] Auto scaling of %s is already %sd", *target.ResourceId, p.suspendAction())
+			continue
+		}
+		if opt.DryRun {
+			continue
+		}
 		d.Log("[INFO] Register scalable target %s %s", *target.ResourceId, p.String())
 		_, err := d.autoScaling.RegisterScalableTarget(
 			ctx,
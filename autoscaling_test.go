@@ -1,9 +1,12 @@
 package ecspresso_test
 
 import (
+	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	aasTypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
 	"github.com/google/go-cmp/cmp"
 	"github.com/kayac/ecspresso/v2"
 )
@@ -122,3 +125,58 @@ func TestModifyAutoScalingParams(t *testing.T) {
 		})
 	}
 }
+
+func TestMarshalAutoScalingDefinition(t *testing.T) {
+	now := time.Now()
+	targets := []aasTypes.ScalableTarget{
+		{
+			ResourceId:        aws.String("service/default/app"),
+			ServiceNamespace:  aasTypes.ServiceNamespaceEcs,
+			ScalableDimension: aasTypes.ScalableDimensionECSServiceDesiredCount,
+			MinCapacity:       aws.Int32(1),
+			MaxCapacity:       aws.Int32(10),
+			ScalableTargetARN: aws.String("arn:aws:application-autoscaling:ap-northeast-1:123456789012:scalable-target/xxx"),
+			CreationTime:      &now,
+		},
+	}
+	policies := []aasTypes.ScalingPolicy{
+		{
+			PolicyName:   aws.String("cpu"),
+			PolicyType:   aasTypes.PolicyTypeTargetTrackingScaling,
+			PolicyARN:    aws.String("arn:aws:autoscaling:ap-northeast-1:123456789012:scalingPolicy:xxx"),
+			CreationTime: &now,
+			Alarms:       []aasTypes.Alarm{{AlarmName: aws.String("alarm")}},
+		},
+	}
+	b, err := ecspresso.MarshalAutoScalingDefinition(targets, policies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var def struct {
+		ScalableTargets []map[string]any `json:"scalableTargets"`
+		ScalingPolicies []map[string]any `json:"scalingPolicies"`
+	}
+	if err := json.Unmarshal(b, &def); err != nil {
+		t.Fatal(err)
+	}
+	if len(def.ScalableTargets) != 1 || len(def.ScalingPolicies) != 1 {
+		t.Fatalf("unexpected definition %s", string(b))
+	}
+	target, policy := def.ScalableTargets[0], def.ScalingPolicies[0]
+	if target["resourceId"] != "service/default/app" || target["maxCapacity"] != float64(10) {
+		t.Errorf("unexpected scalable target %v", target)
+	}
+	if policy["policyName"] != "cpu" {
+		t.Errorf("unexpected scaling policy %v", policy)
+	}
+	for _, key := range []string{"creationTime", "scalableTargetARN"} {
+		if _, ok := target[key]; ok {
+			t.Errorf("%s must be removed from the scalable target", key)
+		}
+	}
+	for _, key := range []string{"creationTime", "policyARN", "alarms"} {
+		if _, ok := policy[key]; ok {
+			t.Errorf("%s must be removed from the scaling policy", key)
+		}
+	}
+}
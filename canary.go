@@ -0,0 +1,163 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/mattn/go-shellwords"
+)
+
+var (
+	canaryHealthCheckInterval       = 5 * time.Second
+	defaultCanaryHealthCheckTimeout = 3 * time.Minute
+)
+
+func (opt DeployOption) canaryHealthCheckTimeout() time.Duration {
+	if opt.CanaryTimeout > 0 {
+		return opt.CanaryTimeout
+	}
+	return defaultCanaryHealthCheckTimeout
+}
+
+// canaryHealthCheck checks the health of the canary task once.
+type canaryHealthCheck func(ctx context.Context, task *types.Task) error
+
+func (opt DeployOption) canaryHealthCheck() (canaryHealthCheck, error) {
+	switch {
+	case opt.CanaryHealthURL != "" && opt.CanaryCommand != "":
+		return nil, ErrConflictOptions("canary-health-url and canary-command are exclusive")
+	case opt.CanaryHealthURL != "":
+		return httpCanaryHealthCheck(opt.CanaryHealthURL), nil
+	case opt.CanaryCommand != "":
+		args, err := shellwords.Parse(opt.CanaryCommand)
+		if err != nil {
+			return nil, fmt.Errorf("invalid canary command: %w", err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("invalid canary command: %s", opt.CanaryCommand)
+		}
+		return commandCanaryHealthCheck(args), nil
+	default:
+		return nil, errors.New("--canary requires --canary-health-url or --canary-command")
+	}
+}
+
+// httpCanaryHealthCheck requests the URL and expects a 2xx status.
+// {ip} in the URL is replaced by the private IP address of the canary task.
+func httpCanaryHealthCheck(u string) canaryHealthCheck {
+	return func(ctx context.Context, task *types.Task) error {
+		u := strings.ReplaceAll(u, "{ip}", taskPrivateIP(task))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return fmt.Errorf("invalid canary health url: %w", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to request %s: %w", u, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || 300 <= resp.StatusCode {
+			return fmt.Errorf("%s returned status %d", u, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// commandCanaryHealthCheck runs the command and expects exit status 0.
+// CANARY_TASK_ARN and CANARY_TASK_IP environment variables are set for the command.
+func commandCanaryHealthCheck(args []string) canaryHealthCheck {
+	return func(ctx context.Context, task *types.Task) error {
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Env = append(os.Environ(),
+			"CANARY_TASK_ARN="+aws.ToString(task.TaskArn),
+			"CANARY_TASK_IP="+taskPrivateIP(task),
+		)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("canary command failed: %w", err)
+		}
+		return nil
+	}
+}
+
+func taskPrivateIP(task *types.Task) string {
+	for _, c := range task.Containers {
+		for _, ni := range c.NetworkInterfaces {
+			if ip := aws.ToString(ni.PrivateIpv4Address); ip != "" {
+				return ip
+			}
+		}
+	}
+	return ""
+}
+
+// runCanary runs a task of the task definition and waits for it to pass the health check.
+// The canary task is stopped afterward.
+func (d *App) runCanary(ctx context.Context, tdArn string, opt DeployOption) error {
+	check, err := opt.canaryHealthCheck()
+	if err != nil {
+		return err
+	}
+	d.Log("Running a canary task of %s", arnToName(tdArn))
+	task, err := d.RunTask(ctx, tdArn, &types.TaskOverride{}, &RunOption{
+		Count:                  1,
+		EBSDeleteOnTermination: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to run a canary task: %w", err)
+	}
+	defer d.stopCanaryTask(task)
+
+	if err := d.waitTask(ctx, task, true); err != nil {
+		return fmt.Errorf("canary task is not running: %w", err)
+	}
+	out, err := d.ecs.DescribeTasks(ctx, d.DescribeTasksInput(task))
+	if err != nil {
+		return fmt.Errorf("failed to describe the canary task: %w", err)
+	}
+	if len(out.Tasks) == 0 {
+		return ErrNotFound("canary task is not found")
+	}
+	task = &out.Tasks[0]
+
+	d.Log("Checking the health of the canary task %s", arnToName(aws.ToString(task.TaskArn)))
+	checkCtx, cancel := context.WithTimeout(ctx, opt.canaryHealthCheckTimeout())
+	defer cancel()
+	for {
+		err := check(checkCtx, task)
+		if err == nil {
+			d.Log("Canary task is healthy")
+			return nil
+		}
+		d.Log("[INFO] canary health check: %s", err)
+		select {
+		case <-checkCtx.Done():
+			return fmt.Errorf("canary task is not healthy: %w", err)
+		case <-time.After(canaryHealthCheckInterval):
+		}
+	}
+}
+
+func (d *App) stopCanaryTask(task *types.Task) {
+	// ctx for the deployment may be already done
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	d.Log("Stopping the canary task %s", arnToName(aws.ToString(task.TaskArn)))
+	if _, err := d.ecs.StopTask(ctx, &ecs.StopTaskInput{
+		Cluster: aws.String(d.Cluster),
+		Task:    task.TaskArn,
+		Reason:  aws.String("canary task of ecspresso deploy"),
+	}); err != nil {
+		d.Log("[WARNING] failed to stop the canary task: %s", err)
+	}
+}
@@ -0,0 +1,65 @@
+package ecspresso_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestCheckCanaryHealth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+	port := ts.URL[strings.LastIndex(ts.URL, ":")+1:]
+
+	ctx := context.Background()
+	task := &types.Task{
+		TaskArn: aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task/default/0123456789abcdef"),
+		Containers: []types.Container{
+			{
+				NetworkInterfaces: []types.NetworkInterface{
+					{PrivateIpv4Address: aws.String("127.0.0.1")},
+				},
+			},
+		},
+	}
+	tests := []struct {
+		opt     ecspresso.DeployOption
+		isError bool
+	}{
+		{opt: ecspresso.DeployOption{CanaryHealthURL: fmt.Sprintf("http://{ip}:%s/health", port)}},
+		{opt: ecspresso.DeployOption{CanaryHealthURL: fmt.Sprintf("http://{ip}:%s/unhealthy", port)}, isError: true},
+		{opt: ecspresso.DeployOption{CanaryCommand: `sh -c 'test "$CANARY_TASK_IP" = 127.0.0.1'`}},
+		{opt: ecspresso.DeployOption{CanaryCommand: "false"}, isError: true},
+		{opt: ecspresso.DeployOption{CanaryHealthURL: ts.URL, CanaryCommand: "true"}, isError: true},
+		{opt: ecspresso.DeployOption{}, isError: true},
+	}
+	for i, tt := range tests {
+		err := ecspresso.CheckCanaryHealth(ctx, tt.opt, task)
+		if tt.isError && err == nil {
+			t.Errorf("[%d] expected error, but got nil", i)
+		} else if !tt.isError && err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+		}
+	}
+}
+
+func TestCanaryHealthCheckTimeout(t *testing.T) {
+	if d := (ecspresso.DeployOption{}).CanaryHealthCheckTimeout(); d != 3*time.Minute {
+		t.Errorf("unexpected default timeout: %s", d)
+	}
+	if d := (ecspresso.DeployOption{CanaryTimeout: 10 * time.Minute}).CanaryHealthCheckTimeout(); d != 10*time.Minute {
+		t.Errorf("unexpected timeout: %s", d)
+	}
+}
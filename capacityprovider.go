@@ -0,0 +1,73 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/samber/lo"
+)
+
+// missingCapacityProviders returns capacity providers in the strategy which are not associated with the cluster.
+func missingCapacityProviders(associated []string, strategy []types.CapacityProviderStrategyItem) []string {
+	var missing []string
+	for _, s := range strategy {
+		name := aws.ToString(s.CapacityProvider)
+		if name == "" || lo.Contains(associated, name) || lo.Contains(missing, name) {
+			continue
+		}
+		missing = append(missing, name)
+	}
+	return missing
+}
+
+// ensureCapacityProviders associates capacity providers referenced by the service definition with the cluster.
+// Capacity providers and the default strategy already associated with the cluster are kept.
+func (d *App) ensureCapacityProviders(ctx context.Context, opt DeployOption) error {
+	if d.config.ServiceDefinitionPath == "" {
+		d.Log("[WARNING] --ensure-capacity-providers requires service_definition. skip")
+		return nil
+	}
+	sv, err := d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+	if err != nil {
+		return err
+	}
+	if len(sv.CapacityProviderStrategy) == 0 {
+		d.Log("[DEBUG] no capacity provider strategy in the service definition")
+		return nil
+	}
+	out, err := d.ecs.DescribeClusters(ctx, &ecs.DescribeClustersInput{
+		Clusters: []string{d.Cluster},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe cluster %s: %w", d.Cluster, err)
+	}
+	if len(out.Clusters) == 0 {
+		return ErrNotFound(fmt.Sprintf("cluster %s is not found", d.Cluster))
+	}
+	cluster := out.Clusters[0]
+	missing := missingCapacityProviders(cluster.CapacityProviders, sv.CapacityProviderStrategy)
+	if len(missing) == 0 {
+		d.Log("[INFO] all capacity providers are associated with cluster %s", d.Cluster)
+		return nil
+	}
+	for _, name := range missing {
+		d.Log("[INFO] associating capacity provider %s with cluster %s %s", name, d.Cluster, opt.DryRunString())
+	}
+	if opt.DryRun {
+		return nil
+	}
+	// PutClusterCapacityProviders replaces all of the capacity providers and the default strategy of the cluster.
+	if _, err := d.ecs.PutClusterCapacityProviders(ctx, &ecs.PutClusterCapacityProvidersInput{
+		Cluster:                         aws.String(d.Cluster),
+		CapacityProviders:               append(cluster.CapacityProviders, missing...),
+		DefaultCapacityProviderStrategy: cluster.DefaultCapacityProviderStrategy,
+	}); err != nil {
+		return fmt.Errorf("failed to associate capacity providers %s with cluster %s: %w", strings.Join(missing, ","), d.Cluster, err)
+	}
+	d.Log("[INFO] associated capacity providers %s with cluster %s", strings.Join(missing, ","), d.Cluster)
+	return nil
+}
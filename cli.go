@@ -4,42 +4,108 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/Songmu/prompter"
+	isatty "github.com/mattn/go-isatty"
+	"github.com/samber/lo"
 )
 
 type CLIOptions struct {
-	Envfile        []string          `help:"environment files" env:"ECSPRESSO_ENVFILE"`
-	Debug          bool              `help:"enable debug log" env:"ECSPRESSO_DEBUG"`
-	ExtStr         map[string]string `help:"external string values for Jsonnet" env:"ECSPRESSO_EXT_STR"`
-	ExtCode        map[string]string `help:"external code values for Jsonnet" env:"ECSPRESSO_EXT_CODE"`
-	ConfigFilePath string            `name:"config" help:"config file" default:"ecspresso.yml" env:"ECSPRESSO_CONFIG"`
-	AssumeRoleARN  string            `help:"the ARN of the role to assume" default:"" env:"ECSPRESSO_ASSUME_ROLE_ARN"`
-	Timeout        *time.Duration    `help:"timeout. Override in a configuration file." env:"ECSPRESSO_TIMEOUT"`
-	FilterCommand  string            `help:"filter command" env:"ECSPRESSO_FILTER_COMMAND"`
-	Color          bool              `help:"enable colorized output" env:"ECSPRESSO_COLOR" default:"true" negatable:""`
+	Envfile               []string          `help:"environment files" env:"ECSPRESSO_ENVFILE"`
+	Debug                 bool              `help:"enable debug log" env:"ECSPRESSO_DEBUG"`
+	LogFormat             string            `help:"log format (text, json)" default:"text" enum:"text,json" env:"ECSPRESSO_LOG_FORMAT"`
+	LogLevel              string            `help:"minimum level of logs (debug, info, warn, error). --debug overrides it" default:"info" enum:"debug,info,warn,error" env:"ECSPRESSO_LOG_LEVEL"`
+	ExtStr                map[string]string `help:"external string values for Jsonnet" env:"ECSPRESSO_EXT_STR"`
+	ExtCode               map[string]string `help:"external code values for Jsonnet" env:"ECSPRESSO_EXT_CODE"`
+	TLAStr                map[string]string `name:"tla-str" help:"top-level arguments as string values for Jsonnet" env:"ECSPRESSO_TLA_STR"`
+	TLACode               map[string]string `name:"tla-code" help:"top-level arguments as code values for Jsonnet" env:"ECSPRESSO_TLA_CODE"`
+	JsonnetLib            []string          `name:"jsonnet-lib" help:"additional library path for imports of Jsonnet. relative to the directory of the config file" env:"ECSPRESSO_JSONNET_LIB"`
+	Strict                bool              `help:"fail when required_version is set but ecspresso is not a release version (e.g. current or a development build)" env:"ECSPRESSO_STRICT"`
+	StrictConfig          bool              `help:"fail on unknown fields in the config file instead of warning. always enabled in verify" env:"ECSPRESSO_STRICT_CONFIG"`
+	ConfigFilePath        string            `name:"config" help:"config file or URL" default:"ecspresso.yml" env:"ECSPRESSO_CONFIG"`
+	ConfigBaseDir         string            `help:"base directory to resolve relative paths in the config file" env:"ECSPRESSO_CONFIG_BASE_DIR"`
+	Overlay               string            `help:"Jsonnet file to merge onto the Jsonnet config file by +" env:"ECSPRESSO_OVERLAY"`
+	NoProjectConfig       bool              `help:"do not look for the project-level defaults file (.ecspresso.*)" env:"ECSPRESSO_NO_PROJECT_CONFIG"`
+	AssumeRoleARN         string            `help:"the ARN of the role to assume" default:"" env:"ECSPRESSO_ASSUME_ROLE_ARN"`
+	AssumeRoleExternalID  string            `name:"assume-role-external-id" help:"the external ID to assume the role" env:"ECSPRESSO_ASSUME_ROLE_EXTERNAL_ID"`
+	AssumeRoleSessionName string            `name:"assume-role-session-name" help:"the session name to assume the role" env:"ECSPRESSO_ASSUME_ROLE_SESSION_NAME"`
+	MFASerial             string            `name:"mfa-serial" help:"the serial number or ARN of the MFA device to assume the role. the token code is read from STDIN" env:"ECSPRESSO_MFA_SERIAL"`
+	Profile               string            `help:"the name of the AWS shared config profile. Override in a configuration file." env:"ECSPRESSO_PROFILE"`
+	Region                *string           `help:"AWS region. Override in a configuration file."`
+	Cluster               *string           `help:"ECS cluster name. Override in a configuration file."`
+	Service               *string           `help:"ECS service name. Override in a configuration file."`
+	ServiceSelector       string            `help:"select the service by the tag (Key=Value) instead of the service name. for status, deploy and scale"`
+	Timeout               *time.Duration    `help:"timeout. Override in a configuration file." env:"ECSPRESSO_TIMEOUT"`
+	RetryMaxAttempts      *int              `help:"max attempts of retries of AWS API calls. Override in a configuration file." env:"ECSPRESSO_RETRY_MAX_ATTEMPTS"`
+	RetryMaxBackoff       *time.Duration    `help:"max backoff duration between retries of AWS API calls. Override in a configuration file." env:"ECSPRESSO_RETRY_MAX_BACKOFF"`
+	FilterCommand         string            `help:"filter command" env:"ECSPRESSO_FILTER_COMMAND"`
+	SortEnvironment       bool              `help:"sort environment variables of containers by name before registering task definitions" env:"ECSPRESSO_SORT_ENVIRONMENT"`
+	Color                 bool              `help:"enable colorized output" env:"ECSPRESSO_COLOR" default:"true" negatable:""`
+	Interactive           bool              `help:"select a config file interactively when multiple candidates exist" env:"ECSPRESSO_INTERACTIVE"`
+	Otel                  bool              `help:"enable OpenTelemetry tracing" env:"ECSPRESSO_OTEL"`
+
+	Appspec             *AppSpecOption             `cmd:"" help:"output AppSpec YAML for CodeDeploy to STDOUT"`
+	Config              *ConfigOption              `cmd:"" help:"manipulate the config file"`
+	Delete              *DeleteOption              `cmd:"" help:"delete service"`
+	Deploy              *DeployOption              `cmd:"" help:"deploy service"`
+	Deregister          *DeregisterOption          `cmd:"" help:"deregister task definition"`
+	Diff                *DiffOption                `cmd:"" help:"show diff between task definition, service definition with current running service and task definition"`
+	Exec                *ExecOption                `cmd:"" help:"execute command on task"`
+	Init                *InitOption                `cmd:"" help:"create configuration files from existing ECS service"`
+	MigrateToCodeDeploy *MigrateToCodeDeployOption `cmd:"" name:"migrate-to-codedeploy" help:"guide the migration of the service to CodeDeploy blue/green deployment"`
+	Plugins             *PluginsOption             `cmd:"" help:"show plugins and functions registered by them"`
+	Prune               *PruneOption               `cmd:"" help:"deregister old revisions of task definition except the recent and in-use ones"`
+	Refresh             *RefreshOption             `cmd:"" help:"refresh service. equivalent to deploy --skip-task-definition --force-new-deployment --no-update-service"`
+	Register            *RegisterOption            `cmd:"" help:"register task definition"`
+	Render              *RenderOption              `cmd:"" help:"render config, service definition or task definition file to STDOUT or a file"`
+	Revisions           *RevisionsOption           `cmd:"" help:"show revisions of task definitions"`
+	Rollback            *RollbackOption            `cmd:"" help:"rollback service"`
+	Run                 *RunOption                 `cmd:"" help:"run task"`
+	Scale               *ScaleOption               `cmd:"" help:"scale service. equivalent to deploy --skip-task-definition --no-update-service"`
+	Status              *StatusOption              `cmd:"" help:"show status of service"`
+	Tasks               *TasksOption               `cmd:"" help:"list tasks that are in a service or having the same family"`
+	Verify              *VerifyOption              `cmd:"" help:"verify resources in configurations"`
+	Wait                *WaitOption                `cmd:"" help:"wait until service stable"`
+	Whoami              *WhoamiOption              `cmd:"" help:"show the AWS identity and region to be used"`
+	Version             struct{}                   `cmd:"" help:"show version"`
+}
+
+// validateOverrides validates --region, --cluster and --service are not empty when they are specified.
+func (opt *CLIOptions) validateOverrides() error {
+	for _, o := range []struct {
+		name  string
+		value *string
+	}{
+		{"region", opt.Region},
+		{"cluster", opt.Cluster},
+		{"service", opt.Service},
+	} {
+		if o.value != nil && *o.value == "" {
+			return fmt.Errorf("--%s must not be empty", o.name)
+		}
+	}
+	return nil
+}
 
-	Appspec    *AppSpecOption    `cmd:"" help:"output AppSpec YAML for CodeDeploy to STDOUT"`
-	Delete     *DeleteOption     `cmd:"" help:"delete service"`
-	Deploy     *DeployOption     `cmd:"" help:"deploy service"`
-	Deregister *DeregisterOption `cmd:"" help:"deregister task definition"`
-	Diff       *DiffOption       `cmd:"" help:"show diff between task definition, service definition with current running service and task definition"`
-	Exec       *ExecOption       `cmd:"" help:"execute command on task"`
-	Init       *InitOption       `cmd:"" help:"create configuration files from existing ECS service"`
-	Refresh    *RefreshOption    `cmd:"" help:"refresh service. equivalent to deploy --skip-task-definition --force-new-deployment --no-update-service"`
-	Register   *RegisterOption   `cmd:"" help:"register task definition"`
-	Render     *RenderOption     `cmd:"" help:"render config, service definition or task definition file to STDOUT"`
-	Revisions  *RevisionsOption  `cmd:"" help:"show revisions of task definitions"`
-	Rollback   *RollbackOption   `cmd:"" help:"rollback service"`
-	Run        *RunOption        `cmd:"" help:"run task"`
-	Scale      *ScaleOption      `cmd:"" help:"scale service. equivalent to deploy --skip-task-definition --no-update-service"`
-	Status     *StatusOption     `cmd:"" help:"show status of service"`
-	Tasks      *TasksOption      `cmd:"" help:"list tasks that are in a service or having the same family"`
-	Verify     *VerifyOption     `cmd:"" help:"verify resources in configurations"`
-	Wait       *WaitOption       `cmd:"" help:"wait until service stable"`
-	Version    struct{}          `cmd:"" help:"show version"`
+// validateServiceSelector validates --service-selector is used with the supported subcommands.
+func (opt *CLIOptions) validateServiceSelector(sub string) error {
+	if opt.ServiceSelector == "" {
+		return nil
+	}
+	if opt.Service != nil {
+		return ErrConflictOptions("service and service-selector are exclusive")
+	}
+	if !lo.Contains(serviceSelectorCommands, sub) {
+		return fmt.Errorf("--service-selector is supported only for %s", strings.Join(serviceSelectorCommands, ", "))
+	}
+	_, err := parseServiceSelector(opt.ServiceSelector)
+	return err
 }
 
-func (opt *CLIOptions) resolveConfigFilePath() (path string) {
+func (opt *CLIOptions) resolveConfigFilePath() (path string, err error) {
 	path = DefaultConfigFilePath
 	defer func() {
 		opt.ConfigFilePath = path
@@ -48,19 +114,39 @@ func (opt *CLIOptions) resolveConfigFilePath() (path string) {
 		path = opt.ConfigFilePath
 		return
 	}
-	for _, ext := range []string{ymlExt, yamlExt, jsonExt, jsonnetExt} {
+	for _, ext := range []string{ymlExt, yamlExt, jsonExt, jsonnetExt, tomlExt} {
 		if _, err := os.Stat("ecspresso" + ext); err == nil {
 			path = "ecspresso" + ext
 			return
 		}
 	}
-	return
+	if !opt.Interactive {
+		return
+	}
+	// find ecspresso.*.{yml,yaml,json,jsonnet,toml}
+	var candidates []string
+	for _, ext := range []string{ymlExt, yamlExt, jsonExt, jsonnetExt, tomlExt} {
+		matches, _ := filepath.Glob("ecspresso.*" + ext)
+		candidates = append(candidates, matches...)
+	}
+	switch {
+	case len(candidates) == 0:
+		return
+	case !isatty.IsTerminal(os.Stdin.Fd()):
+		err = fmt.Errorf("config file is not specified. use --config with one of %s", strings.Join(candidates, ", "))
+		return
+	default:
+		path = prompter.Choose("Select a config file", candidates, candidates[0])
+		return
+	}
 }
 
 func (opts *CLIOptions) ForSubCommand(sub string) interface{} {
 	switch sub {
 	case "appspec":
 		return opts.Appspec
+	case "config":
+		return opts.Config
 	case "delete":
 		return opts.Delete
 	case "deploy":
@@ -73,6 +159,12 @@ func (opts *CLIOptions) ForSubCommand(sub string) interface{} {
 		return opts.Exec
 	case "init":
 		return opts.Init
+	case "migrate-to-codedeploy":
+		return opts.MigrateToCodeDeploy
+	case "plugins":
+		return opts.Plugins
+	case "prune":
+		return opts.Prune
 	case "refresh":
 		return opts.Refresh
 	case "register":
@@ -95,17 +187,52 @@ func (opts *CLIOptions) ForSubCommand(sub string) interface{} {
 		return opts.Verify
 	case "wait":
 		return opts.Wait
+	case "whoami":
+		return opts.Whoami
 	default:
 		return nil
 	}
 }
 
-func dispatchCLI(ctx context.Context, sub string, usage func(), opts *CLIOptions) error {
+func dispatchCLI(ctx context.Context, sub string, usage func(), opts *CLIOptions) (err error) {
 	switch sub {
 	case "version", "":
 		fmt.Println("ecspresso", Version)
 		return nil
 	}
+	ctx, shutdownTracing, err := setupTracing(ctx, opts.Otel)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// ctx may be canceled already, flush the spans in a new context
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(sctx); err != nil {
+			Log("[WARNING] failed to export the spans: %s", err)
+		}
+	}()
+	ctx, span := startSpan(ctx, "ecspresso "+sub)
+	defer func() { endSpan(span, err) }()
+
+	if sub == "config" {
+		if opts.Config.command == "schema" {
+			// the schema does not depend on the config file
+			return outputConfigSchema(os.Stdout)
+		}
+		// the config file is processed as is, without evaluating it
+		if _, err := opts.resolveConfigFilePath(); err != nil {
+			return err
+		}
+		switch opts.Config.command {
+		case "dump-env":
+			return dumpEnv(ctx, opts, os.Stdout)
+		case "fmt":
+			return formatConfigFiles(opts, os.Stdout)
+		}
+		return migrateConfigFile(opts.ConfigFilePath, *opts.Config.Migrate, os.Stdout)
+	}
+
 	var appOpts []AppOption
 	if sub == "init" {
 		config, err := opts.Init.NewConfig(ctx, opts.ConfigFilePath)
@@ -114,10 +241,25 @@ func dispatchCLI(ctx context.Context, sub string, usage func(), opts *CLIOptions
 		}
 		appOpts = append(appOpts, WithConfig(config))
 	}
+	if sub == "render" && opts.Render.NoAWS {
+		appOpts = append(appOpts, WithoutAWS())
+	}
+	if sub == "verify" {
+		appOpts = append(appOpts, WithStrictConfig())
+	}
+	if sub == "deploy" && opts.Deploy.PrintTaskDefinitionArn {
+		// keep STDOUT only for the ARN to be captured by the shell
+		appOpts = append(appOpts, WithStdout(os.Stderr))
+	}
 	app, err := New(ctx, opts, appOpts...)
 	if err != nil {
 		return err
 	}
+	if opts.ServiceSelector != "" {
+		if err := app.selectService(ctx, opts.ServiceSelector); err != nil {
+			return err
+		}
+	}
 	app.Log("[DEBUG] dispatching subcommand: %s", sub)
 	switch sub {
 	case "deploy":
@@ -142,10 +284,16 @@ func dispatchCLI(ctx context.Context, sub string, usage func(), opts *CLIOptions
 		return app.Register(ctx, *opts.Register)
 	case "deregister":
 		return app.Deregister(ctx, *opts.Deregister)
+	case "prune":
+		return app.Prune(ctx, *opts.Prune)
 	case "revisions":
 		return app.Revisions(ctx, *opts.Revisions)
 	case "init":
 		return app.Init(ctx, *opts.Init)
+	case "migrate-to-codedeploy":
+		return app.MigrateToCodeDeploy(ctx, *opts.MigrateToCodeDeploy)
+	case "plugins":
+		return app.Plugins(ctx, *opts.Plugins)
 	case "diff":
 		return app.Diff(ctx, *opts.Diff)
 	case "appspec":
@@ -158,6 +306,8 @@ func dispatchCLI(ctx context.Context, sub string, usage func(), opts *CLIOptions
 		return app.Tasks(ctx, *opts.Tasks)
 	case "exec":
 		return app.Exec(ctx, *opts.Exec)
+	case "whoami":
+		return app.Whoami(ctx, *opts.Whoami)
 	default:
 		usage()
 	}
@@ -172,7 +322,7 @@ func CLI(ctx context.Context, parse CLIParseFunc) (int, error) {
 		return 1, err
 	}
 	if err := dispatchCLI(ctx, sub, usage, opts); err != nil {
-		return 1, err
+		return exitCodeOf(err), err
 	}
 	return 0, nil
 }
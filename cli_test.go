@@ -4,11 +4,13 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/kayac/ecspresso/v2"
+	isatty "github.com/mattn/go-isatty"
 )
 
 var cliTests = []struct {
@@ -28,18 +30,25 @@ var cliTests = []struct {
 			"--ext-code", "c1=123",
 			"--ext-code", "c2=1+2",
 			"--assume-role-arn", "arn:aws:iam::123456789012:role/exampleRole",
+			"--assume-role-external-id", "example-external-id",
+			"--assume-role-session-name", "ecspresso-deploy",
+			"--mfa-serial", "arn:aws:iam::123456789012:mfa/user",
 		},
 		sub: "status",
 		option: &ecspresso.CLIOptions{
-			ConfigFilePath: "config.yml",
-			Debug:          true,
-			Envfile:        []string{"tests/envfile"},
-			ExtStr:         map[string]string{"s1": "v1", "s2": "v2"},
-			ExtCode:        map[string]string{"c1": "123", "c2": "1+2"},
-			AssumeRoleARN:  "arn:aws:iam::123456789012:role/exampleRole",
+			ConfigFilePath:        "config.yml",
+			Debug:                 true,
+			Envfile:               []string{"tests/envfile"},
+			ExtStr:                map[string]string{"s1": "v1", "s2": "v2"},
+			ExtCode:               map[string]string{"c1": "123", "c2": "1+2"},
+			AssumeRoleARN:         "arn:aws:iam::123456789012:role/exampleRole",
+			AssumeRoleExternalID:  "example-external-id",
+			AssumeRoleSessionName: "ecspresso-deploy",
+			MFASerial:             "arn:aws:iam::123456789012:mfa/user",
 		},
 		subOption: &ecspresso.StatusOption{
 			Events: 10,
+			Output: "text",
 		},
 		fn: func(t *testing.T, _ any) {
 			if v := os.Getenv("ECSPRESSO_TEST"); v != "ok" {
@@ -47,6 +56,73 @@ var cliTests = []struct {
 			}
 		},
 	},
+	{
+		args: []string{
+			"--config", "config.yml",
+			"--tla-str", "env=staging",
+			"--tla-code", "replicas=2",
+			"status",
+		},
+		sub: "status",
+		option: &ecspresso.CLIOptions{
+			ConfigFilePath: "config.yml",
+			ExtStr:         map[string]string{},
+			ExtCode:        map[string]string{},
+			TLAStr:         map[string]string{"env": "staging"},
+			TLACode:        map[string]string{"replicas": "2"},
+		},
+		subOption: &ecspresso.StatusOption{
+			Events: 10,
+			Output: "text",
+		},
+	},
+	{
+		args: []string{"--config", "config.yml", "--profile", "staging", "status"},
+		sub:  "status",
+		option: &ecspresso.CLIOptions{
+			ConfigFilePath: "config.yml",
+			ExtStr:         map[string]string{},
+			ExtCode:        map[string]string{},
+			Profile:        "staging",
+		},
+		subOption: &ecspresso.StatusOption{
+			Events: 10,
+			Output: "text",
+		},
+	},
+	{
+		args: []string{"--config", "config.yml", "--strict-config", "status"},
+		sub:  "status",
+		option: &ecspresso.CLIOptions{
+			ConfigFilePath: "config.yml",
+			ExtStr:         map[string]string{},
+			ExtCode:        map[string]string{},
+			StrictConfig:   true,
+		},
+		subOption: &ecspresso.StatusOption{
+			Events: 10,
+			Output: "text",
+		},
+	},
+	{
+		args: []string{
+			"--config", "config.yml",
+			"--jsonnet-lib", "vendor",
+			"--jsonnet-lib", "/opt/jsonnet",
+			"status",
+		},
+		sub: "status",
+		option: &ecspresso.CLIOptions{
+			ConfigFilePath: "config.yml",
+			ExtStr:         map[string]string{},
+			ExtCode:        map[string]string{},
+			JsonnetLib:     []string{"vendor", "/opt/jsonnet"},
+		},
+		subOption: &ecspresso.StatusOption{
+			Events: 10,
+			Output: "text",
+		},
+	},
 	{
 		args: []string{
 			"--config", "config.yml",
@@ -64,6 +140,49 @@ var cliTests = []struct {
 		},
 		subOption: &ecspresso.StatusOption{
 			Events: 100,
+			Output: "text",
+		},
+	},
+	{
+		args: []string{
+			"--config", "config.yml",
+			"--region", "us-east-1",
+			"status",
+			"--cluster", "mycluster",
+			"--service", "myservice",
+		},
+		sub: "status",
+		option: &ecspresso.CLIOptions{
+			ConfigFilePath: "config.yml",
+			ExtStr:         map[string]string{},
+			ExtCode:        map[string]string{},
+			Region:         ptr("us-east-1"),
+			Cluster:        ptr("mycluster"),
+			Service:        ptr("myservice"),
+		},
+		subOption: &ecspresso.StatusOption{
+			Events: 10,
+			Output: "text",
+		},
+	},
+	{
+		args: []string{
+			"--config", "config.yml",
+			"--retry-max-attempts", "10",
+			"--retry-max-backoff", "30s",
+			"status",
+		},
+		sub: "status",
+		option: &ecspresso.CLIOptions{
+			ConfigFilePath:   "config.yml",
+			ExtStr:           map[string]string{},
+			ExtCode:          map[string]string{},
+			RetryMaxAttempts: ptr(10),
+			RetryMaxBackoff:  ptr(30 * time.Second),
+		},
+		subOption: &ecspresso.StatusOption{
+			Events: 10,
+			Output: "text",
 		},
 	},
 	{
@@ -87,6 +206,7 @@ var cliTests = []struct {
 		sub:  "status",
 		subOption: &ecspresso.StatusOption{
 			Events: 10,
+			Output: "text",
 		},
 	},
 	{
@@ -94,6 +214,7 @@ var cliTests = []struct {
 		sub:  "status",
 		subOption: &ecspresso.StatusOption{
 			Events: 100,
+			Output: "text",
 		},
 	},
 	{
@@ -101,6 +222,23 @@ var cliTests = []struct {
 		sub:  "status",
 		subOption: &ecspresso.StatusOption{
 			Events: 20,
+			Output: "text",
+		},
+	},
+	{
+		args: []string{"status", "--output", "prometheus"},
+		sub:  "status",
+		subOption: &ecspresso.StatusOption{
+			Events: 10,
+			Output: "prometheus",
+		},
+	},
+	{
+		args: []string{"status", "--output=dot"},
+		sub:  "status",
+		subOption: &ecspresso.StatusOption{
+			Events: 10,
+			Output: "dot",
 		},
 	},
 	{
@@ -188,6 +326,122 @@ var cliTests = []struct {
 			Revision:             0,
 		},
 	},
+	{
+		args: []string{"deploy", "--lock", "--lock-ttl=30m"},
+		sub:  "deploy",
+		subOption: &ecspresso.DeployOption{
+			DryRun:               false,
+			DesiredCount:         ptr(int32(-1)),
+			SkipTaskDefinition:   false,
+			ForceNewDeployment:   false,
+			Wait:                 true,
+			RollbackEvents:       "",
+			UpdateService:        true,
+			LatestTaskDefinition: false,
+			Revision:             0,
+			Lock:                 true,
+			LockTTL:              30 * time.Minute,
+		},
+	},
+	{
+		args: []string{"deploy", "--lock", "--lock-table", "deploy-locks"},
+		sub:  "deploy",
+		subOption: &ecspresso.DeployOption{
+			DryRun:               false,
+			DesiredCount:         ptr(int32(-1)),
+			SkipTaskDefinition:   false,
+			ForceNewDeployment:   false,
+			Wait:                 true,
+			RollbackEvents:       "",
+			UpdateService:        true,
+			LatestTaskDefinition: false,
+			Revision:             0,
+			Lock:                 true,
+			LockTable:            "deploy-locks",
+		},
+	},
+	{
+		args: []string{"deploy", "--wait-for-min-running-percent=80", "--wait-for-min-running-healthy"},
+		sub:  "deploy",
+		subOption: &ecspresso.DeployOption{
+			DryRun:                   false,
+			DesiredCount:             ptr(int32(-1)),
+			SkipTaskDefinition:       false,
+			ForceNewDeployment:       false,
+			Wait:                     true,
+			RollbackEvents:           "",
+			UpdateService:            true,
+			LatestTaskDefinition:     false,
+			Revision:                 0,
+			WaitForMinRunningPercent: 80,
+			WaitForMinRunningHealthy: true,
+		},
+	},
+	{
+		args: []string{"deploy", "--print-task-definition-arn"},
+		sub:  "deploy",
+		subOption: &ecspresso.DeployOption{
+			DryRun:                 false,
+			DesiredCount:           ptr(int32(-1)),
+			SkipTaskDefinition:     false,
+			ForceNewDeployment:     false,
+			Wait:                   true,
+			RollbackEvents:         "",
+			UpdateService:          true,
+			LatestTaskDefinition:   false,
+			Revision:               0,
+			PrintTaskDefinitionArn: true,
+		},
+	},
+	{
+		args: []string{"deploy", "--quiet-no-changes"},
+		sub:  "deploy",
+		subOption: &ecspresso.DeployOption{
+			DryRun:               false,
+			DesiredCount:         ptr(int32(-1)),
+			SkipTaskDefinition:   false,
+			ForceNewDeployment:   false,
+			Wait:                 true,
+			RollbackEvents:       "",
+			UpdateService:        true,
+			LatestTaskDefinition: false,
+			Revision:             0,
+			QuietNoChanges:       true,
+		},
+	},
+	{
+		args: []string{"deploy", "--pause-before-traffic", "--validate-url", "http://example.com:8080/"},
+		sub:  "deploy",
+		subOption: &ecspresso.DeployOption{
+			DryRun:               false,
+			DesiredCount:         ptr(int32(-1)),
+			SkipTaskDefinition:   false,
+			ForceNewDeployment:   false,
+			Wait:                 true,
+			RollbackEvents:       "",
+			UpdateService:        true,
+			LatestTaskDefinition: false,
+			Revision:             0,
+			PauseBeforeTraffic:   true,
+			ValidateURL:          "http://example.com:8080/",
+		},
+	},
+	{
+		args: []string{"deploy", "--revisions-warning=0"},
+		sub:  "deploy",
+		subOption: &ecspresso.DeployOption{
+			DryRun:               false,
+			DesiredCount:         ptr(int32(-1)),
+			SkipTaskDefinition:   false,
+			ForceNewDeployment:   false,
+			Wait:                 true,
+			RollbackEvents:       "",
+			UpdateService:        true,
+			LatestTaskDefinition: false,
+			Revision:             0,
+			RevisionsWarning:     ptr(0),
+		},
+	},
 	{
 		args: []string{"scale", "--tasks=5"},
 		sub:  "scale",
@@ -288,6 +542,37 @@ var cliTests = []struct {
 			}
 		},
 	},
+	{
+		args: []string{"scale", "--suspend"},
+		sub:  "scale",
+		subOption: &ecspresso.ScaleOption{
+			DryRun:             false,
+			DesiredCount:       ptr(int32(-1)),
+			Wait:               true,
+			SuspendAutoScaling: ptr(true),
+		},
+	},
+	{
+		args: []string{"scale", "--resume"},
+		sub:  "scale",
+		subOption: &ecspresso.ScaleOption{
+			DryRun:            false,
+			DesiredCount:      ptr(int32(-1)),
+			Wait:              true,
+			ResumeAutoScaling: ptr(true),
+		},
+	},
+	{
+		args: []string{"scale", "--tasks", "5", "--min", "3", "--max", "10"},
+		sub:  "scale",
+		subOption: &ecspresso.ScaleOption{
+			DryRun:         false,
+			DesiredCount:   ptr(int32(5)),
+			Wait:           true,
+			AutoScalingMin: ptr(int32(3)),
+			AutoScalingMax: ptr(int32(10)),
+		},
+	},
 	{
 		args: []string{"scale", "--resume-auto-scaling", "--auto-scaling-min=3", "--auto-scaling-max=10"},
 		sub:  "scale",
@@ -392,6 +677,29 @@ var cliTests = []struct {
 			RollbackEvents:           "",
 		},
 	},
+	{
+		args: []string{"rollback", "--to-arn", "test:40"},
+		sub:  "rollback",
+		subOption: &ecspresso.RollbackOption{
+			DryRun:                   false,
+			DeregisterTaskDefinition: true,
+			Wait:                     true,
+			RollbackEvents:           "",
+			ToArn:                    "test:40",
+		},
+	},
+	{
+		args: []string{"rollback", "--deregister-after-rollback", "--deregister-keeps", "3"},
+		sub:  "rollback",
+		subOption: &ecspresso.RollbackOption{
+			DryRun:                   false,
+			DeregisterTaskDefinition: true,
+			Wait:                     true,
+			RollbackEvents:           "",
+			DeregisterAfterRollback:  true,
+			DeregisterKeeps:          ptr(3),
+		},
+	},
 	{
 		args: []string{"delete"},
 		sub:  "delete",
@@ -510,6 +818,184 @@ var cliTests = []struct {
 			EBSDeleteOnTermination: ptr(false),
 		},
 	},
+	{
+		args: []string{"run", "--wait-until=running", "--wait-for-healthy"},
+		sub:  "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:                 false,
+			TaskDefinition:         "",
+			Wait:                   true,
+			Count:                  int32(1),
+			WatchContainer:         "",
+			PropagateTags:          "",
+			TaskOverrideStr:        "",
+			TaskOverrideFile:       "",
+			SkipTaskDefinition:     false,
+			LatestTaskDefinition:   false,
+			Tags:                   "",
+			WaitUntil:              "running",
+			Revision:               ptr(int64(0)),
+			ClientToken:            nil,
+			EBSDeleteOnTermination: ptr(true),
+			WaitForHealthy:         true,
+		},
+	},
+	{
+		args: []string{"run", "--wait-until=running", "--stop-on-timeout"},
+		sub:  "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:                 false,
+			TaskDefinition:         "",
+			Wait:                   true,
+			Count:                  int32(1),
+			WatchContainer:         "",
+			PropagateTags:          "",
+			TaskOverrideStr:        "",
+			TaskOverrideFile:       "",
+			SkipTaskDefinition:     false,
+			LatestTaskDefinition:   false,
+			Tags:                   "",
+			WaitUntil:              "running",
+			Revision:               ptr(int64(0)),
+			ClientToken:            nil,
+			EBSDeleteOnTermination: ptr(true),
+			StopOnTimeout:          true,
+		},
+	},
+	{
+		args: []string{"run", "--availability-zone", "us-east-1a"},
+		sub:  "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:                 false,
+			TaskDefinition:         "",
+			Wait:                   true,
+			Count:                  int32(1),
+			WatchContainer:         "",
+			PropagateTags:          "",
+			TaskOverrideStr:        "",
+			TaskOverrideFile:       "",
+			SkipTaskDefinition:     false,
+			LatestTaskDefinition:   false,
+			Tags:                   "",
+			WaitUntil:              "stopped",
+			Revision:               ptr(int64(0)),
+			ClientToken:            nil,
+			EBSDeleteOnTermination: ptr(true),
+			AvailabilityZone:       "us-east-1a",
+		},
+	},
+	{
+		args: []string{"run", "--group", "migration", "--reference-id", "release-42"},
+		sub:  "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:                 false,
+			TaskDefinition:         "",
+			Wait:                   true,
+			Count:                  int32(1),
+			WatchContainer:         "",
+			PropagateTags:          "",
+			TaskOverrideStr:        "",
+			TaskOverrideFile:       "",
+			SkipTaskDefinition:     false,
+			LatestTaskDefinition:   false,
+			Tags:                   "",
+			WaitUntil:              "stopped",
+			Revision:               ptr(int64(0)),
+			ClientToken:            nil,
+			EBSDeleteOnTermination: ptr(true),
+			Group:                  "migration",
+			ReferenceID:            "release-42",
+		},
+	},
+	{
+		args: []string{"run", "--enable-ecs-managed-tags", "--propagate-tags", "SERVICE"},
+		sub:  "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:                 false,
+			TaskDefinition:         "",
+			Wait:                   true,
+			Count:                  int32(1),
+			WatchContainer:         "",
+			PropagateTags:          "SERVICE",
+			TaskOverrideStr:        "",
+			TaskOverrideFile:       "",
+			SkipTaskDefinition:     false,
+			LatestTaskDefinition:   false,
+			Tags:                   "",
+			WaitUntil:              "stopped",
+			Revision:               ptr(int64(0)),
+			ClientToken:            nil,
+			EBSDeleteOnTermination: ptr(true),
+			EnableECSManagedTags:   ptr(true),
+		},
+	},
+	{
+		args: []string{"run", "--task-definition-arn", "migrate:12", "--overrides", `{"containerOverrides":[]}`},
+		sub:  "run",
+		subOption: &ecspresso.RunOption{
+			DryRun:                 false,
+			TaskDefinition:         "",
+			TaskDefinitionArn:      "migrate:12",
+			Wait:                   true,
+			Count:                  int32(1),
+			WatchContainer:         "",
+			PropagateTags:          "",
+			TaskOverrideStr:        `{"containerOverrides":[]}`,
+			TaskOverrideFile:       "",
+			SkipTaskDefinition:     false,
+			LatestTaskDefinition:   false,
+			Tags:                   "",
+			WaitUntil:              "stopped",
+			Revision:               ptr(int64(0)),
+			ClientToken:            nil,
+			EBSDeleteOnTermination: ptr(true),
+		},
+	},
+	{
+		args: []string{"migrate-to-codedeploy",
+			"--target-group-arn", "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/web-green/0123456789abcdef",
+			"--listener-arn", "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:listener/app/web/0123456789abcdef/0123456789abcdef",
+		},
+		sub: "migrate-to-codedeploy",
+		subOption: &ecspresso.MigrateToCodeDeployOption{
+			DryRun:               true,
+			TargetGroupArn:       "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/web-green/0123456789abcdef",
+			ListenerArn:          "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:listener/app/web/0123456789abcdef/0123456789abcdef",
+			DeploymentConfigName: "CodeDeployDefault.ECSAllAtOnce",
+		},
+	},
+	{
+		args: []string{"migrate-to-codedeploy", "--no-dry-run", "--create-codedeploy",
+			"--target-group-arn", "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/web-green/0123456789abcdef",
+			"--listener-arn", "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:listener/app/web/0123456789abcdef/0123456789abcdef",
+			"--service-role-arn", "arn:aws:iam::123456789012:role/codedeploy",
+		},
+		sub: "migrate-to-codedeploy",
+		subOption: &ecspresso.MigrateToCodeDeployOption{
+			DryRun:               false,
+			CreateCodeDeploy:     true,
+			TargetGroupArn:       "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/web-green/0123456789abcdef",
+			ListenerArn:          "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:listener/app/web/0123456789abcdef/0123456789abcdef",
+			ServiceRoleArn:       "arn:aws:iam::123456789012:role/codedeploy",
+			DeploymentConfigName: "CodeDeployDefault.ECSAllAtOnce",
+		},
+	},
+	{
+		args: []string{"whoami", "--output", "json"},
+		sub:  "whoami",
+		subOption: &ecspresso.WhoamiOption{
+			Output: "json",
+		},
+	},
+	{
+		args: []string{"plugins", "list", "--output", "json"},
+		sub:  "plugins",
+		subOption: &ecspresso.PluginsOption{
+			List: &ecspresso.PluginsListOption{
+				Output: "json",
+			},
+		},
+	},
 	{
 		args: []string{"register"},
 		sub:  "register",
@@ -526,6 +1012,32 @@ var cliTests = []struct {
 			Output: true,
 		},
 	},
+	{
+		args: []string{"register", "--output-arn", "--output-arn-file", "taskdef-arn"},
+		sub:  "register",
+		subOption: &ecspresso.RegisterOption{
+			OutputArn:     true,
+			OutputArnFile: "taskdef-arn",
+		},
+	},
+	{
+		args: []string{"prune"},
+		sub:  "prune",
+		subOption: &ecspresso.PruneOption{
+			DryRun: false,
+			Keeps:  nil,
+			Force:  false,
+		},
+	},
+	{
+		args: []string{"prune", "--dry-run", "--keeps", "5", "--force"},
+		sub:  "prune",
+		subOption: &ecspresso.PruneOption{
+			DryRun: true,
+			Keeps:  ptr(5),
+			Force:  true,
+		},
+	},
 	{
 		args: []string{"deregister"},
 		sub:  "deregister",
@@ -573,6 +1085,14 @@ var cliTests = []struct {
 			Delete:   true,
 		},
 	},
+	{
+		args: []string{"deregister", "--keeps", "5", "--older-than", "40"},
+		sub:  "deregister",
+		subOption: &ecspresso.DeregisterOption{
+			Keeps:     ptr(5),
+			OlderThan: 40,
+		},
+	},
 	{
 		args: []string{"revisions"},
 		sub:  "revisions",
@@ -605,6 +1125,22 @@ var cliTests = []struct {
 			Output:   "json",
 		},
 	},
+	{
+		args: []string{"revisions", "--since", "24h"},
+		sub:  "revisions",
+		subOption: &ecspresso.RevisionsOption{
+			Output: "table",
+			Since:  "24h",
+		},
+	},
+	{
+		args: []string{"revisions", "--since-last-deploy"},
+		sub:  "revisions",
+		subOption: &ecspresso.RevisionsOption{
+			Output:          "table",
+			SinceLastDeploy: true,
+		},
+	},
 	{
 		args:      []string{"wait"},
 		sub:       "wait",
@@ -621,6 +1157,22 @@ var cliTests = []struct {
 			ServiceDefinitionPath: "ecs-service-def.json",
 			ForceOverwrite:        false,
 			Jsonnet:               false,
+			AutoScalingPath:       "ecs-autoscaling.json",
+		},
+	},
+	{
+		args: []string{"init", "--service", "myservice", "--with-autoscaling"},
+		sub:  "init",
+		subOption: &ecspresso.InitOption{
+			Region:                os.Getenv("AWS_REGION"),
+			Cluster:               "default",
+			Service:               "myservice",
+			TaskDefinitionPath:    "ecs-task-def.json",
+			ServiceDefinitionPath: "ecs-service-def.json",
+			ForceOverwrite:        false,
+			Jsonnet:               false,
+			WithAutoScaling:       true,
+			AutoScalingPath:       "ecs-autoscaling.json",
 		},
 	},
 	{
@@ -631,6 +1183,7 @@ var cliTests = []struct {
 			Debug:          false,
 			ExtStr:         map[string]string{},
 			ExtCode:        map[string]string{},
+			Service:        ptr("myservice"),
 		},
 		subOption: &ecspresso.InitOption{
 			Region:                os.Getenv("AWS_REGION"),
@@ -640,6 +1193,7 @@ var cliTests = []struct {
 			ServiceDefinitionPath: "ecs-service-def.json",
 			ForceOverwrite:        false,
 			Jsonnet:               false,
+			AutoScalingPath:       "ecs-autoscaling.json",
 		},
 	},
 	{
@@ -672,6 +1226,7 @@ var cliTests = []struct {
 			ServiceDefinitionPath: "ecs-service-def.json",
 			ForceOverwrite:        false,
 			Jsonnet:               false,
+			AutoScalingPath:       "ecs-autoscaling.json",
 		},
 	},
 	{
@@ -681,6 +1236,14 @@ var cliTests = []struct {
 			Unified: true,
 		},
 	},
+	{
+		args: []string{"diff", "--refresh"},
+		sub:  "diff",
+		subOption: &ecspresso.DiffOption{
+			Unified: true,
+			Refresh: true,
+		},
+	},
 	{
 		args: []string{"diff", "--no-unified"},
 		sub:  "diff",
@@ -755,6 +1318,16 @@ var cliTests = []struct {
 			Cache:      false,
 		},
 	},
+	{
+		args: []string{"verify", "--check-permissions"},
+		sub:  "verify",
+		subOption: &ecspresso.VerifyOption{
+			GetSecrets:       true,
+			PutLogs:          true,
+			Cache:            true,
+			CheckPermissions: true,
+		},
+	},
 	{
 		args: []string{"render", "config", "taskdef", "servicedef"},
 		sub:  "render",
@@ -763,6 +1336,23 @@ var cliTests = []struct {
 			Jsonnet: false,
 		},
 	},
+	{
+		args: []string{"render", "servicedef", "--output", "sv.json"},
+		sub:  "render",
+		subOption: &ecspresso.RenderOption{
+			Targets: ptr([]string{"servicedef"}),
+			Output:  "sv.json",
+		},
+	},
+	{
+		args: []string{"render", "taskdef", "--no-aws"},
+		sub:  "render",
+		subOption: &ecspresso.RenderOption{
+			Targets: ptr([]string{"taskdef"}),
+			Jsonnet: false,
+			NoAWS:   true,
+		},
+	},
 	{
 		args: []string{"tasks"},
 		sub:  "tasks",
@@ -853,15 +1443,105 @@ func TestParseCLIv2(t *testing.T) {
 	}
 }
 
+func TestParseCLIv2EmptyOverrides(t *testing.T) {
+	for _, name := range []string{"region", "cluster", "service"} {
+		_, _, _, err := ecspresso.ParseCLIv2([]string{"status", "--" + name, ""})
+		if err == nil || !strings.Contains(err.Error(), "--"+name+" must not be empty") {
+			t.Errorf("unexpected error for empty --%s: %v", name, err)
+		}
+	}
+}
+
 func CLIOptionsGlobalOnly(opts *ecspresso.CLIOptions) *ecspresso.CLIOptions {
 	return &ecspresso.CLIOptions{
-		ConfigFilePath: opts.ConfigFilePath,
-		Debug:          opts.Debug,
-		ExtStr:         opts.ExtStr,
-		ExtCode:        opts.ExtCode,
-		Envfile:        opts.Envfile,
-		AssumeRoleARN:  opts.AssumeRoleARN,
-		Timeout:        opts.Timeout,
-		FilterCommand:  opts.FilterCommand,
+		ConfigFilePath:        opts.ConfigFilePath,
+		Debug:                 opts.Debug,
+		ExtStr:                opts.ExtStr,
+		ExtCode:               opts.ExtCode,
+		TLAStr:                opts.TLAStr,
+		TLACode:               opts.TLACode,
+		JsonnetLib:            opts.JsonnetLib,
+		Strict:                opts.Strict,
+		StrictConfig:          opts.StrictConfig,
+		Envfile:               opts.Envfile,
+		AssumeRoleARN:         opts.AssumeRoleARN,
+		AssumeRoleExternalID:  opts.AssumeRoleExternalID,
+		AssumeRoleSessionName: opts.AssumeRoleSessionName,
+		MFASerial:             opts.MFASerial,
+		Profile:               opts.Profile,
+		Overlay:               opts.Overlay,
+		ServiceSelector:       opts.ServiceSelector,
+		Region:                opts.Region,
+		Cluster:               opts.Cluster,
+		Service:               opts.Service,
+		RetryMaxAttempts:      opts.RetryMaxAttempts,
+		RetryMaxBackoff:       opts.RetryMaxBackoff,
+		Timeout:               opts.Timeout,
+		FilterCommand:         opts.FilterCommand,
+	}
+}
+
+func TestResolveConfigFilePathInteractive(t *testing.T) {
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		t.Skip("stdin is a terminal")
+	}
+	pwd, _ := os.Getwd()
+	defer os.Chdir(pwd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ecspresso.dev.jsonnet", "ecspresso.prod.jsonnet"} {
+		if err := os.WriteFile(name, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opt := &ecspresso.CLIOptions{}
+	if path, err := opt.ResolveConfigFilePath(); err != nil || path != ecspresso.DefaultConfigFilePath {
+		t.Errorf("unexpected result without --interactive: %s %v", path, err)
+	}
+
+	opt = &ecspresso.CLIOptions{Interactive: true}
+	_, err := opt.ResolveConfigFilePath()
+	if err == nil {
+		t.Fatal("expected an error on non-interactive run")
+	}
+	if !strings.Contains(err.Error(), "ecspresso.dev.jsonnet, ecspresso.prod.jsonnet") {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestResolveConfigFilePathTOML(t *testing.T) {
+	pwd, _ := os.Getwd()
+	defer os.Chdir(pwd)
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("ecspresso.toml", []byte(""), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opt := &ecspresso.CLIOptions{}
+	if path, err := opt.ResolveConfigFilePath(); err != nil || path != "ecspresso.toml" {
+		t.Errorf("unexpected result: %s %v", path, err)
+	}
+
+	if isatty.IsTerminal(os.Stdin.Fd()) {
+		t.Skip("stdin is a terminal")
+	}
+	if err := os.Remove("ecspresso.toml"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"ecspresso.dev.toml", "ecspresso.prod.toml"} {
+		if err := os.WriteFile(name, []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opt = &ecspresso.CLIOptions{Interactive: true}
+	_, err := opt.ResolveConfigFilePath()
+	if err == nil {
+		t.Fatal("expected an error on non-interactive run")
+	}
+	if !strings.Contains(err.Error(), "ecspresso.dev.toml, ecspresso.prod.toml") {
+		t.Errorf("unexpected error: %s", err)
 	}
 }
@@ -23,7 +23,20 @@ func ParseCLIv2(args []string) (string, *CLIOptions, func(), error) {
 	if err != nil {
 		return "", nil, nil, fmt.Errorf("failed to parse args: %w", err)
 	}
-	sub := strings.Fields(c.Command())[0]
+	cmds := strings.Fields(c.Command())
+	sub := cmds[0]
+	if sub == "config" && len(cmds) > 1 {
+		opts.Config.command = cmds[1]
+	}
+	if err := opts.validateOverrides(); err != nil {
+		return sub, &opts, nil, err
+	}
+	if err := opts.validateServiceSelector(sub); err != nil {
+		return sub, &opts, nil, err
+	}
+	if sub == "init" {
+		opts.Init.setCLIOptions(&opts)
+	}
 
 	for _, envFile := range opts.Envfile {
 		if err := ExportEnvFile(envFile); err != nil {
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 	"os/signal"
+	"runtime/debug"
 
 	"github.com/kayac/ecspresso/v2"
 )
@@ -12,7 +13,7 @@ import (
 var Version string
 
 func main() {
-	ecspresso.Version = Version
+	ecspresso.Version = buildVersion()
 	ctx, stop := signal.NotifyContext(context.Background(), trapSignals...)
 	defer stop()
 
@@ -26,3 +27,15 @@ func main() {
 	}
 	os.Exit(exitCode)
 }
+
+// buildVersion returns the version embedded by -ldflags.
+// The version of the module is used when built by `go install` without -ldflags.
+func buildVersion() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "current"
+}
@@ -2,20 +2,29 @@ package ecspresso
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"text/template"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	awsConfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/google/go-jsonnet"
 	goVersion "github.com/hashicorp/go-version"
 	"github.com/kayac/ecspresso/v2/appspec"
+	"github.com/kayac/ecspresso/v2/secretsmanager"
 	goConfig "github.com/kayac/go-config"
 	"github.com/samber/lo"
 )
@@ -30,6 +39,22 @@ var awsv2ConfigLoadOptionsFunc []func(*awsConfig.LoadOptions) error
 type configLoader struct {
 	*goConfig.Loader
 	VM *jsonnet.VM
+
+	// configReader reads the config file before the plugins are set up.
+	// Calls of the plugin functions are left as is to be expanded later.
+	configReader *goConfig.Loader
+	// pluginFuncNames are the names of template functions of each plugin to be deferred.
+	pluginFuncNames map[string][]string
+
+	noAWS           bool        // stub plugin functions calling AWS APIs
+	baseDir         string      // base directory to resolve relative paths in the config
+	overrides       *CLIOptions // CLI options to override the config before the AWS config is loaded
+	overlay         string      // Jsonnet file merged onto the config file by `+`
+	hasTLA          bool        // top-level arguments are given
+	strictVersion   bool        // disallow the version which is not a release version when required_version is set
+	configDir       string      // directory to resolve relative paths of tfstate_lookup in the config file
+	strictConfig    bool        // fail on unknown fields in the config file
+	noProjectConfig bool        // do not look for the project-level defaults file
 }
 
 func newConfigLoader(extStr, extCode map[string]string) *configLoader {
@@ -43,13 +68,110 @@ func newConfigLoader(extStr, extCode map[string]string) *configLoader {
 	for _, f := range DefaultJsonnetNativeFuncs() {
 		vm.NativeFunction(f)
 	}
-	return &configLoader{
+	l := &configLoader{
 		Loader: goConfig.New(),
 		VM:     vm,
 	}
+	l.Funcs(DefaultTemplateFuncs())
+	// ssm, secretsmanager, cfn_output and tfstate_lookup in the config file use the AWS config overridden by CLI options.
+	// they are replaced by the ones using the AWS config of ecspresso after the config file is loaded.
+	noAWS := func() bool { return l.noAWS }
+	s := newSSMFunction(func(ctx context.Context) (ssmGetParameterAPI, error) {
+		return newSSMClientByCLIOptions(ctx, l.overrides)
+	})
+	s.noAWS = noAWS
+	sm := newSecretsManagerFunction(func(ctx context.Context) (*secretsmanager.App, error) {
+		return newSecretsManagerByCLIOptions(ctx, l.overrides)
+	})
+	sm.noAWS = noAWS
+	sm.config = true
+	cfn := newCFnOutputFunction(func(ctx context.Context) (cfnDescribeStacksAPI, error) {
+		return newCFnClientByCLIOptions(ctx, l.overrides)
+	})
+	cfn.noAWS = noAWS
+	tf := newTFStateFunction(func(ctx context.Context) (s3GetObjectAPI, error) {
+		return newS3ClientByCLIOptions(ctx, l.overrides)
+	})
+	tf.dir = func() string { return l.configDir }
+	tf.noAWS = noAWS
+	vm.NativeFunction(s.nativeFunction())
+	vm.NativeFunction(sm.nativeFunction())
+	vm.NativeFunction(cfn.nativeFunction())
+	vm.NativeFunction(tf.nativeFunction())
+	return l
+}
+
+// setTLA sets top-level arguments for Jsonnet.
+// They are passed to the Jsonnet file which evaluates to a function.
+// The config file must be a function when they are given, see requireFunctionForTLA.
+func (l *configLoader) setTLA(tlaStr, tlaCode map[string]string) {
+	for k, v := range tlaStr {
+		l.VM.TLAVar(k, v)
+	}
+	for k, v := range tlaCode {
+		l.VM.TLACode(k, v)
+	}
+	l.hasTLA = l.hasTLA || len(tlaStr) > 0 || len(tlaCode) > 0
+}
+
+// setJsonnetLibs sets the additional library search paths for the imports of Jsonnet.
+// Relative paths are resolved from the directory of the config file.
+// The imports relative to the importing file are resolved before the library paths, as before.
+func (l *configLoader) setJsonnetLibs(libs []string, configPath string) error {
+	if len(libs) == 0 {
+		return nil
+	}
+	var dir string
+	if !isConfigURL(configPath) {
+		dir = filepath.Dir(configPath)
+	}
+	jpaths := make([]string, 0, len(libs))
+	for _, p := range libs {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		if st, err := os.Stat(p); err != nil {
+			return fmt.Errorf("invalid jsonnet library path: %w", err)
+		} else if !st.IsDir() {
+			return fmt.Errorf("invalid jsonnet library path: %s is not a directory", p)
+		}
+		jpaths = append(jpaths, p)
+	}
+	Log("[DEBUG] jsonnet library paths: %s", strings.Join(jpaths, ", "))
+	l.VM.Importer(&jsonnet.FileImporter{JPaths: jpaths})
+	return nil
+}
+
+// requireFunctionForTLA returns an error when top-level arguments are given
+// but the config file does not evaluate to a function.
+// Jsonnet silently ignores top-level arguments for the file which is not a function.
+func (l *configLoader) requireFunctionForTLA(path string) error {
+	if !l.hasTLA {
+		return nil
+	}
+	if ext := filepath.Ext(path); ext != jsonnetExt && ext != jsonExt {
+		return fmt.Errorf("--tla-str and --tla-code are supported only for Jsonnet config files: %s", path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	importPath, err := json.Marshal(abs)
+	if err != nil {
+		return err
+	}
+	out, err := l.VM.EvaluateAnonymousSnippet(path, fmt.Sprintf("std.isFunction(import %s)\n", importPath))
+	if err != nil {
+		return fmt.Errorf("failed to evaluate jsonnet file: %w", err)
+	}
+	if strings.TrimSpace(out) != "true" {
+		return fmt.Errorf("--tla-str or --tla-code is given, but %s does not evaluate to a function. define the config as `function(name) { ... }` to use top-level arguments", path)
+	}
+	return nil
 }
 
 // Config represents a configuration.
+// TOML config files are decoded by the json tags.
 type Config struct {
 	RequiredVersion       string            `yaml:"required_version,omitempty" json:"required_version,omitempty"`
 	Region                string            `yaml:"region" json:"region"`
@@ -61,8 +183,19 @@ type Config struct {
 	AppSpec               *appspec.AppSpec  `yaml:"appspec,omitempty" json:"appspec,omitempty"`
 	FilterCommand         string            `yaml:"filter_command,omitempty" json:"filter_command,omitempty"`
 	Timeout               *Duration         `yaml:"timeout,omitempty" json:"timeout,omitempty"`
+	Timeouts              *ConfigTimeouts   `yaml:"timeouts,omitempty" json:"timeouts,omitempty"`
 	CodeDeploy            *ConfigCodeDeploy `yaml:"codedeploy,omitempty" json:"codedeploy,omitempty"`
 	Ignore                *ConfigIgnore     `yaml:"ignore,omitempty" json:"ignore,omitempty"`
+	TemplateDelimiters    []string          `yaml:"template_delimiters,omitempty" json:"template_delimiters,omitempty"`
+	SortEnvironment       bool              `yaml:"sort_environment,omitempty" json:"sort_environment,omitempty"`
+	Include               []string          `yaml:"include,omitempty" json:"include,omitempty"`
+	AssumeRoleConfig      *ConfigAssumeRole `yaml:"assume_role,omitempty" json:"assume_role,omitempty"`
+	Profile               string            `yaml:"profile,omitempty" json:"profile,omitempty"`
+	Endpoint              string            `yaml:"endpoint,omitempty" json:"endpoint,omitempty"`
+	AWS                   *ConfigAWS        `yaml:"aws,omitempty" json:"aws,omitempty"`
+	Tags                  ConfigTags        `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Prune                 *ConfigPrune      `yaml:"prune,omitempty" json:"prune,omitempty"`
+	RevisionsWarning      *int              `yaml:"revisions_warning,omitempty" json:"revisions_warning,omitempty"`
 
 	path               string
 	templateFuncs      []template.FuncMap
@@ -70,6 +203,19 @@ type Config struct {
 	dir                string
 	versionConstraints goVersion.Constraints
 	awsv2Config        aws.Config
+	noAWS              bool
+	pluginInfos        []pluginInfo
+	assumeRoleARN      string
+	logf               func(format string, v ...any) // set to App.Log by New
+}
+
+// log logs by the logger of the App, or by the common logger until the App is created.
+func (c *Config) log(f string, v ...any) {
+	if c.logf != nil {
+		c.logf(f, v...)
+		return
+	}
+	Log(f, v...)
 }
 
 type ConfigCodeDeploy struct {
@@ -77,58 +223,549 @@ type ConfigCodeDeploy struct {
 	DeploymentGroupName string `yaml:"deployment_group_name,omitempty" json:"deployment_group_name,omitempty"`
 }
 
+// ConfigAssumeRole represents options to assume the role.
+// Values set by CLI flags take precedence over them.
+type ConfigAssumeRole struct {
+	RoleARN     string `yaml:"role_arn,omitempty" json:"role_arn,omitempty"`
+	ExternalID  string `yaml:"external_id,omitempty" json:"external_id,omitempty"`
+	SessionName string `yaml:"session_name,omitempty" json:"session_name,omitempty"`
+	MFASerial   string `yaml:"mfa_serial,omitempty" json:"mfa_serial,omitempty"`
+}
+
+// providerOptions returns the option funcs of the assume role provider.
+// Empty values are not set, to keep the defaults of the provider.
+func (a *ConfigAssumeRole) providerOptions() []func(*stscreds.AssumeRoleOptions) {
+	var fns []func(*stscreds.AssumeRoleOptions)
+	if a.ExternalID != "" {
+		fns = append(fns, func(o *stscreds.AssumeRoleOptions) {
+			o.ExternalID = aws.String(a.ExternalID)
+		})
+	}
+	if a.SessionName != "" {
+		fns = append(fns, func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = a.SessionName
+		})
+	}
+	if a.MFASerial != "" {
+		// the token provider prompts on STDIN. it is set only with the serial, so automated runs never wait for input
+		fns = append(fns, func(o *stscreds.AssumeRoleOptions) {
+			o.SerialNumber = aws.String(a.MFASerial)
+			o.TokenProvider = stscreds.StdinTokenProvider
+		})
+	}
+	return fns
+}
+
+// ConfigAWS represents tunables of the AWS SDK. The SDK defaults are used for unset values.
+type ConfigAWS struct {
+	RetryMaxAttempts int       `yaml:"retry_max_attempts,omitempty" json:"retry_max_attempts,omitempty"`
+	RetryMaxBackoff  *Duration `yaml:"retry_max_backoff,omitempty" json:"retry_max_backoff,omitempty"`
+}
+
+// loadOptions returns the options to load the AWS config.
+func (a *ConfigAWS) loadOptions() []func(*awsConfig.LoadOptions) error {
+	if a == nil || (a.RetryMaxAttempts == 0 && a.RetryMaxBackoff == nil) {
+		return nil
+	}
+	var opts []func(*awsConfig.LoadOptions) error
+	if a.RetryMaxAttempts > 0 {
+		opts = append(opts, awsConfig.WithRetryMaxAttempts(a.RetryMaxAttempts))
+	}
+	opts = append(opts, awsConfig.WithRetryer(func() aws.Retryer {
+		return retry.NewStandard(func(o *retry.StandardOptions) {
+			if a.RetryMaxAttempts > 0 {
+				o.MaxAttempts = a.RetryMaxAttempts
+			}
+			if a.RetryMaxBackoff != nil && a.RetryMaxBackoff.Duration > 0 {
+				o.MaxBackoff = a.RetryMaxBackoff.Duration
+			}
+		})
+	}))
+	return opts
+}
+
+// ConfigTimeouts represents the timeouts of the phases. Unset ones fall back to the timeout of the config.
+type ConfigTimeouts struct {
+	Deploy   *Duration `yaml:"deploy,omitempty" json:"deploy,omitempty"`
+	Wait     *Duration `yaml:"wait,omitempty" json:"wait,omitempty"`
+	Rollback *Duration `yaml:"rollback,omitempty" json:"rollback,omitempty"`
+	Run      *Duration `yaml:"run,omitempty" json:"run,omitempty"`
+}
+
+type timeoutPhase string
+
+const (
+	timeoutPhaseDeploy   timeoutPhase = "deploy"
+	timeoutPhaseWait     timeoutPhase = "wait"
+	timeoutPhaseRollback timeoutPhase = "rollback"
+	timeoutPhaseRun      timeoutPhase = "run"
+)
+
+// durationOf returns the timeout of the phase, or nil when it is not set.
+func (t *ConfigTimeouts) durationOf(phase timeoutPhase) *Duration {
+	if t == nil {
+		return nil
+	}
+	switch phase {
+	case timeoutPhaseDeploy:
+		return t.Deploy
+	case timeoutPhaseWait:
+		return t.Wait
+	case timeoutPhaseRollback:
+		return t.Rollback
+	case timeoutPhaseRun:
+		return t.Run
+	}
+	return nil
+}
+
+// timeoutFor returns the timeout of the phase.
+func (c *Config) timeoutFor(phase timeoutPhase) time.Duration {
+	if d := c.Timeouts.durationOf(phase); d != nil {
+		return d.Duration
+	}
+	return c.Timeout.Duration
+}
+
+// waiterTimeoutFor returns the maximum wait duration of the waiters of the SDK for the phase.
+// The waiters require a positive duration, so the zero timeout, which means no timeout as in startPhase,
+// is the maximum duration.
+func (c *Config) waiterTimeoutFor(phase timeoutPhase) time.Duration {
+	if t := c.timeoutFor(phase); t > 0 {
+		return t
+	}
+	return math.MaxInt64
+}
+
+// deployLockTTL returns the default TTL of the deploy lock.
+// When timeouts are set, the deployment may take the timeout of the deploy phase and of the wait phase.
+func (c *Config) deployLockTTL() time.Duration {
+	if c.Timeouts == nil {
+		return c.Timeout.Duration
+	}
+	deploy, wait := c.timeoutFor(timeoutPhaseDeploy), c.timeoutFor(timeoutPhaseWait)
+	if deploy <= 0 || wait <= 0 {
+		return c.Timeout.Duration
+	}
+	return deploy + wait
+}
+
+// timeoutNameFor returns the name of the config key which sets the timeout of the phase.
+func (c *Config) timeoutNameFor(phase timeoutPhase) string {
+	if d := c.Timeouts.durationOf(phase); d != nil {
+		return "timeouts." + string(phase)
+	}
+	return "timeout"
+}
+
 // Load loads configuration file from file path.
 func (l *configLoader) Load(ctx context.Context, path string, version string) (*Config, error) {
-	conf := &Config{path: path}
-	ext := filepath.Ext(path)
-	switch ext {
-	case ymlExt, yamlExt:
-		b, err := l.ReadWithEnv(path)
+	if l.configReader == nil {
+		names, err := pluginFuncNames(ctx)
 		if err != nil {
 			return nil, err
 		}
-		if err := unmarshalYAML(b, conf, path); err != nil {
-			return nil, fmt.Errorf("failed to parse yaml: %w", err)
+		l.pluginFuncNames = names
+		l.configReader = goConfig.New()
+		l.configReader.Funcs(DefaultTemplateFuncs())
+		l.configReader.Funcs(deferredPluginFuncs(names, nil))
+	}
+	conf := &Config{path: path, noAWS: l.noAWS}
+	if isConfigURL(path) {
+		if err := l.readConfigURL(ctx, path, conf); err != nil {
+			return nil, err
+		}
+		if len(conf.Include) > 0 {
+			return nil, fmt.Errorf("include is not supported for the config file loaded from URL: %s", path)
 		}
-	case jsonExt, jsonnetExt:
-		jsonStr, err := l.VM.EvaluateFile(path)
-		if err != nil {
-			return nil, fmt.Errorf("failed to evaluate jsonnet file: %w", err)
+		if l.overlay != "" {
+			return nil, fmt.Errorf("--overlay is not supported for the config file loaded from URL: %s", path)
 		}
-		b, err := l.ReadWithEnvBytes([]byte(jsonStr))
+		if err := conf.requireLocalDefinitionPaths(); err != nil {
+			return nil, err
+		}
+		if l.baseDir == "" {
+			if err := conf.requireAbsDefinitionPaths(); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if err := l.requireFunctionForTLA(path); err != nil {
+			return nil, err
+		}
+		l.configDir = filepath.Dir(path)
+		if l.baseDir != "" {
+			l.configDir = l.baseDir
+		}
+		readConfigFile := l.readConfigFile
+		if l.overlay != "" {
+			readConfigFile = l.readConfigFileWithOverlay
+		}
+		if err := readConfigFile(path, conf); err != nil {
+			return nil, err
+		}
+		abs, err := filepath.Abs(path)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read template file: %w", err)
+			return nil, err
 		}
-		if err := unmarshalJSON(b, conf, path); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal json: %w", err)
+		if err := l.applyIncludes(conf, []string{abs}); err != nil {
+			return nil, err
 		}
-	default:
-		return nil, fmt.Errorf("unsupported config file extension: %s", ext)
+		if err := l.applyProjectDefaults(conf); err != nil {
+			return nil, err
+		}
+		conf.dir = filepath.Dir(path)
+	}
+	if l.baseDir != "" {
+		conf.dir = l.baseDir
+	}
+	if l.overrides != nil {
+		// region may be overridden, so it must be applied before Restrict loads the AWS config
+		conf.OverrideByCLIOptions(l.overrides)
 	}
-
-	conf.dir = filepath.Dir(path)
 	if err := conf.Restrict(ctx); err != nil {
 		return nil, err
 	}
-	if err := conf.ValidateVersion(version); err != nil {
+	if err := conf.validateVersion(version, l.strictVersion); err != nil {
 		return nil, err
 	}
 	for _, f := range conf.templateFuncs {
 		l.Funcs(f)
 	}
+	// ssm, secretsmanager, cfn_output and tfstate_lookup in the definition files use the AWS config of ecspresso.
+	// the plugins replace them.
+	noAWS := func() bool { return l.noAWS }
+	s := newSSMFunction(func(ctx context.Context) (ssmGetParameterAPI, error) {
+		return ssm.NewFromConfig(conf.awsv2Config), nil
+	})
+	s.noAWS = noAWS
+	sm := newSecretsManagerFunction(func(ctx context.Context) (*secretsmanager.App, error) {
+		return secretsmanager.NewApp(conf.awsv2Config, conf.log), nil
+	})
+	sm.noAWS = noAWS
+	cfn := newCFnOutputFunction(func(ctx context.Context) (cfnDescribeStacksAPI, error) {
+		return cloudformation.NewFromConfig(conf.awsv2Config), nil
+	})
+	cfn.noAWS = noAWS
+	tf := newTFStateFunction(func(ctx context.Context) (s3GetObjectAPI, error) {
+		return s3.NewFromConfig(conf.awsv2Config, s3PathStyle(conf.awsv2Config)), nil
+	})
+	tf.dir = func() string { return conf.dir }
+	tf.noAWS = noAWS
+	l.VM.NativeFunction(s.nativeFunction())
+	l.VM.NativeFunction(sm.nativeFunction())
+	l.VM.NativeFunction(cfn.nativeFunction())
+	l.VM.NativeFunction(tf.nativeFunction())
 	for _, f := range conf.jsonnetNativeFuncs {
 		l.VM.NativeFunction(f)
 	}
+	if err := l.expandNames(conf); err != nil {
+		return nil, err
+	}
 	return conf, nil
 }
 
+// expandNames expands calls of the plugin functions in cluster and service, after the plugins are set up.
+// The other fields are used to set up the plugins, so they cannot call the plugin functions.
+func (l *configLoader) expandNames(conf *Config) error {
+	for _, s := range []string{conf.Region, conf.ServiceDefinitionPath, conf.TaskDefinitionPath} {
+		if strings.Contains(s, "{{") {
+			return fmt.Errorf("plugin functions are available only in cluster and service of the config: %s", s)
+		}
+	}
+	for _, s := range []*string{&conf.Cluster, &conf.Service} {
+		if !strings.Contains(*s, "{{") {
+			continue
+		}
+		b, err := l.ReadWithEnvBytes([]byte(*s))
+		if err != nil {
+			return fmt.Errorf("failed to expand %s: %w", *s, err)
+		}
+		Log("[DEBUG] %s is expanded to %s", *s, string(b))
+		*s = string(b)
+	}
+	return nil
+}
+
+func (l *configLoader) readConfigFile(path string, conf *Config) error {
+	ext := filepath.Ext(path)
+	switch ext {
+	case ymlExt, yamlExt, tomlExt:
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return l.readConfigBytes(src, ext, path, conf)
+	case jsonExt, jsonnetExt:
+		jsonStr, err := l.VM.EvaluateFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to evaluate jsonnet file: %w", err)
+		}
+		return l.readConfigJSON(jsonStr, conf, path)
+	default:
+		return fmt.Errorf("unsupported config file extension: %s", ext)
+	}
+}
+
+// readConfigBytes reads the config from the source on memory.
+// name is used for error messages and the file name of the Jsonnet snippet.
+func (l *configLoader) readConfigBytes(src []byte, ext string, name string, conf *Config) error {
+	switch ext {
+	case ymlExt, yamlExt:
+		l.deferPluginFuncs(src, ext)
+		b, err := readWithEnvBytes(l.configReader, src)
+		if err != nil {
+			return err
+		}
+		if err := l.unmarshalYAML(b, conf, name); err != nil {
+			return fmt.Errorf("failed to parse yaml: %w", err)
+		}
+	case tomlExt:
+		l.deferPluginFuncs(src, ext)
+		b, err := readWithEnvBytes(l.configReader, src)
+		if err != nil {
+			return err
+		}
+		if err := l.unmarshalTOML(b, conf, name); err != nil {
+			return fmt.Errorf("failed to parse toml: %w", err)
+		}
+	case jsonExt, jsonnetExt:
+		jsonStr, err := l.VM.EvaluateAnonymousSnippet(name, string(src))
+		if err != nil {
+			return fmt.Errorf("failed to evaluate jsonnet: %w", err)
+		}
+		return l.readConfigJSON(jsonStr, conf, name)
+	default:
+		return fmt.Errorf("unsupported config file extension: %s", ext)
+	}
+	return nil
+}
+
+// deferPluginFuncs adds the deferred functions with func_prefix of the plugins configured in the source.
+func (l *configLoader) deferPluginFuncs(src []byte, ext string) {
+	l.configReader.Funcs(deferredPluginFuncs(l.pluginFuncNames, configuredPlugins(src, ext)))
+}
+
+func (l *configLoader) readConfigJSON(jsonStr string, conf *Config, name string) error {
+	l.deferPluginFuncs([]byte(jsonStr), jsonExt)
+	b, err := readWithEnvBytes(l.configReader, []byte(jsonStr))
+	if err != nil {
+		return fmt.Errorf("failed to read template file: %w", err)
+	}
+	if err := l.unmarshalJSON(b, conf, name); err != nil {
+		return fmt.Errorf("failed to unmarshal json: %w", err)
+	}
+	return nil
+}
+
+// unmarshalYAML, unmarshalTOML and unmarshalJSON decode the config.
+// Unknown fields are warned, or errors with --strict-config.
+// Deprecated fields like filter_command are known, so they are warned only.
+func (l *configLoader) unmarshalYAML(src []byte, conf *Config, path string) error {
+	if l.strictConfig {
+		return unmarshalYAMLStrict(src, conf, path)
+	}
+	return unmarshalYAML(src, conf, path)
+}
+
+func (l *configLoader) unmarshalTOML(src []byte, conf *Config, path string) error {
+	if l.strictConfig {
+		return unmarshalTOMLStrict(src, conf, path)
+	}
+	return unmarshalTOML(src, conf, path)
+}
+
+func (l *configLoader) unmarshalJSON(src []byte, conf *Config, path string) error {
+	if l.strictConfig {
+		return unmarshalJSONStrict(src, conf, path)
+	}
+	return unmarshalJSON(src, conf, path)
+}
+
+// ProjectConfigFileBaseName is the base name of the project-level defaults file.
+const ProjectConfigFileBaseName = ".ecspresso"
+
+// findProjectConfigFile finds the project-level defaults file by walking up from the config file directory.
+// The search stops at the root of the repository, the directory containing .git.
+func findProjectConfigFile(configPath string) (string, error) {
+	abs, err := filepath.Abs(configPath)
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(abs)
+	for {
+		for _, ext := range []string{ymlExt, yamlExt, jsonExt, jsonnetExt, tomlExt} {
+			p := filepath.Join(dir, ProjectConfigFileBaseName+ext)
+			if p == abs {
+				continue
+			}
+			if _, err := os.Stat(p); err == nil {
+				return p, nil
+			}
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return "", nil // the root of the repository
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// applyProjectDefaults merges the project-level defaults file under the config.
+// The values in the config take precedence over the defaults.
+func (l *configLoader) applyProjectDefaults(conf *Config) error {
+	if l.noProjectConfig {
+		return nil
+	}
+	path, err := findProjectConfigFile(conf.path)
+	if err != nil {
+		return fmt.Errorf("failed to find project config file: %w", err)
+	}
+	if path == "" {
+		return nil
+	}
+	defaults := &Config{path: path}
+	if err := l.readConfigFile(path, defaults); err != nil {
+		return fmt.Errorf("failed to load project config file %s: %w", path, err)
+	}
+	if err := l.applyIncludes(defaults, []string{path}); err != nil {
+		return err
+	}
+	Log("[INFO] project config file %s is applied", path)
+	conf.mergeDefaults(defaults, filepath.Dir(path))
+	return nil
+}
+
+func (c *Config) mergeDefaults(defaults *Config, defaultsDir string) {
+	if c.RequiredVersion == "" {
+		c.RequiredVersion = defaults.RequiredVersion
+	}
+	if c.Region == "" {
+		c.Region = defaults.Region
+	}
+	if c.Cluster == "" {
+		c.Cluster = defaults.Cluster
+	}
+	if c.Service == "" {
+		c.Service = defaults.Service
+	}
+	if c.AppSpec == nil {
+		c.AppSpec = defaults.AppSpec
+	}
+	if c.FilterCommand == "" {
+		c.FilterCommand = defaults.FilterCommand
+	}
+	if c.Timeout == nil {
+		c.Timeout = defaults.Timeout
+	}
+	if c.Timeouts == nil {
+		c.Timeouts = defaults.Timeouts
+	}
+	if c.CodeDeploy == nil {
+		c.CodeDeploy = defaults.CodeDeploy
+	}
+	if len(c.TemplateDelimiters) == 0 {
+		c.TemplateDelimiters = defaults.TemplateDelimiters
+	}
+	if c.Ignore == nil {
+		c.Ignore = defaults.Ignore
+	}
+	if !c.SortEnvironment {
+		c.SortEnvironment = defaults.SortEnvironment
+	}
+	if c.AssumeRoleConfig == nil {
+		c.AssumeRoleConfig = defaults.AssumeRoleConfig
+	}
+	if c.Profile == "" {
+		c.Profile = defaults.Profile
+	}
+	if c.Endpoint == "" {
+		c.Endpoint = defaults.Endpoint
+	}
+	if c.AWS == nil {
+		c.AWS = defaults.AWS
+	}
+	if c.Prune == nil {
+		c.Prune = defaults.Prune
+	}
+	if c.RevisionsWarning == nil {
+		c.RevisionsWarning = defaults.RevisionsWarning
+	}
+	for k, v := range defaults.Tags {
+		if _, ok := c.Tags[k]; ok {
+			continue
+		}
+		if c.Tags == nil {
+			c.Tags = ConfigTags{}
+		}
+		c.Tags[k] = v
+	}
+	if len(defaults.Plugins) > 0 {
+		plugins := make([]ConfigPlugin, 0, len(defaults.Plugins)+len(c.Plugins))
+		for _, p := range defaults.Plugins {
+			plugins = append(plugins, p.withBaseDir(defaultsDir))
+		}
+		c.Plugins = append(plugins, c.Plugins...)
+	}
+}
+
 func (c *Config) OverrideByCLIOptions(opt *CLIOptions) {
+	if opt.Region != nil {
+		c.Region = *opt.Region
+	}
+	if opt.Cluster != nil {
+		c.Cluster = *opt.Cluster
+	}
+	if opt.Service != nil {
+		c.Service = *opt.Service
+	}
+	if opt.Profile != "" {
+		c.Profile = opt.Profile
+	}
 	if opt.Timeout != nil {
 		c.Timeout = &Duration{*opt.Timeout}
 	}
 	if opt.FilterCommand != "" {
 		c.FilterCommand = opt.FilterCommand
 	}
+	if opt.SortEnvironment {
+		c.SortEnvironment = true
+	}
+	if opt.RetryMaxAttempts != nil || opt.RetryMaxBackoff != nil {
+		var a ConfigAWS
+		if c.AWS != nil {
+			a = *c.AWS
+		}
+		if opt.RetryMaxAttempts != nil {
+			a.RetryMaxAttempts = *opt.RetryMaxAttempts
+		}
+		if opt.RetryMaxBackoff != nil {
+			a.RetryMaxBackoff = &Duration{*opt.RetryMaxBackoff}
+		}
+		c.AWS = &a
+	}
+	if opt.AssumeRoleARN != "" || opt.AssumeRoleExternalID != "" || opt.AssumeRoleSessionName != "" || opt.MFASerial != "" {
+		var ar ConfigAssumeRole
+		if c.AssumeRoleConfig != nil {
+			ar = *c.AssumeRoleConfig
+		}
+		if opt.AssumeRoleARN != "" {
+			ar.RoleARN = opt.AssumeRoleARN
+		}
+		if opt.AssumeRoleExternalID != "" {
+			ar.ExternalID = opt.AssumeRoleExternalID
+		}
+		if opt.AssumeRoleSessionName != "" {
+			ar.SessionName = opt.AssumeRoleSessionName
+		}
+		if opt.MFASerial != "" {
+			ar.MFASerial = opt.MFASerial
+		}
+		c.AssumeRoleConfig = &ar
+	}
 }
 
 // Restrict restricts a configuration.
@@ -155,10 +792,39 @@ func (c *Config) Restrict(ctx context.Context) error {
 	if c.Timeout == nil {
 		c.Timeout = &Duration{Duration: DefaultTimeout}
 	}
+	if err := c.Ignore.compilePaths(); err != nil {
+		return fmt.Errorf("ignore.paths has an invalid path: %w", err)
+	}
+	if n := len(c.TemplateDelimiters); n > 0 {
+		if n != 2 || c.TemplateDelimiters[0] == "" || c.TemplateDelimiters[1] == "" {
+			return fmt.Errorf("template_delimiters requires a pair of left and right delimiters: %v", c.TemplateDelimiters)
+		}
+	}
 	if c.Region == "" {
 		c.Region = os.Getenv("AWS_REGION")
 	}
 	var err error
+	c.awsv2Config, err = awsConfig.LoadDefaultConfig(ctx, c.awsLoadOptions()...)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	if c.Endpoint != "" {
+		// all the service clients derived from the config use the endpoint, unless AWS_ENDPOINT_URL_{SERVICE} is set
+		Log("[INFO] custom endpoint: %s", c.Endpoint)
+		c.awsv2Config.BaseEndpoint = aws.String(c.Endpoint)
+	}
+	if err := c.setupPlugins(ctx); err != nil {
+		return fmt.Errorf("failed to setup plugins: %w", err)
+	}
+	if c.FilterCommand != "" {
+		Log("[WARNING] filter_command is deprecated. Use environment variable or CLI flag instead. `ecspresso config migrate` removes it from the config file.")
+	}
+	return nil
+}
+
+// awsLoadOptions returns the options to load the AWS config.
+// The profile takes precedence over AWS_PROFILE environment variable.
+func (c *Config) awsLoadOptions() []func(*awsConfig.LoadOptions) error {
 	var optsFunc []func(*awsConfig.LoadOptions) error
 	if len(awsv2ConfigLoadOptionsFunc) == 0 {
 		// default
@@ -168,28 +834,30 @@ func (c *Config) Restrict(ctx context.Context) error {
 		}
 	} else {
 		// Log("[INFO] override aws config load options")
-		optsFunc = awsv2ConfigLoadOptionsFunc
+		optsFunc = append(optsFunc, awsv2ConfigLoadOptionsFunc...)
 	}
-	c.awsv2Config, err = awsConfig.LoadDefaultConfig(ctx, optsFunc...)
-	if err != nil {
-		return fmt.Errorf("failed to load aws config: %w", err)
-	}
-	if err := c.setupPlugins(ctx); err != nil {
-		return fmt.Errorf("failed to setup plugins: %w", err)
-	}
-	if c.FilterCommand != "" {
-		Log("[WARNING] filter_command is deprecated. Use environment variable or CLI flag instead.")
+	if c.Profile != "" {
+		Log("[DEBUG] aws profile: %s", c.Profile)
+		optsFunc = append(optsFunc, awsConfig.WithSharedConfigProfile(c.Profile))
 	}
-	return nil
+	return append(optsFunc, c.AWS.loadOptions()...)
 }
 
 func (c *Config) AssumeRole(assumeRoleARN string) {
-	if assumeRoleARN == "" {
+	c.AssumeRoleWithOptions(&ConfigAssumeRole{RoleARN: assumeRoleARN})
+}
+
+// AssumeRoleWithOptions sets the credentials of the role to the AWS config.
+// The role is assumed by the base credentials loaded in Restrict, e.g. of the profile.
+// It does nothing when the role ARN is empty.
+func (c *Config) AssumeRoleWithOptions(ar *ConfigAssumeRole) {
+	if ar == nil || ar.RoleARN == "" {
 		return
 	}
-	Log("[INFO] assume role: %s", assumeRoleARN)
+	Log("[INFO] assume role: %s", ar.RoleARN)
+	c.assumeRoleARN = ar.RoleARN
 	stsClient := sts.NewFromConfig(c.awsv2Config)
-	assumeRoleProvider := stscreds.NewAssumeRoleProvider(stsClient, assumeRoleARN)
+	assumeRoleProvider := stscreds.NewAssumeRoleProvider(stsClient, ar.RoleARN, ar.providerOptions()...)
 	c.awsv2Config.Credentials = aws.NewCredentialsCache(assumeRoleProvider)
 }
 
@@ -199,21 +867,32 @@ func (c *Config) setupPlugins(ctx context.Context) error {
 		plugins = append(plugins, ConfigPlugin{Name: name})
 	}
 	plugins = append(plugins, c.Plugins...)
-	for _, p := range plugins {
+	for i, p := range plugins {
+		nt, nj := len(c.templateFuncs), len(c.jsonnetNativeFuncs)
 		if err := p.Setup(ctx, c); err != nil {
 			return err
 		}
+		c.pluginInfos = append(c.pluginInfos, newPluginInfo(p, i < len(defaultPluginNames), c.templateFuncs[nt:], c.jsonnetNativeFuncs[nj:]))
 	}
 	return nil
 }
 
 // ValidateVersion validates a version satisfies required_version.
 func (c *Config) ValidateVersion(version string) error {
+	return c.validateVersion(version, false)
+}
+
+// validateVersion validates a version satisfies required_version.
+// An invalid version (e.g. "current" of a development build) is allowed unless strict.
+func (c *Config) validateVersion(version string, strict bool) error {
 	if c.versionConstraints == nil {
 		return nil
 	}
 	v, err := goVersion.NewVersion(version)
 	if err != nil {
+		if strict {
+			return fmt.Errorf("required_version %s is set, but version \"%s\" of ecspresso is not a release version. --strict does not allow it", c.RequiredVersion, version)
+		}
 		Log("[WARNING] Invalid version format \"%s\". Skip checking required_version.", version)
 		// invalid version string (e.g. "current") always allowed
 		return nil
@@ -234,7 +913,10 @@ func NewDefaultConfig() *Config {
 }
 
 type ConfigIgnore struct {
-	Tags []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Tags  []string `yaml:"tags,omitempty" json:"tags,omitempty"`
+	Paths []string `yaml:"paths,omitempty" json:"paths,omitempty"`
+
+	queries map[string][]string // jq queries compiled from Paths by the target definition
 }
 
 type hasTags interface {
@@ -255,3 +937,31 @@ func (i *ConfigIgnore) Apply(v hasTags) error {
 	v.SetTags(i.filterTags(v.GetTags()))
 	return nil
 }
+
+// ConfigTags represents the tags applied to all the resources managed by ecspresso.
+type ConfigTags map[string]string
+
+// mergeTags appends the tags to the tags of the resource in the order of the keys.
+// The tags of the resource take precedence over the ones with the same keys.
+func (t ConfigTags) mergeTags(tags []types.Tag) []types.Tag {
+	if len(t) == 0 {
+		return tags
+	}
+	keys := lo.Keys(t)
+	sort.Strings(keys)
+	merged := make([]types.Tag, 0, len(tags)+len(keys))
+	merged = append(merged, tags...)
+	for _, k := range keys {
+		if _, found := lo.Find(tags, func(tag types.Tag) bool { return aws.ToString(tag.Key) == k }); found {
+			Log("[DEBUG] tag %s is defined in the resource. the global tag is not applied", k)
+			continue
+		}
+		merged = append(merged, types.Tag{Key: aws.String(k), Value: aws.String(t[k])})
+	}
+	return merged
+}
+
+func (t ConfigTags) Apply(v hasTags) error {
+	v.SetTags(t.mergeTags(v.GetTags()))
+	return nil
+}
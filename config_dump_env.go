@@ -0,0 +1,222 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/samber/lo"
+)
+
+type ConfigDumpEnvOption struct{}
+
+const (
+	envRefKindEnv    = "env"
+	envRefKindExtVar = "ext-var"
+)
+
+// envReference is a reference to an environment variable or a Jsonnet external variable.
+type envReference struct {
+	Name     string
+	Kind     string // env or ext-var
+	Required bool   // referenced by must_env or std.extVar
+	Files    []string
+}
+
+var (
+	templateEnvRegexp      = regexp.MustCompile("\\{\\{-?\\s*(env|must_env)\\s+(\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`)")
+	jsonnetNativeRegexp    = regexp.MustCompile(`local\s+([A-Za-z_][A-Za-z0-9_]*)\s*=\s*std\.native\(\s*['"](env|must_env)['"]\s*\)`)
+	jsonnetNativeEnvRegexp = regexp.MustCompile(`std\.native\(\s*['"](env|must_env)['"]\s*\)\(\s*('[^']*'|"[^"]*")`)
+	jsonnetExtVarRegexp    = regexp.MustCompile(`std\.extVar\(\s*('[^']*'|"[^"]*")`)
+	jsonnetImportRegexp    = regexp.MustCompile(`\bimport(?:str)?\s+('[^']*'|"[^"]*")`)
+)
+
+func isJsonnetExt(ext string) bool {
+	return ext == jsonnetExt || ext == ".libsonnet"
+}
+
+func unquoteJsonnetString(s string) string {
+	return s[1 : len(s)-1]
+}
+
+// scanEnvReferences scans the source of the file before rendering for references to variables.
+// Template functions env and must_env, ${VAR:-default} and ${VAR:?message} are scanned in all files.
+// Jsonnet native functions env, must_env and std.extVar are scanned in Jsonnet files.
+func scanEnvReferences(src []byte, ext string) []envReference {
+	var refs []envReference
+	add := func(name, kind string, required bool) {
+		refs = append(refs, envReference{Name: name, Kind: kind, Required: required})
+	}
+	s := string(src)
+	for _, m := range templateEnvRegexp.FindAllStringSubmatch(s, -1) {
+		name, err := strconv.Unquote(m[2])
+		if err != nil {
+			continue
+		}
+		add(name, envRefKindEnv, m[1] == "must_env")
+	}
+	for _, m := range envDefaultRegexp.FindAllStringSubmatch(s, -1) {
+		if strings.HasPrefix(m[0], "$$") {
+			continue
+		}
+		add(m[1], envRefKindEnv, m[2] == "?")
+	}
+	if !isJsonnetExt(ext) {
+		return refs
+	}
+	for _, m := range jsonnetNativeRegexp.FindAllStringSubmatch(s, -1) {
+		// calls of the local function bound to the native function
+		re := regexp.MustCompile(`\b` + regexp.QuoteMeta(m[1]) + `\(\s*('[^']*'|"[^"]*")`)
+		for _, c := range re.FindAllStringSubmatch(s, -1) {
+			add(unquoteJsonnetString(c[1]), envRefKindEnv, m[2] == "must_env")
+		}
+	}
+	for _, m := range jsonnetNativeEnvRegexp.FindAllStringSubmatch(s, -1) {
+		add(unquoteJsonnetString(m[2]), envRefKindEnv, m[1] == "must_env")
+	}
+	for _, m := range jsonnetExtVarRegexp.FindAllStringSubmatch(s, -1) {
+		add(unquoteJsonnetString(m[1]), envRefKindExtVar, true)
+	}
+	return refs
+}
+
+// jsonnetImports returns the paths of files imported by the Jsonnet source.
+func jsonnetImports(src []byte, dir string) []string {
+	var paths []string
+	for _, m := range jsonnetImportRegexp.FindAllStringSubmatch(string(src), -1) {
+		p := unquoteJsonnetString(m[1])
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		paths = append(paths, p)
+	}
+	return paths
+}
+
+// envReferenceScanner scans the files and merges the references by the kind and the name.
+type envReferenceScanner struct {
+	delims  []string // template delimiters of the definition files
+	refs    map[string]*envReference
+	scanned map[string]bool
+}
+
+func newEnvReferenceScanner() *envReferenceScanner {
+	return &envReferenceScanner{
+		refs:    map[string]*envReference{},
+		scanned: map[string]bool{},
+	}
+}
+
+func (s *envReferenceScanner) scanFile(path string) error {
+	if path == "" || s.scanned[path] {
+		return nil
+	}
+	s.scanned[path] = true
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	ext := filepath.Ext(path)
+	if len(s.delims) == 2 && !isJsonnetExt(ext) {
+		if src, err = convertTemplateDelims(src, s.delims[0], s.delims[1]); err != nil {
+			return fmt.Errorf("failed to convert template delimiters in %s: %w", path, err)
+		}
+	}
+	for _, ref := range scanEnvReferences(src, ext) {
+		key := ref.Kind + ":" + ref.Name
+		r, ok := s.refs[key]
+		if !ok {
+			r = &envReference{Name: ref.Name, Kind: ref.Kind}
+			s.refs[key] = r
+		}
+		r.Required = r.Required || ref.Required
+		if !lo.Contains(r.Files, path) {
+			r.Files = append(r.Files, path)
+		}
+	}
+	if isJsonnetExt(ext) {
+		for _, p := range jsonnetImports(src, filepath.Dir(path)) {
+			if err := s.scanFile(p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *envReferenceScanner) references() []envReference {
+	refs := make([]envReference, 0, len(s.refs))
+	for _, r := range s.refs {
+		refs = append(refs, *r)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Kind != refs[j].Kind {
+			return refs[i].Kind < refs[j].Kind
+		}
+		return refs[i].Name < refs[j].Name
+	})
+	return refs
+}
+
+func formatEnvReferences(w io.Writer, refs []envReference, isSet func(envReference) bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tKIND\tREQUIRED\tSET\tFILES")
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+	for _, r := range refs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", r.Name, r.Kind, yesNo(r.Required), yesNo(isSet(r)), strings.Join(r.Files, ","))
+	}
+	return tw.Flush()
+}
+
+// dumpEnv lists the variables referenced by the config file and the definition files without rendering them.
+func dumpEnv(ctx context.Context, opts *CLIOptions, w io.Writer) error {
+	path := opts.ConfigFilePath
+	if isConfigURL(path) {
+		return fmt.Errorf("config dump-env does not support the config file loaded from URL: %s", path)
+	}
+	s := newEnvReferenceScanner()
+	if err := s.scanFile(path); err != nil {
+		return err
+	}
+
+	// the paths of the definition files are known only by loading the config file
+	loader := newConfigLoader(opts.ExtStr, opts.ExtCode)
+	loader.setTLA(opts.TLAStr, opts.TLACode)
+	loader.noAWS = true
+	loader.baseDir = opts.ConfigBaseDir
+	if err := loader.setJsonnetLibs(opts.JsonnetLib, path); err != nil {
+		return err
+	}
+	if conf, err := loader.Load(ctx, path, Version); err != nil {
+		Log("[WARNING] failed to load config file %s. the definition files are not scanned: %s", path, err)
+	} else {
+		s.delims = conf.TemplateDelimiters
+		for _, p := range []string{conf.TaskDefinitionPath, conf.ServiceDefinitionPath} {
+			if err := s.scanFile(p); err != nil {
+				return err
+			}
+		}
+	}
+
+	return formatEnvReferences(w, s.references(), func(r envReference) bool {
+		if r.Kind == envRefKindExtVar {
+			_, str := opts.ExtStr[r.Name]
+			_, code := opts.ExtCode[r.Name]
+			return str || code
+		}
+		_, ok := os.LookupEnv(r.Name)
+		return ok
+	})
+}
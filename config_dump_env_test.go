@@ -0,0 +1,134 @@
+package ecspresso_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+var scanEnvReferencesTests = []struct {
+	ext      string
+	src      string
+	expected []ecspresso.EnvReference
+}{
+	{
+		ext: ".json",
+		src: `{
+  "family": "{{ env "FAMILY" "app" }}",
+  "image": "{{must_env ` + "`IMAGE`" + `}}",
+  "cpu": "{{- env "CPU" -}}",
+  "memory": "{{ tfstate "aws_instance.foo.id" }}"
+}`,
+		expected: []ecspresso.EnvReference{
+			{Name: "FAMILY", Kind: "env"},
+			{Name: "IMAGE", Kind: "env", Required: true},
+			{Name: "CPU", Kind: "env"},
+		},
+	},
+	{
+		ext: ".yml",
+		src: `cluster: '{{ must_env "CLUSTER" }}'
+`,
+		expected: []ecspresso.EnvReference{
+			{Name: "CLUSTER", Kind: "env", Required: true},
+		},
+	},
+	{
+		ext: ".yml",
+		src: `cluster: ${CLUSTER:-default}
+service: ${SERVICE:?SERVICE is required}
+command: $${NOT_REFERENCED:-x}
+`,
+		expected: []ecspresso.EnvReference{
+			{Name: "CLUSTER", Kind: "env"},
+			{Name: "SERVICE", Kind: "env", Required: true},
+		},
+	},
+	{
+		ext: ".jsonnet",
+		src: `local env = std.native('env');
+local must = std.native("must_env");
+{
+  family: env('FAMILY', 'app'),
+  image: must("IMAGE"),
+  stage: std.extVar('stage'),
+  region: std.native('env')('AWS_REGION', 'ap-northeast-1'),
+}`,
+		expected: []ecspresso.EnvReference{
+			{Name: "FAMILY", Kind: "env"},
+			{Name: "IMAGE", Kind: "env", Required: true},
+			{Name: "AWS_REGION", Kind: "env"},
+			{Name: "stage", Kind: "ext-var", Required: true},
+		},
+	},
+	{
+		// jsonnet functions are not available in the other formats
+		ext:      ".json",
+		src:      `{"stage": "std.extVar('stage')"}`,
+		expected: nil,
+	},
+}
+
+func TestScanEnvReferences(t *testing.T) {
+	for _, tt := range scanEnvReferencesTests {
+		refs := ecspresso.ScanEnvReferences([]byte(tt.src), tt.ext)
+		if diff := cmp.Diff(tt.expected, refs); diff != "" {
+			t.Errorf("unexpected references in %s (-want +got):\n%s", tt.ext, diff)
+		}
+	}
+}
+
+func TestDumpEnv(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"ecspresso.yml": `region: ap-northeast-1
+cluster: '{{ env "ECSPRESSO_TEST_CLUSTER" "default" }}'
+service: test
+task_definition: td.jsonnet
+service_definition: sv.json
+`,
+		"td.jsonnet": `local lib = import 'lib.libsonnet';
+{
+  family: lib.family,
+  containerDefinitions: [],
+}`,
+		"lib.libsonnet": `local must_env = std.native('must_env');
+{
+  family: must_env('ECSPRESSO_TEST_FAMILY'),
+}`,
+		"sv.json": `{"desiredCount": {{ env "ECSPRESSO_TEST_DESIRED_COUNT" "1" }}}`,
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("ECSPRESSO_TEST_FAMILY", "test")
+
+	var b bytes.Buffer
+	opts := &ecspresso.CLIOptions{ConfigFilePath: filepath.Join(dir, "ecspresso.yml")}
+	if err := ecspresso.DumpEnv(context.Background(), opts, &b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	expected := [][]string{
+		{"NAME", "KIND", "REQUIRED", "SET", "FILES"},
+		{"ECSPRESSO_TEST_CLUSTER", "env", "no", "no", filepath.Join(dir, "ecspresso.yml")},
+		{"ECSPRESSO_TEST_DESIRED_COUNT", "env", "no", "no", filepath.Join(dir, "sv.json")},
+		{"ECSPRESSO_TEST_FAMILY", "env", "yes", "yes", filepath.Join(dir, "lib.libsonnet")},
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected output:\n%s", b.String())
+	}
+	for i, line := range lines {
+		if diff := cmp.Diff(expected[i], strings.Fields(line)); diff != "" {
+			t.Errorf("unexpected line %d (-want +got):\n%s", i, diff)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package ecspresso
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-jsonnet/formatter"
+)
+
+type ConfigFmtOption struct {
+	Files []string `arg:"" optional:"" help:"config or definition files to format. default is the config file"`
+	Write bool     `help:"write the formatted file back instead of STDOUT" default:"false"`
+	Check bool     `help:"exit with an error if the files are not formatted, without writing" default:"false"`
+}
+
+// formatConfigSource formats the source of the config or definition file in the canonical form by the extension.
+// Jsonnet files are formatted by the rules of jsonnet fmt, and comments are preserved.
+// YAML keys are sorted and indented by 2 spaces, and comments are preserved as far as the paths of them are kept.
+// JSON files are indented by 2 spaces in the order of keys.
+func formatConfigSource(src []byte, path string) ([]byte, error) {
+	switch ext := filepath.Ext(path); ext {
+	case jsonnetExt, ".libsonnet":
+		out, err := formatter.Format(path, string(src), formatter.DefaultOptions())
+		if err != nil {
+			return nil, err
+		}
+		return []byte(out), nil
+	case ymlExt, yamlExt:
+		cm := yaml.CommentMap{}
+		var v any
+		if err := yaml.UnmarshalWithOptions(src, &v, yaml.CommentToMap(cm)); err != nil {
+			return nil, err
+		}
+		return yaml.MarshalWithOptions(v, yaml.WithComment(cm), yaml.Indent(2), yaml.IndentSequence(true))
+	case jsonExt:
+		var b bytes.Buffer
+		if err := json.Indent(&b, bytes.TrimSpace(src), "", "  "); err != nil {
+			return nil, err
+		}
+		b.WriteByte('\n')
+		return b.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported file extension to format: %s", ext)
+	}
+}
+
+func formatConfigFiles(opts *CLIOptions, w io.Writer) error {
+	opt := opts.Config.Fmt
+	if opt.Write && opt.Check {
+		return ErrConflictOptions("write and check are exclusive")
+	}
+	files := opt.Files
+	if len(files) == 0 {
+		files = []string{opts.ConfigFilePath}
+	}
+	var unformatted []string
+	for _, path := range files {
+		if isConfigURL(path) {
+			return fmt.Errorf("config fmt does not support the file loaded from URL: %s", path)
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		formatted, err := formatConfigSource(src, path)
+		if err != nil {
+			return fmt.Errorf("failed to format %s: %w", path, err)
+		}
+		changed := !bytes.Equal(src, formatted)
+		switch {
+		case opt.Check:
+			if changed {
+				Log("[INFO] %s is not formatted", path)
+				unformatted = append(unformatted, path)
+			}
+		case opt.Write:
+			if !changed {
+				continue
+			}
+			st, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(path, formatted, st.Mode().Perm()); err != nil {
+				return fmt.Errorf("failed to write %s: %w", path, err)
+			}
+			Log("[INFO] %s is formatted", path)
+		default:
+			if _, err := w.Write(formatted); err != nil {
+				return err
+			}
+		}
+	}
+	if len(unformatted) > 0 {
+		return fmt.Errorf("%d file(s) are not formatted", len(unformatted))
+	}
+	return nil
+}
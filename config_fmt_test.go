@@ -0,0 +1,89 @@
+package ecspresso_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestFormatConfigSource(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		out, err := ecspresso.FormatConfigSource([]byte(`{"taskDefinition":"app","desiredCount":1,"tags":[1,2]}`), "ecs-service-def.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := `{
+  "taskDefinition": "app",
+  "desiredCount": 1,
+  "tags": [
+    1,
+    2
+  ]
+}
+`
+		if string(out) != expected {
+			t.Errorf("unexpected formatted json: %s", out)
+		}
+	})
+
+	t.Run("jsonnet", func(t *testing.T) {
+		src := "// config\nlocal region = \"ap-northeast-1\";\n{region: region,\n    cluster:   'default'}\n"
+		out, err := ecspresso.FormatConfigSource([]byte(src), "ecspresso.jsonnet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(out)
+		for _, expected := range []string{"// config\n", "local region = 'ap-northeast-1';", "  cluster: 'default',\n"} {
+			if !strings.Contains(s, expected) {
+				t.Errorf("%q is not contained in the formatted jsonnet: %s", expected, s)
+			}
+		}
+		assertFormatted(t, out, "ecspresso.jsonnet")
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		src := "service: app\n# the cluster name\ncluster: default\nplugins:\n- name: tfstate\n  config:\n      path: terraform.tfstate\nregion: ap-northeast-1\n"
+		out, err := ecspresso.FormatConfigSource([]byte(src), "ecspresso.yml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := string(out)
+		keys := []string{"cluster:", "plugins:", "region:", "service:"}
+		pos := -1
+		for _, k := range keys {
+			i := strings.Index(s, "\n"+k)
+			if !strings.HasPrefix(s, k) && i < 0 {
+				t.Fatalf("%s is not found: %s", k, s)
+			}
+			if i < pos {
+				t.Errorf("keys are not sorted: %s", s)
+			}
+			pos = i
+		}
+		if !strings.Contains(s, "the cluster name") {
+			t.Errorf("the comment is not preserved: %s", s)
+		}
+		if !strings.Contains(s, "\n  - name: tfstate\n    config:\n      path: terraform.tfstate\n") {
+			t.Errorf("unexpected indentation: %s", s)
+		}
+		assertFormatted(t, out, "ecspresso.yml")
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		if _, err := ecspresso.FormatConfigSource([]byte(`region = "ap-northeast-1"`), "ecspresso.toml"); err == nil {
+			t.Error("expected an error for toml")
+		}
+	})
+}
+
+func assertFormatted(t *testing.T, src []byte, path string) {
+	t.Helper()
+	out, err := ecspresso.FormatConfigSource(src, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != string(src) {
+		t.Errorf("formatting is not idempotent:\n%s\n%s", src, out)
+	}
+}
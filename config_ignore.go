@@ -0,0 +1,69 @@
+package ecspresso
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+const (
+	ignorePathService        = "service"
+	ignorePathTaskDefinition = "taskDefinition"
+)
+
+// ignorePathSegmentRegexp matches a segment of ignore.paths, a field name followed by array indexes.
+// e.g. "containerDefinitions[*]", "portMappings[0]"
+var ignorePathSegmentRegexp = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)((?:\[(?:\*|[0-9]+)\])*)$`)
+
+// compilePaths compiles ignore.paths into jq queries to delete the selected fields.
+// A path starts with "service." or "taskDefinition.", and the rest is the field names of
+// the definition joined by ".". "[*]" selects all the elements of an array, and "[N]" selects the Nth one.
+func (i *ConfigIgnore) compilePaths() error {
+	if i == nil {
+		return nil
+	}
+	i.queries = map[string][]string{}
+	for _, p := range i.Paths {
+		target, q, err := ignorePathQuery(p)
+		if err != nil {
+			return err
+		}
+		if _, err := gojq.Parse(q); err != nil {
+			return fmt.Errorf("invalid path %q: %w", p, err)
+		}
+		i.queries[target] = append(i.queries[target], q)
+	}
+	return nil
+}
+
+// ignorePathQuery returns the target definition and the jq query to delete the field selected by the path.
+func ignorePathQuery(p string) (string, string, error) {
+	target, rest, _ := strings.Cut(p, ".")
+	if target != ignorePathService && target != ignorePathTaskDefinition {
+		return "", "", fmt.Errorf("invalid path %q: must start with %s. or %s.", p, ignorePathService, ignorePathTaskDefinition)
+	}
+	if rest == "" {
+		return "", "", fmt.Errorf("invalid path %q: no field is selected", p)
+	}
+	var b strings.Builder
+	for _, seg := range strings.Split(rest, ".") {
+		m := ignorePathSegmentRegexp.FindStringSubmatch(seg)
+		if m == nil {
+			return "", "", fmt.Errorf("invalid path %q: invalid segment %q", p, seg)
+		}
+		b.WriteString("." + m[1])
+		idx := strings.ReplaceAll(m[2], "[*]", "[]?") // missing arrays are skipped
+		b.WriteString(idx)
+	}
+	return target, "del(" + b.String() + ")", nil
+}
+
+// pathQueries returns the jq queries to mask the fields of the target definition.
+func (i *ConfigIgnore) pathQueries(target string) []string {
+	if i == nil {
+		return nil
+	}
+	return i.queries[target]
+}
@@ -0,0 +1,60 @@
+package ecspresso
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/samber/lo"
+)
+
+// applyIncludes merges the config files listed in include into the config.
+// The values in the config take precedence over the included ones, and the later included ones
+// take precedence over the earlier ones. Plugins are concatenated in the order of the includes.
+// chain is the absolute paths of the including config files to detect circular includes.
+func (l *configLoader) applyIncludes(conf *Config, chain []string) error {
+	dir := filepath.Dir(conf.path)
+	for i := len(conf.Include) - 1; i >= 0; i-- {
+		p := conf.Include[i]
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(dir, p)
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		next := append(append([]string{}, chain...), abs)
+		if lo.Contains(chain, abs) {
+			return fmt.Errorf("circular include: %s", strings.Join(next, " -> "))
+		}
+		inc := &Config{path: p}
+		if err := l.readConfigFile(p, inc); err != nil {
+			return fmt.Errorf("failed to load included config file %s: %w", p, err)
+		}
+		if err := l.applyIncludes(inc, next); err != nil {
+			return err
+		}
+		Log("[DEBUG] config file %s is included", p)
+		conf.mergeIncluded(inc, filepath.Dir(abs))
+	}
+	conf.Include = nil
+	return nil
+}
+
+// mergeIncluded merges the included config under the config.
+// Relative definition paths in the included config are resolved by its directory.
+func (c *Config) mergeIncluded(inc *Config, incDir string) {
+	c.mergeDefaults(inc, incDir)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(incDir, p)
+	}
+	if c.ServiceDefinitionPath == "" {
+		c.ServiceDefinitionPath = resolve(inc.ServiceDefinitionPath)
+	}
+	if c.TaskDefinitionPath == "" {
+		c.TaskDefinitionPath = resolve(inc.TaskDefinitionPath)
+	}
+}
@@ -0,0 +1,217 @@
+package ecspresso
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+type ConfigOption struct {
+	Migrate *ConfigMigrateOption `cmd:"" help:"rewrite deprecated fields in the config file"`
+	DumpEnv *ConfigDumpEnvOption `cmd:"" name:"dump-env" help:"list environment variables referenced by the config file and the definition files"`
+	Fmt     *ConfigFmtOption     `cmd:"" help:"format the config file or definition files in the canonical form"`
+	Schema  *ConfigSchemaOption  `cmd:"" hidden:"" help:"output JSON Schema of the config file"`
+
+	command string `kong:"-"` // subcommand of config
+}
+
+type ConfigMigrateOption struct {
+	DryRun bool `help:"output the migrated config file to STDOUT instead of writing it back" default:"false"`
+}
+
+type configMigration struct {
+	key     string
+	message string
+}
+
+// configMigrations are deprecated top-level fields of the config file.
+var configMigrations = []configMigration{
+	{
+		key:     "filter_command",
+		message: "filter_command is removed. use --filter-command or ECSPRESSO_FILTER_COMMAND environment variable instead",
+	},
+}
+
+// topLevelKeyRegexp returns a regexp matching the line which starts the key.
+// For JSON and Jsonnet, whether the key is at the top level is checked by jsonDepthScanner.
+func topLevelKeyRegexp(ext, key string) *regexp.Regexp {
+	k := regexp.QuoteMeta(key)
+	switch ext {
+	case jsonExt:
+		return regexp.MustCompile(`^\s*"` + k + `"\s*:`)
+	case jsonnetExt:
+		return regexp.MustCompile(`^\s*(` + k + `|"` + k + `"|'` + k + `')\s*:`)
+	case tomlExt:
+		return regexp.MustCompile(`^(` + k + `|"` + k + `"|'` + k + `')\s*=`)
+	default: // yaml
+		return regexp.MustCompile(`^` + k + `\s*:`)
+	}
+}
+
+// removeTopLevelKey removes the lines of the top-level key.
+// Comments and formatting of other lines are preserved.
+func removeTopLevelKey(lines []string, ext, key string) ([]string, bool) {
+	re := topLevelKeyRegexp(ext, key)
+	var removed, inTable bool
+	var scanner jsonDepthScanner
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		if ext == tomlExt && strings.HasPrefix(lines[i], "[") {
+			// keys after a table header are not top-level
+			inTable = true
+		}
+		topLevel := !inTable
+		if ext == jsonExt || ext == jsonnetExt {
+			// keys in the outermost object are top-level
+			topLevel = scanner.inTopLevelObject()
+			scanner.scan(lines[i])
+		}
+		if !topLevel || !re.MatchString(lines[i]) {
+			out = append(out, lines[i])
+			continue
+		}
+		removed = true
+		if ext == ymlExt || ext == yamlExt {
+			// skip a block value of the key
+			for i+1 < len(lines) && (strings.HasPrefix(lines[i+1], " ") || strings.HasPrefix(lines[i+1], "\t")) {
+				i++
+			}
+			continue
+		}
+		if ext == jsonExt {
+			// JSON does not allow a trailing comma
+			if next := nextNonEmptyLine(lines[i+1:]); strings.HasPrefix(next, "}") {
+				for j := len(out) - 1; j >= 0; j-- {
+					if strings.TrimSpace(out[j]) != "" {
+						out[j] = strings.TrimSuffix(strings.TrimRight(out[j], " \t"), ",")
+						break
+					}
+				}
+			}
+		}
+	}
+	return out, removed
+}
+
+// jsonDepthScanner tracks the nesting depth of objects and arrays in JSON or Jsonnet source line by line.
+// Brackets in strings, comments and text blocks are not counted.
+type jsonDepthScanner struct {
+	depth        int
+	blockComment bool
+	textBlock    bool
+	local        bool // in a top-level local of Jsonnet
+}
+
+// inTopLevelObject reports whether the next line is in the outermost object.
+func (s *jsonDepthScanner) inTopLevelObject() bool {
+	return s.depth == 1 && !s.blockComment && !s.textBlock && !s.local
+}
+
+func (s *jsonDepthScanner) scan(line string) {
+	if s.textBlock {
+		t := strings.TrimSpace(line)
+		if !strings.HasPrefix(t, "|||") {
+			return
+		}
+		s.textBlock = false
+		line = strings.TrimPrefix(t, "|||")
+	}
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case s.blockComment:
+			if strings.HasPrefix(line[i:], "*/") {
+				s.blockComment = false
+				i++
+			}
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(line[i:], "|||"):
+			s.textBlock = true
+			return
+		case strings.HasPrefix(line[i:], "//") || c == '#':
+			return
+		case strings.HasPrefix(line[i:], "/*"):
+			s.blockComment = true
+			i++
+		case s.depth == 0 && strings.HasPrefix(line[i:], "local") && (i+5 == len(line) || !isIdentByte(line[i+5])):
+			s.local = true
+			i += 4
+		case s.depth == 0 && c == ';':
+			s.local = false
+		case c == '{' || c == '[':
+			s.depth++
+		case c == '}' || c == ']':
+			s.depth--
+		}
+	}
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func nextNonEmptyLine(lines []string) string {
+	for _, l := range lines {
+		if s := strings.TrimSpace(l); s != "" {
+			return s
+		}
+	}
+	return ""
+}
+
+// migrateConfig rewrites deprecated fields in the config file source.
+// It returns the migrated source and messages of the applied migrations.
+func migrateConfig(src []byte, ext string) ([]byte, []string) {
+	lines := strings.Split(string(src), "\n")
+	var msgs []string
+	for _, m := range configMigrations {
+		var removed bool
+		lines, removed = removeTopLevelKey(lines, ext, m.key)
+		if removed {
+			msgs = append(msgs, m.message)
+		}
+	}
+	return []byte(strings.Join(lines, "\n")), msgs
+}
+
+func migrateConfigFile(path string, opt ConfigMigrateOption, w io.Writer) error {
+	if isConfigURL(path) {
+		return fmt.Errorf("config migrate does not support the config file loaded from URL: %s", path)
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	migrated, msgs := migrateConfig(src, filepath.Ext(path))
+	for _, msg := range msgs {
+		Log("[INFO] %s", msg)
+	}
+	if opt.DryRun {
+		_, err := w.Write(migrated)
+		return err
+	}
+	if len(msgs) == 0 {
+		Log("[INFO] %s has no deprecated fields", path)
+		return nil
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, migrated, st.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	Log("[INFO] %s is migrated", path)
+	return nil
+}
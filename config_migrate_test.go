@@ -0,0 +1,197 @@
+package ecspresso_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+var migrateConfigTests = []struct {
+	ext      string
+	src      string
+	expected string
+	migrated bool
+}{
+	{
+		ext: ".yml",
+		src: `# ecspresso config
+region: ap-northeast-1
+filter_command: peco
+cluster: default # cluster name
+`,
+		expected: `# ecspresso config
+region: ap-northeast-1
+cluster: default # cluster name
+`,
+		migrated: true,
+	},
+	{
+		ext: ".yaml",
+		src: `region: ap-northeast-1
+filter_command: >-
+  fzf
+  --height 40%
+cluster: default
+`,
+		expected: `region: ap-northeast-1
+cluster: default
+`,
+		migrated: true,
+	},
+	{
+		ext: ".json",
+		src: `{
+  "region": "ap-northeast-1",
+  "cluster": "default",
+  "filter_command": "peco"
+}
+`,
+		expected: `{
+  "region": "ap-northeast-1",
+  "cluster": "default"
+}
+`,
+		migrated: true,
+	},
+	{
+		ext: ".jsonnet",
+		src: `local env = std.extVar('env');
+{
+  region: 'ap-northeast-1',
+  filter_command: 'peco',
+  cluster: env,
+}
+`,
+		expected: `local env = std.extVar('env');
+{
+  region: 'ap-northeast-1',
+  cluster: env,
+}
+`,
+		migrated: true,
+	},
+	{
+		ext: ".toml",
+		src: `region = "ap-northeast-1"
+filter_command = "peco"
+cluster = "default"
+
+[ignore]
+filter_command = "not a top-level key"
+`,
+		expected: `region = "ap-northeast-1"
+cluster = "default"
+
+[ignore]
+filter_command = "not a top-level key"
+`,
+		migrated: true,
+	},
+	{
+		ext: ".json",
+		src: `{
+  "region": "ap-northeast-1",
+  "plugins": [
+    {
+      "name": "custom",
+      "config": {
+        "filter_command": "not a top-level key"
+      }
+    }
+  ],
+  "filter_command": "peco"
+}
+`,
+		expected: `{
+  "region": "ap-northeast-1",
+  "plugins": [
+    {
+      "name": "custom",
+      "config": {
+        "filter_command": "not a top-level key"
+      }
+    }
+  ]
+}
+`,
+		migrated: true,
+	},
+	{
+		ext: ".jsonnet",
+		src: `local plugin = {
+  filter_command: 'not a top-level key',
+};
+{
+  region: 'ap-northeast-1', // {
+  note: |||
+    filter_command: not a key in a text block {
+  |||,
+  plugins: [
+    {
+      name: 'custom',
+      config: { filter_command: 'not a top-level key' },
+    },
+  ],
+  filter_command: 'peco',
+}
+`,
+		expected: `local plugin = {
+  filter_command: 'not a top-level key',
+};
+{
+  region: 'ap-northeast-1', // {
+  note: |||
+    filter_command: not a key in a text block {
+  |||,
+  plugins: [
+    {
+      name: 'custom',
+      config: { filter_command: 'not a top-level key' },
+    },
+  ],
+}
+`,
+		migrated: true,
+	},
+	{
+		ext: ".jsonnet",
+		src: `{
+  plugins: [
+    {
+      filter_command: 'not a top-level key',
+    },
+  ],
+}
+`,
+		expected: `{
+  plugins: [
+    {
+      filter_command: 'not a top-level key',
+    },
+  ],
+}
+`,
+	},
+	{
+		ext: ".yml",
+		src: `region: ap-northeast-1
+cluster: default
+`,
+		expected: `region: ap-northeast-1
+cluster: default
+`,
+	},
+}
+
+func TestMigrateConfig(t *testing.T) {
+	for _, tt := range migrateConfigTests {
+		b, msgs := ecspresso.MigrateConfig([]byte(tt.src), tt.ext)
+		if diff := cmp.Diff(tt.expected, string(b)); diff != "" {
+			t.Errorf("unexpected migrated config %s: %s", tt.ext, diff)
+		}
+		if migrated := len(msgs) > 0; migrated != tt.migrated {
+			t.Errorf("unexpected migrated %v: %v", tt.migrated, msgs)
+		}
+	}
+}
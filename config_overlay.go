@@ -0,0 +1,103 @@
+package ecspresso
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// readConfigFileWithOverlay reads the Jsonnet config file with the overlay merged by `+` of Jsonnet.
+// The config and the overlay are evaluated together as `(import config) + (import overlay)`,
+// so the overlay can use `+:` to merge the nested objects, `super` to refer the values of the config,
+// and can override the hidden fields referred by `self` in the config.
+func (l *configLoader) readConfigFileWithOverlay(path string, conf *Config) error {
+	importPaths := make([]string, 0, 2)
+	for _, p := range []string{path, l.overlay} {
+		if ext := filepath.Ext(p); ext != jsonnetExt && ext != jsonExt {
+			return fmt.Errorf("--overlay is supported only for Jsonnet or JSON files: %s", p)
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		b, err := json.Marshal(abs)
+		if err != nil {
+			return err
+		}
+		importPaths = append(importPaths, string(b))
+	}
+	// the config is evaluated alone only to check the type conflicts
+	base, err := l.VM.EvaluateFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate jsonnet file: %w", err)
+	}
+	snippet := fmt.Sprintf("(import %s) + (import %s)\n", importPaths[0], importPaths[1])
+	merged, err := l.VM.EvaluateAnonymousSnippet(path, snippet)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate overlay %s: %w", l.overlay, err)
+	}
+	if err := overlayTypeConflict(base, merged); err != nil {
+		return fmt.Errorf("failed to apply overlay %s: %w", l.overlay, err)
+	}
+	Log("[DEBUG] overlay %s is applied", l.overlay)
+	return l.readConfigJSON(merged, conf, path)
+}
+
+// overlayTypeConflict returns an error when the overlay changes the type of a value in the base,
+// e.g. an object to a string. null in the base or the merged values is not a conflict.
+func overlayTypeConflict(base, merged string) error {
+	var b, m any
+	if err := json.Unmarshal([]byte(base), &b); err != nil {
+		return err
+	}
+	if err := json.Unmarshal([]byte(merged), &m); err != nil {
+		return err
+	}
+	return typeConflict(nil, b, m)
+}
+
+func typeConflict(path []string, b, m any) error {
+	if b == nil || m == nil {
+		return nil
+	}
+	bt, mt := jsonTypeName(b), jsonTypeName(m)
+	if bt != mt {
+		return fmt.Errorf("type conflict at %s: %s in the config, %s in the overlay", jsonPathString(path), bt, mt)
+	}
+	bm, ok := b.(map[string]any)
+	if !ok {
+		return nil
+	}
+	mm := m.(map[string]any)
+	for k, bv := range bm {
+		if err := typeConflict(append(path, k), bv, mm[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func jsonPathString(path []string) string {
+	if len(path) == 0 {
+		return "(root)"
+	}
+	return strings.Join(path, ".")
+}
@@ -0,0 +1,113 @@
+package ecspresso
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/kayac/ecspresso/v2/appspec"
+	"github.com/samber/lo"
+)
+
+type ConfigSchemaOption struct{}
+
+const jsonSchemaDraft07 = "http://json-schema.org/draft-07/schema#"
+
+var (
+	durationType = reflect.TypeOf(Duration{})
+	appSpecType  = reflect.TypeOf(appspec.AppSpec{})
+	pluginType   = reflect.TypeOf(ConfigPlugin{})
+)
+
+// JSONSchema returns the JSON Schema (draft-07) of the config file, reflected by the json tags of Config.
+// The keys are sorted, so the output is stable.
+func (c *Config) JSONSchema() ([]byte, error) {
+	schema := jsonSchemaOf(reflect.TypeOf(Config{}))
+	schema["$schema"] = jsonSchemaDraft07
+	schema["title"] = "ecspresso config"
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JSON Schema: %w", err)
+	}
+	return append(b, '\n'), nil
+}
+
+// jsonSchemaOf returns the schema of the type. encoding/json sorts the keys of maps on marshaling.
+func jsonSchemaOf(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case durationType:
+		// "10m" or nanoseconds
+		return map[string]any{"type": []string{"string", "number"}}
+	case appSpecType:
+		// defined by CodeDeploy
+		return map[string]any{"type": "object"}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": jsonSchemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": jsonSchemaOf(t.Elem())}
+	case reflect.Struct:
+		return jsonSchemaOfStruct(t)
+	default:
+		return map[string]any{}
+	}
+}
+
+func jsonSchemaOfStruct(t reflect.Type) map[string]any {
+	props := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		s := jsonSchemaOf(f.Type)
+		if t == pluginType && name == "name" {
+			s["enum"] = knownPluginNames()
+		}
+		props[name] = s
+	}
+	return map[string]any{
+		"type":                 "object",
+		"properties":           props,
+		"additionalProperties": false,
+	}
+}
+
+// knownPluginNames returns the sorted names of the plugins, including the default plugins.
+func knownPluginNames() []string {
+	names := lo.Uniq(append(append([]string{}, defaultPluginNames...), pluginNames...))
+	sort.Strings(names)
+	return names
+}
+
+func outputConfigSchema(w io.Writer) error {
+	b, err := (&Config{}).JSONSchema()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
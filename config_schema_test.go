@@ -0,0 +1,167 @@
+package ecspresso_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+// validateJSONSchema validates the value by the subset of JSON Schema generated by Config.JSONSchema.
+func validateJSONSchema(schema map[string]any, v any, path string) error {
+	if enum, ok := schema["enum"].([]any); ok {
+		found := false
+		for _, e := range enum {
+			if e == v {
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: %v is not in %v", path, v, enum)
+		}
+	}
+	var types []any
+	switch t := schema["type"].(type) {
+	case string:
+		types = []any{t}
+	case []any:
+		types = t
+	}
+	if len(types) > 0 {
+		matched := false
+		for _, t := range types {
+			switch t {
+			case "string":
+				_, matched = v.(string)
+			case "boolean":
+				_, matched = v.(bool)
+			case "number":
+				_, matched = v.(float64)
+			case "integer":
+				f, ok := v.(float64)
+				matched = ok && f == float64(int64(f))
+			case "array":
+				_, matched = v.([]any)
+			case "object":
+				_, matched = v.(map[string]any)
+			}
+			if matched {
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s: %v is not %v", path, v, types)
+		}
+	}
+	switch vv := v.(type) {
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range vv {
+				if err := validateJSONSchema(items, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case map[string]any:
+		props, _ := schema["properties"].(map[string]any)
+		for k, item := range vv {
+			if p, ok := props[k].(map[string]any); ok {
+				if err := validateJSONSchema(p, item, path+"."+k); err != nil {
+					return err
+				}
+				continue
+			}
+			switch ap := schema["additionalProperties"].(type) {
+			case bool:
+				if !ap {
+					return fmt.Errorf("%s: unknown property %s", path, k)
+				}
+			case map[string]any:
+				if err := validateJSONSchema(ap, item, path+"."+k); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func TestConfigJSONSchema(t *testing.T) {
+	b, err := (&ecspresso.Config{}).JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := (&ecspresso.Config{}).JSONSchema()
+	if !bytes.Equal(b, again) {
+		t.Error("JSON Schema must be stable")
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(b, &schema); err != nil {
+		t.Fatal(err)
+	}
+	if schema["$schema"] != "http://json-schema.org/draft-07/schema#" {
+		t.Errorf("unexpected $schema: %v", schema["$schema"])
+	}
+
+	props := schema["properties"].(map[string]any)
+	var names []string
+	for name := range props {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range []string{"codedeploy", "ignore", "plugins", "region", "timeout"} {
+		if _, ok := props[name]; !ok {
+			t.Errorf("%s is not in the properties: %v", name, names)
+		}
+	}
+	plugin := props["plugins"].(map[string]any)["items"].(map[string]any)["properties"].(map[string]any)
+	enum := plugin["name"].(map[string]any)["enum"].([]any)
+	if fmt.Sprint(enum) != "[cloudformation secretsmanager ssm tfstate]" {
+		t.Errorf("unexpected plugin names: %v", enum)
+	}
+
+	valid := `{
+  "required_version": ">= 2.0.0",
+  "region": "ap-northeast-1",
+  "cluster": "default",
+  "service": "myservice",
+  "service_definition": "ecs-service-def.json",
+  "task_definition": "ecs-task-def.json",
+  "timeout": "10m0s",
+  "timeouts": {"deploy": "5m", "wait": 600000000000},
+  "plugins": [
+    {"name": "tfstate", "config": {"path": "terraform.tfstate"}, "func_prefix": "network_"},
+    {"name": "ssm"}
+  ],
+  "codedeploy": {"application_name": "app", "deployment_group_name": "dg"},
+  "ignore": {"tags": ["ecspresso:ignore"], "paths": [".containerDefinitions[].image"]},
+  "appspec": {"Hooks": [{"BeforeInstall": "LambdaFunctionToValidateBeforeInstall"}]},
+  "aws": {"retry_max_attempts": 10},
+  "tags": {"Env": "production"}
+}`
+	var doc any
+	if err := json.Unmarshal([]byte(valid), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if err := validateJSONSchema(schema, doc, "$"); err != nil {
+		t.Errorf("the valid config is not valid: %s", err)
+	}
+
+	for _, invalid := range []string{
+		`{"region": 1}`,
+		`{"plugins": [{"name": "unknown"}]}`,
+		`{"unknown_field": true}`,
+		`{"codedeploy": {"application": "app"}}`,
+	} {
+		var doc any
+		if err := json.Unmarshal([]byte(invalid), &doc); err != nil {
+			t.Fatal(err)
+		}
+		if err := validateJSONSchema(schema, doc, "$"); err == nil {
+			t.Errorf("%s must be invalid", invalid)
+		}
+	}
+}
@@ -2,6 +2,10 @@ package ecspresso_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -11,6 +15,7 @@ import (
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/kayac/ecspresso/v2"
+	"github.com/samber/lo"
 )
 
 func TestLoadServiceDefinition(t *testing.T) {
@@ -81,13 +86,104 @@ func TestLoadConfigWithPluginDuplicate(t *testing.T) {
 }
 
 func TestLoadConfigWithPlugin(t *testing.T) {
-	for _, ext := range []string{".yml", ".yaml", ".json", ".jsonnet"} {
+	for _, ext := range []string{".yml", ".yaml", ".json", ".jsonnet", ".toml"} {
 		t.Run("tests/ecspresso"+ext, func(t *testing.T) {
 			testLoadConfigWithPlugin(t, "tests/ecspresso"+ext)
 		})
 	}
 }
 
+func TestLoadConfigFormats(t *testing.T) {
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+	ctx := context.Background()
+	var expected *ecspresso.Config
+	for _, ext := range []string{".yml", ".json", ".toml"} {
+		path := "tests/ecspresso" + ext
+		conf, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, path, "")
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		if expected == nil {
+			expected = conf
+			continue
+		}
+		if diff := cmp.Diff(expected, conf, cmpopts.IgnoreUnexported(ecspresso.Config{})); diff != "" {
+			t.Errorf("%s is not equal to tests/ecspresso.yml (-want +got):\n%s", path, diff)
+		}
+	}
+}
+
+func TestLoadConfigWithPluginNames(t *testing.T) {
+	ctx := context.Background()
+	conf, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, "tests/config_plugin_names.yml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Cluster != "app" {
+		t.Errorf("unexpected cluster: %s", conf.Cluster)
+	}
+	if conf.Service != "subnet-07ac54af5e41a4fc4-svc" {
+		t.Errorf("unexpected service: %s", conf.Service)
+	}
+}
+
+func TestLoadConfigWithPluginPrefix(t *testing.T) {
+	ctx := context.Background()
+	conf, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, "tests/config_plugin_prefix.yml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Cluster != "app" {
+		t.Errorf("unexpected cluster: %s", conf.Cluster)
+	}
+	if conf.Service != "subnet-07ac54af5e41a4fc4-svc" {
+		t.Errorf("unexpected service: %s", conf.Service)
+	}
+}
+
+func TestDeferredFuncCall(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		args     []any
+		expected string
+	}{
+		{"tfstate", []any{`aws_ecs_cluster.main.name`}, "{{ tfstate `aws_ecs_cluster.main.name` }}"},
+		{"tfstatef", []any{`aws_ecs_service.main["%s"].name`, "app"}, "{{ tfstatef `aws_ecs_service.main[\"%s\"].name` `app` }}"},
+		{"ssm", []any{"/path/to/list", 1}, "{{ ssm `/path/to/list` 1 }}"},
+		{"ssm", []any{"with`backquote"}, `{{ ssm "with` + "`" + `backquote" }}`},
+	} {
+		if s := ecspresso.DeferredFuncCall(tt.name, tt.args); s != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, s)
+		}
+	}
+}
+
+func TestPluginInfos(t *testing.T) {
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/config_multiple_plugins.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos := app.Config().PluginInfos()
+	var names []string
+	for _, info := range infos {
+		names = append(names, fmt.Sprintf("%s:%v:%s", info.Name, info.Default, info.FuncPrefix))
+	}
+	if diff := cmp.Diff([]string{"ssm:true:", "secretsmanager:true:", "tfstate:false:bucket_", "tfstate:false:"}, names); diff != "" {
+		t.Errorf("unexpected plugins: %s", diff)
+	}
+	if !lo.Contains(infos[0].TemplateFuncs, "ssm") {
+		t.Errorf("ssm template function is not listed: %v", infos[0].TemplateFuncs)
+	}
+	if !lo.Contains(infos[1].JsonnetNativeFuncs, "secretsmanager_arn") {
+		t.Errorf("secretsmanager_arn native function is not listed: %v", infos[1].JsonnetNativeFuncs)
+	}
+	if !lo.Contains(infos[2].TemplateFuncs, "bucket_tfstate") {
+		t.Errorf("prefixed tfstate template function is not listed: %v", infos[2].TemplateFuncs)
+	}
+}
+
 func testLoadConfigWithPlugin(t *testing.T, path string) {
 	t.Setenv("TAG", "testing")
 	t.Setenv("JSON", `{"foo":"bar"}`)
@@ -199,6 +295,31 @@ func TestRestrictConfigWithRequiredVersion(t *testing.T) {
 	}
 }
 
+func TestConfigWithRequiredVersionStrict(t *testing.T) {
+	ctx := context.Background()
+	conf := ecspresso.NewDefaultConfig()
+	conf.RequiredVersion = ">= v1"
+	if err := conf.Restrict(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []string{"current", "", "(devel)"} {
+		if err := conf.ValidateVersion(v); err != nil {
+			t.Errorf("%q unexpected error without strict: %s", v, err)
+		}
+		if err := conf.ValidateVersionStrict(v); err == nil {
+			t.Errorf("%q expected error with strict, but got nil", v)
+		}
+	}
+	if err := conf.ValidateVersionStrict("v2.3.0"); err != nil {
+		t.Errorf("unexpected error with strict: %s", err)
+	}
+
+	// strict is effective only when required_version is set
+	if err := ecspresso.NewDefaultConfig().ValidateVersionStrict("current"); err != nil {
+		t.Errorf("unexpected error without required_version: %s", err)
+	}
+}
+
 func TestConfigWithRequiredVersionUnsatisfied(t *testing.T) {
 	cases := []struct {
 		RequiredVersion string
@@ -276,6 +397,127 @@ func TestConfigWithInvalidRequiredVersion(t *testing.T) {
 	}
 }
 
+func TestLoadConfigWithTLA(t *testing.T) {
+	ctx := context.Background()
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	loader.SetTLA(map[string]string{"env": "staging"}, map[string]string{"replicas": "2"})
+	conf, err := loader.Load(ctx, "tests/ecspresso-tla.jsonnet", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Cluster != "staging" || conf.Service != "test-staging" {
+		t.Errorf("unexpected cluster and service %s %s", conf.Cluster, conf.Service)
+	}
+	if conf.Timeout.Duration != 10*time.Minute {
+		t.Errorf("unexpected timeout %s", conf.Timeout.Duration)
+	}
+
+	// a required argument is missing
+	if _, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, "tests/ecspresso-tla.jsonnet", ""); err == nil {
+		t.Error("expected error without top-level arguments")
+	}
+	// top-level arguments are given to the file which is not a function
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+	if _, err := loader.Load(ctx, "tests/ecspresso.jsonnet", ""); err == nil {
+		t.Error("expected error for top-level arguments to the file which is not a function")
+	} else if !strings.Contains(err.Error(), "does not evaluate to a function") {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, err := loader.Load(ctx, "tests/test.yaml", ""); err == nil {
+		t.Error("expected error for top-level arguments to the YAML config")
+	}
+}
+
+func TestLoadConfigStrict(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+	cases := []struct {
+		path     string
+		contains string
+	}{
+		{path: "tests/strict-config/typo.yml", contains: "service_definision"},
+		{path: "tests/strict-config/nested-typo.yml", contains: "deployment_group"},
+		{path: "tests/strict-config/typo.jsonnet", contains: "service_definision"},
+	}
+	for _, c := range cases {
+		t.Run(c.path, func(t *testing.T) {
+			// unknown fields are warned only by default
+			if _, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, c.path, ""); err != nil {
+				t.Fatalf("unexpected error without strict: %s", err)
+			}
+			loader := ecspresso.NewConfigLoader(nil, nil)
+			loader.SetStrictConfig(true)
+			_, err := loader.Load(ctx, c.path, "")
+			if err == nil {
+				t.Fatal("expected error for the unknown field with strict")
+			}
+			if !strings.Contains(err.Error(), c.contains) {
+				t.Errorf("the error must report the field %s: %s", c.contains, err)
+			}
+		})
+	}
+
+	// the unknown field in YAML is reported with the line
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	loader.SetStrictConfig(true)
+	if _, err := loader.Load(ctx, "tests/strict-config/typo.yml", ""); err == nil || !strings.Contains(err.Error(), "[4:1]") {
+		t.Errorf("the error must report the line: %v", err)
+	}
+
+	for _, path := range []string{"tests/test.yaml", "tests/ecspresso.jsonnet", "tests/strict-config/deprecated.yml"} {
+		loader := ecspresso.NewConfigLoader(nil, nil)
+		loader.SetStrictConfig(true)
+		if _, err := loader.Load(ctx, path, ""); err != nil {
+			t.Errorf("unexpected error for the valid config %s: %s", path, err)
+		}
+	}
+}
+
+func TestLoadConfigWithJsonnetLib(t *testing.T) {
+	ctx := context.Background()
+	path := "tests/jsonnet-lib/config/ecspresso.jsonnet"
+
+	// naming.libsonnet is not found without the library path
+	if _, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, path, ""); err == nil {
+		t.Error("expected error without the library path")
+	}
+
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	// relative to the directory of the config file
+	if err := loader.SetJsonnetLibs([]string{"../vendor"}, path); err != nil {
+		t.Fatal(err)
+	}
+	conf, err := loader.Load(ctx, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// local.libsonnet is imported relative to the config file
+	if conf.Region != "ap-northeast-1" || conf.Service != "test-staging" {
+		t.Errorf("unexpected region and service %s %s", conf.Region, conf.Service)
+	}
+
+	if err := ecspresso.NewConfigLoader(nil, nil).SetJsonnetLibs([]string{"not-found"}, path); err == nil {
+		t.Error("expected error for the library path not found")
+	}
+}
+
+func TestLoadConfigTLAAndExtVar(t *testing.T) {
+	tla := ecspresso.NewConfigLoader(nil, nil)
+	tla.SetTLA(map[string]string{"env": "staging"}, map[string]string{"replicas": "2"})
+	byTLA, err := tla.VM.EvaluateFile("tests/ecspresso-tla.jsonnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ext := ecspresso.NewConfigLoader(map[string]string{"env": "staging"}, map[string]string{"replicas": "2"})
+	byExtVar, err := ext.VM.EvaluateFile("tests/ecspresso-extvar.jsonnet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(byExtVar, byTLA); diff != "" {
+		t.Errorf("unexpected difference between ext vars and top-level arguments: %s", diff)
+	}
+}
+
 func TestLoadConfigWithoutTimeout(t *testing.T) {
 	t.Setenv("AWS_REGION", "ap-northeast-2")
 
@@ -404,3 +646,534 @@ func TestConfigIgnore(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadConfigWithProjectDefaults(t *testing.T) {
+	ctx := context.Background()
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	conf, err := loader.Load(ctx, "tests/project/app/ecspresso.yml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Region != "ap-northeast-1" {
+		t.Errorf("expected region from the project file, but %s", conf.Region)
+	}
+	if conf.Cluster != "app-cluster" {
+		t.Errorf("expected cluster from the config file, but %s", conf.Cluster)
+	}
+	if conf.Service != "app" {
+		t.Errorf("expected service from the config file, but %s", conf.Service)
+	}
+	if conf.Timeout.Duration != 5*time.Minute {
+		t.Errorf("expected timeout from the project file, but %s", conf.Timeout)
+	}
+	if conf.Ignore == nil || len(conf.Ignore.Tags) != 1 {
+		t.Errorf("expected ignore from the project file, but %#v", conf.Ignore)
+	}
+	if conf.Prune == nil || conf.Prune.Keeps != 5 {
+		t.Errorf("expected prune from the project file, but %#v", conf.Prune)
+	}
+}
+
+func TestLoadConfigWithoutProjectDefaults(t *testing.T) {
+	ctx := context.Background()
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	loader.SetNoProjectConfig(true)
+	conf, err := loader.Load(ctx, "tests/project/app/ecspresso.yml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Timeout.Duration == 5*time.Minute || conf.Prune != nil {
+		t.Errorf("the project file must not be applied: %s %#v", conf.Timeout, conf.Prune)
+	}
+}
+
+func TestFindProjectConfigFileStopsAtRepositoryRoot(t *testing.T) {
+	root := t.TempDir()
+	repo := filepath.Join(root, "repo")
+	app := filepath.Join(repo, "app")
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(app, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// outside of the repository
+	if err := os.WriteFile(filepath.Join(root, ".ecspresso.yml"), []byte("region: us-east-1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := ecspresso.FindProjectConfigFile(filepath.Join(app, "ecspresso.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "" {
+		t.Errorf("the project file outside of the repository must not be found: %s", path)
+	}
+
+	inRepo := filepath.Join(repo, ".ecspresso.yml")
+	if err := os.WriteFile(inRepo, []byte("region: us-east-1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path, err = ecspresso.FindProjectConfigFile(filepath.Join(app, "ecspresso.yml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != inRepo {
+		t.Errorf("the project file at the repository root must be found: %s", path)
+	}
+}
+
+func TestLoadConfigWithOverrides(t *testing.T) {
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+	ctx := context.Background()
+	region, cluster, service := "us-west-2", "override-cluster", "override-service"
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	loader.SetOverrides(&ecspresso.CLIOptions{Region: &region, Cluster: &cluster, Service: &service})
+	conf, err := loader.Load(ctx, "tests/ecspresso.yml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Region != region || conf.Cluster != cluster || conf.Service != service {
+		t.Errorf("unexpected overridden config: %s %s %s", conf.Region, conf.Cluster, conf.Service)
+	}
+	if r := conf.AWSRegion(); r != region {
+		t.Errorf("the AWS config must be loaded for the overridden region, but %s", r)
+	}
+}
+
+func TestConfigAWSRetryer(t *testing.T) {
+	ctx := context.Background()
+	conf := ecspresso.NewDefaultConfig()
+	conf.Region = "ap-northeast-1"
+	conf.AWS = &ecspresso.ConfigAWS{RetryMaxAttempts: 3}
+	maxAttempts, maxBackoff := 10, 30*time.Second
+	conf.OverrideByCLIOptions(&ecspresso.CLIOptions{RetryMaxAttempts: &maxAttempts, RetryMaxBackoff: &maxBackoff})
+	if diff := cmp.Diff(&ecspresso.ConfigAWS{RetryMaxAttempts: 10, RetryMaxBackoff: &ecspresso.Duration{Duration: maxBackoff}}, conf.AWS); diff != "" {
+		t.Errorf("unexpected aws config: %s", diff)
+	}
+	if err := conf.Restrict(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cfg := conf.AWSv2Config()
+	if cfg.Retryer == nil {
+		t.Fatal("the retryer is not configured")
+	}
+	if n := cfg.Retryer().MaxAttempts(); n != maxAttempts {
+		t.Errorf("unexpected max attempts of the retryer: %d", n)
+	}
+	if cfg.RetryMaxAttempts != maxAttempts {
+		t.Errorf("unexpected retry max attempts: %d", cfg.RetryMaxAttempts)
+	}
+}
+
+func TestAssumeRoleOptions(t *testing.T) {
+	conf := &ecspresso.Config{
+		AssumeRoleConfig: &ecspresso.ConfigAssumeRole{
+			RoleARN:     "arn:aws:iam::123456789012:role/config",
+			ExternalID:  "config-external-id",
+			SessionName: "config-session",
+		},
+	}
+	conf.OverrideByCLIOptions(&ecspresso.CLIOptions{
+		AssumeRoleARN:         "arn:aws:iam::123456789012:role/cli",
+		AssumeRoleSessionName: "cli-session",
+	})
+	expected := &ecspresso.ConfigAssumeRole{
+		RoleARN:     "arn:aws:iam::123456789012:role/cli",
+		ExternalID:  "config-external-id",
+		SessionName: "cli-session",
+	}
+	if diff := cmp.Diff(expected, conf.AssumeRoleConfig); diff != "" {
+		t.Errorf("unexpected assume role config: %s", diff)
+	}
+	o := conf.AssumeRoleConfig.ProviderOptions()
+	if aws.ToString(o.ExternalID) != "config-external-id" {
+		t.Errorf("unexpected external id: %v", o.ExternalID)
+	}
+	if o.RoleSessionName != "cli-session" {
+		t.Errorf("unexpected session name: %s", o.RoleSessionName)
+	}
+
+	o = (&ecspresso.ConfigAssumeRole{RoleARN: "arn:aws:iam::123456789012:role/config"}).ProviderOptions()
+	if o.ExternalID != nil {
+		t.Errorf("empty external id must be ignored: %s", *o.ExternalID)
+	}
+	if o.RoleSessionName != "" {
+		t.Errorf("empty session name must be ignored: %s", o.RoleSessionName)
+	}
+	if o.SerialNumber != nil || o.TokenProvider != nil {
+		t.Error("the token provider must not be set without the MFA serial")
+	}
+}
+
+func TestAssumeRoleOptionsMFA(t *testing.T) {
+	conf := &ecspresso.Config{
+		AssumeRoleConfig: &ecspresso.ConfigAssumeRole{
+			RoleARN:   "arn:aws:iam::123456789012:role/config",
+			MFASerial: "arn:aws:iam::123456789012:mfa/config",
+		},
+	}
+	conf.OverrideByCLIOptions(&ecspresso.CLIOptions{MFASerial: "arn:aws:iam::123456789012:mfa/cli"})
+	o := conf.AssumeRoleConfig.ProviderOptions()
+	if s := aws.ToString(o.SerialNumber); s != "arn:aws:iam::123456789012:mfa/cli" {
+		t.Errorf("unexpected serial number: %s", s)
+	}
+	if o.TokenProvider == nil {
+		t.Error("the token provider must be set with the MFA serial")
+	}
+}
+
+func TestLoadConfigWithIncludes(t *testing.T) {
+	ctx := context.Background()
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	conf, err := loader.Load(ctx, "tests/include/ecspresso.yml", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Region != "ap-northeast-1" {
+		t.Errorf("expected region from the nested included file, but %s", conf.Region)
+	}
+	if conf.Cluster != "app-cluster" {
+		t.Errorf("expected cluster from the config file, but %s", conf.Cluster)
+	}
+	if conf.Timeout.Duration != 5*time.Minute {
+		t.Errorf("expected timeout from the included file, but %s", conf.Timeout)
+	}
+	if !strings.HasSuffix(conf.TaskDefinitionPath, "tests/ecs-task-def.json") {
+		t.Errorf("expected task definition resolved by the included file, but %s", conf.TaskDefinitionPath)
+	}
+	if !strings.HasSuffix(conf.ServiceDefinitionPath, "tests/ecs-service-def.json") {
+		t.Errorf("expected service definition resolved by the included file, but %s", conf.ServiceDefinitionPath)
+	}
+	var paths []string
+	for _, p := range conf.Plugins {
+		paths = append(paths, filepath.Base(p.Config["path"].(string)))
+	}
+	if diff := cmp.Diff([]string{"terraform.tfstate", "bucket.tfstate"}, paths); diff != "" {
+		t.Errorf("unexpected plugins order: %s", diff)
+	}
+	if len(conf.Include) != 0 {
+		t.Errorf("include must be cleared after merging: %v", conf.Include)
+	}
+
+	t.Run("circular", func(t *testing.T) {
+		_, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, "tests/include/circular-a.yml", "")
+		if err == nil {
+			t.Fatal("expected an error for circular include")
+		}
+		if !strings.Contains(err.Error(), "circular include:") ||
+			!strings.Contains(err.Error(), "circular-a.yml -> ") ||
+			!strings.HasSuffix(err.Error(), "circular-a.yml") {
+			t.Errorf("unexpected error: %s", err)
+		}
+	})
+}
+
+func TestLoadTaskDefinitionWithTemplateDelimiters(t *testing.T) {
+	t.Setenv("DELIMS_IMAGE", "nginx:alpine")
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-delims-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, err := app.LoadTaskDefinition(app.Config().TaskDefinitionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := td.ContainerDefinitions[0]
+	if image := aws.ToString(c.Image); image != "nginx:alpine" {
+		t.Errorf("unexpected image %s", image)
+	}
+	if diff := cmp.Diff([]string{"echo", "{{ .Literal }}"}, c.Command); diff != "" {
+		t.Errorf("literal braces must be kept: %s", diff)
+	}
+}
+
+func TestLoadTaskDefinitionWithoutAWS(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-no-aws-config.yml"}, ecspresso.WithoutAWS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	td, err := app.LoadTaskDefinition(app.Config().TaskDefinitionPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := td.ContainerDefinitions[0]
+	if image := aws.ToString(c.Image); image != "no-aws:ssm(/app/image)" {
+		t.Errorf("unexpected image %s", image)
+	}
+	if v := aws.ToString(c.Environment[0].Value); v != "no-aws:cfn_output(app-stack,BucketName)" {
+		t.Errorf("unexpected environment value %s", v)
+	}
+	if v := aws.ToString(c.Secrets[0].ValueFrom); v != "no-aws:secretsmanager_arn(app/token)" {
+		t.Errorf("unexpected secret valueFrom %s", v)
+	}
+}
+
+func TestConvertTemplateDelims(t *testing.T) {
+	for _, tt := range []struct {
+		src      string
+		expected string
+		isError  bool
+	}{
+		{src: `[[ env "FOO" ]]`, expected: `{{ env "FOO" }}`},
+		{src: `[[- .Value -]] {{ literal }}`, expected: `{{- .Value -}} {{"{{"}} literal {{"}}"}}`},
+		{src: `no actions`, expected: `no actions`},
+		{src: `[[ unclosed`, isError: true},
+	} {
+		b, err := ecspresso.ConvertTemplateDelims([]byte(tt.src), "[[", "]]")
+		if tt.isError {
+			if err == nil {
+				t.Errorf("%s expected error, but got nil", tt.src)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s unexpected error: %s", tt.src, err)
+			continue
+		}
+		if string(b) != tt.expected {
+			t.Errorf("%s expected %s, but got %s", tt.src, tt.expected, b)
+		}
+	}
+}
+
+func TestConfigProfile(t *testing.T) {
+	conf := ecspresso.NewDefaultConfig()
+	conf.Profile = "config-profile"
+	o, err := conf.AWSLoadOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.SharedConfigProfile != "config-profile" {
+		t.Errorf("unexpected profile: %s", o.SharedConfigProfile)
+	}
+
+	conf.OverrideByCLIOptions(&ecspresso.CLIOptions{Profile: "cli-profile"})
+	o, err = conf.AWSLoadOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.SharedConfigProfile != "cli-profile" {
+		t.Errorf("--profile must override the profile in the config: %s", o.SharedConfigProfile)
+	}
+
+	o, err = ecspresso.NewDefaultConfig().AWSLoadOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o.SharedConfigProfile != "" {
+		t.Errorf("the profile must be empty to use AWS_PROFILE: %s", o.SharedConfigProfile)
+	}
+}
+
+func TestConfigProfileOverridesEnv(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "config")
+	src := "[profile env]\nregion = us-east-1\n\n[profile staging]\nregion = eu-west-1\n"
+	if err := os.WriteFile(f, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", f)
+	t.Setenv("AWS_PROFILE", "env")
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+
+	conf := ecspresso.NewDefaultConfig()
+	conf.Profile = "staging"
+	if err := conf.Restrict(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if r := conf.AWSRegion(); r != "eu-west-1" {
+		t.Errorf("the region of the profile in the config is expected: %s", r)
+	}
+}
+
+func TestLoadConfigWithOverlay(t *testing.T) {
+	ctx := context.Background()
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	loader.SetOverlay("tests/overlay/production.jsonnet")
+	conf, err := loader.Load(ctx, "tests/overlay/ecspresso.jsonnet", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Cluster != "production" {
+		t.Errorf("expected cluster from the overlay, but %s", conf.Cluster)
+	}
+	if conf.Service != "app" {
+		t.Errorf("expected service from the config file, but %s", conf.Service)
+	}
+	expected := &ecspresso.ConfigCodeDeploy{
+		ApplicationName:     "app",
+		DeploymentGroupName: "app-dg-production",
+	}
+	if diff := cmp.Diff(expected, conf.CodeDeploy); diff != "" {
+		t.Errorf("unexpected codedeploy merged by the overlay: %s", diff)
+	}
+	if !strings.HasSuffix(conf.TaskDefinitionPath, "tests/ecs-task-def.json") {
+		t.Errorf("expected task definition relative to the config file, but %s", conf.TaskDefinitionPath)
+	}
+
+	if conf.Timeout.Duration != 5*time.Minute {
+		t.Errorf("expected timeout of the config file, but %s", conf.Timeout)
+	}
+
+	t.Run("hidden field", func(t *testing.T) {
+		loader := ecspresso.NewConfigLoader(nil, nil)
+		loader.SetOverlay("tests/overlay/hidden.jsonnet")
+		conf, err := loader.Load(ctx, "tests/overlay/ecspresso.jsonnet", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf.Timeout.Duration != 10*time.Minute {
+			t.Errorf("expected timeout by the hidden field of the overlay, but %s", conf.Timeout)
+		}
+	})
+
+	t.Run("type conflict", func(t *testing.T) {
+		loader := ecspresso.NewConfigLoader(nil, nil)
+		loader.SetOverlay("tests/overlay/conflict.jsonnet")
+		_, err := loader.Load(ctx, "tests/overlay/ecspresso.jsonnet", "")
+		if err == nil || !strings.Contains(err.Error(), "type conflict at codedeploy: object in the config, string in the overlay") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		loader := ecspresso.NewConfigLoader(nil, nil)
+		loader.SetOverlay("tests/overlay/production.jsonnet")
+		if _, err := loader.Load(ctx, "tests/include/ecspresso.yml", ""); err == nil {
+			t.Error("expected an error for the yaml config file")
+		}
+	})
+}
+
+func TestConfigTagsApply(t *testing.T) {
+	tag := func(k, v string) types.Tag {
+		return types.Tag{Key: aws.String(k), Value: aws.String(v)}
+	}
+	for _, tt := range []struct {
+		name     string
+		global   ecspresso.ConfigTags
+		tags     []types.Tag
+		expected []types.Tag
+	}{
+		{
+			name:     "merge in the order of keys",
+			global:   ecspresso.ConfigTags{"team": "platform", "cost-center": "1234"},
+			tags:     []types.Tag{tag("app", "web")},
+			expected: []types.Tag{tag("app", "web"), tag("cost-center", "1234"), tag("team", "platform")},
+		},
+		{
+			name:     "resource tags take precedence",
+			global:   ecspresso.ConfigTags{"team": "platform", "cost-center": "1234"},
+			tags:     []types.Tag{tag("team", "web")},
+			expected: []types.Tag{tag("team", "web"), tag("cost-center", "1234")},
+		},
+		{
+			name:     "no resource tags",
+			global:   ecspresso.ConfigTags{"team": "platform"},
+			tags:     nil,
+			expected: []types.Tag{tag("team", "platform")},
+		},
+		{
+			name:     "empty global tags",
+			global:   ecspresso.ConfigTags{},
+			tags:     []types.Tag{tag("app", "web")},
+			expected: []types.Tag{tag("app", "web")},
+		},
+		{
+			name:     "nil global tags",
+			global:   nil,
+			tags:     nil,
+			expected: nil,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			td := &ecspresso.TaskDefinitionInput{Tags: tt.tags}
+			if err := tt.global.Apply(td); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, td.Tags, cmpopts.IgnoreUnexported(types.Tag{})); diff != "" {
+				t.Errorf("unexpected tags: %s", diff)
+			}
+		})
+	}
+}
+
+func TestConfigTimeouts(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/timeouts.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := app.Config()
+	for phase, expected := range map[string]time.Duration{
+		"deploy":   300 * time.Second,
+		"wait":     time.Nanosecond,
+		"rollback": 300 * time.Second,
+		"run":      30 * time.Minute,
+	} {
+		if d := conf.TimeoutFor(phase); d != expected {
+			t.Errorf("unexpected timeout of %s: %s", phase, d)
+		}
+	}
+
+	if r := app.RunTimeoutReason(); r != "ecspresso run timed out after 30m0s (timeouts.run)" {
+		t.Errorf("unexpected reason: %s", r)
+	}
+
+	wctx, cancel := app.StartPhase(ctx, "wait")
+	defer cancel()
+	select {
+	case <-wctx.Done():
+		if !errors.Is(wctx.Err(), context.DeadlineExceeded) {
+			t.Errorf("unexpected error of the wait phase: %s", wctx.Err())
+		}
+	case <-time.After(time.Second):
+		t.Error("the wait phase must be timed out quickly")
+	}
+
+	// the deploy phase (e.g. registering the task definition) is limited by its own timeout, not by the wait phase
+	dctx, cancel := app.StartPhase(ctx, "deploy")
+	defer cancel()
+	deadline, ok := dctx.Deadline()
+	if !ok {
+		t.Fatal("the deploy phase must have a deadline")
+	}
+	if remaining := time.Until(deadline); remaining <= 290*time.Second || remaining > 300*time.Second {
+		t.Errorf("unexpected deadline of the deploy phase: %s", remaining)
+	}
+	if err := dctx.Err(); err != nil {
+		t.Errorf("the deploy phase must not be timed out: %s", err)
+	}
+
+	// the lock is held while the deploy phase and the wait phase
+	if ttl := conf.DeployLockTTL(); ttl != 300*time.Second+time.Nanosecond {
+		t.Errorf("unexpected TTL of the deploy lock: %s", ttl)
+	}
+}
+
+func TestConfigTimeoutsZero(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/timeouts-zero.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := app.Config()
+	// zero means no timeout both in the phase and in the waiters
+	wctx, cancel := app.StartPhase(ctx, "wait")
+	defer cancel()
+	if deadline, ok := wctx.Deadline(); ok {
+		t.Errorf("the wait phase must not have a deadline: %s", deadline)
+	}
+	if d := conf.WaiterTimeoutFor("wait"); d < 24*time.Hour {
+		t.Errorf("unexpected timeout of the waiter: %s", d)
+	}
+	if d := conf.WaiterTimeoutFor("deploy"); d != 300*time.Second {
+		t.Errorf("unexpected timeout of the waiter: %s", d)
+	}
+	// the TTL of the lock falls back to timeout because the wait phase is not limited
+	if ttl := conf.DeployLockTTL(); ttl != 300*time.Second {
+		t.Errorf("unexpected TTL of the deploy lock: %s", ttl)
+	}
+}
@@ -0,0 +1,149 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ConfigAuthorizationEnv is the environment variable name of the Authorization header to fetch the config file from URL.
+const ConfigAuthorizationEnv = "ECSPRESSO_CONFIG_AUTHORIZATION"
+
+func isConfigURL(p string) bool {
+	return strings.HasPrefix(p, "https://") || strings.HasPrefix(p, "http://") || isConfigS3URL(p)
+}
+
+func isConfigS3URL(p string) bool {
+	return strings.HasPrefix(p, "s3://")
+}
+
+// configURLExt returns the extension of the path of the URL, ignoring a query string.
+func configURLExt(u string) (string, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return "", fmt.Errorf("invalid config URL %s: %w", u, err)
+	}
+	return path.Ext(parsed.Path), nil
+}
+
+func fetchConfigURL(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config URL %s: %w", u, err)
+	}
+	if v := os.Getenv(ConfigAuthorizationEnv); v != "" {
+		req.Header.Set("Authorization", v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to fetch config %s: %s %s", u, resp.Status, strings.TrimSpace(string(b)))
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", u, err)
+	}
+	return b, nil
+}
+
+// fetchConfigS3 fetches the config file from S3.
+// The region of the config is not known yet, so the default AWS config (e.g. AWS_REGION) is used.
+func fetchConfigS3(ctx context.Context, u string) ([]byte, error) {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config URL %s: %w", u, err)
+	}
+	bucket, key := parsed.Host, strings.TrimPrefix(parsed.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid config URL %s: s3://bucket/key is required", u)
+	}
+	cfg, err := awsConfig.LoadDefaultConfig(ctx, awsv2ConfigLoadOptionsFunc...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load aws config: %w", err)
+	}
+	out, err := s3.NewFromConfig(cfg, s3PathStyle(cfg)).GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config %s: %w", u, err)
+	}
+	defer out.Body.Close()
+	b, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", u, err)
+	}
+	return b, nil
+}
+
+// readConfigURL reads the config file from URL.
+// The format of the config is inferred from the suffix of the URL path.
+func (l *configLoader) readConfigURL(ctx context.Context, u string, conf *Config) error {
+	ext, err := configURLExt(u)
+	if err != nil {
+		return err
+	}
+	switch ext {
+	case ymlExt, yamlExt, jsonExt, jsonnetExt, tomlExt:
+	default:
+		return fmt.Errorf("unsupported config file extension: %q", ext)
+	}
+	var src []byte
+	if isConfigS3URL(u) {
+		src, err = fetchConfigS3(ctx, u)
+	} else {
+		src, err = fetchConfigURL(ctx, u)
+	}
+	if err != nil {
+		return err
+	}
+	return l.readConfigBytes(src, ext, u, conf)
+}
+
+// requireLocalDefinitionPaths returns an error if the config has definition paths of URL.
+// Only the config file can be loaded from URL.
+func (c *Config) requireLocalDefinitionPaths() error {
+	if p := c.ServiceDefinitionPath; isConfigURL(p) {
+		return fmt.Errorf("service_definition %s must be a local file. loading definition files from URL is not supported", p)
+	}
+	if p := c.TaskDefinitionPath; isConfigURL(p) {
+		return fmt.Errorf("task_definition %s must be a local file. loading definition files from URL is not supported", p)
+	}
+	return nil
+}
+
+// requireAbsDefinitionPaths returns an error if the config has relative definition paths
+// which cannot be resolved without the base directory.
+func (c *Config) requireAbsDefinitionPaths() error {
+	if p := c.ServiceDefinitionPath; p != "" && !filepath.IsAbs(p) {
+		return fmt.Errorf("service_definition %s must be an absolute path for the config loaded from URL, or specify --config-base-dir", p)
+	}
+	if p := c.TaskDefinitionPath; p != "" && !filepath.IsAbs(p) {
+		return fmt.Errorf("task_definition %s must be an absolute path for the config loaded from URL, or specify --config-base-dir", p)
+	}
+	return nil
+}
+
+// s3PathStyle makes the S3 client use path-style addressing when a custom endpoint is set,
+// because custom endpoints like LocalStack may not resolve virtual hosted-style bucket names.
+func s3PathStyle(cfg aws.Config) func(*s3.Options) {
+	return func(o *s3.Options) {
+		if cfg.BaseEndpoint != nil || os.Getenv("AWS_ENDPOINT_URL_S3") != "" {
+			o.UsePathStyle = true
+		}
+	}
+}
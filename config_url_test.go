@@ -0,0 +1,175 @@
+package ecspresso_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/kayac/ecspresso/v2"
+)
+
+var configURLSources = map[string]string{
+	"/ecspresso.yml": `region: ap-northeast-1
+cluster: {{ must_env "CLUSTER" }}
+service: test
+service_definition: ecs-service-def.json
+task_definition: ecs-task-def.json
+`,
+	"/ecspresso.jsonnet": `{
+  region: 'ap-northeast-1',
+  cluster: std.native('must_env')('CLUSTER'),
+  service: 'test',
+  service_definition: '/path/to/ecs-service-def.json',
+  task_definition: '/path/to/ecs-task-def.json',
+}
+`,
+}
+
+func newConfigURLServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "invalid token", http.StatusForbidden)
+			return
+		}
+		src, ok := configURLSources[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(src))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestLoadConfigFromURL(t *testing.T) {
+	ts := newConfigURLServer(t)
+	t.Setenv("CLUSTER", "url-cluster")
+	t.Setenv(ecspresso.ConfigAuthorizationEnv, "Bearer secret")
+	ctx := context.Background()
+
+	t.Run("jsonnet with absolute paths", func(t *testing.T) {
+		loader := ecspresso.NewConfigLoader(nil, nil)
+		conf, err := loader.Load(ctx, ts.URL+"/ecspresso.jsonnet?ref=main", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf.Cluster != "url-cluster" {
+			t.Errorf("unexpected cluster %s", conf.Cluster)
+		}
+		if conf.TaskDefinitionPath != "/path/to/ecs-task-def.json" {
+			t.Errorf("unexpected task definition path %s", conf.TaskDefinitionPath)
+		}
+	})
+
+	t.Run("yaml with base dir", func(t *testing.T) {
+		loader := ecspresso.NewConfigLoader(nil, nil)
+		loader.SetBaseDir("tests")
+		conf, err := loader.Load(ctx, ts.URL+"/ecspresso.yml", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf.Cluster != "url-cluster" {
+			t.Errorf("unexpected cluster %s", conf.Cluster)
+		}
+		if conf.ServiceDefinitionPath != filepath.Join("tests", "ecs-service-def.json") {
+			t.Errorf("unexpected service definition path %s", conf.ServiceDefinitionPath)
+		}
+	})
+
+	t.Run("relative paths without base dir", func(t *testing.T) {
+		loader := ecspresso.NewConfigLoader(nil, nil)
+		_, err := loader.Load(ctx, ts.URL+"/ecspresso.yml", "")
+		if err == nil || !strings.Contains(err.Error(), "must be an absolute path") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		loader := ecspresso.NewConfigLoader(nil, nil)
+		_, err := loader.Load(ctx, ts.URL+"/missing.yml", "")
+		if err == nil || !strings.Contains(err.Error(), "404 Not Found") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+
+	t.Run("unsupported extension", func(t *testing.T) {
+		loader := ecspresso.NewConfigLoader(nil, nil)
+		_, err := loader.Load(ctx, ts.URL+"/ecspresso.ini", "")
+		if err == nil || !strings.Contains(err.Error(), "unsupported config file extension") {
+			t.Errorf("unexpected error %v", err)
+		}
+	})
+}
+
+func TestLoadConfigFromURLUnauthorized(t *testing.T) {
+	ts := newConfigURLServer(t)
+	t.Setenv(ecspresso.ConfigAuthorizationEnv, "")
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	_, err := loader.Load(context.Background(), ts.URL+"/ecspresso.yml", "")
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden invalid token") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+// s3GetObjectMiddleware returns the object of configURLSources for s3://config-bucket/{key}.
+func s3GetObjectMiddleware(stack *middleware.Stack) error {
+	return stack.Initialize.Add(
+		middleware.InitializeMiddlewareFunc(
+			"test",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+				params, ok := in.Parameters.(*s3.GetObjectInput)
+				if !ok {
+					return next.HandleInitialize(ctx, in)
+				}
+				src, ok := configURLSources["/"+aws.ToString(params.Key)]
+				if aws.ToString(params.Bucket) != "config-bucket" || !ok {
+					return middleware.InitializeOutput{}, middleware.Metadata{}, fmt.Errorf("NoSuchKey: %s", aws.ToString(params.Key))
+				}
+				return middleware.InitializeOutput{
+					Result: &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(src))},
+				}, middleware.Metadata{}, nil
+			},
+		),
+		middleware.Before,
+	)
+}
+
+func TestLoadConfigFromS3(t *testing.T) {
+	ecspresso.SetAWSV2ConfigLoadOptionsFunc([]func(*config.LoadOptions) error{
+		config.WithRegion("ap-northeast-1"),
+		config.WithAPIOptions([]func(*middleware.Stack) error{s3GetObjectMiddleware}),
+	})
+	defer ecspresso.ResetAWSV2ConfigLoadOptionsFunc()
+	t.Setenv("CLUSTER", "s3-cluster")
+	ctx := context.Background()
+
+	loader := ecspresso.NewConfigLoader(nil, nil)
+	conf, err := loader.Load(ctx, "s3://config-bucket/ecspresso.jsonnet", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Cluster != "s3-cluster" {
+		t.Errorf("unexpected cluster %s", conf.Cluster)
+	}
+
+	loader = ecspresso.NewConfigLoader(nil, nil)
+	if _, err := loader.Load(ctx, "s3://config-bucket/ecspresso.yml", ""); err == nil || !strings.Contains(err.Error(), "must be an absolute path") {
+		t.Errorf("unexpected error %v", err)
+	}
+
+	loader = ecspresso.NewConfigLoader(nil, nil)
+	if _, err := loader.Load(ctx, "s3://config-bucket/missing.yml", ""); err == nil || !strings.Contains(err.Error(), "NoSuchKey") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
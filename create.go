@@ -12,15 +12,16 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 )
 
-func (d *App) createService(ctx context.Context, opt DeployOption) error {
+// createService creates the service and returns the ARN of the task definition of the service.
+func (d *App) createService(ctx context.Context, opt DeployOption) (string, error) {
 	d.Log("Starting create service %s", opt.DryRunString())
-	svd, err := d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+	svd, err := d.loadServiceDefinitionForDeploy(ctx, d.config.ServiceDefinitionPath)
 	if err != nil {
-		return err
+		return "", err
 	}
 	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	count := calcDesiredCount(svd, opt)
@@ -34,7 +35,7 @@ func (d *App) createService(ctx context.Context, opt DeployOption) error {
 		d.Log("service definition:")
 		d.OutputJSONForAPI(os.Stderr, svd)
 		d.Log("DRY RUN OK")
-		return nil
+		return "", nil
 	}
 
 	var tdArn string
@@ -42,13 +43,13 @@ func (d *App) createService(ctx context.Context, opt DeployOption) error {
 		var err error
 		tdArn, err = d.findLatestTaskDefinitionArn(ctx, aws.ToString(td.Family))
 		if err != nil {
-			return err
+			return "", err
 		}
 		d.Log("Using latest task definition %s", tdArn)
 	} else {
 		newTd, err := d.RegisterTaskDefinition(ctx, td)
 		if err != nil {
-			return err
+			return "", err
 		}
 		tdArn = *newTd.TaskDefinitionArn
 	}
@@ -78,34 +79,34 @@ func (d *App) createService(ctx context.Context, opt DeployOption) error {
 		VolumeConfigurations:          svd.VolumeConfigurations,
 	}
 	if _, err := d.ecs.CreateService(ctx, createServiceInput); err != nil {
-		return fmt.Errorf("failed to create service: %w", err)
+		return "", fmt.Errorf("failed to create service: %w", err)
 	}
 	d.Log("Service is created")
 
 	if !opt.Wait {
-		return nil
+		return tdArn, nil
 	}
 
 	time.Sleep(delayForServiceChanged) // wait for service created
 
 	sv, err := d.DescribeService(ctx)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	doWait, err := d.WaitFunc(sv, nil)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	if err := doWait(ctx, sv); err != nil {
 		if errors.As(err, &errNotFound) && sv.isCodeDeploy() {
 			d.Log("[INFO] %s", err)
-			return d.WaitTaskSetStable(ctx, sv)
+			return tdArn, d.WaitTaskSetStable(ctx, sv)
 		}
-		return err
+		return "", err
 	}
 
 	d.Log("Service is stable now. Completed!")
-	return nil
+	return tdArn, nil
 }
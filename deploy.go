@@ -26,19 +26,51 @@ const (
 )
 
 type DeployOption struct {
-	DryRun               bool   `help:"dry run" default:"false"`
-	DesiredCount         *int32 `name:"tasks" help:"desired count of tasks" default:"-1"`
-	SkipTaskDefinition   bool   `help:"skip register a new task definition" default:"false"`
-	Revision             int64  `help:"revision of the task definition to run when --skip-task-definition" default:"0"`
-	ForceNewDeployment   bool   `help:"force a new deployment of the service" default:"false"`
-	Wait                 bool   `help:"wait for service stable" default:"true" negatable:""`
-	SuspendAutoScaling   *bool  `help:"suspend application auto-scaling attached with the ECS service"`
-	ResumeAutoScaling    *bool  `help:"resume application auto-scaling attached with the ECS service"`
-	AutoScalingMin       *int32 `help:"set minimum capacity of application auto-scaling attached with the ECS service"`
-	AutoScalingMax       *int32 `help:"set maximum capacity of application auto-scaling attached with the ECS service"`
-	RollbackEvents       string `help:"roll back when specified events happened (DEPLOYMENT_FAILURE,DEPLOYMENT_STOP_ON_ALARM,DEPLOYMENT_STOP_ON_REQUEST,...) CodeDeploy only." default:""`
-	UpdateService        bool   `help:"update service attributes by service definition" default:"true" negatable:""`
-	LatestTaskDefinition bool   `help:"deploy with the latest task definition without registering a new task definition" default:"false"`
+	DryRun                        bool          `help:"dry run" default:"false"`
+	DesiredCount                  *int32        `name:"tasks" help:"desired count of tasks" default:"-1"`
+	SkipTaskDefinition            bool          `help:"skip register a new task definition" default:"false"`
+	Revision                      int64         `help:"revision of the task definition to run when --skip-task-definition" default:"0"`
+	ForceNewDeployment            bool          `help:"force a new deployment of the service" default:"false"`
+	Wait                          bool          `help:"wait for service stable" default:"true" negatable:""`
+	SuspendAutoScaling            *bool         `help:"suspend application auto-scaling attached with the ECS service"`
+	ResumeAutoScaling             *bool         `help:"resume application auto-scaling attached with the ECS service"`
+	AutoScalingMin                *int32        `help:"set minimum capacity of application auto-scaling attached with the ECS service"`
+	AutoScalingMax                *int32        `help:"set maximum capacity of application auto-scaling attached with the ECS service"`
+	RollbackEvents                string        `help:"roll back when specified events happened (DEPLOYMENT_FAILURE,DEPLOYMENT_STOP_ON_ALARM,DEPLOYMENT_STOP_ON_REQUEST,...) CodeDeploy only." default:""`
+	UpdateService                 bool          `help:"update service attributes by service definition" default:"true" negatable:""`
+	LatestTaskDefinition          bool          `help:"deploy with the latest task definition without registering a new task definition" default:"false"`
+	RequireApproval               bool          `help:"show the diff and wait for an approval before deploying" default:"false"`
+	ApprovalSSMParameter          string        `name:"approval-ssm-parameter" help:"SSM parameter name to poll for an approval (approved or rejected). requires --require-approval" default:""`
+	ApprovalFile                  string        `help:"file path to poll for an approval. the file appearing approves the deployment. requires --require-approval" default:""`
+	VerifyBefore                  bool          `help:"verify resources in configurations before deploying and abort on failures" default:"false"`
+	VerifySkip                    []string      `help:"checks to skip in --verify-before (role,image,secret,log,environment-file,load-balancer,network,platform-version,cluster,deployment-controller)"`
+	Canary                        bool          `help:"run a canary task of the new task definition and check its health before updating the service" default:"false"`
+	CanaryHealthURL               string        `name:"canary-health-url" help:"URL to check the health of the canary task. {ip} is replaced by the private IP of the task" default:""`
+	CanaryCommand                 string        `help:"command to check the health of the canary task. CANARY_TASK_ARN and CANARY_TASK_IP are set" default:""`
+	CanaryTimeout                 time.Duration `help:"timeout of the health check of the canary task (default: 3m)"`
+	RecordTable                   string        `help:"DynamoDB table name to record the deployment" default:""`
+	RecordFile                    string        `help:"file path to append the record of the deployment as JSON Lines" default:""`
+	RecordFatal                   bool          `help:"fail when recording the deployment failed. otherwise warn only" default:"false"`
+	RecordPrevious                string        `help:"record the task definition active before the deployment to the file or SSM parameter (ssm:{name}) for rollback --from-record" default:""`
+	Lock                          bool          `help:"acquire a lock of the service stored in a DynamoDB table to prevent concurrent deployments" default:"false"`
+	LockTTL                       time.Duration `name:"lock-ttl" help:"TTL of the lock. an expired lock is taken over (default: timeouts of deploy and wait in the config)"`
+	LockTable                     string        `help:"DynamoDB table name to store the lock. the partition key must be id of string (default: ecspresso-lock)"`
+	EnsureCapacityProviders       bool          `help:"associate capacity providers referenced by the service definition with the cluster if missing" default:"false"`
+	WaitForMinRunningPercent      int32         `help:"complete the deployment when the running tasks of the PRIMARY deployment reach the percent of the desired count, instead of waiting for the service stable (1-100)" default:"0"`
+	WaitForMinRunningHealthy      bool          `help:"count only HEALTHY tasks for --wait-for-min-running-percent" default:"false"`
+	PrintTaskDefinitionArn        bool          `help:"print only the ARN of the deployed task definition to STDOUT. other outputs go to STDERR" default:"false"`
+	RevisionsWarning              *int          `help:"warn when ACTIVE revisions of the task definition family exceed the number (default: revisions_warning in the config or 1000). 0 disables the warning"`
+	SkipDeploymentControllerCheck bool          `help:"skip checking the deployment controller of the service matches the config" default:"false"`
+	PauseBeforeTraffic            bool          `help:"validate the replacement task set before shifting traffic, then continue or stop the deployment with rollback. CodeDeploy only" default:"false"`
+	ValidateURL                   string        `name:"validate-url" help:"URL to validate the replacement task set for --pause-before-traffic" default:""`
+	ValidateCommand               string        `help:"command to validate the replacement task set for --pause-before-traffic. CODEDEPLOY_DEPLOYMENT_ID is set" default:""`
+	WaitBackoff                   bool          `help:"poll the status while waiting with exponential backoff and jitter between --wait-interval-min and --wait-interval-max, instead of the fixed interval" default:"false"`
+	WaitIntervalMin               time.Duration `help:"minimum interval of polling for --wait-backoff (default: 5s)"`
+	WaitIntervalMax               time.Duration `help:"maximum interval of polling for --wait-backoff (default: 1m)"`
+	QuietNoChanges                bool          `help:"print a single line instead of the logs of the deployment when nothing is changed" default:"false"`
+	SilentNoChanges               bool          `help:"print nothing when nothing is changed" default:"false"`
+
+	arnWriter io.Writer `kong:"-"` // writer of the ARN by PrintTaskDefinitionArn. the default is os.Stdout
 }
 
 func (opt DeployOption) DryRunString() string {
@@ -48,6 +80,35 @@ func (opt DeployOption) DryRunString() string {
 	return ""
 }
 
+// DefaultRevisionsWarning is the default threshold of ACTIVE revisions to warn on deploy.
+const DefaultRevisionsWarning = 1000
+
+// revisionsWarning returns the threshold of ACTIVE revisions to warn.
+// The flag takes precedence over revisions_warning in the config.
+func (opt DeployOption) revisionsWarning(conf *Config) int {
+	if opt.RevisionsWarning != nil {
+		return *opt.RevisionsWarning
+	}
+	if conf != nil && conf.RevisionsWarning != nil {
+		return *conf.RevisionsWarning
+	}
+	return DefaultRevisionsWarning
+}
+
+func (opt DeployOption) validateWaitForMinRunning() error {
+	p := opt.WaitForMinRunningPercent
+	if p < 0 || p > 100 {
+		return fmt.Errorf("--wait-for-min-running-percent must be between 1 and 100: %d", p)
+	}
+	if p == 0 && opt.WaitForMinRunningHealthy {
+		return ErrConflictOptions("wait-for-min-running-healthy requires wait-for-min-running-percent")
+	}
+	if p > 0 && !opt.Wait {
+		return ErrConflictOptions("wait-for-min-running-percent and no-wait are exclusive")
+	}
+	return nil
+}
+
 func (opt DeployOption) ModifyAutoScalingParams() *modifyAutoScalingParams {
 	p := &modifyAutoScalingParams{
 		Suspend:     nil,
@@ -75,23 +136,147 @@ func calcDesiredCount(sv *Service, opt DeployOption) *int32 {
 	return nil
 }
 
-func (d *App) Deploy(ctx context.Context, opt DeployOption) error {
+// preserveDesiredCount sets the desired count of the live service to the service definition
+// which omits desiredCount (e.g. owned by auto scaling), not to reset it by UpdateService.
+// An explicit desiredCount in the definition, including zero, is kept.
+func preserveDesiredCount(newSv, sv *Service) {
+	if newSv.DesiredCount != nil || newSv.SchedulingStrategy == types.SchedulingStrategyDaemon {
+		return
+	}
+	if sv != nil && sv.DesiredCount != nil {
+		newSv.DesiredCount = aws.Int32(*sv.DesiredCount)
+	}
+}
+
+func (d *App) Deploy(ctx context.Context, opt DeployOption) (err error) {
 	d.Log("[DEBUG] deploy")
 	d.LogJSON(opt)
-	ctx, cancel := d.Start(ctx)
+
+	var record *DeployRecord
+	if err := opt.validateWaitForMinRunning(); err != nil {
+		return err
+	}
+	if err := opt.validateAutoScaling(); err != nil {
+		return err
+	}
+	validate, err := opt.trafficValidation()
+	if err != nil {
+		return err
+	}
+	polling, err := opt.waitPolling()
+	if err != nil {
+		return err
+	}
+	if err := opt.validateNoChanges(); err != nil {
+		return err
+	}
+	var logs *bufferedLog
+	if opt.skipNoChanges() && opt.mayBeNoChanges() {
+		// hold the logs until the deploy turns out to be a no-op or not
+		logs = bufferLog(d.logger)
+		defer logs.flush()
+	}
+	if opt.RecordTable != "" && opt.RecordFile != "" {
+		return ErrConflictOptions("record-table and record-file are exclusive")
+	} else if (opt.RecordTable != "" || opt.RecordFile != "") && !opt.DryRun {
+		record = d.newDeployRecord(ctx)
+		// record with ctx which is not limited by the timeout of the deployment
+		defer func(ctx context.Context) {
+			err = d.recordDeploy(ctx, record, opt, err)
+		}(ctx)
+	}
+
+	var deployedTdArn string
+	if opt.PrintTaskDefinitionArn && !opt.DryRun {
+		// other outputs should be written to STDERR by WithStdout
+		w := opt.arnWriter
+		if w == nil {
+			w = os.Stdout
+		}
+		defer func() {
+			if err == nil && deployedTdArn != "" {
+				fmt.Fprintln(w, deployedTdArn)
+			}
+		}()
+	}
+
+	// the wait is limited by the timeout of the wait phase, not of the deploy phase, if timeouts are set
+	waitCtx := ctx
+	ctx, cancel := d.startPhase(ctx, timeoutPhaseDeploy)
 	defer cancel()
+	if d.config.Timeouts == nil {
+		waitCtx = ctx
+	}
+
+	if opt.Lock && !opt.DryRun {
+		release, err := d.acquireDeployLock(ctx, opt.LockTable, opt.LockTTL)
+		if err != nil {
+			return err
+		}
+		defer release()
+	}
+
+	if opt.VerifyBefore {
+		vopt := VerifyOption{
+			GetSecrets: true,
+			PutLogs:    true,
+			Cache:      true,
+			Skip:       opt.VerifySkip,
+		}
+		if err := d.verify(ctx, vopt, true); err != nil {
+			return fmt.Errorf("deploy is aborted: %w", err)
+		}
+	}
+
+	if opt.EnsureCapacityProviders {
+		if err := d.ensureCapacityProviders(ctx, opt); err != nil {
+			return err
+		}
+	}
 
 	var sv *Service
 	d.Log("Starting deploy %s", opt.DryRunString())
-	sv, err := d.DescribeServiceStatus(ctx, 0)
+	sv, err = d.DescribeServiceStatus(ctx, 0)
 	if err != nil {
 		if errors.As(err, &errNotFound) {
+			logs.flush()
 			d.Log("Service %s not found. Creating a new service %s", d.Service, opt.DryRunString())
-			return d.createService(ctx, opt)
+			if err := d.approveDeploy(ctx, opt); err != nil {
+				return err
+			}
+			deployedTdArn, err = d.createService(ctx, opt)
+			return err
 		}
 		return err
 	}
 
+	if !opt.SkipDeploymentControllerCheck {
+		if err := checkDeploymentController(sv, d.config, nil); err != nil {
+			return fmt.Errorf("deploy is aborted: %w", err)
+		}
+	}
+
+	if logs != nil {
+		noChanges, err := d.deployHasNoChanges(ctx, sv, opt)
+		if err != nil {
+			return err
+		}
+		if noChanges {
+			logs.discard()
+			record = nil // nothing is deployed
+			deployedTdArn = aws.ToString(sv.TaskDefinition)
+			if opt.QuietNoChanges {
+				d.Log("No changes. Deploy is skipped %s", opt.DryRunString())
+			}
+			return nil
+		}
+		logs.flush()
+	}
+
+	if err := d.approveDeploy(ctx, opt); err != nil {
+		return err
+	}
+
 	doDeploy, err := d.DeployFunc(sv)
 	if err != nil {
 		return err
@@ -102,24 +287,67 @@ func (d *App) Deploy(ctx context.Context, opt DeployOption) error {
 		return err
 	}
 
+	d.warnActiveRevisions(ctx, tdArn, opt.revisionsWarning(d.config))
+
+	if opt.Canary {
+		if opt.DryRun {
+			d.Log("canary task will be run %s", opt.DryRunString())
+		} else if err := d.runCanary(ctx, tdArn, opt); err != nil {
+			return fmt.Errorf("deploy is aborted: %w", err)
+		}
+	}
+
 	doWait, err := d.WaitFunc(sv, d.confirmPrimaryTD(tdArn))
 	if err != nil {
 		return err
 	}
+	if p := opt.WaitForMinRunningPercent; p > 0 {
+		if sv.isCodeDeploy() {
+			return fmt.Errorf("--wait-for-min-running-percent is not supported for the service using CodeDeploy")
+		}
+		doWait = d.confirmPrimaryTD(tdArn).wrap(d.waitMinRunningFunc(p, opt.WaitForMinRunningHealthy))
+	}
+	if validate != nil {
+		if !sv.isCodeDeploy() {
+			return fmt.Errorf("--pause-before-traffic is supported only for the service using CodeDeploy")
+		}
+		if err := d.requireDeploymentReadyWait(ctx); err != nil {
+			return err
+		}
+		doWait = d.waitForCodeDeployWithValidation(validate)
+	}
+	if record != nil {
+		record.TaskDefinition = tdArn
+	}
+	if opt.RecordPrevious != "" {
+		if opt.DryRun {
+			d.Log("the previous task definition will be recorded to %s %s", opt.RecordPrevious, opt.DryRunString())
+		} else if aws.ToString(sv.TaskDefinition) == tdArn {
+			d.Log("[INFO] the task definition is not changed. the record is not updated")
+		} else if err := d.recordPrevious(ctx, opt.RecordPrevious, sv); err != nil {
+			return fmt.Errorf("deploy is aborted: %w", err)
+		}
+	}
 
 	var count *int32
 	if d.config.ServiceDefinitionPath != "" && opt.UpdateService {
-		newSv, err := d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+		_, span := startSpan(ctx, "render")
+		newSv, err := d.loadServiceDefinitionForDeploy(ctx, d.config.ServiceDefinitionPath)
+		endSpan(span, err)
 		if err != nil {
 			return err
 		}
+		preserveDesiredCount(newSv, sv)
 		addedTags, updatedTags, deletedTags := CompareTags(sv.Tags, newSv.Tags)
-		differ, err := diffServices(ctx, newSv, sv, d.config.ServiceDefinitionPath, &DiffOption{Unified: true, w: io.Discard})
+		differ, err := diffServices(ctx, newSv, sv, d.config.ServiceDefinitionPath, &DiffOption{Unified: true, w: io.Discard, ignore: d.config.Ignore})
 		if err != nil {
 			return fmt.Errorf("failed to diff of service definitions: %w", err)
 		}
 		if differ {
-			if err = d.UpdateServiceAttributes(ctx, newSv, tdArn, opt); err != nil {
+			uctx, span := startSpan(ctx, "update")
+			err = d.UpdateServiceAttributes(uctx, newSv, tdArn, opt)
+			endSpan(span, err)
+			if err != nil {
 				return err
 			}
 			sv = newSv // updated
@@ -138,6 +366,9 @@ func (d *App) Deploy(ctx context.Context, opt DeployOption) error {
 	} else {
 		d.Log("desired count: unchanged")
 	}
+	if record != nil {
+		record.DesiredCount = count
+	}
 
 	// manage auto scaling
 	if err := d.modifyAutoScaling(ctx, opt); err != nil {
@@ -149,16 +380,26 @@ func (d *App) Deploy(ctx context.Context, opt DeployOption) error {
 		return nil
 	}
 
-	if err := doDeploy(ctx, tdArn, count, sv, opt); err != nil {
+	uctx, span := startSpan(ctx, "update")
+	err = doDeploy(uctx, tdArn, count, sv, opt)
+	endSpan(span, err)
+	if err != nil {
 		return err
 	}
 
+	deployedTdArn = tdArn
 	if !opt.Wait {
+		// the result of the deployment is not checked. the exit code reflects only the update of the service
 		d.Log("Service is deployed.")
 		return nil
 	}
 
-	if err := doWait(ctx, sv); err != nil {
+	waitCtx, waitCancel := d.startPhase(waitCtx, timeoutPhaseWait)
+	defer waitCancel()
+	wctx, span := startSpan(withWaitPolling(waitCtx, polling), "wait")
+	err = doWait(wctx, sv)
+	endSpan(span, err)
+	if err != nil {
 		if errors.As(err, &errNotFound) {
 			d.Log("[INFO] %s", err)
 			// no need to wait
@@ -187,11 +428,19 @@ func (d *App) UpdateServiceTasks(ctx context.Context, taskDefinitionArn string,
 	d.Log(msg)
 	d.LogJSON(in)
 
-	_, err := d.ecs.UpdateService(ctx, in)
+	out, err := d.ecs.UpdateService(ctx, in)
 	if err != nil {
 		return fmt.Errorf("failed to update service tasks: %w", err)
 	}
-	time.Sleep(delayForServiceChanged) // wait for service updated
+	if out.Service != nil {
+		updated := Service{Service: *out.Service}
+		if dp, ok := updated.PrimaryDeployment(); ok {
+			d.Log("Deployment %s is created", aws.ToString(dp.Id))
+		}
+	}
+	if opt.Wait {
+		time.Sleep(delayForServiceChanged) // wait for service updated
+	}
 	return nil
 }
 
@@ -295,9 +544,10 @@ func (d *App) findDeploymentInfo(ctx context.Context) (*cdTypes.DeploymentInfo,
 			for _, ecsService := range dg.EcsServices {
 				if *ecsService.ClusterName == d.config.Cluster && *ecsService.ServiceName == d.config.Service {
 					return &cdTypes.DeploymentInfo{
-						ApplicationName:      app.ApplicationName,
-						DeploymentGroupName:  dg.DeploymentGroupName,
-						DeploymentConfigName: dg.DeploymentConfigName,
+						ApplicationName:                  app.ApplicationName,
+						DeploymentGroupName:              dg.DeploymentGroupName,
+						DeploymentConfigName:             dg.DeploymentConfigName,
+						BlueGreenDeploymentConfiguration: dg.BlueGreenDeploymentConfiguration,
 					}, nil
 				}
 			}
@@ -475,6 +725,38 @@ func (d *App) DeployFunc(sv *Service) (deployFunc, error) {
 	return defaultFunc, nil
 }
 
+// deploymentControllerType returns the type of the deployment controller of the service. ECS is the default.
+func (sv *Service) deploymentControllerType() types.DeploymentControllerType {
+	if sv.DeploymentController == nil || sv.DeploymentController.Type == "" {
+		return types.DeploymentControllerTypeEcs
+	}
+	return sv.DeploymentController.Type
+}
+
+// checkDeploymentController checks the deployment controller of the live service matches the one implied by
+// codedeploy or appspec in the config, and deploymentController in the service definition if def is not nil.
+func checkDeploymentController(live *Service, conf *Config, def *Service) error {
+	name := aws.ToString(live.ServiceName)
+	actual := live.deploymentControllerType()
+	if (conf.CodeDeploy != nil || conf.AppSpec != nil) && actual != types.DeploymentControllerTypeCodeDeploy {
+		return fmt.Errorf(
+			"the service %s uses the %s deployment controller, but codedeploy or appspec is set in the config. "+
+				"remove them from the config for the %s deployment, or recreate the service with the %s deployment controller",
+			name, actual, actual, types.DeploymentControllerTypeCodeDeploy,
+		)
+	}
+	if def != nil && def.DeploymentController != nil {
+		if expected := def.deploymentControllerType(); expected != actual {
+			return fmt.Errorf(
+				"the service %s uses the %s deployment controller, but deploymentController.type in the service definition is %s. "+
+					"fix the service definition to match the service, or recreate the service with the %s deployment controller",
+				name, actual, expected, expected,
+			)
+		}
+	}
+	return nil
+}
+
 func (d *App) UpdateServiceTags(ctx context.Context, sv *Service, added, updated, deleted []types.Tag, opt DeployOption) error {
 	if len(added) == 0 && len(updated) == 0 && len(deleted) == 0 {
 		d.Log("[DEBUG] no service tags to update")
@@ -541,7 +823,9 @@ func (d *App) taskDefinitionArnForDeploy(ctx context.Context, sv *Service, opt D
 		return *sv.TaskDefinition, nil
 	}
 
+	_, span := startSpan(ctx, "render")
 	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
+	endSpan(span, err)
 	if err != nil {
 		return "", err
 	}
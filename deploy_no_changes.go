@@ -0,0 +1,150 @@
+package ecspresso
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func (opt DeployOption) validateNoChanges() error {
+	if opt.QuietNoChanges && opt.SilentNoChanges {
+		return ErrConflictOptions("quiet-no-changes and silent-no-changes are exclusive")
+	}
+	return nil
+}
+
+func (opt DeployOption) skipNoChanges() bool {
+	return opt.QuietNoChanges || opt.SilentNoChanges
+}
+
+// mayBeNoChanges reports whether the deploy can be a no-op by the options.
+// --force-new-deployment and modifying auto scaling always change something.
+func (opt DeployOption) mayBeNoChanges() bool {
+	if opt.ForceNewDeployment {
+		return false
+	}
+	p := opt.ModifyAutoScalingParams()
+	return p.isEmpty()
+}
+
+// bufferedLog holds the outputs of the logger until the deploy turns out to be a no-op or not.
+type bufferedLog struct {
+	mu     sync.Mutex
+	logger *log.Logger
+	w      io.Writer
+	buf    bytes.Buffer
+	done   bool
+}
+
+func bufferLog(l *log.Logger) *bufferedLog {
+	b := &bufferedLog{logger: l, w: l.Writer()}
+	l.SetOutput(b)
+	return b
+}
+
+func (b *bufferedLog) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.done {
+		return b.w.Write(p)
+	}
+	return b.buf.Write(p)
+}
+
+// flush writes the buffered logs and restores the output of the logger.
+func (b *bufferedLog) flush() {
+	b.release(true)
+}
+
+// discard drops the buffered logs and restores the output of the logger.
+func (b *bufferedLog) discard() {
+	b.release(false)
+}
+
+func (b *bufferedLog) release(write bool) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	if b.done {
+		b.mu.Unlock()
+		return
+	}
+	b.done = true
+	if write {
+		b.w.Write(b.buf.Bytes())
+	}
+	b.buf.Reset()
+	b.mu.Unlock()
+	// the logger locks itself while writing to b, so restore it after unlocking b
+	b.logger.SetOutput(b.w)
+}
+
+// deployHasNoChanges reports whether the deploy changes nothing of the running service.
+// The task definition and the service definition are compared with the live ones
+// in the same way as the diff command, without registering a new task definition.
+func (d *App) deployHasNoChanges(ctx context.Context, sv *Service, opt DeployOption) (bool, error) {
+	if !opt.mayBeNoChanges() {
+		return false, nil
+	}
+	diffOpt := &DiffOption{Unified: true, w: io.Discard, ignore: d.config.Ignore}
+
+	switch {
+	case opt.Revision > 0 || opt.LatestTaskDefinition:
+		tdArn, err := d.taskDefinitionArnForDeploy(ctx, sv, opt)
+		if err != nil {
+			return false, err
+		}
+		if arnToName(tdArn) != arnToName(aws.ToString(sv.TaskDefinition)) {
+			d.Log("[DEBUG] task definition will change to %s", tdArn)
+			return false, nil
+		}
+	case opt.SkipTaskDefinition:
+	default:
+		newTd, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
+		if err != nil {
+			return false, err
+		}
+		remoteTd, err := d.DescribeTaskDefinition(ctx, aws.ToString(sv.TaskDefinition))
+		if err != nil {
+			return false, err
+		}
+		differ, err := diffTaskDefs(ctx, newTd, remoteTd, d.config.TaskDefinitionPath, aws.ToString(sv.TaskDefinition), diffOpt)
+		if err != nil {
+			return false, fmt.Errorf("failed to diff of task definitions: %w", err)
+		}
+		if differ {
+			d.Log("[DEBUG] task definition will change")
+			return false, nil
+		}
+	}
+
+	target := sv
+	if d.config.ServiceDefinitionPath != "" && opt.UpdateService {
+		newSv, err := d.loadServiceDefinitionForDeploy(ctx, d.config.ServiceDefinitionPath)
+		if err != nil {
+			return false, err
+		}
+		preserveDesiredCount(newSv, sv)
+		differ, err := diffServices(ctx, newSv, sv, d.config.ServiceDefinitionPath, diffOpt)
+		if err != nil {
+			return false, fmt.Errorf("failed to diff of service definitions: %w", err)
+		}
+		added, updated, deleted := CompareTags(sv.Tags, newSv.Tags)
+		if differ || len(added)+len(updated)+len(deleted) > 0 {
+			d.Log("[DEBUG] service attributes will change")
+			return false, nil
+		}
+		target = newSv
+	}
+	if count := calcDesiredCount(target, opt); count != nil && aws.ToInt32(sv.DesiredCount) != *count {
+		d.Log("[DEBUG] desired count will change to %d", *count)
+		return false, nil
+	}
+	return true, nil
+}
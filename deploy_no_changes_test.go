@@ -0,0 +1,69 @@
+package ecspresso_test
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestDeployOptionValidateNoChanges(t *testing.T) {
+	if err := (ecspresso.DeployOption{QuietNoChanges: true}).ValidateNoChanges(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := (ecspresso.DeployOption{SilentNoChanges: true}).ValidateNoChanges(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := (ecspresso.DeployOption{QuietNoChanges: true, SilentNoChanges: true}).ValidateNoChanges(); err == nil {
+		t.Error("expected error for both options")
+	}
+}
+
+func TestDeployOptionMayBeNoChanges(t *testing.T) {
+	cases := []struct {
+		name string
+		opt  ecspresso.DeployOption
+		want bool
+	}{
+		{name: "default", opt: ecspresso.DeployOption{}, want: true},
+		{name: "skip task definition", opt: ecspresso.DeployOption{SkipTaskDefinition: true}, want: true},
+		{name: "force new deployment", opt: ecspresso.DeployOption{ForceNewDeployment: true}, want: false},
+		{name: "suspend auto scaling", opt: ecspresso.DeployOption{SuspendAutoScaling: aws.Bool(true)}, want: false},
+		{name: "auto scaling min", opt: ecspresso.DeployOption{AutoScalingMin: aws.Int32(1)}, want: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := c.opt.MayBeNoChanges(); got != c.want {
+				t.Errorf("got %v, want %v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestBufferLog(t *testing.T) {
+	var out bytes.Buffer
+	logger := log.New(&out, "", 0)
+
+	flush, _ := ecspresso.BufferLog(logger)
+	logger.Println("starting deploy")
+	if out.Len() != 0 {
+		t.Errorf("logs must be buffered: %q", out.String())
+	}
+	flush()
+	logger.Println("deployed")
+	if got, want := out.String(), "starting deploy\ndeployed\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	flush() // no effect
+
+	out.Reset()
+	_, discard := ecspresso.BufferLog(logger)
+	logger.Println("starting deploy")
+	discard()
+	logger.Println("no changes")
+	if got, want := out.String(), "no changes\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
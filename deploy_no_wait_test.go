@@ -0,0 +1,123 @@
+package ecspresso_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+// the deployment never becomes stable, so waiting for it blocks until the timeout
+var deployNoWaitResponses = map[string]string{
+	"DescribeServices":        `{"services":[{"serviceName":"test","clusterArn":"arn:aws:ecs:ap-northeast-1:123456789012:cluster/default2","status":"ACTIVE","desiredCount":2,"taskDefinition":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:1","deployments":[{"id":"ecs-svc/1","status":"PRIMARY","desiredCount":2,"runningCount":0,"rolloutState":"IN_PROGRESS"}]}],"failures":[]}`,
+	"UpdateService":           `{"service":{"serviceName":"test","status":"ACTIVE","deployments":[{"id":"ecs-svc/2","status":"PRIMARY","desiredCount":2,"runningCount":0,"rolloutState":"IN_PROGRESS"}]}}`,
+	"ListTaskDefinitions":     `{"taskDefinitionArns":[]}`,
+	"DescribeScalableTargets": `{"scalableTargets":[]}`,
+}
+
+func TestDeployNoWait(t *testing.T) {
+	var mu sync.Mutex
+	var ops []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.SplitN(r.Header.Get("X-Amz-Target"), ".", 2)[1]
+		mu.Lock()
+		ops = append(ops, op)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if res, ok := deployNoWaitResponses[op]; ok {
+			w.Write([]byte(res))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+	t.Setenv("AWS_ENDPOINT_URL_APPLICATION_AUTO_SCALING", ts.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = app.Deploy(ctx, ecspresso.DeployOption{
+		DesiredCount:       ptr(int32(-1)),
+		SkipTaskDefinition: true,
+		UpdateService:      false,
+		Wait:               false,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	last := -1
+	for i, op := range ops {
+		if op == "UpdateService" {
+			last = i
+		}
+	}
+	if last < 0 {
+		t.Fatalf("UpdateService is not called: %v", ops)
+	}
+	// the service is not described for waiting after the update
+	for _, op := range ops[last+1:] {
+		if op == "DescribeServices" {
+			t.Errorf("the deployment must not be waited for with --no-wait: %v", ops)
+		}
+	}
+}
+
+func TestDeployPrintTaskDefinitionArn(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.SplitN(r.Header.Get("X-Amz-Target"), ".", 2)[1]
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if res, ok := deployNoWaitResponses[op]; ok {
+			w.Write([]byte(res))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+	t.Setenv("AWS_ENDPOINT_URL_APPLICATION_AUTO_SCALING", ts.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	var stdout, arn bytes.Buffer
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"}, ecspresso.WithStdout(&stdout))
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := ecspresso.DeployOption{
+		DesiredCount:           ptr(int32(-1)),
+		SkipTaskDefinition:     true,
+		UpdateService:          false,
+		Wait:                   false,
+		PrintTaskDefinitionArn: true,
+	}
+	opt.SetArnWriter(&arn)
+	if err := app.Deploy(ctx, opt); err != nil {
+		t.Fatal(err)
+	}
+	if s := arn.String(); s != "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:1\n" {
+		t.Errorf("unexpected ARN output: %q", s)
+	}
+	if !strings.Contains(stdout.String(), "Service: test") {
+		t.Errorf("the status must be written to the writer of the app: %q", stdout.String())
+	}
+	if strings.Contains(stdout.String(), "task-definition/test:1\n") {
+		t.Errorf("the ARN must not be written to the writer of the app: %q", stdout.String())
+	}
+}
@@ -1,10 +1,14 @@
 package ecspresso_test
 
 import (
+	"context"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/google/go-cmp/cmp"
 	"github.com/kayac/ecspresso/v2"
 )
 
@@ -72,3 +76,191 @@ func TestCalcDesiredCount(t *testing.T) {
 		}
 	}
 }
+
+func TestPreserveDesiredCount(t *testing.T) {
+	live := &ecspresso.Service{DesiredCount: aws.Int32(4)}
+	cases := []struct {
+		name     string
+		newSv    *ecspresso.Service
+		expected *int32
+	}{
+		{
+			name:     "omitted",
+			newSv:    &ecspresso.Service{},
+			expected: aws.Int32(4),
+		},
+		{
+			name:     "explicit zero",
+			newSv:    &ecspresso.Service{DesiredCount: aws.Int32(0)},
+			expected: aws.Int32(0),
+		},
+		{
+			name:     "explicit",
+			newSv:    &ecspresso.Service{DesiredCount: aws.Int32(2)},
+			expected: aws.Int32(2),
+		},
+		{
+			name: "daemon",
+			newSv: &ecspresso.Service{
+				Service: types.Service{
+					SchedulingStrategy: types.SchedulingStrategyDaemon,
+				},
+			},
+			expected: nil,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ecspresso.PreserveDesiredCount(c.newSv, live)
+			if diff := cmp.Diff(c.expected, c.newSv.DesiredCount); diff != "" {
+				t.Errorf("unexpected desired count: %s", diff)
+			}
+			in := ecspresso.SvToUpdateServiceInput(c.newSv)
+			if diff := cmp.Diff(c.expected, in.DesiredCount); diff != "" {
+				t.Errorf("unexpected desired count of UpdateService: %s", diff)
+			}
+			count := ecspresso.CalcDesiredCount(c.newSv, ecspresso.DeployOption{DesiredCount: aws.Int32(ecspresso.DefaultDesiredCount)})
+			if diff := cmp.Diff(c.expected, count); diff != "" {
+				t.Errorf("unexpected desired count to deploy: %s", diff)
+			}
+		})
+	}
+	if *live.DesiredCount != 4 {
+		t.Errorf("the live service must not be modified: %d", *live.DesiredCount)
+	}
+}
+
+func TestCheckDeploymentController(t *testing.T) {
+	codeDeploy := &types.DeploymentController{Type: types.DeploymentControllerTypeCodeDeploy}
+	ecsController := &types.DeploymentController{Type: types.DeploymentControllerTypeEcs}
+	newService := func(dc *types.DeploymentController) *ecspresso.Service {
+		return &ecspresso.Service{Service: types.Service{ServiceName: aws.String("test"), DeploymentController: dc}}
+	}
+	cases := []struct {
+		name    string
+		live    *ecspresso.Service
+		conf    *ecspresso.Config
+		def     *ecspresso.Service
+		isError bool
+	}{
+		{
+			name: "ecs",
+			live: newService(ecsController),
+			conf: &ecspresso.Config{},
+		},
+		{
+			name: "default controller",
+			live: newService(nil),
+			conf: &ecspresso.Config{},
+			def:  newService(ecsController),
+		},
+		{
+			name: "codedeploy",
+			live: newService(codeDeploy),
+			conf: &ecspresso.Config{CodeDeploy: &ecspresso.ConfigCodeDeploy{ApplicationName: "app"}},
+			def:  newService(codeDeploy),
+		},
+		{
+			name: "codedeploy without the config",
+			live: newService(codeDeploy),
+			conf: &ecspresso.Config{},
+		},
+		{
+			name:    "codedeploy in the config for ecs",
+			live:    newService(ecsController),
+			conf:    &ecspresso.Config{CodeDeploy: &ecspresso.ConfigCodeDeploy{ApplicationName: "app"}},
+			isError: true,
+		},
+		{
+			name:    "definition mismatch",
+			live:    newService(codeDeploy),
+			conf:    &ecspresso.Config{},
+			def:     newService(ecsController),
+			isError: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ecspresso.CheckDeploymentController(c.live, c.conf, c.def)
+			if c.isError && err == nil {
+				t.Error("expected an error")
+			} else if !c.isError && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestMissingCapacityProviders(t *testing.T) {
+	strategy := []types.CapacityProviderStrategyItem{
+		{CapacityProvider: aws.String("FARGATE"), Base: 1},
+		{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 1},
+		{CapacityProvider: aws.String("ec2-provider"), Weight: 1},
+		{CapacityProvider: aws.String("FARGATE_SPOT"), Weight: 2},
+	}
+	for _, tt := range []struct {
+		associated []string
+		expected   []string
+	}{
+		{associated: nil, expected: []string{"FARGATE", "FARGATE_SPOT", "ec2-provider"}},
+		{associated: []string{"FARGATE", "other"}, expected: []string{"FARGATE_SPOT", "ec2-provider"}},
+		{associated: []string{"FARGATE", "FARGATE_SPOT", "ec2-provider"}, expected: nil},
+	} {
+		missing := ecspresso.MissingCapacityProviders(tt.associated, strategy)
+		if diff := cmp.Diff(tt.expected, missing); diff != "" {
+			t.Errorf("unexpected missing capacity providers for %v: %s", tt.associated, diff)
+		}
+	}
+}
+
+func TestCountActiveRevisions(t *testing.T) {
+	ctx := context.TODO()
+	ecspresso.SetAWSV2ConfigLoadOptionsFunc([]func(*config.LoadOptions) error{
+		config.WithRegion("ap-northeast-1"),
+		config.WithAPIOptions([]func(*middleware.Stack) error{
+			SDKTestingMiddleware("katsubushi"),
+		}),
+	})
+	defer ecspresso.ResetAWSV2ConfigLoadOptionsFunc()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the mock returns 10 revisions of katsubushi
+	for _, tt := range []struct {
+		family   string
+		max      int
+		expected int
+	}{
+		{family: "katsubushi", max: 100, expected: 10},
+		{family: "katsubushi", max: 5, expected: 10},
+		{family: "katsu", max: 100, expected: 0}, // other families with the prefix are not counted
+	} {
+		n, err := app.CountActiveRevisions(ctx, tt.family, tt.max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.expected {
+			t.Errorf("%s: expected %d, got %d", tt.family, tt.expected, n)
+		}
+	}
+}
+
+func TestRevisionsWarning(t *testing.T) {
+	for _, tt := range []struct {
+		flag     *int
+		conf     *ecspresso.Config
+		expected int
+	}{
+		{expected: ecspresso.DefaultRevisionsWarning},
+		{conf: &ecspresso.Config{}, expected: ecspresso.DefaultRevisionsWarning},
+		{conf: &ecspresso.Config{RevisionsWarning: ptr(0)}, expected: 0},
+		{flag: ptr(500), conf: &ecspresso.Config{RevisionsWarning: ptr(0)}, expected: 500},
+		{flag: ptr(0), conf: &ecspresso.Config{RevisionsWarning: ptr(200)}, expected: 0},
+	} {
+		opt := ecspresso.DeployOption{RevisionsWarning: tt.flag}
+		if n := opt.RevisionsWarningOf(tt.conf); n != tt.expected {
+			t.Errorf("expected %d, got %d", tt.expected, n)
+		}
+	}
+}
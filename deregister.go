@@ -3,6 +3,7 @@ package ecspresso
 import (
 	"context"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -16,11 +17,12 @@ import (
 )
 
 type DeregisterOption struct {
-	DryRun   bool   `help:"dry run" default:"false"`
-	Keeps    *int   `help:"number of task definitions to keep except in-use"`
-	Revision string `help:"revision number or 'latest'" default:""`
-	Force    bool   `help:"force deregister without confirmation" default:"false"`
-	Delete   bool   `help:"delete task definition on deregistered" default:"false"`
+	DryRun    bool   `help:"dry run" default:"false"`
+	Keeps     *int   `help:"number of task definitions to keep except in-use"`
+	OlderThan int32  `help:"deregister only the revisions older than the revision number with --keeps"`
+	Revision  string `help:"revision number or 'latest'" default:""`
+	Force     bool   `help:"force deregister without confirmation" default:"false"`
+	Delete    bool   `help:"delete task definition on deregistered" default:"false"`
 }
 
 func (opt DeregisterOption) DryRunString() string {
@@ -40,7 +42,13 @@ func (d *App) Deregister(ctx context.Context, opt DeregisterOption) error {
 		return err
 	}
 
+	if opt.OlderThan < 0 {
+		return fmt.Errorf("--older-than must not be negative: %d", opt.OlderThan)
+	}
 	if opt.Revision != "" {
+		if opt.OlderThan > 0 {
+			return ErrConflictOptions("revision and older-than are exclusive")
+		}
 		return d.deregiserRevision(ctx, opt, inUse)
 	} else if opt.Keeps != nil && *opt.Keeps > 0 {
 		return d.deregisterKeeps(ctx, opt, inUse)
@@ -89,7 +97,7 @@ func (d *App) deregiserRevision(ctx context.Context, opt DeregisterOption, inUse
 		return fmt.Errorf("confirmation failed")
 	}
 
-	d.Log("Deregistring %s", name)
+	d.Log("Deregistering %s", name)
 	if _, err := d.ecs.DeregisterTaskDefinition(ctx, &ecs.DeregisterTaskDefinitionInput{
 		TaskDefinition: aws.String(name),
 	}); err != nil {
@@ -109,63 +117,47 @@ func (d *App) deregiserRevision(ctx context.Context, opt DeregisterOption, inUse
 }
 
 func (d *App) deregisterKeeps(ctx context.Context, opt DeregisterOption, inUse map[string]string) error {
-	keeps := aws.ToInt(opt.Keeps)
 	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
 	if err != nil {
 		return err
 	}
-	names := []string{}
-	var nextToken *string
-	for {
-		res, err := d.ecs.ListTaskDefinitions(ctx, &ecs.ListTaskDefinitionsInput{
-			FamilyPrefix: td.Family,
-			NextToken:    nextToken,
-		})
-		if err != nil {
-			return fmt.Errorf("failed to list task definitions: %w", err)
-		}
-		for _, a := range res.TaskDefinitionArns {
-			name, err := taskDefinitionToName(a)
-			if err != nil {
-				continue
-			}
-			if s := inUse[name]; s != "" {
-				d.Log("%s is in use by %s. skip", name, s)
-			} else {
-				d.Log("[DEBUG] %s is marked to deregister", name)
-				names = append(names, name)
-			}
-		}
-		if nextToken = res.NextToken; nextToken == nil {
-			break
-		}
+	if err := d.deregisterOldRevisions(ctx, aws.ToString(td.Family), opt, inUse); err != nil {
+		return err
+	}
+	if opt.DryRun {
+		d.Log("DRY RUN OK")
 	}
+	return nil
+}
 
-	deregs := []string{}
-	idx := len(names) - keeps
-	if idx <= 0 {
+// deregisterOldRevisions deregisters the ACTIVE revisions of the family
+// except the opt.Keeps most recent ones, the ones in use and the ones not older than opt.OlderThan.
+func (d *App) deregisterOldRevisions(ctx context.Context, family string, opt DeregisterOption, inUse map[string]string) error {
+	keeps := aws.ToInt(opt.Keeps)
+	revisions, err := d.listActiveRevisions(ctx, family)
+	if err != nil {
+		return err
+	}
+	deregs := deregisterTargets(family, revisions, opt.OlderThan, keeps, inUse)
+	if len(deregs) == 0 {
 		d.Log("No need to deregister task definitions")
 		return nil
 	}
-	for i, name := range names {
-		if i < idx {
-			d.Log("%s will be deregistered", name)
-			deregs = append(deregs, name)
-		}
+	for _, name := range deregs {
+		d.Log("%s will be deregistered", name)
 	}
 	if opt.DryRun {
-		d.Log("DRY RUN OK")
 		return nil
 	}
 
 	deregistered := 0
-	confirmed := opt.Force || prompter.YesNo(fmt.Sprintf("Deregister %d revisons?", len(deregs)), false)
+	confirmed := opt.Force || prompter.YesNo(fmt.Sprintf("Deregister %d revisions?", len(deregs)), false)
 	if !confirmed {
 		d.Log("Aborted")
 		return fmt.Errorf("confirmation failed")
 	}
 	for _, name := range deregs {
-		d.Log("Deregistring %s", name)
+		d.Log("Deregistering %s", name)
 		if _, err := d.ecs.DeregisterTaskDefinition(ctx, &ecs.DeregisterTaskDefinitionInput{
 			TaskDefinition: aws.String(name),
 		}); err != nil {
@@ -190,6 +182,66 @@ func (d *App) deregisterKeeps(ctx context.Context, opt DeregisterOption, inUse m
 	return nil
 }
 
+// deregisterTargets returns the names of the revisions to be deregistered, from the oldest.
+// The revisions in use, the keeps most recent revisions except them,
+// and the revisions newer than or equal to olderThan (0 means no bound) are kept.
+func deregisterTargets(family string, revisions []int32, olderThan int32, keeps int, inUse map[string]string) []string {
+	revisions = append([]int32{}, revisions...)
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i] > revisions[j] })
+	names := []string{}
+	kept := 0
+	for _, rev := range revisions {
+		name := fmt.Sprintf("%s:%d", family, rev)
+		if inUse[name] != "" {
+			continue
+		}
+		if kept < keeps {
+			kept++
+			continue
+		}
+		if olderThan > 0 && rev >= olderThan {
+			continue
+		}
+		names = append(names, name)
+	}
+	return lo.Reverse(names)
+}
+
+// listActiveRevisions returns the revision numbers of the ACTIVE task definitions of the family.
+func (d *App) listActiveRevisions(ctx context.Context, family string) ([]int32, error) {
+	var revisions []int32
+	var nextToken *string
+	for {
+		res, err := d.ecs.ListTaskDefinitions(ctx, &ecs.ListTaskDefinitionsInput{
+			FamilyPrefix: aws.String(family),
+			Status:       types.TaskDefinitionStatusActive,
+			NextToken:    nextToken,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list task definitions: %w", err)
+		}
+		for _, a := range res.TaskDefinitionArns {
+			// FamilyPrefix also matches the other families which have the same prefix
+			if f, rev := taskDefinitionRevision(a); f == family && rev > 0 {
+				revisions = append(revisions, rev)
+			}
+		}
+		if nextToken = res.NextToken; nextToken == nil {
+			return revisions, nil
+		}
+	}
+}
+
+// taskDefinitionRevision returns the family and the revision of the task definition ARN or family:revision.
+func taskDefinitionRevision(tdArn string) (string, int32) {
+	family, rev, _ := strings.Cut(arnToName(tdArn), ":")
+	n, err := strconv.ParseInt(rev, 10, 32)
+	if err != nil {
+		return family, 0
+	}
+	return family, int32(n)
+}
+
 func (d *App) inUseRevisions(ctx context.Context) (map[string]string, error) {
 	inUse := make(map[string]string)
 	tasks, err := d.listTasks(ctx)
@@ -209,15 +261,30 @@ func (d *App) inUseRevisions(ctx context.Context) (map[string]string, error) {
 		d.Log("[DEBUG] %s is in use by tasks", name)
 	}
 
-	if d.config.Service != "" {
-		sv, err := d.DescribeService(ctx)
+	// the revisions may be used by the other services in the cluster
+	p := ecs.NewListServicesPaginator(d.ecs, &ecs.ListServicesInput{
+		Cluster: aws.String(d.Cluster),
+	})
+	for p.HasMorePages() {
+		out, err := p.NextPage(ctx)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to list services: %w", err)
 		}
-		for _, dp := range sv.Deployments {
-			name, _ := taskDefinitionToName(*dp.TaskDefinition)
-			inUse[name] = fmt.Sprintf("%s deployment", *dp.Status)
-			d.Log("[DEBUG] %s is in use by deployments", name)
+		for _, arns := range lo.Chunk(out.ServiceArns, 10) { // 10 is max batch size
+			res, err := d.ecs.DescribeServices(ctx, &ecs.DescribeServicesInput{
+				Cluster:  aws.String(d.Cluster),
+				Services: arns,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe services: %w", err)
+			}
+			for _, sv := range res.Services {
+				for _, dp := range sv.Deployments {
+					name, _ := taskDefinitionToName(aws.ToString(dp.TaskDefinition))
+					inUse[name] = fmt.Sprintf("%s deployment of %s", aws.ToString(dp.Status), aws.ToString(sv.ServiceName))
+					d.Log("[DEBUG] %s is in use by deployments of %s", name, aws.ToString(sv.ServiceName))
+				}
+			}
 		}
 	}
 	return inUse, nil
@@ -231,3 +298,55 @@ func taskDefinitionToName(a string) (string, error) {
 	n := strings.SplitN(an.Resource, "/", 2)
 	return n[1], nil
 }
+
+// countActiveRevisions counts ACTIVE revisions of the task definition family.
+// Counting stops when the count exceeds max.
+func (d *App) countActiveRevisions(ctx context.Context, family string, max int) (int, error) {
+	var n int
+	var nextToken *string
+	for {
+		res, err := d.ecs.ListTaskDefinitions(ctx, &ecs.ListTaskDefinitionsInput{
+			FamilyPrefix: &family,
+			Status:       types.TaskDefinitionStatusActive,
+			NextToken:    nextToken,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to list task definitions: %w", err)
+		}
+		for _, a := range res.TaskDefinitionArns {
+			// FamilyPrefix also matches the other families which have the same prefix
+			if name, err := taskDefinitionToName(a); err == nil && strings.HasPrefix(name, family+":") {
+				n++
+			}
+		}
+		if n > max {
+			return n, nil
+		}
+		if nextToken = res.NextToken; nextToken == nil {
+			return n, nil
+		}
+	}
+}
+
+// warnActiveRevisions warns when ACTIVE revisions of the family of the task definition exceed the threshold.
+// It is informational only, so failures are also reported as warnings.
+func (d *App) warnActiveRevisions(ctx context.Context, tdArn string, threshold int) {
+	if threshold <= 0 {
+		return
+	}
+	family, rev := taskDefinitionRevision(tdArn)
+	if rev > 0 && int(rev) <= threshold {
+		// a family can not have more ACTIVE revisions than the revision number
+		d.Log("[DEBUG] %s has %d revisions at most. skip counting ACTIVE revisions", family, rev)
+		return
+	}
+	n, err := d.countActiveRevisions(ctx, family, threshold)
+	if err != nil {
+		d.Log("[WARNING] failed to count ACTIVE revisions of %s: %s", family, err)
+		return
+	}
+	d.Log("[DEBUG] %s has %d ACTIVE revisions at least", family, n)
+	if n > threshold {
+		d.Log("[WARNING] task definition family %s has more than %d ACTIVE revisions. consider deregistering old revisions by `ecspresso deregister --keeps N`", family, threshold)
+	}
+}
@@ -0,0 +1,89 @@
+package ecspresso_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestDeregisterTargets(t *testing.T) {
+	revisions := []int32{1, 2, 3, 4, 5, 6, 7, 8}
+	cases := []struct {
+		name      string
+		olderThan int32
+		keeps     int
+		inUse     map[string]string
+		want      []string
+	}{
+		{
+			name:  "keeps less than revisions",
+			keeps: 5,
+			want:  []string{"app:1", "app:2", "app:3"},
+		},
+		{
+			name:  "keeps equal to revisions",
+			keeps: 8,
+			want:  []string{},
+		},
+		{
+			name:  "keeps more than revisions",
+			keeps: 9,
+			want:  []string{},
+		},
+		{
+			name:  "keeps one less than revisions",
+			keeps: 7,
+			want:  []string{"app:1"},
+		},
+		{
+			name:      "older than",
+			olderThan: 3,
+			keeps:     1,
+			want:      []string{"app:1", "app:2"},
+		},
+		{
+			name:      "older than and keeps",
+			olderThan: 6,
+			keeps:     4,
+			want:      []string{"app:1", "app:2", "app:3", "app:4"},
+		},
+		{
+			name:  "in use",
+			keeps: 5,
+			inUse: map[string]string{"app:2": "RUNNING task"},
+			want:  []string{"app:1", "app:3"},
+		},
+		{
+			name:      "in use and older than",
+			olderThan: 7,
+			keeps:     2,
+			inUse:     map[string]string{"app:8": "service"},
+			want:      []string{"app:1", "app:2", "app:3", "app:4", "app:5"},
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ecspresso.DeregisterTargets("app", revisions, c.olderThan, c.keeps, c.inUse)
+			if diff := cmp.Diff(c.want, got); diff != "" {
+				t.Errorf("unexpected targets (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestTaskDefinitionRevision(t *testing.T) {
+	for s, want := range map[string]struct {
+		family   string
+		revision int32
+	}{
+		"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:12": {"app", 12},
+		"app:3": {"app", 3},
+		"app":   {"app", 0},
+	} {
+		family, rev := ecspresso.TaskDefinitionRevision(s)
+		if family != want.family || rev != want.revision {
+			t.Errorf("unexpected revision of %s: %s %d", s, family, rev)
+		}
+	}
+}
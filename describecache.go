@@ -0,0 +1,159 @@
+package ecspresso
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// describeCache caches results of DescribeServices and DescribeTaskDefinition in the process.
+// It is cleared by any ECS API call other than Describe*, List* and Get*.
+type describeCache struct {
+	mu      sync.Mutex
+	results map[string]any
+}
+
+func newDescribeCache() *describeCache {
+	return &describeCache{results: make(map[string]any)}
+}
+
+func (c *describeCache) get(key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	v, ok := c.results[key]
+	return v, ok
+}
+
+func (c *describeCache) set(key string, v any) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results[key] = v
+}
+
+func (c *describeCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = make(map[string]any)
+}
+
+// isReadOnlyOperation reports whether the ECS API operation does not mutate any resources.
+func isReadOnlyOperation(op string) bool {
+	return strings.HasPrefix(op, "Describe") || strings.HasPrefix(op, "List") || strings.HasPrefix(op, "Get")
+}
+
+// invalidateMiddleware returns an API option to clear the cache on mutations.
+func (c *describeCache) invalidateMiddleware() func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(
+			middleware.InitializeMiddlewareFunc(
+				"ecspressoDescribeCacheInvalidation",
+				func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+					if !isReadOnlyOperation(middleware.GetOperationName(ctx)) {
+						c.clear()
+					}
+					return next.HandleInitialize(ctx, in)
+				},
+			),
+			middleware.Before,
+		)
+	}
+}
+
+type noDescribeCacheKey struct{}
+
+// withoutDescribeCache returns a context to fetch the latest state ignoring the cache,
+// e.g. for polling until the service becomes stable.
+func withoutDescribeCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noDescribeCacheKey{}, true)
+}
+
+// cachedDescribe calls fn with the cache keyed by the operation and the input.
+// The results are deep copies, so callers may modify them without breaking the cache.
+func cachedDescribe[I, O any](ctx context.Context, c *describeCache, op string, in *I, fn func(context.Context, *I, ...func(*ecs.Options)) (*O, error)) (*O, error) {
+	if c == nil {
+		return fn(ctx, in)
+	}
+	b, err := json.Marshal(in)
+	if err != nil {
+		return fn(ctx, in)
+	}
+	key := op + ":" + string(b)
+	if ctx.Value(noDescribeCacheKey{}) == nil {
+		if v, ok := c.get(key); ok {
+			Log("[DEBUG] %s is cached", op)
+			return deepCopy(v.(*O)), nil
+		}
+	}
+	out, err := fn(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	c.set(key, deepCopy(out))
+	return out, nil
+}
+
+// deepCopy returns a deep copy of v. Unexported fields are copied shallowly.
+func deepCopy[T any](v T) T {
+	return deepCopyValue(reflect.ValueOf(&v).Elem()).Interface().(T)
+}
+
+func deepCopyValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Elem().Type())
+		c.Elem().Set(deepCopyValue(v.Elem()))
+		return c
+	case reflect.Struct:
+		c := reflect.New(v.Type()).Elem()
+		c.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := c.Field(i); f.CanSet() {
+				f.Set(deepCopyValue(v.Field(i)))
+			}
+		}
+		return c
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			c.Index(i).Set(deepCopyValue(v.Index(i)))
+		}
+		return c
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), deepCopyValue(iter.Value()))
+		}
+		return c
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		c := reflect.New(v.Type()).Elem()
+		c.Set(deepCopyValue(v.Elem()))
+		return c
+	default:
+		return v
+	}
+}
+
+// ClearDescribeCache clears the cache of the remote state enabled by WithDescribeCache.
+func (d *App) ClearDescribeCache() {
+	if d.describeCache != nil {
+		d.describeCache.clear()
+	}
+}
@@ -0,0 +1,132 @@
+package ecspresso_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+var describeCacheResponses = map[string]string{
+	"DescribeServices":       `{"services":[{"serviceName":"test","clusterArn":"arn:aws:ecs:ap-northeast-1:123456789012:cluster/default2","status":"ACTIVE","taskDefinition":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:1"}],"failures":[]}`,
+	"DescribeTaskDefinition": `{"taskDefinition":{"taskDefinitionArn":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:1","family":"test","revision":1,"status":"ACTIVE"}}`,
+	"RegisterTaskDefinition": `{"taskDefinition":{"taskDefinitionArn":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:2","family":"test","revision":2,"status":"ACTIVE"}}`,
+}
+
+type describeCacheServer struct {
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+func (s *describeCacheServer) count(op string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[op]
+}
+
+func newDescribeCacheApp(t *testing.T, opts ...ecspresso.AppOption) (*ecspresso.App, *describeCacheServer) {
+	t.Helper()
+	s := &describeCacheServer{calls: map[string]int{}}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.SplitN(r.Header.Get("X-Amz-Target"), ".", 2)[1]
+		s.mu.Lock()
+		s.calls[op]++
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(describeCacheResponses[op]))
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+
+	app, err := ecspresso.New(context.Background(), &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"}, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, s
+}
+
+func TestDescribeCache(t *testing.T) {
+	ctx := context.Background()
+	app, s := newDescribeCacheApp(t, ecspresso.WithDescribeCache())
+
+	for i := 0; i < 2; i++ {
+		if _, err := app.DescribeService(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := app.DescribeTaskDefinition(ctx, "test:1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.count("DescribeServices"); n != 1 {
+		t.Errorf("DescribeServices must be cached, but called %d times", n)
+	}
+	if n := s.count("DescribeTaskDefinition"); n != 1 {
+		t.Errorf("DescribeTaskDefinition must be cached, but called %d times", n)
+	}
+
+	// a mutation invalidates the cache
+	if _, err := app.RegisterTaskDefinition(ctx, &ecspresso.TaskDefinitionInput{Family: aws.String("test")}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.DescribeService(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count("DescribeServices"); n != 2 {
+		t.Errorf("DescribeServices must be called after the mutation, but called %d times", n)
+	}
+
+	app.ClearDescribeCache()
+	if _, err := app.DescribeTaskDefinition(ctx, "test:1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count("DescribeTaskDefinition"); n != 2 {
+		t.Errorf("DescribeTaskDefinition must be called after clearing the cache, but called %d times", n)
+	}
+}
+
+func TestDescribeCacheDisabled(t *testing.T) {
+	ctx := context.Background()
+	app, s := newDescribeCacheApp(t)
+	for i := 0; i < 2; i++ {
+		if _, err := app.DescribeService(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.count("DescribeServices"); n != 2 {
+		t.Errorf("DescribeServices must not be cached by default, but called %d times", n)
+	}
+}
+
+func TestDescribeCacheReturnsCopies(t *testing.T) {
+	ctx := context.Background()
+	app, s := newDescribeCacheApp(t, ecspresso.WithDescribeCache())
+
+	sv, err := app.DescribeService(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sv.TaskDefinition = aws.String("modified")
+	sv.Deployments = append(sv.Deployments, types.Deployment{Id: aws.String("modified")})
+
+	cached, err := app.DescribeService(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := s.count("DescribeServices"); n != 1 {
+		t.Errorf("DescribeServices must be cached, but called %d times", n)
+	}
+	if td := aws.ToString(cached.TaskDefinition); td != "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:1" {
+		t.Errorf("the cache must not be modified by the caller: %s", td)
+	}
+	if len(cached.Deployments) != 0 {
+		t.Errorf("the cache must not be modified by the caller: %v", cached.Deployments)
+	}
+}
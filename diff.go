@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
@@ -28,22 +29,28 @@ import (
 type DiffOption struct {
 	Unified  bool   `help:"unified diff format" default:"true" negatable:""`
 	External string `help:"external command to format diff" env:"ECSPRESSO_DIFF_COMMAND"`
+	Refresh  bool   `help:"fetch the remote state again ignoring the cache in the process" default:"false"`
 
-	w io.Writer `kong:"-"`
+	w      io.Writer     `kong:"-"`
+	ignore *ConfigIgnore `kong:"-"` // ignore.paths are masked on both sides
 }
 
 func (d *App) Diff(ctx context.Context, opt DiffOption) error {
 	ctx, cancel := d.Start(ctx)
 	defer cancel()
 	if opt.w == nil {
-		opt.w = os.Stdout
+		opt.w = d.stdout
+	}
+	opt.ignore = d.config.Ignore
+	if opt.Refresh {
+		d.ClearDescribeCache()
 	}
 
 	var remoteTaskDefArn string
 	// diff for services only when service defined
 	if d.config.Service != "" {
 		d.Log("[DEBUG] diff service compare with %s", d.config.Service)
-		newSv, err := d.LoadServiceDefinition(d.config.ServiceDefinitionPath)
+		newSv, err := d.loadServiceDefinitionForDeploy(ctx, d.config.ServiceDefinitionPath)
 		if err != nil {
 			return fmt.Errorf("failed to load service definition: %w", err)
 		}
@@ -60,6 +67,7 @@ func (d *App) Diff(ctx context.Context, opt DiffOption) error {
 		}
 		if remoteSv != nil {
 			remoteTaskDefArn = *remoteSv.TaskDefinition
+			fmt.Fprint(opt.w, diffTags("service", remoteSv.Tags, newSv.Tags))
 		}
 	}
 
@@ -91,10 +99,44 @@ func (d *App) Diff(ctx context.Context, opt DiffOption) error {
 	if _, err := diffTaskDefs(ctx, newTd, remoteTd, d.config.TaskDefinitionPath, remoteTaskDefArn, &opt); err != nil {
 		return err
 	}
+	if remoteTd != nil {
+		fmt.Fprint(opt.w, diffTags("task definition", remoteTd.Tags, newTd.Tags))
+	}
 
 	return nil
 }
 
+// diffTags returns the summary of added, updated and deleted tags.
+// Tags in the ignore list are already filtered out on loading the definitions.
+func diffTags(name string, remote, local []types.Tag) string {
+	added, updated, deleted := CompareTags(remote, local)
+	if len(added) == 0 && len(updated) == 0 && len(deleted) == 0 {
+		return ""
+	}
+	remoteValues := make(map[string]string, len(remote))
+	for _, t := range remote {
+		remoteValues[aws.ToString(t.Key)] = aws.ToString(t.Value)
+	}
+	byKey := func(tags []types.Tag) []types.Tag {
+		sort.SliceStable(tags, func(i, j int) bool {
+			return aws.ToString(tags[i].Key) < aws.ToString(tags[j].Key)
+		})
+		return tags
+	}
+	var lines []string
+	for _, t := range byKey(added) {
+		lines = append(lines, fmt.Sprintf("+ %s=%s", aws.ToString(t.Key), aws.ToString(t.Value)))
+	}
+	for _, t := range byKey(updated) {
+		key := aws.ToString(t.Key)
+		lines = append(lines, fmt.Sprintf("~ %s=%s -> %s", key, remoteValues[key], aws.ToString(t.Value)))
+	}
+	for _, t := range byKey(deleted) {
+		lines = append(lines, fmt.Sprintf("- %s=%s", aws.ToString(t.Key), aws.ToString(t.Value)))
+	}
+	return fmt.Sprintf("# tags of %s\n", name) + coloredDiff(strings.Join(lines, "\n"))
+}
+
 type ServiceForDiff struct {
 	*ecs.UpdateServiceInput
 	Tags []types.Tag
@@ -109,7 +151,8 @@ func diffServices(ctx context.Context, local, remote *Service, localPath string,
 	localSvForDiff := ServiceDefinitionForDiff(local)
 	remoteSvForDiff := ServiceDefinitionForDiff(remote)
 
-	newSvBytes, err := MarshalJSONForAPI(localSvForDiff)
+	queries := opt.ignore.pathQueries(ignorePathService)
+	newSvBytes, err := MarshalJSONForAPI(localSvForDiff, queries...)
 	if err != nil {
 		return false, fmt.Errorf("failed to marshal new service definition: %w", err)
 	}
@@ -117,7 +160,7 @@ func diffServices(ctx context.Context, local, remote *Service, localPath string,
 		// ignore DesiredCount when it in local is not defined.
 		remoteSvForDiff.UpdateServiceInput.DesiredCount = nil
 	}
-	remoteSvBytes, err := MarshalJSONForAPI(remoteSvForDiff)
+	remoteSvBytes, err := MarshalJSONForAPI(remoteSvForDiff, queries...)
 	if err != nil {
 		return false, fmt.Errorf("failed to marshal remote service definition: %w", err)
 	}
@@ -135,24 +178,27 @@ func diffServices(ctx context.Context, local, remote *Service, localPath string,
 		edits := myers.ComputeEdits(span.URIFromPath(remoteArn), remoteSv, newSv)
 		ds := fmt.Sprint(gotextdiff.ToUnified(remoteArn, localPath, remoteSv, edits))
 		fmt.Fprint(opt.w, coloredDiff(ds))
-		return true, nil
 	default:
 		ds := diff.Diff(remoteSv, newSv)
 		fmt.Fprint(opt.w, coloredDiff(fmt.Sprintf("--- %s\n+++ %s\n%s", remoteArn, localPath, ds)))
-		return true, nil
 	}
+	if remote != nil {
+		fmt.Fprint(opt.w, annotateServiceDiff(remoteSvBytes, newSvBytes))
+	}
+	return true, nil
 }
 
 func diffTaskDefs(ctx context.Context, local, remote *TaskDefinitionInput, localPath, remoteArn string, opt *DiffOption) (bool, error) {
 	sortTaskDefinition(local)
 	sortTaskDefinition(remote)
 
-	newTdBytes, err := MarshalJSONForAPI(local)
+	queries := opt.ignore.pathQueries(ignorePathTaskDefinition)
+	newTdBytes, err := MarshalJSONForAPI(local, queries...)
 	if err != nil {
 		return false, fmt.Errorf("failed to marshal new task definition: %w", err)
 	}
 
-	remoteTdBytes, err := MarshalJSONForAPI(remote)
+	remoteTdBytes, err := MarshalJSONForAPI(remote, queries...)
 	if err != nil {
 		return false, fmt.Errorf("failed to marshal remote task definition: %w", err)
 	}
@@ -170,12 +216,87 @@ func diffTaskDefs(ctx context.Context, local, remote *TaskDefinitionInput, local
 		edits := myers.ComputeEdits(span.URIFromPath(remoteArn), remoteTd, newTd)
 		ds := fmt.Sprint(gotextdiff.ToUnified(remoteArn, localPath, remoteTd, edits))
 		fmt.Fprint(opt.w, coloredDiff(ds))
-		return true, nil
 	default:
 		ds := diff.Diff(remoteTd, newTd)
 		fmt.Fprint(opt.w, coloredDiff(fmt.Sprintf("--- %s\n+++ %s\n%s", remoteArn, localPath, ds)))
-		return true, nil
 	}
+	if remote != nil {
+		fmt.Fprintf(opt.w, "# taskDefinition: %s\n", impactNewDeployment)
+	}
+	return true, nil
+}
+
+type deploymentImpact string
+
+const (
+	impactNewDeployment deploymentImpact = "new deployment"
+	impactInPlace       deploymentImpact = "in-place update"
+	impactUnknown       deploymentImpact = "unknown"
+)
+
+// serviceFieldImpacts classifies the changes of service definition fields
+// whether they start a new deployment (replacing tasks) or not.
+var serviceFieldImpacts = map[string]deploymentImpact{
+	"capacityProviderStrategy":      impactNewDeployment,
+	"deploymentConfiguration":       impactInPlace,
+	"desiredCount":                  impactInPlace,
+	"enableECSManagedTags":          impactInPlace,
+	"enableExecuteCommand":          impactInPlace,
+	"forceNewDeployment":            impactNewDeployment,
+	"healthCheckGracePeriodSeconds": impactInPlace,
+	"loadBalancers":                 impactNewDeployment,
+	"networkConfiguration":          impactNewDeployment,
+	"placementConstraints":          impactNewDeployment,
+	"placementStrategy":             impactNewDeployment,
+	"platformVersion":               impactNewDeployment,
+	"propagateTags":                 impactInPlace,
+	"serviceConnectConfiguration":   impactNewDeployment,
+	"serviceRegistries":             impactNewDeployment,
+	"tags":                          impactInPlace,
+	"taskDefinition":                impactNewDeployment,
+	"volumeConfigurations":          impactNewDeployment,
+}
+
+// changedFields returns the top-level field names which differ between two JSON objects.
+func changedFields(remote, local []byte) ([]string, error) {
+	var rm, lm map[string]interface{}
+	if err := json.Unmarshal(remote, &rm); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(local, &lm); err != nil {
+		return nil, err
+	}
+	var fields []string
+	for k, v := range lm {
+		if !reflect.DeepEqual(v, rm[k]) {
+			fields = append(fields, k)
+		}
+	}
+	for k := range rm {
+		if _, ok := lm[k]; !ok {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+	return fields, nil
+}
+
+// annotateServiceDiff returns comment lines showing the deployment impact of each changed field.
+func annotateServiceDiff(remote, local []byte) string {
+	fields, err := changedFields(remote, local)
+	if err != nil {
+		Log("[WARNING] failed to annotate diff: %s", err)
+		return ""
+	}
+	var b strings.Builder
+	for _, f := range fields {
+		impact, ok := serviceFieldImpacts[f]
+		if !ok {
+			impact = impactUnknown
+		}
+		fmt.Fprintf(&b, "# %s: %s\n", f, impact)
+	}
+	return b.String()
 }
 
 func diffExternal(ctx context.Context, diffCmd string, target, remote, local string, opt *DiffOption) error {
@@ -357,6 +478,34 @@ func sortTaskDefinition(td *TaskDefinitionInput) {
 		sort.SliceStable(cd.Secrets, func(i, j int) bool {
 			return aws.ToString(cd.Secrets[i].Name) < aws.ToString(cd.Secrets[j].Name)
 		})
+		// environmentFiles are not sorted. ECS processes them in order,
+		// so the order decides which value wins for the same variable.
+		for i, ef := range cd.EnvironmentFiles {
+			if ef.Type == "" {
+				ef.Type = types.EnvironmentFileTypeS3 // s3 is the only and default type
+			}
+			cd.EnvironmentFiles[i] = ef
+		}
+		sort.SliceStable(cd.SystemControls, func(i, j int) bool {
+			return aws.ToString(cd.SystemControls[i].Namespace) < aws.ToString(cd.SystemControls[j].Namespace)
+		})
+		if len(cd.SystemControls) == 0 {
+			cd.SystemControls = nil // ECS returns an empty systemControls
+		}
+		sort.SliceStable(cd.Ulimits, func(i, j int) bool {
+			return cd.Ulimits[i].Name < cd.Ulimits[j].Name
+		})
+		if len(cd.Ulimits) == 0 {
+			cd.Ulimits = nil
+		}
+		if rp := cd.RestartPolicy; rp != nil {
+			sort.SliceStable(rp.IgnoredExitCodes, func(i, j int) bool {
+				return rp.IgnoredExitCodes[i] < rp.IgnoredExitCodes[j]
+			})
+			if len(rp.IgnoredExitCodes) == 0 {
+				rp.IgnoredExitCodes = nil
+			}
+		}
 		td.ContainerDefinitions[i] = cd // set sorted value
 	}
 	sort.SliceStable(td.PlacementConstraints, func(i, j int) bool {
@@ -382,10 +531,13 @@ func sortTaskDefinition(td *TaskDefinitionInput) {
 	if td.Memory != nil {
 		td.Memory = toNumberMemory(*td.Memory)
 	}
-	if td.ProxyConfiguration != nil && len(td.ProxyConfiguration.Properties) > 0 {
-		p := td.ProxyConfiguration.Properties
-		sort.SliceStable(p, func(i, j int) bool {
-			return aws.ToString(p[i].Name) < aws.ToString(p[j].Name)
+	if pc := td.ProxyConfiguration; pc != nil {
+		// APPMESH is the only and default type of the proxy configuration
+		if pc.Type == "" {
+			pc.Type = types.ProxyConfigurationTypeAppmesh
+		}
+		sort.SliceStable(pc.Properties, func(i, j int) bool {
+			return aws.ToString(pc.Properties[i].Name) < aws.ToString(pc.Properties[j].Name)
 		})
 	}
 }
@@ -3,6 +3,7 @@ package ecspresso_test
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 
@@ -360,3 +361,333 @@ func TestDiffTaskDefs(t *testing.T) {
 		}
 	})
 }
+
+func TestDiffTaskDefsAppMesh(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := app.LoadTaskDefinition("tests/td-appmesh.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pc := local.ProxyConfiguration; pc == nil || aws.ToString(pc.ContainerName) != "envoy" || len(pc.Properties) != 5 {
+		t.Fatalf("unexpected proxyConfiguration %#v", pc)
+	}
+
+	// remote has the type filled by ECS and properties in a different order
+	remote, err := app.LoadTaskDefinition("tests/td-appmesh.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.ProxyConfiguration.Type = types.ProxyConfigurationTypeAppmesh
+	p := remote.ProxyConfiguration.Properties
+	for i, j := 0, len(p)-1; i < j; i, j = i+1, j-1 {
+		p[i], p[j] = p[j], p[i]
+	}
+
+	b := new(bytes.Buffer)
+	opt := &ecspresso.DiffOption{Unified: true}
+	opt.SetWriter(b)
+	differ, err := ecspresso.DiffTaskDefs(ctx, local, remote, "tests/td-appmesh.json", "remote", opt)
+	if err != nil {
+		t.Error(err)
+	}
+	if differ {
+		t.Errorf("unexpected diff: %s", b.String())
+	}
+}
+
+func TestDiffTaskDefsEnvironmentFiles(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := app.LoadTaskDefinition("tests/td-envfiles.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if efs := local.ContainerDefinitions[0].EnvironmentFiles; len(efs) != 2 {
+		t.Fatalf("unexpected environmentFiles %#v", efs)
+	}
+
+	// remote has the type filled by ECS
+	remote, err := app.LoadTaskDefinition("tests/td-envfiles.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.ContainerDefinitions[0].EnvironmentFiles[0].Type = types.EnvironmentFileTypeS3
+
+	b := new(bytes.Buffer)
+	opt := &ecspresso.DiffOption{Unified: true}
+	opt.SetWriter(b)
+	differ, err := ecspresso.DiffTaskDefs(ctx, local, remote, "tests/td-envfiles.json", "remote", opt)
+	if err != nil {
+		t.Error(err)
+	}
+	if differ {
+		t.Errorf("unexpected diff: %s", b.String())
+	}
+
+	// the order of environmentFiles is significant
+	efs := remote.ContainerDefinitions[0].EnvironmentFiles
+	efs[0], efs[1] = efs[1], efs[0]
+	b.Reset()
+	differ, err = ecspresso.DiffTaskDefs(ctx, local, remote, "tests/td-envfiles.json", "remote", opt)
+	if err != nil {
+		t.Error(err)
+	}
+	if !differ {
+		t.Error("reordered environmentFiles must be a diff")
+	}
+}
+
+func TestDiffTaskDefsRestartPolicy(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := app.LoadTaskDefinition("tests/td-restart-policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rp := local.ContainerDefinitions[0].RestartPolicy
+	if rp == nil || !aws.ToBool(rp.Enabled) || aws.ToInt32(rp.RestartAttemptPeriod) != 180 || len(rp.IgnoredExitCodes) != 2 {
+		t.Fatalf("unexpected restartPolicy %#v", rp)
+	}
+
+	// the order of ignoredExitCodes is not significant
+	remote, err := app.LoadTaskDefinition("tests/td-restart-policy.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	remote.ContainerDefinitions[0].RestartPolicy.IgnoredExitCodes = []int32{0, 2}
+
+	b := new(bytes.Buffer)
+	opt := &ecspresso.DiffOption{Unified: true}
+	opt.SetWriter(b)
+	differ, err := ecspresso.DiffTaskDefs(ctx, local, remote, "tests/td-restart-policy.json", "remote", opt)
+	if err != nil {
+		t.Error(err)
+	}
+	if differ {
+		t.Errorf("unexpected diff: %s", b.String())
+	}
+
+	remote.ContainerDefinitions[0].RestartPolicy.RestartAttemptPeriod = aws.Int32(300)
+	b.Reset()
+	differ, err = ecspresso.DiffTaskDefs(ctx, local, remote, "tests/td-restart-policy.json", "remote", opt)
+	if err != nil {
+		t.Error(err)
+	}
+	if !differ || !strings.Contains(b.String(), "restartAttemptPeriod") {
+		t.Errorf("restartAttemptPeriod must be a diff: %s", b.String())
+	}
+}
+
+func TestDiffTaskDefsSystemControlsAndUlimits(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := app.LoadTaskDefinition("tests/td-ulimits.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	app0 := local.ContainerDefinitions[0]
+	if len(app0.SystemControls) != 2 || len(app0.Ulimits) != 2 {
+		t.Fatalf("unexpected systemControls and ulimits %#v %#v", app0.SystemControls, app0.Ulimits)
+	}
+	b, err := ecspresso.MarshalJSONForAPI(local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{`"systemControls"`, `"net.core.somaxconn"`, `"ulimits"`, `"nofile"`, `"hardLimit": 65536`} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Errorf("%s is not found in the task definition for API: %s", s, string(b))
+		}
+	}
+
+	// the order of systemControls and ulimits is not significant, and ECS returns an empty systemControls
+	remote, err := app.LoadTaskDefinition("tests/td-ulimits.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc := &remote.ContainerDefinitions[0]
+	rc.SystemControls[0], rc.SystemControls[1] = rc.SystemControls[1], rc.SystemControls[0]
+	rc.Ulimits[0], rc.Ulimits[1] = rc.Ulimits[1], rc.Ulimits[0]
+	remote.ContainerDefinitions[1].SystemControls = []types.SystemControl{}
+	remote.ContainerDefinitions[1].Ulimits = []types.Ulimit{}
+
+	buf := new(bytes.Buffer)
+	opt := &ecspresso.DiffOption{Unified: true}
+	opt.SetWriter(buf)
+	differ, err := ecspresso.DiffTaskDefs(ctx, local, remote, "tests/td-ulimits.json", "remote", opt)
+	if err != nil {
+		t.Error(err)
+	}
+	if differ {
+		t.Errorf("unexpected diff: %s", buf.String())
+	}
+
+	for i, u := range rc.Ulimits {
+		if u.Name == types.UlimitNameNofile {
+			rc.Ulimits[i].SoftLimit = 1024
+		}
+	}
+	buf.Reset()
+	differ, err = ecspresso.DiffTaskDefs(ctx, local, remote, "tests/td-ulimits.json", "remote", opt)
+	if err != nil {
+		t.Error(err)
+	}
+	if !differ || !strings.Contains(buf.String(), `"softLimit": 1024`) {
+		t.Errorf("softLimit of ulimits must be a diff: %s", buf.String())
+	}
+}
+
+func TestAnnotateServiceDiff(t *testing.T) {
+	remote := []byte(`{"desiredCount":1,"platformVersion":"1.4.0","enableExecuteCommand":true,"foo":"bar"}`)
+	local := []byte(`{"desiredCount":2,"platformVersion":"LATEST","enableExecuteCommand":true}`)
+	expected := strings.Join([]string{
+		"# desiredCount: in-place update",
+		"# foo: unknown",
+		"# platformVersion: new deployment",
+		"",
+	}, "\n")
+	if got := ecspresso.AnnotateServiceDiff(remote, local); got != expected {
+		t.Errorf("unexpected annotation %s", cmp.Diff(expected, got))
+	}
+}
+
+func TestDiffTags(t *testing.T) {
+	noColor := color.NoColor
+	color.NoColor = true
+	defer func() { color.NoColor = noColor }()
+
+	tag := func(k, v string) types.Tag {
+		return types.Tag{Key: aws.String(k), Value: aws.String(v)}
+	}
+	remote := []types.Tag{tag("Env", "prod"), tag("Team", "infra"), tag("Owner", "bob")}
+	local := []types.Tag{tag("Env", "prod"), tag("Team", "platform"), tag("Cost", "123"), tag("App", "web")}
+	expected := strings.Join([]string{
+		"# tags of service",
+		"+ App=web",
+		"+ Cost=123",
+		"~ Team=infra -> platform",
+		"- Owner=bob",
+		"",
+	}, "\n")
+	if got := ecspresso.DiffTags("service", remote, local); got != expected {
+		t.Errorf("unexpected tags diff %s", cmp.Diff(expected, got))
+	}
+	if got := ecspresso.DiffTags("service", remote, remote); got != "" {
+		t.Errorf("expected no tags diff, but %s", got)
+	}
+}
+
+func TestDiffWithIgnorePaths(t *testing.T) {
+	ctx := context.Background()
+	color.NoColor = true
+	ignore := &ecspresso.ConfigIgnore{
+		Paths: []string{
+			"service.desiredCount",
+			"service.deploymentConfiguration.maximumPercent",
+			"taskDefinition.containerDefinitions[*].image",
+		},
+	}
+	if err := ignore.CompilePaths(); err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	opt := &ecspresso.DiffOption{Unified: true}
+	opt.SetWriter(b)
+	opt.SetIgnore(ignore)
+
+	t.Run("service", func(t *testing.T) {
+		b.Reset()
+		local := &ecspresso.Service{
+			Service: types.Service{
+				LaunchType:              types.LaunchTypeFargate,
+				DeploymentConfiguration: &types.DeploymentConfiguration{MaximumPercent: aws.Int32(200)},
+			},
+			DesiredCount: ptr(int32(2)),
+		}
+		remote := &ecspresso.Service{
+			Service: types.Service{
+				LaunchType:              types.LaunchTypeFargate,
+				DeploymentConfiguration: &types.DeploymentConfiguration{MaximumPercent: aws.Int32(150)},
+			},
+			DesiredCount: ptr(int32(5)),
+		}
+		differ, err := ecspresso.DiffServices(ctx, local, remote, "file", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if differ || b.String() != "" {
+			t.Errorf("unexpected diff of ignored paths: %s", b.String())
+		}
+
+		remote.LaunchType = types.LaunchTypeEc2
+		differ, err = ecspresso.DiffServices(ctx, local, remote, "file", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !differ {
+			t.Error("expected diff of launchType")
+		}
+		if s := b.String(); strings.Contains(s, "desiredCount") || strings.Contains(s, "maximumPercent") {
+			t.Errorf("ignored paths must be masked in the diff: %s", s)
+		}
+	})
+
+	t.Run("task definition", func(t *testing.T) {
+		b.Reset()
+		td := func(images ...string) *ecspresso.TaskDefinitionInput {
+			td := &ecspresso.TaskDefinitionInput{Family: aws.String("app")}
+			for i, image := range images {
+				td.ContainerDefinitions = append(td.ContainerDefinitions, types.ContainerDefinition{
+					Name:  aws.String(fmt.Sprintf("container%d", i)),
+					Image: aws.String(image),
+				})
+			}
+			return td
+		}
+		differ, err := ecspresso.DiffTaskDefs(ctx, td("nginx:1.25", "envoy:v1"), td("nginx:1.24", "envoy:v2"), "file", "arn", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if differ || b.String() != "" {
+			t.Errorf("unexpected diff of ignored paths: %s", b.String())
+		}
+
+		differ, err = ecspresso.DiffTaskDefs(ctx, td("nginx:1.25"), td("nginx:1.24", "envoy:v2"), "file", "arn", opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !differ {
+			t.Error("expected diff of containerDefinitions")
+		}
+	})
+}
+
+func TestConfigIgnorePathsInvalid(t *testing.T) {
+	for _, p := range []string{
+		"desiredCount",
+		"service",
+		"service.",
+		"service.containers[x]",
+		"taskDefinition.containerDefinitions[*]..image",
+		"cluster.name",
+	} {
+		conf := ecspresso.NewDefaultConfig()
+		conf.Ignore = &ecspresso.ConfigIgnore{Paths: []string{p}}
+		err := conf.Restrict(context.Background())
+		if err == nil || !strings.Contains(err.Error(), "ignore.paths has an invalid path") {
+			t.Errorf("%s: unexpected error: %v", p, err)
+		}
+	}
+}
@@ -6,13 +6,15 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"reflect"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
 	aasTypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
@@ -141,15 +143,22 @@ type App struct {
 	sd          *servicediscovery.Client
 	verifier    *verifier
 
-	config *Config
-	loader *configLoader
-	logger *log.Logger
+	config        *Config
+	loader        *configLoader
+	logger        *log.Logger
+	logFormat     string
+	describeCache *describeCache
+	stdout        io.Writer // outputs other than logs, e.g. the status of the service
 }
 
 type appOptions struct {
-	config *Config
-	loader *configLoader
-	logger *log.Logger
+	config        *Config
+	loader        *configLoader
+	logger        *log.Logger
+	noAWS         bool
+	strictConfig  bool
+	describeCache bool
+	stdout        io.Writer
 }
 
 type AppOption func(*appOptions)
@@ -166,6 +175,36 @@ func WithConfigLoader(extstr, extcode map[string]string) AppOption {
 	}
 }
 
+// WithoutAWS makes functions of plugins calling AWS APIs return a placeholder instead.
+func WithoutAWS() AppOption {
+	return func(o *appOptions) {
+		o.noAWS = true
+	}
+}
+
+// WithStrictConfig makes unknown fields in the config file errors instead of warnings.
+func WithStrictConfig() AppOption {
+	return func(o *appOptions) {
+		o.strictConfig = true
+	}
+}
+
+// WithDescribeCache enables the cache of DescribeServices and DescribeTaskDefinition results in the App.
+// The cache is cleared by any mutation through the App, so composite workflows like diff and deploy
+// in the same process fetch the remote state once.
+func WithDescribeCache() AppOption {
+	return func(o *appOptions) {
+		o.describeCache = true
+	}
+}
+
+// WithStdout sets the writer of the outputs other than logs. The default is os.Stdout.
+func WithStdout(w io.Writer) AppOption {
+	return func(o *appOptions) {
+		o.stdout = w
+	}
+}
+
 func WithLogger(l *log.Logger) AppOption {
 	return func(o *appOptions) {
 		o.logger = l
@@ -173,42 +212,68 @@ func WithLogger(l *log.Logger) AppOption {
 }
 
 func New(ctx context.Context, opt *CLIOptions, newAppOptions ...AppOption) (*App, error) {
-	opt.resolveConfigFilePath()
+	if _, err := opt.resolveConfigFilePath(); err != nil {
+		return nil, err
+	}
 
+	loader := newConfigLoader(opt.ExtStr, opt.ExtCode)
+	loader.setTLA(opt.TLAStr, opt.TLACode)
 	appOpts := appOptions{
-		loader: newConfigLoader(opt.ExtStr, opt.ExtCode),
+		loader: loader,
 		logger: newLogger(),
+		stdout: os.Stdout,
 	}
 	for _, fn := range newAppOptions {
 		fn(&appOpts)
 	}
 
 	// set log level
+	minLevel := logFilterLevel(opt.LogLevel)
 	if opt.Debug {
-		appOpts.logger.SetOutput(newLogFilter(os.Stderr, "DEBUG"))
-	} else {
-		appOpts.logger.SetOutput(newLogFilter(os.Stderr, "INFO"))
+		minLevel = "DEBUG"
 	}
+	setLogOutput(commonLogger, os.Stderr, opt.LogFormat, minLevel, nil)
 	Log("[INFO] ecspresso version: %s", Version)
 
 	// load config file
+	appOpts.loader.noAWS = appOpts.noAWS
+	appOpts.loader.baseDir = opt.ConfigBaseDir
+	appOpts.loader.overrides = opt
+	appOpts.loader.overlay = opt.Overlay
+	appOpts.loader.strictVersion = opt.Strict
+	appOpts.loader.strictConfig = opt.StrictConfig || appOpts.strictConfig
+	appOpts.loader.noProjectConfig = opt.NoProjectConfig
+	if err := appOpts.loader.setJsonnetLibs(opt.JsonnetLib, opt.ConfigFilePath); err != nil {
+		return nil, err
+	}
 	if appOpts.config == nil {
-		if config, err := appOpts.loader.Load(ctx, opt.ConfigFilePath, Version); err != nil {
+		_, span := startSpan(ctx, "load")
+		config, err := appOpts.loader.Load(ctx, opt.ConfigFilePath, Version)
+		endSpan(span, err)
+		if err != nil {
 			return nil, fmt.Errorf("failed to load config file %s: %w", opt.ConfigFilePath, err)
-		} else {
-			appOpts.config = config
 		}
+		appOpts.config = config
 	}
 	conf := appOpts.config
 	conf.OverrideByCLIOptions(opt)
-	conf.AssumeRole(opt.AssumeRoleARN)
+	conf.AssumeRoleWithOptions(conf.AssumeRoleConfig)
+
+	var cache *describeCache
+	var ecsOptFns []func(*ecs.Options)
+	if appOpts.describeCache {
+		cache = newDescribeCache()
+		ecsOptFns = append(ecsOptFns, func(o *ecs.Options) {
+			o.APIOptions = append(o.APIOptions, cache.invalidateMiddleware())
+		})
+	}
 
 	// new app
 	d := &App{
 		Service: conf.Service,
 		Cluster: conf.Cluster,
 
-		ecs:         ecs.NewFromConfig(conf.awsv2Config),
+		ecs:         ecs.NewFromConfig(conf.awsv2Config, ecsOptFns...),
 		autoScaling: applicationautoscaling.NewFromConfig(conf.awsv2Config),
 		codedeploy:  codedeploy.NewFromConfig(conf.awsv2Config),
 		cwl:         cloudwatchlogs.NewFromConfig(conf.awsv2Config),
@@ -218,7 +283,13 @@ func New(ctx context.Context, opt *CLIOptions, newAppOptions ...AppOption) (*App
 		loader:      appOpts.loader,
 		config:      appOpts.config,
 		logger:      appOpts.logger,
+		logFormat:   opt.LogFormat,
+
+		describeCache: cache,
+		stdout:        appOpts.stdout,
 	}
+	setLogOutput(d.logger, os.Stderr, opt.LogFormat, minLevel, d.logFields)
+	conf.logf = d.Log
 
 	d.Log("[DEBUG] config file path: %s", opt.ConfigFilePath)
 	d.Log("[DEBUG] timeout: %s", d.config.Timeout)
@@ -241,6 +312,14 @@ func (d *App) Start(ctx context.Context) (context.Context, context.CancelFunc) {
 	}
 }
 
+// startPhase is Start with the timeout of the phase in timeouts of the config.
+func (d *App) startPhase(ctx context.Context, phase timeoutPhase) (context.Context, context.CancelFunc) {
+	if t := d.config.timeoutFor(phase); t > 0 {
+		return context.WithTimeout(ctx, t)
+	}
+	return ctx, func() {}
+}
+
 func (d *App) DescribeServicesInput() *ecs.DescribeServicesInput {
 	return &ecs.DescribeServicesInput{
 		Cluster:  aws.String(d.Cluster),
@@ -266,7 +345,7 @@ func (d *App) GetLogEventsInput(logGroup string, logStream string, startAt int64
 }
 
 func (d *App) DescribeService(ctx context.Context) (*Service, error) {
-	out, err := d.ecs.DescribeServices(ctx, d.DescribeServicesInput())
+	out, err := cachedDescribe(ctx, d.describeCache, "DescribeServices", d.DescribeServicesInput(), d.ecs.DescribeServices)
 	if err != nil {
 		return nil, fmt.Errorf("failed to describe service: %w", err)
 	}
@@ -297,19 +376,19 @@ func (d *App) DescribeServiceStatus(ctx context.Context, events int) (*Service,
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("Service:", *s.ServiceName)
-	fmt.Println("Cluster:", arnToName(*s.ClusterArn))
-	fmt.Println("TaskDefinition:", arnToName(*s.TaskDefinition))
+	fmt.Fprintln(d.stdout, "Service:", *s.ServiceName)
+	fmt.Fprintln(d.stdout, "Cluster:", arnToName(*s.ClusterArn))
+	fmt.Fprintln(d.stdout, "TaskDefinition:", arnToName(*s.TaskDefinition))
 	if len(s.Deployments) > 0 {
-		fmt.Println("Deployments:")
+		fmt.Fprintln(d.stdout, "Deployments:")
 		for _, dep := range s.Deployments {
-			fmt.Println(spcIndent + formatDeployment(dep))
+			fmt.Fprintln(d.stdout, spcIndent+formatDeployment(dep))
 		}
 	}
 	if len(s.TaskSets) > 0 {
-		fmt.Println("TaskSets:")
+		fmt.Fprintln(d.stdout, "TaskSets:")
 		for _, ts := range s.TaskSets {
-			fmt.Println(spcIndent + formatTaskSet(ts))
+			fmt.Fprintln(d.stdout, spcIndent+formatTaskSet(ts))
 		}
 	}
 
@@ -317,13 +396,13 @@ func (d *App) DescribeServiceStatus(ctx context.Context, events int) (*Service,
 		return nil, fmt.Errorf("failed to describe autoscaling: %w", err)
 	}
 
-	fmt.Println("Events:")
+	fmt.Fprintln(d.stdout, "Events:")
 	sort.SliceStable(s.Events, func(i, j int) bool {
 		return s.Events[i].CreatedAt.Before(*s.Events[j].CreatedAt)
 	})
 	head := lo.Max([]int{len(s.Events) - events, 0})
 	for i := head; i < len(s.Events); i++ {
-		fmt.Println(formatEvent(s.Events[i]))
+		fmt.Fprintln(d.stdout, formatEvent(s.Events[i]))
 	}
 	return s, nil
 }
@@ -350,9 +429,9 @@ func (d *App) describeAutoScaling(ctx context.Context, s *Service) error {
 		return nil
 	}
 
-	fmt.Println("AutoScaling:")
+	fmt.Fprintln(d.stdout, "AutoScaling:")
 	for _, target := range tout.ScalableTargets {
-		fmt.Println(formatScalableTarget(target))
+		fmt.Fprintln(d.stdout, formatScalableTarget(target))
 	}
 
 	pout, err := d.autoScaling.DescribeScalingPolicies(
@@ -372,7 +451,7 @@ func (d *App) describeAutoScaling(ctx context.Context, s *Service) error {
 		return fmt.Errorf("failed to describe scaling policies: %w", err)
 	}
 	for _, policy := range pout.ScalingPolicies {
-		fmt.Println(formatScalingPolicy(policy))
+		fmt.Fprintln(d.stdout, formatScalingPolicy(policy))
 	}
 	return nil
 }
@@ -388,39 +467,54 @@ func (d *App) DescribeTaskStatus(ctx context.Context, task *types.Task, watchCon
 		return fmt.Errorf(*f.Reason)
 	}
 
-	ts := out.Tasks[0]
-	if ts.StopCode == types.TaskStopCodeTaskFailedToStart {
-		return fmt.Errorf("task failed to start: %s", aws.ToString(ts.StoppedReason))
-	}
+	return taskStoppedStatus(&out.Tasks[0], aws.ToString(watchContainer.Name))
+}
 
-	var container *types.Container
-	for _, c := range ts.Containers {
-		if *c.Name == *watchContainer.Name {
-			container = &c
-			break
+// taskStoppedStatus returns the error of the task by the exit codes of the containers.
+// The watch container is checked first, and then the other containers.
+func taskStoppedStatus(task *types.Task, watchContainer string) error {
+	if task.StopCode == types.TaskStopCodeTaskFailedToStart {
+		return fmt.Errorf("task failed to start: %s", aws.ToString(task.StoppedReason))
+	}
+	if len(task.Containers) == 0 {
+		return nil
+	}
+	containers := make([]types.Container, 0, len(task.Containers))
+	watch := task.Containers[0]
+	for _, c := range task.Containers {
+		if aws.ToString(c.Name) == watchContainer {
+			watch = c
 		}
 	}
-	if container == nil {
-		container = &(ts.Containers[0])
+	containers = append(containers, watch)
+	for _, c := range task.Containers {
+		if aws.ToString(c.Name) != aws.ToString(watch.Name) {
+			containers = append(containers, c)
+		}
 	}
-
-	if container.ExitCode != nil && *container.ExitCode != 0 {
-		msg := fmt.Sprintf("container: %s, exit code: %s", *container.Name, strconv.FormatInt(int64(*container.ExitCode), 10))
-		if container.Reason != nil {
-			msg += ", reason: " + *container.Reason
+	for _, c := range containers {
+		if c.ExitCode != nil && *c.ExitCode != 0 {
+			return &ErrContainerExit{
+				Container: aws.ToString(c.Name),
+				ExitCode:  *c.ExitCode,
+				Reason:    aws.ToString(c.Reason),
+			}
 		}
-		return fmt.Errorf(msg)
-	} else if container.Reason != nil {
-		return fmt.Errorf("container: %s, reason: %s", *container.Name, *container.Reason)
+	}
+	if watch.Reason != nil {
+		return fmt.Errorf("container: %s, reason: %s", aws.ToString(watch.Name), aws.ToString(watch.Reason))
+	}
+	if aws.ToString(task.LastStatus) == "STOPPED" && task.StartedAt == nil {
+		return fmt.Errorf("task is stopped before running: %s", aws.ToString(task.StoppedReason))
 	}
 	return nil
 }
 
 func (d *App) DescribeTaskDefinition(ctx context.Context, tdArn string) (*TaskDefinitionInput, error) {
-	out, err := d.ecs.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+	out, err := cachedDescribe(ctx, d.describeCache, "DescribeTaskDefinition", &ecs.DescribeTaskDefinitionInput{
 		TaskDefinition: &tdArn,
 		Include:        []types.TaskDefinitionField{types.TaskDefinitionFieldTags},
-	})
+	}, d.ecs.DescribeTaskDefinition)
 	if err != nil {
 		return nil, fmt.Errorf("failed to describe task definition: %w", err)
 	}
@@ -442,7 +536,7 @@ func (d *App) GetLogEvents(ctx context.Context, logGroup string, logStream strin
 		return nextToken, nil
 	}
 	for _, event := range out.Events {
-		fmt.Println(formatLogEvent(event))
+		fmt.Fprintln(d.stdout, formatLogEvent(event))
 	}
 	return out.NextForwardToken, nil
 }
@@ -483,14 +577,14 @@ func (d *App) Name() string {
 
 func (d *App) RegisterTaskDefinition(ctx context.Context, td *TaskDefinitionInput) (*TaskDefinition, error) {
 	d.Log("Registering a new task definition...")
-	if len(td.Tags) == 0 {
-		td.Tags = nil // Tags can not be empty.
-	}
+	d.prepareTaskDefinitionForRegister(td)
 	tdi := ecs.RegisterTaskDefinitionInput(*td)
+	ctx, span := startSpan(ctx, "register")
 	out, err := d.ecs.RegisterTaskDefinition(
 		ctx,
 		&tdi,
 	)
+	endSpan(span, err)
 	if err != nil {
 		return nil, fmt.Errorf("failed to register task definition: %w", err)
 	}
@@ -499,6 +593,25 @@ func (d *App) RegisterTaskDefinition(ctx context.Context, td *TaskDefinitionInpu
 	return &otd, nil
 }
 
+// prepareTaskDefinitionForRegister modifies the task definition to the input of RegisterTaskDefinition.
+func (d *App) prepareTaskDefinitionForRegister(td *TaskDefinitionInput) {
+	if len(td.Tags) == 0 {
+		td.Tags = nil // Tags can not be empty.
+	}
+	if d.config.SortEnvironment {
+		sortEnvironment(td)
+	}
+}
+
+// sortEnvironment sorts environment variables of each container by name.
+func sortEnvironment(td *TaskDefinitionInput) {
+	for _, cd := range td.ContainerDefinitions {
+		sort.SliceStable(cd.Environment, func(i, j int) bool {
+			return aws.ToString(cd.Environment[i].Name) < aws.ToString(cd.Environment[j].Name)
+		})
+	}
+}
+
 func (d *App) LoadTaskDefinition(path string) (*TaskDefinitionInput, error) {
 	src, err := d.readDefinitionFile(path)
 	if err != nil {
@@ -521,6 +634,9 @@ func (d *App) LoadTaskDefinition(path string) (*TaskDefinitionInput, error) {
 	if len(td.Tags) == 0 {
 		td.Tags = nil
 	}
+	if err := d.config.Tags.Apply(&td); err != nil {
+		return nil, fmt.Errorf("failed to apply tags: %w", err)
+	}
 	if err := d.config.Ignore.Apply(&td); err != nil {
 		return nil, fmt.Errorf("failed to apply ignore: %w", err)
 	}
@@ -536,11 +652,71 @@ func unmarshalYAML(src []byte, v interface{}, path string) error {
 	return unmarshalJSON(b, v, path)
 }
 
+// unmarshalYAMLStrict is unmarshalYAML which fails on unknown fields.
+// The YAML decoder detects them to report the line, then the source is decoded by JSON as usual.
+func unmarshalYAMLStrict(src []byte, v interface{}, path string) error {
+	probe := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+	if err := yaml.NewDecoder(bytes.NewReader(src), yaml.DisallowUnknownField()).Decode(probe); err != nil && isUnknownFieldError(err) {
+		return fmt.Errorf("%s in %s:\n%s", unknownFieldsMessage, path, yaml.FormatError(err, false, true))
+	}
+	b, err := yaml.YAMLToJSON(src)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return unmarshalJSONStrict(b, v, path)
+}
+
+func unmarshalTOML(src []byte, v interface{}, path string) error {
+	b, err := tomlToJSON(src)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return unmarshalJSON(b, v, path)
+}
+
+// tomlToJSON converts TOML to JSON.
+// Date and time values of TOML are converted to strings in RFC 3339 format.
+func tomlToJSON(src []byte) ([]byte, error) {
+	var v map[string]interface{}
+	if err := toml.Unmarshal(src, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// unmarshalTOMLStrict is unmarshalTOML which fails on unknown fields.
+func unmarshalTOMLStrict(src []byte, v interface{}, path string) error {
+	b, err := tomlToJSON(src)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return unmarshalJSONStrict(b, v, path)
+}
+
+const unknownFieldsMessage = "unknown fields are not allowed by --strict-config"
+
+func isUnknownFieldError(err error) bool {
+	return strings.Contains(err.Error(), "unknown field")
+}
+
+// unmarshalJSONStrict is unmarshalJSON which fails on unknown fields, instead of warning.
+func unmarshalJSONStrict(src []byte, v interface{}, path string) error {
+	strict := json.NewDecoder(bytes.NewReader(src))
+	strict.DisallowUnknownFields()
+	if err := strict.Decode(&v); err != nil {
+		if isUnknownFieldError(err) {
+			return fmt.Errorf("%s in %s: %w", unknownFieldsMessage, path, err)
+		}
+		return err
+	}
+	return nil
+}
+
 func unmarshalJSON(src []byte, v interface{}, path string) error {
 	strict := json.NewDecoder(bytes.NewReader(src))
 	strict.DisallowUnknownFields()
 	if err := strict.Decode(&v); err != nil {
-		if !strings.Contains(err.Error(), "unknown field") {
+		if !isUnknownFieldError(err) {
 			return err
 		}
 		Log("[WARNING] %s in %s", err, path)
@@ -551,7 +727,20 @@ func unmarshalJSON(src []byte, v interface{}, path string) error {
 	return nil
 }
 
+// LoadServiceDefinition loads the service definition.
+// loadBalancers[].targetGroupName is not resolved, use loadServiceDefinitionForDeploy to create or update the service.
 func (d *App) LoadServiceDefinition(path string) (*Service, error) {
+	return d.loadServiceDefinition(path, d.stripTargetGroupNames)
+}
+
+// loadServiceDefinitionForDeploy loads the service definition and resolves loadBalancers[].targetGroupName to targetGroupArn.
+func (d *App) loadServiceDefinitionForDeploy(ctx context.Context, path string) (*Service, error) {
+	return d.loadServiceDefinition(path, func(src []byte) ([]byte, error) {
+		return d.resolveTargetGroupNames(ctx, src)
+	})
+}
+
+func (d *App) loadServiceDefinition(path string, replaceTargetGroupNames func([]byte) ([]byte, error)) (*Service, error) {
 	if path == "" {
 		return nil, fmt.Errorf("service_definition is not defined")
 	}
@@ -561,6 +750,12 @@ func (d *App) LoadServiceDefinition(path string) (*Service, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to load service definition %s: %w", path, err)
 	}
+	if src, err = replaceTargetGroupNames(src); err != nil {
+		return nil, fmt.Errorf("failed to load service definition %s: %w", path, err)
+	}
+	if src, err = coerceLooseNumbersJSON(src, &sv); err != nil {
+		return nil, fmt.Errorf("failed to load service definition %s: %w", path, err)
+	}
 	if err := unmarshalJSON(src, &sv, path); err != nil {
 		return nil, fmt.Errorf("failed to load service definition %s: %w", path, err)
 	}
@@ -572,6 +767,9 @@ func (d *App) LoadServiceDefinition(path string) (*Service, error) {
 		d.Log("[DEBUG] Loaded DesiredCount: %d", *sv.DesiredCount)
 	}
 
+	if err := d.config.Tags.Apply(&sv); err != nil {
+		return nil, fmt.Errorf("failed to apply tags: %w", err)
+	}
 	if err := d.config.Ignore.Apply(&sv); err != nil {
 		return nil, fmt.Errorf("failed to apply ignore: %w", err)
 	}
@@ -5,6 +5,9 @@ import (
 	"encoding/json"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
 	"github.com/kayac/ecspresso/v2"
 )
 
@@ -28,6 +31,7 @@ func TestLoadTaskDefinition(t *testing.T) {
 		"tests/td-in-tags.json",
 		"tests/td-plain-in-tags.json",
 		"tests/td.jsonnet",
+		"tests/td.toml",
 	} {
 		app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{
 			ConfigFilePath: "tests/td-config.yml",
@@ -56,7 +60,7 @@ func TestLoadTaskDefinition(t *testing.T) {
 
 func TestLoadTaskDefinitionTags(t *testing.T) {
 	ctx := context.Background()
-	for _, path := range []string{"tests/td.json", "tests/td-plain.json", "tests/td.jsonnet"} {
+	for _, path := range []string{"tests/td.json", "tests/td-plain.json", "tests/td.jsonnet", "tests/td.toml"} {
 		app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{
 			ConfigFilePath: "tests/td-config.yml",
 			ExtStr:         map[string]string{"WorkerID": "3"},
@@ -85,3 +89,55 @@ func TestLoadTaskDefinitionTags(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadTaskDefinitionTOML(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAG", "v1.2.3")
+	fromJSON, err := app.LoadTaskDefinition("tests/td.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fromTOML, err := app.LoadTaskDefinition("tests/td.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(str(fromJSON), str(fromTOML)); diff != "" {
+		t.Errorf("unexpected task definition loaded from TOML: %s", diff)
+	}
+	if img := aws.ToString(fromTOML.ContainerDefinitions[0].Image); img != "katsubushi/katsubushi:v1.2.3" {
+		t.Errorf("unexpected image %s", img)
+	}
+}
+
+func TestSortEnvironment(t *testing.T) {
+	td := &ecspresso.TaskDefinitionInput{
+		ContainerDefinitions: []types.ContainerDefinition{
+			{
+				Name: aws.String("app"),
+				Environment: []types.KeyValuePair{
+					{Name: aws.String("ZONE"), Value: aws.String("a")},
+					{Name: aws.String("APP_ENV"), Value: aws.String("production")},
+					{Name: aws.String("LOG_LEVEL"), Value: aws.String("info")},
+				},
+			},
+			{
+				Name: aws.String("sidecar"),
+			},
+		},
+	}
+	ecspresso.SortEnvironment(td)
+	var names []string
+	for _, kv := range td.ContainerDefinitions[0].Environment {
+		names = append(names, aws.ToString(kv.Name))
+	}
+	if diff := cmp.Diff([]string{"APP_ENV", "LOG_LEVEL", "ZONE"}, names); diff != "" {
+		t.Errorf("unexpected order of environment: %s", diff)
+	}
+	if td.ContainerDefinitions[1].Environment != nil {
+		t.Errorf("environment of sidecar must be nil, got %v", td.ContainerDefinitions[1].Environment)
+	}
+}
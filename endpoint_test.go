@@ -0,0 +1,89 @@
+package ecspresso_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestServiceSpecificEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	var targets []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		targets = append(targets, r.Header.Get("X-Amz-Target"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"services":[],"failures":[]}`))
+	}))
+	defer ts.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.DescribeService(ctx); !errors.As(err, new(ecspresso.ErrNotFound)) {
+		t.Errorf("expected ErrNotFound from the mock endpoint, but got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(targets) != 1 || targets[0] != "AmazonEC2ContainerServiceV20141113.DescribeServices" {
+		t.Errorf("unexpected requests to the ECS endpoint: %v", targets)
+	}
+}
+
+func TestConfigEndpoint(t *testing.T) {
+	var mu sync.Mutex
+	var targets []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		targets = append(targets, r.Header.Get("X-Amz-Target"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"services":[],"failures":[]}`))
+	}))
+	defer ts.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("TEST_ENDPOINT", ts.URL)
+
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/endpoint.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.DescribeService(ctx); !errors.As(err, new(ecspresso.ErrNotFound)) {
+		t.Errorf("expected ErrNotFound from the mock endpoint, but got %v", err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(targets) != 1 || targets[0] != "AmazonEC2ContainerServiceV20141113.DescribeServices" {
+		t.Errorf("unexpected requests to the endpoint: %v", targets)
+	}
+}
+
+func TestS3PathStyle(t *testing.T) {
+	t.Setenv("AWS_ENDPOINT_URL_S3", "")
+	var o s3.Options
+	ecspresso.S3PathStyle(aws.Config{})(&o)
+	if o.UsePathStyle {
+		t.Error("path-style must not be used without a custom endpoint")
+	}
+	ecspresso.S3PathStyle(aws.Config{BaseEndpoint: aws.String("http://localhost:4566")})(&o)
+	if !o.UsePathStyle {
+		t.Error("path-style must be used with a custom endpoint")
+	}
+}
@@ -0,0 +1,72 @@
+package ecspresso
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	goConfig "github.com/kayac/go-config"
+)
+
+// envDefaultRegexp matches ${VAR:-default} and ${VAR:?message}. $${...} is an escape to output ${...} as is.
+var envDefaultRegexp = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*):([-?])([^}]*)\}`)
+
+// expandEnvDefaults expands the shell-style default and required syntax of environment variables.
+// ${VAR:-default} is expanded to default when VAR is unset or empty.
+// ${VAR:?message} fails with the message when VAR is unset or empty.
+// ${VAR} without them is left as is for the template of go-config.
+func expandEnvDefaults(src []byte) ([]byte, error) {
+	var errs []error
+	dst := envDefaultRegexp.ReplaceAllFunc(src, func(m []byte) []byte {
+		if m[1] == '$' { // escaped
+			return m[1:]
+		}
+		sm := envDefaultRegexp.FindSubmatch(m)
+		name, op, word := string(sm[1]), string(sm[2]), string(sm[3])
+		if v := os.Getenv(name); v != "" {
+			return []byte(v)
+		}
+		if op == "?" {
+			if word == "" {
+				word = "parameter null or not set"
+			}
+			errs = append(errs, fmt.Errorf("%s: %s", name, word))
+			return m
+		}
+		return []byte(word)
+	})
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return dst, nil
+}
+
+func readWithEnv(r *goConfig.Loader, path string) ([]byte, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	src, err = expandEnvDefaults(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand environment variables in %s: %w", path, err)
+	}
+	return r.ReadWithEnvBytes(src)
+}
+
+func readWithEnvBytes(r *goConfig.Loader, src []byte) ([]byte, error) {
+	src, err := expandEnvDefaults(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand environment variables: %w", err)
+	}
+	return r.ReadWithEnvBytes(src)
+}
+
+// ReadWithEnv reads the file and expands ${VAR:-default} and ${VAR:?message} before the template.
+func (l *configLoader) ReadWithEnv(path string) ([]byte, error) {
+	return readWithEnv(l.Loader, path)
+}
+
+// ReadWithEnvBytes expands ${VAR:-default} and ${VAR:?message} in src before the template.
+func (l *configLoader) ReadWithEnvBytes(src []byte) ([]byte, error) {
+	return readWithEnvBytes(l.Loader, src)
+}
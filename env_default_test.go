@@ -0,0 +1,78 @@
+package ecspresso_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestExpandEnvDefaults(t *testing.T) {
+	t.Setenv("ECSPRESSO_TEST_SET", "value")
+	t.Setenv("ECSPRESSO_TEST_EMPTY", "")
+	cases := []struct {
+		src      string
+		expected string
+	}{
+		{src: "${ECSPRESSO_TEST_UNSET:-fallback}", expected: "fallback"},
+		{src: "${ECSPRESSO_TEST_EMPTY:-fallback}", expected: "fallback"},
+		{src: "${ECSPRESSO_TEST_SET:-fallback}", expected: "value"},
+		{src: "${ECSPRESSO_TEST_UNSET:-}", expected: ""},
+		{src: "${ECSPRESSO_TEST_SET:?required}", expected: "value"},
+		{src: "a-${ECSPRESSO_TEST_UNSET:-b}-${ECSPRESSO_TEST_SET:-c}", expected: "a-b-value"},
+		{src: "$${ECSPRESSO_TEST_UNSET:-fallback}", expected: "${ECSPRESSO_TEST_UNSET:-fallback}"},
+		{src: "${ECSPRESSO_TEST_SET}", expected: "${ECSPRESSO_TEST_SET}"},
+	}
+	for _, c := range cases {
+		got, err := ecspresso.ExpandEnvDefaults([]byte(c.src))
+		if err != nil {
+			t.Errorf("unexpected error for %s: %s", c.src, err)
+			continue
+		}
+		if string(got) != c.expected {
+			t.Errorf("%s is expanded to %s, expected %s", c.src, got, c.expected)
+		}
+	}
+
+	for _, src := range []string{"${ECSPRESSO_TEST_UNSET:?must be set}", "${ECSPRESSO_TEST_EMPTY:?must be set}"} {
+		_, err := ecspresso.ExpandEnvDefaults([]byte(src))
+		if err == nil {
+			t.Errorf("expected error for %s", src)
+		} else if !strings.HasSuffix(err.Error(), ": must be set") {
+			t.Errorf("unexpected error for %s: %s", src, err)
+		}
+	}
+}
+
+func TestLoadConfigWithEnvDefaults(t *testing.T) {
+	ctx := context.Background()
+	for _, path := range []string{"tests/env-default.yaml", "tests/env-default.jsonnet"} {
+		t.Run(path, func(t *testing.T) {
+			t.Setenv("ECSPRESSO_TEST_SERVICE", "web")
+			conf, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.Cluster != "default" || conf.Service != "web" {
+				t.Errorf("unexpected cluster and service %s %s", conf.Cluster, conf.Service)
+			}
+
+			t.Setenv("ECSPRESSO_TEST_CLUSTER", "production")
+			conf, err = ecspresso.NewConfigLoader(nil, nil).Load(ctx, path, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if conf.Cluster != "production" {
+				t.Errorf("unexpected cluster %s", conf.Cluster)
+			}
+
+			t.Setenv("ECSPRESSO_TEST_SERVICE", "")
+			if _, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, path, ""); err == nil {
+				t.Error("expected error for the required variable")
+			} else if !strings.Contains(err.Error(), "ECSPRESSO_TEST_SERVICE is required") {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
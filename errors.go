@@ -1,5 +1,10 @@
 package ecspresso
 
+import (
+	"errors"
+	"fmt"
+)
+
 type ErrSkipVerify string
 
 func (e ErrSkipVerify) Error() string {
@@ -18,6 +23,35 @@ func (e ErrConflictOptions) Error() string {
 	return string(e)
 }
 
+// ErrContainerExit is the error of the container of the task exited with a non-zero code.
+// The CLI exits with the code.
+type ErrContainerExit struct {
+	Container string
+	ExitCode  int32
+	Reason    string
+}
+
+func (e *ErrContainerExit) Error() string {
+	msg := fmt.Sprintf("container: %s, exit code: %d", e.Container, e.ExitCode)
+	if e.Reason != "" {
+		msg += ", reason: " + e.Reason
+	}
+	return msg
+}
+
+// exitCodeOf returns the exit code of the CLI for the error.
+// The exit code of the container is surfaced when it is in the range of exit codes.
+func exitCodeOf(err error) int {
+	if err == nil {
+		return 0
+	}
+	var ce *ErrContainerExit
+	if errors.As(err, &ce) && ce.ExitCode > 0 && ce.ExitCode < 256 {
+		return int(ce.ExitCode)
+	}
+	return 1
+}
+
 var (
 	errNotFound   = ErrNotFound("not found")
 	errSkipVerify = ErrSkipVerify("skip verify")
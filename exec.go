@@ -2,9 +2,19 @@ package ecspresso
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
 
+	"github.com/Songmu/prompter"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/fujiwara/ecsta"
+	"github.com/samber/lo"
 )
 
 type ExecOption struct {
@@ -19,6 +29,151 @@ type ExecOption struct {
 	L           string `name:"L" short:"L" help:"short expression of local-port:host:port" default:""`
 }
 
+// portforwardOption returns the options of ecsta to start the port forwarding session.
+// -L is expanded to the local port, the remote host and the remote port.
+func (opt ExecOption) portforwardOption() (*ecsta.PortforwardOption, error) {
+	local, host, port := opt.LocalPort, opt.Host, opt.Port
+	if opt.L != "" {
+		parts := strings.Split(opt.L, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("-L must be local-port:host:port: %s", opt.L)
+		}
+		var err error
+		if local, err = strconv.Atoi(parts[0]); err != nil {
+			return nil, fmt.Errorf("invalid local port of -L %s: %w", opt.L, err)
+		}
+		host = parts[1]
+		if port, err = strconv.Atoi(parts[2]); err != nil {
+			return nil, fmt.Errorf("invalid port of -L %s: %w", opt.L, err)
+		}
+	}
+	if port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("--port is required for --port-forward in 1-65535: %d", port)
+	}
+	if local < 0 || local > 65535 {
+		return nil, fmt.Errorf("invalid local port: %d", local)
+	}
+	return &ecsta.PortforwardOption{
+		ID:         opt.ID,
+		Container:  opt.Container,
+		LocalPort:  local,
+		RemotePort: port,
+		RemoteHost: host,
+	}, nil
+}
+
+// portForwardable returns an error when the task does not support port forwarding by ECS Exec.
+// Any container of the task is acceptable when container is empty.
+func portForwardable(task *types.Task, container string) error {
+	if !task.EnableExecuteCommand {
+		return errors.New("ECS Exec is not enabled for the task. set enableExecuteCommand in the service definition, or run the task with --exec")
+	}
+	if st := aws.ToString(task.LastStatus); st != "RUNNING" {
+		return fmt.Errorf("the task is %s. port forwarding requires a RUNNING task", st)
+	}
+	var found bool
+	for _, c := range task.Containers {
+		if container != "" && aws.ToString(c.Name) != container {
+			continue
+		}
+		found = true
+		for _, a := range c.ManagedAgents {
+			if a.Name == executeCommandAgentName && aws.ToString(a.LastStatus) == "RUNNING" {
+				return nil
+			}
+		}
+	}
+	if !found {
+		return fmt.Errorf("container %s is not found in the task", container)
+	}
+	return errors.New("the execute command agent is not running in the task. port forwarding requires ECS Exec, which is supported by Fargate platform version 1.4.0 or later, or ECS container agent 1.50.2 or later on EC2")
+}
+
+// checkPortForward checks the task specified by the ID supports port forwarding before starting the session.
+func (d *App) checkPortForward(ctx context.Context, id, container string) error {
+	out, err := d.ecs.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+		Cluster: aws.String(d.Cluster),
+		Tasks:   []string{id},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe tasks: %w", err)
+	}
+	if len(out.Tasks) == 0 {
+		return ErrNotFound(fmt.Sprintf("task ID %s is not found", id))
+	}
+	if err := portForwardable(&out.Tasks[0], container); err != nil {
+		return fmt.Errorf("task ID %s does not support port forwarding: %w", id, err)
+	}
+	return nil
+}
+
+// selectPortForwardTask selects the task to start the port forwarding session from the RUNNING tasks of the service or the family.
+// The tasks which do not support port forwarding are not selectable.
+func (d *App) selectPortForwardTask(ctx context.Context, family string, service *string, container string) (string, error) {
+	input := &ecs.ListTasksInput{
+		Cluster:       aws.String(d.Cluster),
+		DesiredStatus: types.DesiredStatusRunning,
+	}
+	if service != nil {
+		input.ServiceName = service
+	} else {
+		input.Family = aws.String(family)
+	}
+	ids := []string{}
+	tp := ecs.NewListTasksPaginator(d.ecs, input)
+	for tp.HasMorePages() {
+		to, err := tp.NextPage(ctx)
+		if err != nil {
+			return "", fmt.Errorf("failed to list tasks: %w", err)
+		}
+		if len(to.TaskArns) == 0 {
+			continue
+		}
+		out, err := d.ecs.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: aws.String(d.Cluster),
+			Tasks:   to.TaskArns,
+		})
+		if err != nil {
+			return "", fmt.Errorf("failed to describe tasks: %w", err)
+		}
+		for _, task := range out.Tasks {
+			id := arnToName(aws.ToString(task.TaskArn))
+			if err := portForwardable(&task, container); err != nil {
+				d.Log("[WARNING] task ID %s does not support port forwarding: %s", id, err)
+				continue
+			}
+			ids = append(ids, id)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return "", ErrNotFound("no RUNNING tasks support port forwarding")
+	case 1:
+		return ids[0], nil
+	}
+	return d.selectTaskID(ctx, ids)
+}
+
+// selectTaskID selects one of the task IDs by the filter command, or by the prompt without the filter command.
+func (d *App) selectTaskID(ctx context.Context, ids []string) (string, error) {
+	fc := d.FilterCommand()
+	if fc == "" {
+		return prompter.Choose("Select a task", ids, ids[0]), nil
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", fc)
+	cmd.Stdin = strings.NewReader(strings.Join(ids, "\n") + "\n")
+	cmd.Stderr = os.Stderr
+	b, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to execute filter command: %w", err)
+	}
+	id, _, _ := strings.Cut(strings.TrimSpace(string(b)), "\n")
+	if !lo.Contains(ids, id) {
+		return "", fmt.Errorf("no task is selected")
+	}
+	return id, nil
+}
+
 func (d *App) NewEcsta(ctx context.Context) (*ecsta.Ecsta, error) {
 	app, err := ecsta.New(ctx, d.config.Region, d.config.Cluster)
 	if err != nil {
@@ -33,6 +188,19 @@ func (d *App) NewEcsta(ctx context.Context) (*ecsta.Ecsta, error) {
 func (d *App) Exec(ctx context.Context, opt ExecOption) error {
 	// Do not call d.Start() because timeout disabled for exec.
 
+	var pfOpt *ecsta.PortforwardOption
+	if opt.PortForward {
+		var err error
+		if pfOpt, err = opt.portforwardOption(); err != nil {
+			return err
+		}
+		if opt.ID != "" {
+			if err := d.checkPortForward(ctx, opt.ID, opt.Container); err != nil {
+				return err
+			}
+		}
+	}
+
 	ecstaApp, err := d.NewEcsta(ctx)
 	if err != nil {
 		return err
@@ -47,16 +215,15 @@ func (d *App) Exec(ctx context.Context, opt ExecOption) error {
 	}
 
 	if opt.PortForward {
-		return ecstaApp.RunPortforward(ctx, &ecsta.PortforwardOption{
-			ID:         opt.ID,
-			Container:  opt.Container,
-			LocalPort:  opt.LocalPort,
-			RemotePort: opt.Port,
-			RemoteHost: opt.Host,
-			L:          opt.L,
-			Family:     &family,
-			Service:    service,
-		})
+		if pfOpt.ID == "" {
+			// select the task here to check it supports port forwarding before starting the session
+			if pfOpt.ID, err = d.selectPortForwardTask(ctx, family, service, opt.Container); err != nil {
+				return err
+			}
+		}
+		pfOpt.Family = &family
+		pfOpt.Service = service
+		return ecstaApp.RunPortforward(ctx, pfOpt)
 	} else {
 		return ecstaApp.RunExec(ctx, &ecsta.ExecOption{
 			ID:        opt.ID,
@@ -0,0 +1,118 @@
+package ecspresso_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/fujiwara/ecsta"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestExecPortforwardOption(t *testing.T) {
+	cases := []struct {
+		opt   ecspresso.ExecOption
+		want  *ecsta.PortforwardOption
+		isErr bool
+	}{
+		{
+			opt:  ecspresso.ExecOption{Port: 80, LocalPort: 8080},
+			want: &ecsta.PortforwardOption{RemotePort: 80, LocalPort: 8080},
+		},
+		{
+			opt:  ecspresso.ExecOption{ID: "abcdef", Container: "app", Port: 80},
+			want: &ecsta.PortforwardOption{ID: "abcdef", Container: "app", RemotePort: 80},
+		},
+		{
+			opt:  ecspresso.ExecOption{Port: 5432, LocalPort: 15432, Host: "db.example.com"},
+			want: &ecsta.PortforwardOption{RemotePort: 5432, LocalPort: 15432, RemoteHost: "db.example.com"},
+		},
+		{
+			opt:  ecspresso.ExecOption{L: "8080:example.com:80"},
+			want: &ecsta.PortforwardOption{RemotePort: 80, LocalPort: 8080, RemoteHost: "example.com"},
+		},
+		{opt: ecspresso.ExecOption{}, isErr: true},
+		{opt: ecspresso.ExecOption{Port: 70000}, isErr: true},
+		{opt: ecspresso.ExecOption{L: "8080:80"}, isErr: true},
+		{opt: ecspresso.ExecOption{L: "a:example.com:80"}, isErr: true},
+	}
+	for _, c := range cases {
+		got, err := c.opt.PortforwardOption()
+		if c.isErr {
+			if err == nil {
+				t.Errorf("%#v expected error, but got nil", c.opt)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%#v unexpected error: %s", c.opt, err)
+			continue
+		}
+		if diff := cmp.Diff(c.want, got, cmpopts.IgnoreUnexported(ecsta.PortforwardOption{})); diff != "" {
+			t.Errorf("%#v unexpected options: %s", c.opt, diff)
+		}
+	}
+}
+
+func TestPortForwardable(t *testing.T) {
+	agent := func(status string) []types.ManagedAgent {
+		return []types.ManagedAgent{{Name: "ExecuteCommandAgent", LastStatus: aws.String(status)}}
+	}
+	task := func(enabled bool, status string, containers ...types.Container) *types.Task {
+		return &types.Task{EnableExecuteCommand: enabled, LastStatus: aws.String(status), Containers: containers}
+	}
+	cases := []struct {
+		name      string
+		task      *types.Task
+		container string
+		errMsg    string
+	}{
+		{
+			name: "agent running",
+			task: task(true, "RUNNING", types.Container{Name: aws.String("app"), ManagedAgents: agent("RUNNING")}),
+		},
+		{
+			name:      "agent running in the container",
+			task:      task(true, "RUNNING", types.Container{Name: aws.String("app")}, types.Container{Name: aws.String("proxy"), ManagedAgents: agent("RUNNING")}),
+			container: "proxy",
+		},
+		{
+			name:   "exec is not enabled",
+			task:   task(false, "RUNNING", types.Container{Name: aws.String("app"), ManagedAgents: agent("RUNNING")}),
+			errMsg: "ECS Exec is not enabled",
+		},
+		{
+			name:   "not running",
+			task:   task(true, "PENDING", types.Container{Name: aws.String("app")}),
+			errMsg: "requires a RUNNING task",
+		},
+		{
+			name:   "no agent",
+			task:   task(true, "RUNNING", types.Container{Name: aws.String("app")}),
+			errMsg: "the execute command agent is not running",
+		},
+		{
+			name:      "container not found",
+			task:      task(true, "RUNNING", types.Container{Name: aws.String("app"), ManagedAgents: agent("RUNNING")}),
+			container: "proxy",
+			errMsg:    "container proxy is not found",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ecspresso.PortForwardable(c.task, c.container)
+			if c.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Errorf("unexpected error: %v, expected to contain %s", err, c.errMsg)
+			}
+		})
+	}
+}
@@ -2,32 +2,101 @@ package ecspresso
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	aasTypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	dynamodbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	elbv2Types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/fujiwara/ecsta"
+	"github.com/google/go-jsonnet"
 )
 
 var (
-	SortTaskDefinition = sortTaskDefinition
-	ToNumberCPU        = toNumberCPU
-	ToNumberMemory     = toNumberMemory
-	CalcDesiredCount   = calcDesiredCount
-	ParseTags          = parseTags
-	ExtractRoleName    = extractRoleName
-	IsLongArnFormat    = isLongArnFormat
-	ECRImageURLRegex   = ecrImageURLRegex
-	NewLogger          = newLogger
-	NewLogFilter       = newLogFilter
-	NewConfigLoader    = newConfigLoader
-	NewVerifier        = newVerifier
-	ArnToName          = arnToName
-	InitVerifyState    = initVerifyState
-	VerifyResource     = verifyResource
-	Map2str            = map2str
-	DiffServices       = diffServices
-	DiffTaskDefs       = diffTaskDefs
+	SortTaskDefinition            = sortTaskDefinition
+	ToNumberCPU                   = toNumberCPU
+	ToNumberMemory                = toNumberMemory
+	CalcDesiredCount              = calcDesiredCount
+	ParseTags                     = parseTags
+	ExtractRoleName               = extractRoleName
+	IsLongArnFormat               = isLongArnFormat
+	ECRImageURLRegex              = ecrImageURLRegex
+	NewLogger                     = newLogger
+	NewLogFilter                  = newLogFilter
+	NewConfigLoader               = newConfigLoader
+	FindProjectConfigFile         = findProjectConfigFile
+	NewVerifier                   = newVerifier
+	ArnToName                     = arnToName
+	InitVerifyState               = initVerifyState
+	VerifyResource                = verifyResource
+	Map2str                       = map2str
+	DiffServices                  = diffServices
+	WithTracer                    = withTracer
+	SetupTracing                  = setupTracing
+	StartSpan                     = startSpan
+	VerifyPlatformVersion         = verifyPlatformVersion
+	AwslogsContainers             = awslogsContainers
+	MigrateConfig                 = migrateConfig
+	DeferredFuncCall              = deferredFuncCall
+	PreserveDesiredCount          = preserveDesiredCount
+	CheckDeploymentController     = checkDeploymentController
+	SvToUpdateServiceInput        = svToUpdateServiceInput
+	DeploymentReady               = deploymentReady
+	ScanEnvReferences             = scanEnvReferences
+	DumpEnv                       = dumpEnv
+	FormatConfigSource            = formatConfigSource
+	ParseSince                    = parseSince
+	ConvertTemplateDelims         = convertTemplateDelims
+	FormatCodeDeployStatus        = formatCodeDeployStatus
+	RestartPolicyWarnings         = restartPolicyWarnings
+	DeployLockID                  = deployLockID
+	TaskHealth                    = taskHealth
+	DiffTags                      = diffTags
+	S3PathStyle                   = s3PathStyle
+	NetworkConfigurationConflicts = networkConfigurationConflicts
+	PrincipalArnOf                = principalArnOf
+	SimulatePermissions           = simulatePermissions
+	PermissionResourceArns        = permissionResourceArns
+	CannotSimulate                = cannotSimulate
+	FormatPrometheusMetrics       = formatPrometheusMetrics
+	EscapePrometheusLabel         = escapePrometheusLabel
+	MissingCapacityProviders      = missingCapacityProviders
+	IsWaitTimeout                 = isWaitTimeout
+	ExecContainerName             = execContainerName
+	ExecuteCommandAgentRunning    = executeCommandAgentRunning
+	ParsePreviousRecordLocation   = parsePreviousRecordLocation
+	ParsePreviousRecord           = parsePreviousRecord
+	ParseServiceSelector          = parseServiceSelector
+	SelectServiceByTag            = selectServiceByTag
+	IsLogStreamNotFound           = isLogStreamNotFound
+	SetCodeDeployConfig           = setCodeDeployConfig
+	CodeDeployMigrationSteps      = codeDeployMigrationSteps
+	PrimaryDeploymentMinRunning   = primaryDeploymentMinRunning
+	CoerceLooseNumbersJSON        = coerceLooseNumbersJSON
+	FormatDOT                     = formatDOT
+	SortEnvironment               = sortEnvironment
+	RequireActiveTaskDefinition   = requireActiveTaskDefinition
+	PrimaryDeploymentStable       = primaryDeploymentStable
+	DiffTaskDefs                  = diffTaskDefs
+	ParseApproval                 = parseApproval
+	ValidateFargateTaskSize       = validateFargateTaskSize
+	AnnotateServiceDiff           = annotateServiceDiff
+	ExpandEnvDefaults             = expandEnvDefaults
+	DeregisterTargets             = deregisterTargets
+	TaskDefinitionRevision        = taskDefinitionRevision
+	NewJSONLogFilter              = newJSONLogFilter
+	LogFilterLevel                = logFilterLevel
+	TaskStoppedStatus             = taskStoppedStatus
+	StoppedBeforeRunning          = stoppedBeforeRunning
+	ExitCodeOf                    = exitCodeOf
+	PortForwardable               = portForwardable
 )
 
 type ModifyAutoScalingParams = modifyAutoScalingParams
@@ -36,6 +105,22 @@ func (d *App) SetLogger(logger *log.Logger) {
 	d.logger = logger
 }
 
+func (d *App) SetLogFormat(format string) {
+	d.logFormat = format
+}
+
+func (opt ExecOption) PortforwardOption() (*ecsta.PortforwardOption, error) {
+	return opt.portforwardOption()
+}
+
+func (opt DeployOption) ValidateAutoScaling() error {
+	return opt.validateAutoScaling()
+}
+
+func (d *App) OutputStatusJSON(ctx context.Context, w io.Writer) error {
+	return d.outputStatusJSON(ctx, w)
+}
+
 func SetLogger(logger *log.Logger) {
 	commonLogger = logger
 }
@@ -56,6 +141,300 @@ func (opt *DiffOption) SetWriter(w io.Writer) {
 	opt.w = w
 }
 
+func (opt *DeployOption) SetArnWriter(w io.Writer) {
+	opt.arnWriter = w
+}
+
+func (opt *DiffOption) SetIgnore(i *ConfigIgnore) {
+	opt.ignore = i
+}
+
+func (i *ConfigIgnore) CompilePaths() error {
+	return i.compilePaths()
+}
+
 func (i *ConfigIgnore) FilterTags(tags []types.Tag) []types.Tag {
 	return i.filterTags(tags)
 }
+
+func NewFileApproval(path string) ApprovalSource {
+	return &fileApproval{path: path}
+}
+
+func SetApprovalPollInterval(d time.Duration) {
+	approvalPollInterval = d
+}
+
+func (opt *CLIOptions) ResolveConfigFilePath() (string, error) {
+	return opt.resolveConfigFilePath()
+}
+
+func SetVerifyKeepGoing(keepGoing bool) {
+	verifyState.keepGoing = keepGoing
+}
+
+func VerifyFailures() []error {
+	return verifyState.failures
+}
+
+func (opt *VerifyOption) Skipped(check string) error {
+	return opt.skipped(check)
+}
+
+func CheckCanaryHealth(ctx context.Context, opt DeployOption, task *types.Task) error {
+	check, err := opt.canaryHealthCheck()
+	if err != nil {
+		return err
+	}
+	return check(ctx, task)
+}
+
+func (opt DeployOption) CanaryHealthCheckTimeout() time.Duration {
+	return opt.canaryHealthCheckTimeout()
+}
+
+func (d *App) NextTaskDefinitionName(ctx context.Context, family string) (string, error) {
+	return d.nextTaskDefinitionName(ctx, family)
+}
+
+func (d *App) ResolveTargetGroupNames(ctx context.Context, src []byte) ([]byte, error) {
+	return d.resolveTargetGroupNames(ctx, src)
+}
+
+func (d *App) StripTargetGroupNames(src []byte) ([]byte, error) {
+	return d.stripTargetGroupNames(src)
+}
+
+func AppendDeployRecordFile(path string, r *DeployRecord) error {
+	return appendDeployRecordFile(path, r)
+}
+
+func (r *DeployRecord) DynamoDBItem() map[string]dynamodbTypes.AttributeValue {
+	return r.dynamoDBItem()
+}
+
+func (d *App) AcquireDeployLock(ctx context.Context, table string, ttl time.Duration) (func(), error) {
+	return d.acquireDeployLock(ctx, table, ttl)
+}
+
+func (d *App) PutDeployRecordItem(ctx context.Context, table string, r *DeployRecord) error {
+	return d.putDeployRecordItem(ctx, table, r)
+}
+
+type DeployLock = deployLock
+
+func (l deployLock) Expired(now time.Time) bool {
+	return l.expired(now)
+}
+
+type PluginInfo = pluginInfo
+
+func (c *Config) PluginInfos() []PluginInfo {
+	return c.pluginInfos
+}
+
+func MarshalAutoScalingDefinition(targets []aasTypes.ScalableTarget, policies []aasTypes.ScalingPolicy) ([]byte, error) {
+	return MarshalJSONForAPI(autoScalingDefinition{ScalableTargets: targets, ScalingPolicies: policies}, autoScalingDefinitionQuery)
+}
+
+func (l *configLoader) SetBaseDir(dir string) {
+	l.baseDir = dir
+}
+
+func (l *configLoader) SetStrictConfig(strict bool) {
+	l.strictConfig = strict
+}
+
+func (l *configLoader) SetOverrides(opts *CLIOptions) {
+	l.overrides = opts
+}
+
+func (l *configLoader) SetOverlay(path string) {
+	l.overlay = path
+}
+
+func (l *configLoader) SetNoProjectConfig(b bool) {
+	l.noProjectConfig = b
+}
+
+func (c *Config) AWSRegion() string {
+	return c.awsv2Config.Region
+}
+
+func (c *Config) AWSv2Config() aws.Config {
+	return c.awsv2Config
+}
+
+func (c *Config) AWSLoadOptions() (config.LoadOptions, error) {
+	var o config.LoadOptions
+	for _, fn := range c.awsLoadOptions() {
+		if err := fn(&o); err != nil {
+			return o, err
+		}
+	}
+	return o, nil
+}
+
+func (l *configLoader) SetTLA(tlaStr, tlaCode map[string]string) {
+	l.setTLA(tlaStr, tlaCode)
+}
+
+func (c *Config) ValidateVersionStrict(version string) error {
+	return c.validateVersion(version, true)
+}
+
+func (l *configLoader) SetJsonnetLibs(libs []string, configPath string) error {
+	return l.setJsonnetLibs(libs, configPath)
+}
+
+type AWSIdentity = awsIdentity
+
+func (c *Config) AWSProfile() string {
+	return c.awsProfile()
+}
+
+func (opt DeployOption) ValidateWaitForMinRunning() error {
+	return opt.validateWaitForMinRunning()
+}
+
+func (d *App) NetworkConfigurationInAvailabilityZone(ctx context.Context, nc *types.NetworkConfiguration, az string) (*types.NetworkConfiguration, error) {
+	return d.networkConfigurationInAvailabilityZone(ctx, nc, az)
+}
+
+func (d *App) CountActiveRevisions(ctx context.Context, family string, max int) (int, error) {
+	return d.countActiveRevisions(ctx, family, max)
+}
+
+func (opt DeployOption) RevisionsWarningOf(conf *Config) int {
+	return opt.revisionsWarning(conf)
+}
+
+func (opt RunOption) ValidateGroupAndReferenceID() error {
+	return opt.validateGroupAndReferenceID()
+}
+
+func (opt RunOption) ValidateExec() error {
+	return opt.validateExec()
+}
+
+// NewCFnOutputFunc returns the cfn_output native function calling the client.
+func NewCFnOutputFunc(client interface {
+	DescribeStacks(context.Context, *cloudformation.DescribeStacksInput, ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+}) *jsonnet.NativeFunction {
+	s := newCFnOutputFunction(func(_ context.Context) (cfnDescribeStacksAPI, error) {
+		return client, nil
+	})
+	return s.nativeFunction()
+}
+
+// NewTFStateFunc returns the tfstate native function which resolves relative paths by dir.
+func NewTFStateFunc(dir string) *jsonnet.NativeFunction {
+	s := newTFStateFunction(func(_ context.Context) (s3GetObjectAPI, error) {
+		return nil, errors.New("S3 is not available in tests")
+	})
+	s.dir = func() string { return dir }
+	return s.nativeFunction()
+}
+
+func (opt DeployOption) ValidateNoChanges() error {
+	return opt.validateNoChanges()
+}
+
+func (opt DeployOption) MayBeNoChanges() bool {
+	return opt.mayBeNoChanges()
+}
+
+// BufferLog returns functions to flush and discard the logs buffered by bufferLog.
+func BufferLog(l *log.Logger) (flush func(), discard func()) {
+	b := bufferLog(l)
+	return b.flush, b.discard
+}
+
+func (opt RunOption) ValidatePollLogs() error {
+	return opt.validatePollLogs()
+}
+
+func (opt RunOption) TaskGroup(tdArn string) string {
+	return opt.taskGroup(tdArn)
+}
+
+type EnvReference = envReference
+
+func ValidateTraffic(ctx context.Context, opt DeployOption, deploymentID string) error {
+	validate, err := opt.trafficValidation()
+	if err != nil {
+		return err
+	}
+	if validate == nil {
+		return nil
+	}
+	return validate(ctx, deploymentID)
+}
+
+func (opt RunOption) ValidatePropagateTags() error {
+	return opt.validatePropagateTags()
+}
+
+// ProviderOptions returns the options of the assume role provider applied by the option funcs.
+func (a *ConfigAssumeRole) ProviderOptions() stscreds.AssumeRoleOptions {
+	var o stscreds.AssumeRoleOptions
+	for _, fn := range a.providerOptions() {
+		fn(&o)
+	}
+	return o
+}
+
+// WaitPollIntervals returns the first n intervals of polling in the wait loops by the option.
+func WaitPollIntervals(opt DeployOption, n int) ([]time.Duration, error) {
+	p, err := opt.waitPolling()
+	if err != nil {
+		return nil, err
+	}
+	poller := newWaitPoller(withWaitPolling(context.Background(), p), waiterMaxDelay)
+	ds := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		ds = append(ds, poller.next())
+	}
+	return ds, nil
+}
+
+func WaitPoll(ctx context.Context, fixed time.Duration) error {
+	return newWaitPoller(ctx, fixed).wait(ctx)
+}
+
+func (d *App) StartPhase(ctx context.Context, phase string) (context.Context, context.CancelFunc) {
+	return d.startPhase(ctx, timeoutPhase(phase))
+}
+
+func (c *Config) TimeoutFor(phase string) time.Duration {
+	return c.timeoutFor(timeoutPhase(phase))
+}
+
+func (c *Config) WaiterTimeoutFor(phase string) time.Duration {
+	return c.waiterTimeoutFor(timeoutPhase(phase))
+}
+
+func (c *Config) DeployLockTTL() time.Duration {
+	return c.deployLockTTL()
+}
+
+func (d *App) RunTimeoutReason() string {
+	return d.runTimeoutReason()
+}
+
+func CheckCodeDeployTargets(blue, green elbv2Types.TargetGroup, listener elbv2Types.Listener, testListener *elbv2Types.Listener) error {
+	return checkCodeDeployTargets(&codeDeployTargets{
+		blue:         blue,
+		green:        green,
+		listener:     listener,
+		testListener: testListener,
+	})
+}
+
+func (opt MigrateToCodeDeployOption) Validate() error {
+	return opt.validate()
+}
+
+func (opt MigrateToCodeDeployOption) CodeDeployNames(cluster, service string) (string, string) {
+	return opt.codeDeployNames(cluster, service)
+}
@@ -10,6 +10,8 @@ import (
 
 	"github.com/Songmu/prompter"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/applicationautoscaling"
+	aasTypes "github.com/aws/aws-sdk-go-v2/service/applicationautoscaling/types"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/goccy/go-yaml"
@@ -19,18 +21,41 @@ import (
 var CreateFileMode = os.FileMode(0644)
 
 type InitOption struct {
-	Region                string `help:"AWS region" env:"AWS_REGION" default:""`
-	Cluster               string `help:"ECS cluster name" default:"default"`
-	Service               string `help:"ECS service name" required:"" xor:"FROM"`
-	TaskDefinition        string `help:"ECS task definition name:revision" required:"" xor:"FROM"`
+	Region                string `kong:"-"` // set by --region or AWS_REGION
+	Cluster               string `kong:"-"` // set by --cluster
+	Service               string `kong:"-"` // set by --service
+	TaskDefinition        string `help:"ECS task definition name:revision. exclusive with --service"`
 	TaskDefinitionPath    string `help:"path to output task definition file" default:"ecs-task-def.json"`
 	ServiceDefinitionPath string `help:"path to output service definition file" default:"ecs-service-def.json"`
 	Sort                  bool   `help:"sort elements in task definition" default:"false" negatable:""`
 	ForceOverwrite        bool   `help:"overwrite existing files" default:"false"`
 	Jsonnet               bool   `help:"output files as jsonnet format" default:"false"`
+	WithAutoScaling       bool   `help:"output application auto scaling settings of the service" default:"false"`
+	AutoScalingPath       string `help:"path to output application auto scaling settings file" default:"ecs-autoscaling.json"`
+}
+
+// setCLIOptions sets the global --region, --cluster and --service to the option.
+func (opt *InitOption) setCLIOptions(opts *CLIOptions) {
+	opt.Region = os.Getenv("AWS_REGION")
+	if opts.Region != nil {
+		opt.Region = *opts.Region
+	}
+	opt.Cluster = DefaultClusterName
+	if opts.Cluster != nil {
+		opt.Cluster = *opts.Cluster
+	}
+	if opts.Service != nil {
+		opt.Service = *opts.Service
+	}
 }
 
 func (opt *InitOption) NewConfig(ctx context.Context, configFilePath string) (*Config, error) {
+	switch {
+	case opt.Service == "" && opt.TaskDefinition == "":
+		return nil, fmt.Errorf("init requires --service or --task-definition")
+	case opt.Service != "" && opt.TaskDefinition != "":
+		return nil, ErrConflictOptions("service and task-definition are exclusive")
+	}
 	conf := NewDefaultConfig()
 	conf.path = configFilePath
 	conf.Region = opt.Region
@@ -49,6 +74,7 @@ var (
 	jsonExt    = ".json"
 	ymlExt     = ".yml"
 	yamlExt    = ".yaml"
+	tomlExt    = ".toml"
 )
 
 func (d *App) Init(ctx context.Context, opt InitOption) error {
@@ -67,6 +93,9 @@ func (d *App) Init(ctx context.Context, opt InitOption) error {
 		if ext := filepath.Ext(conf.path); ext == ymlExt || ext == yamlExt {
 			conf.path = strings.TrimSuffix(conf.path, ext) + jsonnetExt
 		}
+		if ext := filepath.Ext(opt.AutoScalingPath); ext == jsonExt {
+			opt.AutoScalingPath = strings.TrimSuffix(opt.AutoScalingPath, ext) + jsonnetExt
+		}
 	}
 	var sv *Service
 	var tdArn string
@@ -83,6 +112,13 @@ func (d *App) Init(ctx context.Context, opt InitOption) error {
 	if err != nil {
 		return err
 	}
+	if opt.WithAutoScaling {
+		if tdOnly {
+			d.Log("[WARNING] --with-autoscaling requires --service. skip auto scaling settings")
+		} else if err := d.initAutoScaling(ctx, opt); err != nil {
+			return err
+		}
+	}
 	if err := d.initConfigurationFile(ctx, conf.path, opt, sv, td); err != nil {
 		return err
 	}
@@ -207,6 +243,64 @@ func (d *App) initTaskDefinition(ctx context.Context, opt InitOption, tdArn stri
 	return td, nil
 }
 
+// autoScalingDefinition represents application auto scaling settings of the service.
+type autoScalingDefinition struct {
+	ScalableTargets []aasTypes.ScalableTarget
+	ScalingPolicies []aasTypes.ScalingPolicy
+}
+
+// fields which are determined by AWS
+const autoScalingDefinitionQuery = `del(
+  .scalableTargets[]?.creationTime, .scalableTargets[]?.scalableTargetARN,
+  .scalingPolicies[]?.creationTime, .scalingPolicies[]?.policyARN, .scalingPolicies[]?.alarms
+)`
+
+func (d *App) initAutoScaling(ctx context.Context, opt InitOption) error {
+	resourceId := fmt.Sprintf("service/%s/%s", d.Cluster, d.Service)
+	out, err := d.autoScaling.DescribeScalableTargets(ctx, &applicationautoscaling.DescribeScalableTargetsInput{
+		ResourceIds:       []string{resourceId},
+		ServiceNamespace:  aasTypes.ServiceNamespaceEcs,
+		ScalableDimension: aasTypes.ScalableDimensionECSServiceDesiredCount,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe scalable targets: %w", err)
+	}
+	if len(out.ScalableTargets) == 0 {
+		d.Log("no application auto scaling settings found for %s", resourceId)
+		return nil
+	}
+	def := autoScalingDefinition{ScalableTargets: out.ScalableTargets}
+	p := applicationautoscaling.NewDescribeScalingPoliciesPaginator(d.autoScaling, &applicationautoscaling.DescribeScalingPoliciesInput{
+		ResourceId:        aws.String(resourceId),
+		ServiceNamespace:  aasTypes.ServiceNamespaceEcs,
+		ScalableDimension: aasTypes.ScalableDimensionECSServiceDesiredCount,
+	})
+	for p.HasMorePages() {
+		po, err := p.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to describe scaling policies: %w", err)
+		}
+		def.ScalingPolicies = append(def.ScalingPolicies, po.ScalingPolicies...)
+	}
+	if len(def.ScalingPolicies) == 0 {
+		d.Log("no scaling policies found for %s", resourceId)
+	}
+
+	b, err := MarshalJSONForAPI(def, autoScalingDefinitionQuery)
+	if err != nil {
+		return fmt.Errorf("unable to marshal auto scaling settings to JSON: %w", err)
+	}
+	if opt.Jsonnet {
+		out, err := formatter.Format(opt.AutoScalingPath, string(b), formatter.DefaultOptions())
+		if err != nil {
+			return fmt.Errorf("unable to format auto scaling settings as Jsonnet: %w", err)
+		}
+		b = []byte(out)
+	}
+	d.Log("save the auto scaling settings of %s to %s", resourceId, opt.AutoScalingPath)
+	return d.saveFile(opt.AutoScalingPath, b, CreateFileMode, opt.ForceOverwrite)
+}
+
 func treatmentServiceDefinition(sv *Service) {
 	sv.ClusterArn = nil
 	sv.CreatedAt = nil
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"reflect"
+	"strconv"
 	"strings"
 
 	"github.com/itchyny/gojq"
@@ -39,7 +41,7 @@ func MarshalJSONForAPI(v interface{}, queries ...string) ([]byte, error) {
 		return nil, err
 	}
 	walkMap(m, jsonKeyForAPI)
-	if len(queries) > 0 {
+	if len(queries) > 0 && m != nil { // m is nil for null
 		for _, q := range queries {
 			if m, err = jqFilter(m, q); err != nil {
 				return nil, err
@@ -60,6 +62,7 @@ func UnmarshalJSONForStruct(src []byte, v interface{}, path string) error {
 		return err
 	}
 	walkMap(m, jsonKeyForStruct)
+	coerceLooseNumbers(m, reflect.TypeOf(v))
 	if b, err := json.Marshal(m); err != nil {
 		return err
 	} else {
@@ -67,6 +70,83 @@ func UnmarshalJSONForStruct(src []byte, v interface{}, path string) error {
 	}
 }
 
+// looseNumericFields are the fields which ECS accepts as both of numbers and strings.
+var looseNumericFields = map[string]bool{
+	"Cpu":               true,
+	"Memory":            true,
+	"MemoryReservation": true,
+	"ContainerPort":     true,
+	"HostPort":          true,
+	"Port":              true,
+}
+
+// coerceLooseNumbersJSON converts the values of looseNumericFields in src to the type of the fields of v.
+func coerceLooseNumbersJSON(src []byte, v interface{}) ([]byte, error) {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(src, &m); err != nil {
+		return nil, err
+	}
+	coerceLooseNumbers(m, reflect.TypeOf(v))
+	return json.Marshal(m)
+}
+
+// coerceLooseNumbers converts the values of looseNumericFields in m to the type of the fields of t.
+// e.g. "256" for an int32 field is converted to 256, and 256 for a string field is converted to "256".
+// The keys of m are matched with the field names case-insensitively like encoding/json.
+func coerceLooseNumbers(m map[string]interface{}, t reflect.Type) {
+	t = indirectType(t)
+	if t == nil || t.Kind() != reflect.Struct {
+		return
+	}
+	for key, value := range m {
+		f, ok := t.FieldByNameFunc(func(name string) bool {
+			return strings.EqualFold(name, key)
+		})
+		if !ok {
+			continue
+		}
+		ft := indirectType(f.Type)
+		switch value := value.(type) {
+		case float64:
+			if looseNumericFields[f.Name] && ft.Kind() == reflect.String {
+				m[key] = strconv.FormatFloat(value, 'f', -1, 64)
+			}
+		case string:
+			if looseNumericFields[f.Name] && isIntKind(ft.Kind()) {
+				if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+					m[key] = n
+				}
+			}
+		case map[string]interface{}:
+			coerceLooseNumbers(value, ft)
+		case []interface{}:
+			if ft.Kind() != reflect.Slice {
+				continue
+			}
+			for _, elem := range value {
+				if em, ok := elem.(map[string]interface{}); ok {
+					coerceLooseNumbers(em, ft.Elem())
+				}
+			}
+		}
+	}
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isIntKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	}
+	return false
+}
+
 func jsonKeyForAPI(s string) string {
 	if len(s) == 0 {
 		return s
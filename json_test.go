@@ -2,6 +2,7 @@ package ecspresso_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"testing"
 
@@ -89,3 +90,84 @@ func TestUnmarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestUnmarshalJSONForStructLooseNumbers(t *testing.T) {
+	ctx := context.Background()
+	canonical := `{
+  "family": "test",
+  "cpu": "256",
+  "memory": "512",
+  "containerDefinitions": [
+    {
+      "name": "app",
+      "cpu": 128,
+      "memory": 256,
+      "memoryReservation": 128,
+      "portMappings": [{"containerPort": 80, "hostPort": 80}]
+    }
+  ]
+}`
+	loose := `{
+  "family": "test",
+  "cpu": 256,
+  "memory": 512,
+  "containerDefinitions": [
+    {
+      "name": "app",
+      "cpu": "128",
+      "memory": "256",
+      "memoryReservation": " 128 ",
+      "portMappings": [{"containerPort": "80", "hostPort": "80"}]
+    }
+  ]
+}`
+	var expected, actual ecspresso.TaskDefinitionInput
+	if err := ecspresso.UnmarshalJSONForStruct([]byte(canonical), &expected, "canonical"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ecspresso.UnmarshalJSONForStruct([]byte(loose), &actual, "loose"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(str(expected), str(actual)); diff != "" {
+		t.Errorf("unexpected task definition: %s", diff)
+	}
+	opt := &ecspresso.DiffOption{Unified: true}
+	buf := new(bytes.Buffer)
+	opt.SetWriter(buf)
+	if differ, err := ecspresso.DiffTaskDefs(ctx, &actual, &expected, "loose", "remote", opt); err != nil {
+		t.Error(err)
+	} else if differ {
+		t.Errorf("unexpected diff: %s", buf.String())
+	}
+
+	// not numeric strings are left as is to be reported by the decoder
+	var td ecspresso.TaskDefinitionInput
+	if err := ecspresso.UnmarshalJSONForStruct([]byte(`{"containerDefinitions":[{"cpu":"xxx"}]}`), &td, "invalid"); err == nil {
+		t.Error("expected error for not numeric cpu")
+	}
+}
+
+func TestCoerceLooseNumbersJSONForService(t *testing.T) {
+	src := `{
+  "desiredCount": 2,
+  "loadBalancers": [{"containerName": "app", "containerPort": "80"}],
+  "serviceRegistries": [{"port": "8080", "containerPort": 8080}]
+}`
+	var sv ecspresso.Service
+	b, err := ecspresso.CoerceLooseNumbersJSON([]byte(src), &sv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &sv); err != nil {
+		t.Fatal(err)
+	}
+	if p := sv.LoadBalancers[0].ContainerPort; p == nil || *p != 80 {
+		t.Errorf("unexpected containerPort of loadBalancers %v", p)
+	}
+	if p := sv.ServiceRegistries[0].Port; p == nil || *p != 8080 {
+		t.Errorf("unexpected port of serviceRegistries %v", p)
+	}
+	if c := sv.DesiredCount; c == nil || *c != 2 {
+		t.Errorf("unexpected desiredCount %v", c)
+	}
+}
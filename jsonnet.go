@@ -1,14 +1,30 @@
 package ecspresso
 
 import (
+	"context"
 	"fmt"
 	"os"
 
 	"github.com/google/go-jsonnet"
 	"github.com/google/go-jsonnet/ast"
+	"github.com/kayac/ecspresso/v2/secretsmanager"
 )
 
+// DefaultJsonnetNativeFuncs returns the native functions available in all Jsonnet files.
+// ssm, secretsmanager, cfn_output and tfstate_lookup (in S3) read the values by the default AWS config.
 func DefaultJsonnetNativeFuncs() []*jsonnet.NativeFunction {
+	s := newSSMFunction(func(ctx context.Context) (ssmGetParameterAPI, error) {
+		return newSSMClientByCLIOptions(ctx, nil)
+	})
+	sm := newSecretsManagerFunction(func(ctx context.Context) (*secretsmanager.App, error) {
+		return newSecretsManagerByCLIOptions(ctx, nil)
+	})
+	cfn := newCFnOutputFunction(func(ctx context.Context) (cfnDescribeStacksAPI, error) {
+		return newCFnClientByCLIOptions(ctx, nil)
+	})
+	tf := newTFStateFunction(func(ctx context.Context) (s3GetObjectAPI, error) {
+		return newS3ClientByCLIOptions(ctx, nil)
+	})
 	return []*jsonnet.NativeFunction{
 		{
 			Name:   "env",
@@ -38,5 +54,9 @@ func DefaultJsonnetNativeFuncs() []*jsonnet.NativeFunction {
 				return nil, fmt.Errorf("must_env: %s is not set", key)
 			},
 		},
+		s.nativeFunction(),
+		sm.nativeFunction(),
+		cfn.nativeFunction(),
+		tf.nativeFunction(),
 	}
 }
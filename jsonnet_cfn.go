@@ -0,0 +1,114 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/smithy-go"
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+)
+
+type cfnDescribeStacksAPI interface {
+	DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error)
+}
+
+// cfnOutputFunction reads outputs of CloudFormation stacks for the cfn_output native function.
+// The outputs are cached by the stack name, so a stack is described only once.
+type cfnOutputFunction struct {
+	mu      sync.Mutex
+	client  *lazyClient[cfnDescribeStacksAPI]
+	outputs map[string]map[string]string
+	noAWS   func() bool
+}
+
+func newCFnOutputFunction(newClient func(ctx context.Context) (cfnDescribeStacksAPI, error)) *cfnOutputFunction {
+	return &cfnOutputFunction{
+		client:  newLazyClient(newClient),
+		outputs: map[string]map[string]string{},
+	}
+}
+
+func (s *cfnOutputFunction) get(ctx context.Context, stack, key string) (string, error) {
+	outputs, err := s.stackOutputs(ctx, stack)
+	if err != nil {
+		return "", err
+	}
+	v, ok := outputs[key]
+	if !ok {
+		return "", ErrNotFound(fmt.Sprintf("cfn_output: output %s is not found in stack %s", key, stack))
+	}
+	return v, nil
+}
+
+func (s *cfnOutputFunction) stackOutputs(ctx context.Context, stack string) (map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if outputs, ok := s.outputs[stack]; ok {
+		return outputs, nil
+	}
+	client, err := s.client.get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cfn_output: failed to load AWS config: %w", err)
+	}
+	out, err := client.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stack),
+	})
+	if err != nil {
+		if isStackNotExist(err) {
+			return nil, ErrNotFound(fmt.Sprintf("cfn_output: stack %s is not found", stack))
+		}
+		return nil, fmt.Errorf("cfn_output: failed to describe stack %s: %w", stack, err)
+	}
+	if len(out.Stacks) == 0 {
+		return nil, ErrNotFound(fmt.Sprintf("cfn_output: stack %s is not found", stack))
+	}
+	outputs := make(map[string]string, len(out.Stacks[0].Outputs))
+	for _, o := range out.Stacks[0].Outputs {
+		outputs[aws.ToString(o.OutputKey)] = aws.ToString(o.OutputValue)
+	}
+	s.outputs[stack] = outputs
+	return outputs, nil
+}
+
+// isStackNotExist reports whether DescribeStacks failed because the stack does not exist.
+// CloudFormation returns ValidationError for it, not a specific error type.
+func isStackNotExist(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationError" && strings.Contains(apiErr.ErrorMessage(), "does not exist")
+}
+
+func (s *cfnOutputFunction) nativeFunction() *jsonnet.NativeFunction {
+	return &jsonnet.NativeFunction{
+		Name:   "cfn_output",
+		Params: []ast.Identifier{"stack", "key"},
+		Func: func(args []any) (any, error) {
+			stack, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("cfn_output: stack must be a string")
+			}
+			key, ok := args[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("cfn_output: key must be a string")
+			}
+			if s.noAWS != nil && s.noAWS() {
+				return noAWSPlaceholder("cfn_output", args), nil
+			}
+			return s.get(context.Background(), stack, key)
+		},
+	}
+}
+
+// newCFnClientByCLIOptions returns the CloudFormation client for the config file, see awsConfigByCLIOptions.
+func newCFnClientByCLIOptions(ctx context.Context, opt *CLIOptions) (cfnDescribeStacksAPI, error) {
+	cfg, err := awsConfigByCLIOptions(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return cloudformation.NewFromConfig(cfg), nil
+}
@@ -0,0 +1,63 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/kayac/ecspresso/v2/secretsmanager"
+)
+
+// secretsManagerFunction reads secrets of Secrets Manager for the secretsmanager native function.
+// The values are cached by secretsmanager.App.
+type secretsManagerFunction struct {
+	app    *lazyClient[*secretsmanager.App]
+	noAWS  func() bool
+	config bool // for the config file, whose values are not written into the definitions
+}
+
+func newSecretsManagerFunction(newApp func(ctx context.Context) (*secretsmanager.App, error)) *secretsManagerFunction {
+	return &secretsManagerFunction{app: newLazyClient(newApp)}
+}
+
+func (s *secretsManagerFunction) value(ctx context.Context, id, key string) (any, error) {
+	app, err := s.app.get(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("secretsmanager: failed to load AWS config: %w", err)
+	}
+	if s.config {
+		return app.ConfigValue(ctx, id, key)
+	}
+	return app.Value(ctx, id, key)
+}
+
+func (s *secretsManagerFunction) nativeFunction() *jsonnet.NativeFunction {
+	return &jsonnet.NativeFunction{
+		Name:   "secretsmanager",
+		Params: []ast.Identifier{"id", "key"},
+		Func: func(args []any) (any, error) {
+			id, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("secretsmanager: id must be string")
+			}
+			key, err := secretsmanager.KeyArg(args[1])
+			if err != nil {
+				return nil, err
+			}
+			if s.noAWS != nil && s.noAWS() {
+				return noAWSPlaceholder("secretsmanager", args), nil
+			}
+			return s.value(context.Background(), id, key)
+		},
+	}
+}
+
+// newSecretsManagerByCLIOptions returns secretsmanager.App for the config file, see awsConfigByCLIOptions.
+func newSecretsManagerByCLIOptions(ctx context.Context, opt *CLIOptions) (*secretsmanager.App, error) {
+	cfg, err := awsConfigByCLIOptions(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return secretsmanager.NewApp(cfg, Log), nil
+}
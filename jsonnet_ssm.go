@@ -0,0 +1,97 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsConfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+)
+
+type ssmGetParameterAPI interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+// ssmFunction reads parameters of SSM Parameter Store for the ssm native function.
+// The values are cached by the parameter name.
+type ssmFunction struct {
+	mu     sync.Mutex
+	client *lazyClient[ssmGetParameterAPI]
+	cache  map[string]string
+	noAWS  func() bool
+}
+
+func newSSMFunction(newClient func(ctx context.Context) (ssmGetParameterAPI, error)) *ssmFunction {
+	return &ssmFunction{
+		client: newLazyClient(newClient),
+		cache:  map[string]string{},
+	}
+}
+
+func (s *ssmFunction) get(ctx context.Context, name string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.cache[name]; ok {
+		return v, nil
+	}
+	client, err := s.client.get(ctx)
+	if err != nil {
+		return "", fmt.Errorf("ssm: failed to load AWS config: %w", err)
+	}
+	out, err := client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("ssm: failed to get parameter %s: %w", name, err)
+	}
+	v := aws.ToString(out.Parameter.Value)
+	s.cache[name] = v
+	return v, nil
+}
+
+func (s *ssmFunction) nativeFunction() *jsonnet.NativeFunction {
+	return &jsonnet.NativeFunction{
+		Name:   "ssm",
+		Params: []ast.Identifier{"name"},
+		Func: func(args []any) (any, error) {
+			name, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("ssm: name must be a string")
+			}
+			if s.noAWS != nil && s.noAWS() {
+				return noAWSPlaceholder("ssm", args), nil
+			}
+			return s.get(context.Background(), name)
+		},
+	}
+}
+
+// newSSMClientByCLIOptions returns the SSM client for the config file, see awsConfigByCLIOptions.
+func newSSMClientByCLIOptions(ctx context.Context, opt *CLIOptions) (ssmGetParameterAPI, error) {
+	cfg, err := awsConfigByCLIOptions(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return ssm.NewFromConfig(cfg), nil
+}
+
+// awsConfigByCLIOptions returns the AWS config by the environment and CLI options,
+// e.g. --region, --profile and --assume-role-arn, for the functions called before the config file is loaded.
+func awsConfigByCLIOptions(ctx context.Context, opt *CLIOptions) (aws.Config, error) {
+	c := NewDefaultConfig()
+	if opt != nil {
+		c.OverrideByCLIOptions(opt)
+	}
+	cfg, err := awsConfig.LoadDefaultConfig(ctx, c.awsLoadOptions()...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	c.awsv2Config = cfg
+	c.AssumeRoleWithOptions(c.AssumeRoleConfig)
+	return c.awsv2Config, nil
+}
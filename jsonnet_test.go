@@ -1,9 +1,19 @@
 package ecspresso_test
 
 import (
+	"context"
 	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	cfnTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"github.com/aws/smithy-go"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-jsonnet"
 	"github.com/kayac/ecspresso/v2"
@@ -84,3 +94,165 @@ func TestJsonnetNativeFuncs(t *testing.T) {
 		})
 	}
 }
+
+func TestJsonnetNativeFuncSSM(t *testing.T) {
+	var mu sync.Mutex
+	var requests []map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		var in map[string]any
+		json.Unmarshal(b, &in)
+		mu.Lock()
+		requests = append(requests, in)
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if r.Header.Get("X-Amz-Target") != "AmazonSSM.GetParameter" || in["Name"] != "/ecspresso/cluster" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ParameterNotFound","message":"not found"}`))
+			return
+		}
+		w.Write([]byte(`{"Parameter":{"Name":"/ecspresso/cluster","Type":"SecureString","Value":"production"}}`))
+	}))
+	defer ts.Close()
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_REGION", "ap-northeast-1")
+	t.Setenv("AWS_ENDPOINT_URL_SSM", ts.URL)
+
+	conf, err := ecspresso.NewConfigLoader(nil, nil).Load(context.Background(), "tests/ecspresso-ssm.jsonnet", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Cluster != "production" || conf.Service != "production-web" {
+		t.Errorf("unexpected cluster and service %s %s", conf.Cluster, conf.Service)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	// the same parameter is fetched once
+	if len(requests) != 1 {
+		t.Fatalf("unexpected number of requests: %d", len(requests))
+	}
+	if requests[0]["WithDecryption"] != true {
+		t.Errorf("WithDecryption must be true: %v", requests[0])
+	}
+}
+
+type mockCFnClient struct {
+	calls map[string]int
+}
+
+func (m *mockCFnClient) DescribeStacks(ctx context.Context, params *cloudformation.DescribeStacksInput, optFns ...func(*cloudformation.Options)) (*cloudformation.DescribeStacksOutput, error) {
+	name := aws.ToString(params.StackName)
+	m.calls[name]++
+	if name != "ECS-ecspresso" {
+		return nil, &smithy.GenericAPIError{
+			Code:    "ValidationError",
+			Message: "Stack with id " + name + " does not exist",
+		}
+	}
+	return &cloudformation.DescribeStacksOutput{
+		Stacks: []cfnTypes.Stack{
+			{
+				StackName: aws.String(name),
+				Outputs: []cfnTypes.Output{
+					{OutputKey: aws.String("SubnetAz1"), OutputValue: aws.String("subnet-0001")},
+					{OutputKey: aws.String("SubnetAz2"), OutputValue: aws.String("subnet-0002")},
+				},
+			},
+		},
+	}, nil
+}
+
+func TestJsonnetNativeFuncCFnOutput(t *testing.T) {
+	client := &mockCFnClient{calls: map[string]int{}}
+	vm := jsonnet.MakeVM()
+	vm.NativeFunction(ecspresso.NewCFnOutputFunc(client))
+
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `
+local cfn_output = std.native('cfn_output');
+{
+  subnets: [
+    cfn_output('ECS-ecspresso', 'SubnetAz1'),
+    cfn_output('ECS-ecspresso', 'SubnetAz2'),
+  ],
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string][]string
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string][]string{"subnets": {"subnet-0001", "subnet-0002"}}, got); diff != "" {
+		t.Error(diff)
+	}
+	// the outputs of the same stack are described once
+	if n := client.calls["ECS-ecspresso"]; n != 1 {
+		t.Errorf("unexpected number of DescribeStacks calls: %d", n)
+	}
+
+	if _, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `std.native('cfn_output')('ECS-ecspresso', 'NoSuchKey')`); err == nil {
+		t.Error("expected error for the missing output key")
+	} else if !strings.Contains(err.Error(), "output NoSuchKey is not found in stack ECS-ecspresso") {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if _, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `std.native('cfn_output')('no-such-stack', 'SubnetAz1')`); err == nil {
+		t.Error("expected error for the missing stack")
+	} else if !strings.Contains(err.Error(), "stack no-such-stack is not found") {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestJsonnetNativeFuncTFState(t *testing.T) {
+	vm := jsonnet.MakeVM()
+	vm.NativeFunction(ecspresso.NewTFStateFunc("tests/tfstate-func"))
+
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `
+local tfstate_lookup = std.native('tfstate_lookup');
+{
+  cluster: tfstate_lookup('terraform.tfstate', 'aws_ecs_cluster.main.name'),
+  tags: tfstate_lookup('terraform.tfstate', 'aws_ecs_cluster.main.tags'),
+  vpc: tfstate_lookup('terraform.tfstate', 'output.vpc_id'),
+  subnets: tfstate_lookup('terraform.tfstate', 'output.private_subnet_ids'),
+}
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{
+		"cluster": "production",
+		"tags":    map[string]any{"Env": "production"},
+		"vpc":     "vpc-0123456789abcdef0",
+		"subnets": []any{"subnet-0a1b2c3d4e5f60001", "subnet-0a1b2c3d4e5f60002"},
+	}
+	if diff := cmp.Diff(expected, got); diff != "" {
+		t.Error(diff)
+	}
+
+	errorCases := []struct {
+		src      string
+		contains string
+	}{
+		{
+			src:      `std.native('tfstate_lookup')('terraform.tfstate', 'aws_ecs_cluster.missing.name')`,
+			contains: "aws_ecs_cluster.missing.name is not found",
+		},
+		{
+			src:      `std.native('tfstate_lookup')('gs://bucket/terraform.tfstate', 'aws_ecs_cluster.main.name')`,
+			contains: "unsupported backend gs of gs://bucket/terraform.tfstate to look up aws_ecs_cluster.main.name",
+		},
+	}
+	for _, c := range errorCases {
+		if _, err := vm.EvaluateAnonymousSnippet("test.jsonnet", c.src); err == nil {
+			t.Errorf("expected error for %s", c.src)
+		} else if !strings.Contains(err.Error(), c.contains) {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package ecspresso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/fujiwara/tfstate-lookup/tfstate"
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+)
+
+type s3GetObjectAPI interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// tfstateFunction reads Terraform states for the tfstate_lookup native function, which takes the location of the state.
+// It is not named tfstate, not to collide with tfstate(address) of the tfstate plugin.
+// A state in S3 is read by the S3 client. The states are cached by the location.
+type tfstateFunction struct {
+	mu     sync.Mutex
+	client *lazyClient[s3GetObjectAPI]
+	dir    func() string // resolves relative paths of local states
+	states map[string]*tfstate.TFState
+	noAWS  func() bool
+}
+
+func newTFStateFunction(newClient func(ctx context.Context) (s3GetObjectAPI, error)) *tfstateFunction {
+	return &tfstateFunction{
+		client: newLazyClient(newClient),
+		states: map[string]*tfstate.TFState{},
+	}
+}
+
+// lookup returns the value of the address in the state as a Jsonnet value.
+// Outputs of the state are looked up by output.{name}, as terraform_remote_state provides them.
+func (s *tfstateFunction) lookup(ctx context.Context, loc, address string) (any, error) {
+	state, err := s.state(ctx, loc, address)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := state.Lookup(address)
+	if err != nil {
+		return nil, fmt.Errorf("tfstate_lookup: failed to look up %s in %s: %w", address, loc, err)
+	}
+	if obj == nil || obj.Value == nil {
+		return nil, ErrNotFound(fmt.Sprintf("tfstate_lookup: %s is not found in %s", address, loc))
+	}
+	// convert to the types of JSON, e.g. numbers to float64, for Jsonnet
+	b, err := json.Marshal(obj.Value)
+	if err != nil {
+		return nil, fmt.Errorf("tfstate_lookup: failed to marshal %s: %w", address, err)
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return nil, fmt.Errorf("tfstate_lookup: failed to unmarshal %s: %w", address, err)
+	}
+	return v, nil
+}
+
+func (s *tfstateFunction) state(ctx context.Context, loc, address string) (*tfstate.TFState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("tfstate_lookup: invalid location %s to look up %s: %w", loc, address, err)
+	}
+	switch u.Scheme {
+	case "", "file":
+		path := loc
+		if u.Scheme == "file" {
+			path = u.Host + u.Path
+		}
+		if !filepath.IsAbs(path) && s.dir != nil {
+			path = filepath.Join(s.dir(), path)
+		}
+		if state, ok := s.states[path]; ok {
+			return state, nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("tfstate_lookup: failed to open %s to look up %s: %w", path, address, err)
+		}
+		defer f.Close()
+		state, err := tfstate.Read(ctx, f)
+		if err != nil {
+			return nil, fmt.Errorf("tfstate_lookup: failed to read %s to look up %s: %w", path, address, err)
+		}
+		s.states[path] = state
+		return state, nil
+	case "s3":
+		if state, ok := s.states[loc]; ok {
+			return state, nil
+		}
+		client, err := s.client.get(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("tfstate_lookup: failed to load AWS config: %w", err)
+		}
+		out, err := client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(u.Host),
+			Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("tfstate_lookup: failed to get %s to look up %s: %w", loc, address, err)
+		}
+		defer out.Body.Close()
+		state, err := tfstate.Read(ctx, out.Body)
+		if err != nil {
+			return nil, fmt.Errorf("tfstate_lookup: failed to read %s to look up %s: %w", loc, address, err)
+		}
+		s.states[loc] = state
+		return state, nil
+	default:
+		return nil, fmt.Errorf("tfstate_lookup: unsupported backend %s of %s to look up %s. use a local path or s3://", u.Scheme, loc, address)
+	}
+}
+
+func (s *tfstateFunction) nativeFunction() *jsonnet.NativeFunction {
+	return &jsonnet.NativeFunction{
+		Name:   "tfstate_lookup",
+		Params: []ast.Identifier{"location", "address"},
+		Func: func(args []any) (any, error) {
+			loc, ok := args[0].(string)
+			if !ok {
+				return nil, fmt.Errorf("tfstate_lookup: location must be a string")
+			}
+			address, ok := args[1].(string)
+			if !ok {
+				return nil, fmt.Errorf("tfstate_lookup: address must be a string")
+			}
+			if s.noAWS != nil && s.noAWS() && strings.HasPrefix(loc, "s3://") {
+				return noAWSPlaceholder("tfstate_lookup", args), nil
+			}
+			return s.lookup(context.Background(), loc, address)
+		},
+	}
+}
+
+// newS3ClientByCLIOptions returns the S3 client for the config file, see awsConfigByCLIOptions.
+func newS3ClientByCLIOptions(ctx context.Context, opt *CLIOptions) (s3GetObjectAPI, error) {
+	cfg, err := awsConfigByCLIOptions(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	return s3.NewFromConfig(cfg, s3PathStyle(cfg)), nil
+}
@@ -0,0 +1,27 @@
+package ecspresso
+
+import (
+	"context"
+	"sync"
+)
+
+// lazyClient creates an AWS client on the first call, for the native functions of Jsonnet.
+// The AWS config of ecspresso is loaded after the config file is evaluated,
+// so the clients cannot be created when the functions are registered.
+type lazyClient[T any] struct {
+	once      sync.Once
+	client    T
+	err       error
+	newClient func(ctx context.Context) (T, error)
+}
+
+func newLazyClient[T any](newClient func(ctx context.Context) (T, error)) *lazyClient[T] {
+	return &lazyClient[T]{newClient: newClient}
+}
+
+func (c *lazyClient[T]) get(ctx context.Context) (T, error) {
+	c.once.Do(func() {
+		c.client, c.err = c.newClient(ctx)
+	})
+	return c.client, c.err
+}
@@ -0,0 +1,169 @@
+package ecspresso
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// defaultDeployLockTable is the DynamoDB table name to store deploy locks.
+const defaultDeployLockTable = "ecspresso-lock"
+
+// deployLock is a lock of the deployment, stored as an item of the DynamoDB table.
+// The partition key of the table is "id", and "expires_at" is available as the TTL attribute.
+type deployLock struct {
+	ID         string
+	Owner      string
+	Token      string
+	AcquiredAt time.Time
+	ExpiresAt  time.Time
+}
+
+func (l deployLock) String() string {
+	return fmt.Sprintf("%s since %s (expires at %s)",
+		l.Owner, l.AcquiredAt.Local().Format(time.RFC3339), l.ExpiresAt.Local().Format(time.RFC3339))
+}
+
+func (l deployLock) expired(now time.Time) bool {
+	return !now.Before(l.ExpiresAt)
+}
+
+func (l deployLock) item() map[string]dynamodbTypes.AttributeValue {
+	return map[string]dynamodbTypes.AttributeValue{
+		"id":          &dynamodbTypes.AttributeValueMemberS{Value: l.ID},
+		"owner":       &dynamodbTypes.AttributeValueMemberS{Value: l.Owner},
+		"token":       &dynamodbTypes.AttributeValueMemberS{Value: l.Token},
+		"acquired_at": &dynamodbTypes.AttributeValueMemberS{Value: l.AcquiredAt.Format(time.RFC3339)},
+		"expires_at":  &dynamodbTypes.AttributeValueMemberN{Value: strconv.FormatInt(l.ExpiresAt.Unix(), 10)},
+	}
+}
+
+// deployLockFromItem returns the lock of the item. The missing attributes are left zero.
+func deployLockFromItem(item map[string]dynamodbTypes.AttributeValue) deployLock {
+	var l deployLock
+	str := func(name string) string {
+		if v, ok := item[name].(*dynamodbTypes.AttributeValueMemberS); ok {
+			return v.Value
+		}
+		return ""
+	}
+	l.ID, l.Owner, l.Token = str("id"), str("owner"), str("token")
+	l.AcquiredAt, _ = time.Parse(time.RFC3339, str("acquired_at"))
+	if v, ok := item["expires_at"].(*dynamodbTypes.AttributeValueMemberN); ok {
+		if n, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+			l.ExpiresAt = time.Unix(n, 0)
+		}
+	}
+	return l
+}
+
+func deployLockID(cluster, service string) string {
+	return cluster + "/" + service
+}
+
+func deployLockOwner() string {
+	var name string
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// newDeployLockToken returns a random token to identify the acquisition of the lock.
+func newDeployLockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate the lock token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// acquireDeployLock acquires the lock for the service and returns a function to release it.
+// The lock is put by a conditional write, so only one deployment acquires it even when
+// the deployments take over an expired lock at the same time.
+func (d *App) acquireDeployLock(ctx context.Context, table string, ttl time.Duration) (func(), error) {
+	if table == "" {
+		table = defaultDeployLockTable
+	}
+	if ttl <= 0 {
+		ttl = d.config.deployLockTTL()
+	}
+	token, err := newDeployLockToken()
+	if err != nil {
+		return nil, err
+	}
+	client := dynamodb.NewFromConfig(d.config.awsv2Config)
+	now := time.Now()
+	lock := deployLock{
+		ID:         deployLockID(d.Cluster, d.Service),
+		Owner:      deployLockOwner(),
+		Token:      token,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	out, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName:           aws.String(table),
+		Item:                lock.item(),
+		ConditionExpression: aws.String("attribute_not_exists(id) OR expires_at <= :now"),
+		ExpressionAttributeValues: map[string]dynamodbTypes.AttributeValue{
+			":now": &dynamodbTypes.AttributeValueMemberN{Value: strconv.FormatInt(now.Unix(), 10)},
+		},
+		ReturnValues:                        dynamodbTypes.ReturnValueAllOld,
+		ReturnValuesOnConditionCheckFailure: dynamodbTypes.ReturnValuesOnConditionCheckFailureAllOld,
+	})
+	if err != nil {
+		var failed *dynamodbTypes.ConditionalCheckFailedException
+		if errors.As(err, &failed) {
+			return nil, fmt.Errorf("%s is locked by %s", lock.ID, deployLockFromItem(failed.Item))
+		}
+		return nil, fmt.Errorf("failed to acquire the deploy lock %s in %s: %w", lock.ID, table, err)
+	}
+	if len(out.Attributes) > 0 {
+		d.Log("[WARNING] the expired deploy lock held by %s was taken over", deployLockFromItem(out.Attributes))
+	}
+	d.Log("[INFO] acquired the deploy lock %s in %s", lock.ID, table)
+	return func() { d.releaseDeployLock(client, table, lock) }, nil
+}
+
+// releaseDeployLock releases the lock only if it is still held by the token of the lock.
+func (d *App) releaseDeployLock(client *dynamodb.Client, table string, lock deployLock) {
+	// ctx for the deployment may be already done
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := client.DeleteItem(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(table),
+		Key: map[string]dynamodbTypes.AttributeValue{
+			"id": &dynamodbTypes.AttributeValueMemberS{Value: lock.ID},
+		},
+		ConditionExpression: aws.String("#token = :token"),
+		ExpressionAttributeNames: map[string]string{
+			"#token": "token", // TOKEN is a reserved word
+		},
+		ExpressionAttributeValues: map[string]dynamodbTypes.AttributeValue{
+			":token": &dynamodbTypes.AttributeValueMemberS{Value: lock.Token},
+		},
+	})
+	if err != nil {
+		var failed *dynamodbTypes.ConditionalCheckFailedException
+		if errors.As(err, &failed) {
+			d.Log("[WARNING] the deploy lock %s was taken over by another deployment", lock.ID)
+			return
+		}
+		d.Log("[WARNING] failed to release the deploy lock %s: %s", lock.ID, err)
+		return
+	}
+	d.Log("[INFO] released the deploy lock %s", lock.ID)
+}
@@ -0,0 +1,187 @@
+package ecspresso_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestDeployLockID(t *testing.T) {
+	if id := ecspresso.DeployLockID("default", "app"); id != "default/app" {
+		t.Errorf("unexpected lock id %s", id)
+	}
+}
+
+func TestDeployLockExpired(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	lock := ecspresso.DeployLock{
+		Owner:      "alice@example",
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(10 * time.Minute),
+	}
+	if lock.Expired(now.Add(5 * time.Minute)) {
+		t.Error("lock must not be expired before ExpiresAt")
+	}
+	if !lock.Expired(now.Add(10 * time.Minute)) {
+		t.Error("lock must be expired at ExpiresAt")
+	}
+}
+
+type attributeValue struct {
+	S string `json:"S,omitempty"`
+	N string `json:"N,omitempty"`
+}
+
+// mockLockTable is a DynamoDB table holding the lock items.
+// It evaluates the conditions of the lock instead of the condition expressions.
+type mockLockTable struct {
+	mu         sync.Mutex
+	items      map[string]map[string]attributeValue
+	conditions []string
+}
+
+func (m *mockLockTable) serve(w http.ResponseWriter, r *http.Request) {
+	op := strings.SplitN(r.Header.Get("X-Amz-Target"), ".", 2)[1]
+	b, _ := io.ReadAll(r.Body)
+	var in struct {
+		TableName                 string
+		Item                      map[string]attributeValue
+		Key                       map[string]attributeValue
+		ConditionExpression       string
+		ExpressionAttributeValues map[string]attributeValue
+	}
+	json.Unmarshal(b, &in)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.conditions = append(m.conditions, op+" "+in.ConditionExpression)
+	respond := func(status int, v any) {
+		b, _ := json.Marshal(v)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Header().Set("X-Amz-Crc32", fmt.Sprint(crc32.ChecksumIEEE(b)))
+		w.WriteHeader(status)
+		w.Write(b)
+	}
+	failed := func(item map[string]attributeValue) {
+		respond(http.StatusBadRequest, map[string]any{
+			"__type":  "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException",
+			"message": "The conditional request failed",
+			"Item":    item,
+		})
+	}
+	switch op {
+	case "PutItem":
+		id := in.Item["id"].S
+		old, exists := m.items[id]
+		if exists {
+			expiresAt, _ := strconv.ParseInt(old["expires_at"].N, 10, 64)
+			now, _ := strconv.ParseInt(in.ExpressionAttributeValues[":now"].N, 10, 64)
+			if expiresAt > now {
+				failed(old)
+				return
+			}
+		}
+		m.items[id] = in.Item
+		if exists {
+			respond(http.StatusOK, map[string]any{"Attributes": old})
+			return
+		}
+	case "DeleteItem":
+		id := in.Key["id"].S
+		if old, ok := m.items[id]; !ok || old["token"].S != in.ExpressionAttributeValues[":token"].S {
+			failed(old)
+			return
+		}
+		delete(m.items, id)
+	}
+	respond(http.StatusOK, map[string]any{})
+}
+
+func (m *mockLockTable) item(id string) (map[string]attributeValue, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	item, ok := m.items[id]
+	return item, ok
+}
+
+func newLockApp(t *testing.T, items map[string]map[string]attributeValue) (*ecspresso.App, *mockLockTable) {
+	t.Helper()
+	m := &mockLockTable{items: items}
+	ts := httptest.NewServer(http.HandlerFunc(m.serve))
+	t.Cleanup(ts.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_DYNAMODB", ts.URL)
+
+	app, err := ecspresso.New(context.Background(), &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, m
+}
+
+func lockItem(owner, token string, expiresAt time.Time) map[string]attributeValue {
+	return map[string]attributeValue{
+		"id":          {S: "default2/test"},
+		"owner":       {S: owner},
+		"token":       {S: token},
+		"acquired_at": {S: expiresAt.Add(-time.Hour).Format(time.RFC3339)},
+		"expires_at":  {N: fmt.Sprint(expiresAt.Unix())},
+	}
+}
+
+func TestAcquireDeployLock(t *testing.T) {
+	ctx := context.Background()
+	app, m := newLockApp(t, map[string]map[string]attributeValue{})
+	release, err := app.AcquireDeployLock(ctx, "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := app.AcquireDeployLock(ctx, "", time.Minute); err == nil {
+		t.Error("the lock held by another deployment must not be acquired")
+	} else if !strings.Contains(err.Error(), "is locked by") {
+		t.Errorf("the error must tell who holds the lock: %s", err)
+	}
+	release()
+	if _, ok := m.item("default2/test"); ok {
+		t.Error("the lock must be released")
+	}
+	for _, c := range m.conditions {
+		if strings.HasSuffix(c, " ") {
+			t.Errorf("the lock must be written conditionally: %s", c)
+		}
+	}
+}
+
+func TestAcquireDeployLockTakeOver(t *testing.T) {
+	app, m := newLockApp(t, map[string]map[string]attributeValue{
+		"default2/test": lockItem("bob@example", "expired", time.Now().Add(-time.Minute)),
+	})
+	release, err := app.AcquireDeployLock(context.Background(), "", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item, _ := m.item("default2/test")
+	if item["token"].S == "expired" {
+		t.Error("the expired lock must be taken over")
+	}
+
+	// another deployment takes over the lock after it expired
+	m.mu.Lock()
+	m.items["default2/test"] = lockItem("carol@example", "another", time.Now().Add(time.Minute))
+	m.mu.Unlock()
+	release()
+	if item, _ := m.item("default2/test"); item["token"].S != "another" {
+		t.Error("the lock taken over by another deployment must not be released")
+	}
+}
@@ -1,17 +1,27 @@
 package ecspresso
 
 import (
+	"bytes"
 	"encoding/json"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/fujiwara/logutils"
 )
 
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
 var (
 	commonLogger *log.Logger
+	logLevels    = []logutils.LogLevel{"DEBUG", "INFO", "WARNING", "ERROR"}
 )
 
 func init() {
@@ -21,7 +31,7 @@ func init() {
 
 func newLogFilter(w io.Writer, minLevel string) *logutils.LevelFilter {
 	return &logutils.LevelFilter{
-		Levels: []logutils.LogLevel{"DEBUG", "INFO", "WARNING", "ERROR"},
+		Levels: logLevels,
 		ModifierFuncs: []logutils.ModifierFunc{
 			nil, // DEBUG
 			nil, // default
@@ -33,18 +43,113 @@ func newLogFilter(w io.Writer, minLevel string) *logutils.LevelFilter {
 	}
 }
 
+// newJSONLogFilter returns the level filter which writes the log lines as JSON records.
+// The lines are not colored, and fields returns the context of the records.
+func newJSONLogFilter(w io.Writer, minLevel string, fields func() map[string]string) *logutils.LevelFilter {
+	return &logutils.LevelFilter{
+		Levels:        logLevels,
+		ModifierFuncs: make([]logutils.ModifierFunc, len(logLevels)),
+		MinLevel:      logutils.LogLevel(minLevel),
+		Writer:        &jsonLogWriter{w: w, fields: fields},
+	}
+}
+
+// setLogOutput sets the output of the logger by the log format.
+func setLogOutput(l *log.Logger, w io.Writer, format, minLevel string, fields func() map[string]string) {
+	if format == logFormatJSON {
+		l.SetFlags(0) // the timestamp is a field of the record
+		l.SetOutput(newJSONLogFilter(w, minLevel, fields))
+		return
+	}
+	l.SetOutput(newLogFilter(w, minLevel))
+}
+
+// logFilterLevel returns the level of the filter by the name of --log-level.
+// Unknown names are INFO, the default.
+func logFilterLevel(name string) string {
+	switch strings.ToLower(name) {
+	case "debug":
+		return "DEBUG"
+	case "warn", "warning":
+		return "WARNING"
+	case "error":
+		return "ERROR"
+	default:
+		return "INFO"
+	}
+}
+
 func newLogger() *log.Logger {
 	return log.New(io.Discard, "", log.LstdFlags)
 }
 
+// jsonLogWriter converts the log lines to JSON records.
+// The level is parsed from the [LEVEL] prefix of the message, so the callers of Log are unchanged.
+type jsonLogWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	fields func() map[string]string
+}
+
+func (j *jsonLogWriter) Write(p []byte) (int, error) {
+	level, msg := parseLogLevel(string(bytes.TrimRight(p, "\n")))
+	rec := map[string]string{}
+	if j.fields != nil {
+		for k, v := range j.fields() {
+			rec[k] = v
+		}
+	}
+	rec["time"] = time.Now().Format(time.RFC3339Nano)
+	rec["level"] = level
+	rec["msg"] = msg
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return 0, err
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(append(b, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// parseLogLevel splits the line into the level and the message.
+// The line without a known level prefix is INFO.
+func parseLogLevel(line string) (string, string) {
+	if strings.HasPrefix(line, "[") {
+		if i := strings.Index(line, "]"); i > 0 {
+			level := line[1:i]
+			for _, l := range logLevels {
+				if string(l) == level {
+					return level, strings.TrimPrefix(line[i+1:], " ")
+				}
+			}
+		}
+	}
+	return "INFO", line
+}
+
 func Log(f string, v ...interface{}) {
 	commonLogger.Printf(f, v...)
 }
 
 func (d *App) Log(f string, v ...interface{}) {
+	if d.logFormat == logFormatJSON {
+		// the name is a field of the record
+		d.logger.Printf(f, v...)
+		return
+	}
 	d.logger.Printf(d.Name()+" "+f, v...)
 }
 
+func (d *App) logFields() map[string]string {
+	return map[string]string{
+		"service": d.Service,
+		"cluster": d.Cluster,
+	}
+}
+
 func (d *App) LogJSON(v interface{}) {
 	b, err := json.Marshal(v)
 	if err != nil {
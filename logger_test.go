@@ -2,7 +2,10 @@ package ecspresso_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kayac/ecspresso/v2"
 )
@@ -41,3 +44,89 @@ func TestLogger(t *testing.T) {
 		t.Log(b.String())
 	}
 }
+
+func TestJSONLogger(t *testing.T) {
+	app := &ecspresso.App{Service: "test", Cluster: "default"}
+	b := new(bytes.Buffer)
+	logger := ecspresso.NewLogger()
+	logger.SetFlags(0)
+	logger.SetOutput(ecspresso.NewJSONLogFilter(b, "INFO", func() map[string]string {
+		return map[string]string{"service": app.Service, "cluster": app.Cluster}
+	}))
+	app.SetLogger(logger)
+	app.SetLogFormat("json")
+
+	app.Log("test %s", "default")
+	app.Log("[DEBUG] test %s", "debug")
+	app.Log("[INFO] test %s", "info")
+	app.Log("[WARNING] test %s", "warning")
+	app.Log("[ERROR] test %s", "error")
+
+	expected := []struct {
+		level string
+		msg   string
+	}{
+		{"INFO", "test default"},
+		{"INFO", "test info"},
+		{"WARNING", "test warning"},
+		{"ERROR", "test error"},
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected number of records: %d\n%s", len(lines), b.String())
+	}
+	for i, line := range lines {
+		var rec map[string]string
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid JSON record %s: %s", line, err)
+		}
+		if rec["level"] != expected[i].level || rec["msg"] != expected[i].msg {
+			t.Errorf("unexpected record: %s", line)
+		}
+		if rec["service"] != "test" || rec["cluster"] != "default" {
+			t.Errorf("unexpected context of the record: %s", line)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, rec["time"]); err != nil {
+			t.Errorf("invalid time of the record: %s", line)
+		}
+	}
+}
+
+func TestLogLevelWarn(t *testing.T) {
+	app := &ecspresso.App{Service: "test", Cluster: "default"}
+	b := new(bytes.Buffer)
+	logger := ecspresso.NewLogger()
+	logger.SetOutput(ecspresso.NewLogFilter(b, ecspresso.LogFilterLevel("warn")))
+	app.SetLogger(logger)
+
+	app.Log("[DEBUG] test debug")
+	app.Log("[INFO] test info")
+	app.Log("[WARNING] test warning")
+	app.Log("[ERROR] test error")
+
+	out := b.String()
+	for _, s := range []string{"test debug", "test info"} {
+		if strings.Contains(out, s) {
+			t.Errorf("%s must be dropped at warn level:\n%s", s, out)
+		}
+	}
+	for _, s := range []string{"test warning", "test error"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("%s must be written at warn level:\n%s", s, out)
+		}
+	}
+}
+
+func TestLogFilterLevel(t *testing.T) {
+	for name, level := range map[string]string{
+		"debug": "DEBUG",
+		"info":  "INFO",
+		"warn":  "WARNING",
+		"error": "ERROR",
+		"":      "INFO",
+	} {
+		if got := ecspresso.LogFilterLevel(name); got != level {
+			t.Errorf("unexpected level of %q: %s", name, got)
+		}
+	}
+}
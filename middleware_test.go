@@ -21,6 +21,16 @@ var middlewareResults = map[string]func(string) any{
 			},
 		}
 	},
+	"DescribeTaskDefinition": func(family string) any {
+		return &ecs.DescribeTaskDefinitionOutput{
+			TaskDefinition: &types.TaskDefinition{
+				TaskDefinitionArn: ptr(fmt.Sprintf("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/%s:42", family)),
+				Family:            ptr(family),
+				Revision:          42,
+				Status:            types.TaskDefinitionStatusActive,
+			},
+		}
+	},
 	"ListTaskDefinitions": func(family string) any {
 		td := func(rev int) string {
 			return fmt.Sprintf("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/%s:%d", family, rev)
@@ -0,0 +1,402 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	cdTypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbv2Types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/samber/lo"
+)
+
+type MigrateToCodeDeployOption struct {
+	DryRun               bool   `help:"validate the prerequisites and show the steps of the migration without any changes" default:"true" negatable:""`
+	ApplicationName      string `help:"name of the CodeDeploy application (default: AppECS-{cluster}-{service})" default:""`
+	DeploymentGroupName  string `help:"name of the CodeDeploy deployment group (default: DgpECS-{cluster}-{service})" default:""`
+	TargetGroupArn       string `name:"target-group-arn" help:"ARN of the second target group for the replacement tasks" default:""`
+	ListenerArn          string `help:"ARN of the production listener which forwards to the target group of the service" default:""`
+	TestListenerArn      string `help:"ARN of the test listener (optional)" default:""`
+	ServiceRoleArn       string `help:"ARN of the IAM role for CodeDeploy. required to create the deployment group" default:""`
+	DeploymentConfigName string `help:"deployment config of the deployment group" default:"CodeDeployDefault.ECSAllAtOnce"`
+	CreateCodeDeploy     bool   `name:"create-codedeploy" help:"create the CodeDeploy application and deployment group which do not exist" default:"false"`
+}
+
+func (opt MigrateToCodeDeployOption) DryRunString() string {
+	if opt.DryRun {
+		return dryRunStr
+	}
+	return ""
+}
+
+func (opt MigrateToCodeDeployOption) validate() error {
+	if opt.TargetGroupArn == "" {
+		return errors.New("--target-group-arn is required. CodeDeploy blue/green deployment requires two target groups")
+	}
+	if opt.ListenerArn == "" {
+		return errors.New("--listener-arn is required")
+	}
+	if opt.CreateCodeDeploy && !opt.DryRun && opt.ServiceRoleArn == "" {
+		return errors.New("--service-role-arn is required to create the deployment group")
+	}
+	return nil
+}
+
+// codeDeployNames returns the names of the CodeDeploy application and deployment group.
+// The defaults are the same as the ECS console creates.
+func (opt MigrateToCodeDeployOption) codeDeployNames(cluster, service string) (string, string) {
+	app, dg := opt.ApplicationName, opt.DeploymentGroupName
+	if app == "" {
+		app = fmt.Sprintf("AppECS-%s-%s", cluster, service)
+	}
+	if dg == "" {
+		dg = fmt.Sprintf("DgpECS-%s-%s", cluster, service)
+	}
+	return app, dg
+}
+
+// codeDeployTargets are the resources of ELB used by the deployment group.
+type codeDeployTargets struct {
+	blue         elbv2Types.TargetGroup
+	green        elbv2Types.TargetGroup
+	listener     elbv2Types.Listener
+	testListener *elbv2Types.Listener
+}
+
+func (d *App) MigrateToCodeDeploy(ctx context.Context, opt MigrateToCodeDeployOption) error {
+	ctx, cancel := d.Start(ctx)
+	defer cancel()
+
+	d.Log("Migrating service to CodeDeploy blue/green deployment %s", opt.DryRunString())
+	if err := opt.validate(); err != nil {
+		return err
+	}
+	appName, dgName := opt.codeDeployNames(d.Cluster, d.Service)
+
+	sv, err := d.DescribeService(ctx)
+	if err != nil {
+		return err
+	}
+	targets, err := d.describeCodeDeployTargets(ctx, sv, opt)
+	if err != nil {
+		return err
+	}
+	if err := checkCodeDeployTargets(targets); err != nil {
+		return fmt.Errorf("prerequisites are not satisfied: %w", err)
+	}
+	d.Log("Prerequisites are satisfied: target groups %s, %s and listener %s",
+		aws.ToString(targets.blue.TargetGroupName),
+		aws.ToString(targets.green.TargetGroupName),
+		arnToName(aws.ToString(targets.listener.ListenerArn)),
+	)
+
+	appExists, err := d.codeDeployApplicationExists(ctx, appName)
+	if err != nil {
+		return err
+	}
+	dgExists := false
+	if appExists {
+		if dgExists, err = d.codeDeployDeploymentGroupExists(ctx, appName, dgName); err != nil {
+			return err
+		}
+	}
+
+	if opt.CreateCodeDeploy && !appExists {
+		if opt.DryRun {
+			d.Log("CodeDeploy application %s will be created", appName)
+		} else if err := d.createCodeDeployApplication(ctx, appName); err != nil {
+			return err
+		}
+		appExists = !opt.DryRun
+	}
+
+	// the deployment group requires the service of the CODE_DEPLOY deployment controller
+	if sv.isCodeDeploy() && opt.CreateCodeDeploy && !dgExists {
+		in := d.createDeploymentGroupInput(appName, dgName, targets, opt)
+		if opt.DryRun {
+			d.Log("CodeDeploy deployment group %s will be created", dgName)
+			d.LogJSON(in)
+		} else {
+			if _, err := d.codedeploy.CreateDeploymentGroup(ctx, in); err != nil {
+				return fmt.Errorf("failed to create deployment group %s: %w", dgName, err)
+			}
+			d.Log("CodeDeploy deployment group %s is created", dgName)
+			dgExists = true
+		}
+	}
+
+	// codedeploy in the config makes deploy fail until the deployment group is created
+	if !opt.DryRun && dgExists {
+		if err := d.updateCodeDeployConfig(appName, dgName); err != nil {
+			return err
+		}
+	}
+
+	steps := codeDeployMigrationSteps(d.config.path, d.config.ServiceDefinitionPath, appName, dgName, sv.isCodeDeploy(), appExists, dgExists)
+	if len(steps) == 0 {
+		d.Log("Migration completed! ecspresso deploy creates deployments by CodeDeploy %s/%s", appName, dgName)
+		return nil
+	}
+	fmt.Println("Next steps to migrate to CodeDeploy blue/green deployment:")
+	for i, s := range steps {
+		fmt.Printf("%d. %s\n", i+1, s)
+	}
+	return nil
+}
+
+func (d *App) describeCodeDeployTargets(ctx context.Context, sv *Service, opt MigrateToCodeDeployOption) (*codeDeployTargets, error) {
+	var blueArns []string
+	for _, lb := range sv.LoadBalancers {
+		if lb.TargetGroupArn != nil {
+			blueArns = append(blueArns, aws.ToString(lb.TargetGroupArn))
+		}
+	}
+	if len(blueArns) != 1 {
+		return nil, fmt.Errorf("the service must have exactly one target group in loadBalancers, but has %d", len(blueArns))
+	}
+	if blueArns[0] == opt.TargetGroupArn {
+		return nil, fmt.Errorf("--target-group-arn must be another target group than the one of the service: %s", opt.TargetGroupArn)
+	}
+	tgs, err := d.elbv2.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
+		TargetGroupArns: []string{blueArns[0], opt.TargetGroupArn},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe target groups: %w", err)
+	}
+	targets := &codeDeployTargets{}
+	for _, tg := range tgs.TargetGroups {
+		switch aws.ToString(tg.TargetGroupArn) {
+		case blueArns[0]:
+			targets.blue = tg
+		case opt.TargetGroupArn:
+			targets.green = tg
+		}
+	}
+	if targets.blue.TargetGroupArn == nil || targets.green.TargetGroupArn == nil {
+		return nil, ErrNotFound("target groups are not found")
+	}
+
+	listenerArns := []string{opt.ListenerArn}
+	if opt.TestListenerArn != "" {
+		listenerArns = append(listenerArns, opt.TestListenerArn)
+	}
+	ls, err := d.elbv2.DescribeListeners(ctx, &elasticloadbalancingv2.DescribeListenersInput{
+		ListenerArns: listenerArns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe listeners: %w", err)
+	}
+	for _, l := range ls.Listeners {
+		l := l
+		switch aws.ToString(l.ListenerArn) {
+		case opt.ListenerArn:
+			targets.listener = l
+		case opt.TestListenerArn:
+			targets.testListener = &l
+		}
+	}
+	if targets.listener.ListenerArn == nil || (opt.TestListenerArn != "" && targets.testListener == nil) {
+		return nil, ErrNotFound("listeners are not found")
+	}
+	return targets, nil
+}
+
+// checkCodeDeployTargets checks the target groups and the listeners are available for the blue/green deployment.
+func checkCodeDeployTargets(t *codeDeployTargets) error {
+	blue, green := t.blue, t.green
+	if aws.ToString(blue.VpcId) != aws.ToString(green.VpcId) {
+		return fmt.Errorf("target groups %s and %s must be in the same VPC", aws.ToString(blue.TargetGroupName), aws.ToString(green.TargetGroupName))
+	}
+	if blue.Protocol != green.Protocol || blue.TargetType != green.TargetType {
+		return fmt.Errorf("target groups %s and %s must have the same protocol and target type", aws.ToString(blue.TargetGroupName), aws.ToString(green.TargetGroupName))
+	}
+	lbArn := aws.ToString(t.listener.LoadBalancerArn)
+	for _, tg := range []elbv2Types.TargetGroup{blue, green} {
+		if !lo.Contains(tg.LoadBalancerArns, lbArn) {
+			return fmt.Errorf("target group %s is not associated with the load balancer of the listener %s", aws.ToString(tg.TargetGroupName), arnToName(aws.ToString(t.listener.ListenerArn)))
+		}
+	}
+	if !listenerForwardsTo(t.listener, aws.ToString(blue.TargetGroupArn)) {
+		return fmt.Errorf("listener %s must forward to the target group of the service %s", arnToName(aws.ToString(t.listener.ListenerArn)), aws.ToString(blue.TargetGroupName))
+	}
+	if tl := t.testListener; tl != nil && aws.ToString(tl.LoadBalancerArn) != lbArn {
+		return fmt.Errorf("test listener %s must be of the same load balancer as the listener", arnToName(aws.ToString(tl.ListenerArn)))
+	}
+	return nil
+}
+
+// listenerForwardsTo reports whether the default actions of the listener forward to the target group.
+func listenerForwardsTo(l elbv2Types.Listener, tgArn string) bool {
+	for _, a := range l.DefaultActions {
+		if a.Type != elbv2Types.ActionTypeEnumForward {
+			continue
+		}
+		if aws.ToString(a.TargetGroupArn) == tgArn {
+			return true
+		}
+		if a.ForwardConfig != nil {
+			for _, tg := range a.ForwardConfig.TargetGroups {
+				if aws.ToString(tg.TargetGroupArn) == tgArn {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (d *App) codeDeployApplicationExists(ctx context.Context, name string) (bool, error) {
+	_, err := d.codedeploy.GetApplication(ctx, &codedeploy.GetApplicationInput{
+		ApplicationName: aws.String(name),
+	})
+	if err != nil {
+		var notExist *cdTypes.ApplicationDoesNotExistException
+		if errors.As(err, &notExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get application %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (d *App) codeDeployDeploymentGroupExists(ctx context.Context, appName, name string) (bool, error) {
+	_, err := d.codedeploy.GetDeploymentGroup(ctx, &codedeploy.GetDeploymentGroupInput{
+		ApplicationName:     aws.String(appName),
+		DeploymentGroupName: aws.String(name),
+	})
+	if err != nil {
+		var notExist *cdTypes.DeploymentGroupDoesNotExistException
+		if errors.As(err, &notExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to get deployment group %s: %w", name, err)
+	}
+	return true, nil
+}
+
+func (d *App) createCodeDeployApplication(ctx context.Context, name string) error {
+	if _, err := d.codedeploy.CreateApplication(ctx, &codedeploy.CreateApplicationInput{
+		ApplicationName: aws.String(name),
+		ComputePlatform: cdTypes.ComputePlatformEcs,
+	}); err != nil {
+		return fmt.Errorf("failed to create application %s: %w", name, err)
+	}
+	d.Log("CodeDeploy application %s is created", name)
+	return nil
+}
+
+func (d *App) createDeploymentGroupInput(appName, dgName string, t *codeDeployTargets, opt MigrateToCodeDeployOption) *codedeploy.CreateDeploymentGroupInput {
+	pair := cdTypes.TargetGroupPairInfo{
+		TargetGroups: []cdTypes.TargetGroupInfo{
+			{Name: t.blue.TargetGroupName},
+			{Name: t.green.TargetGroupName},
+		},
+		ProdTrafficRoute: &cdTypes.TrafficRoute{
+			ListenerArns: []string{aws.ToString(t.listener.ListenerArn)},
+		},
+	}
+	if t.testListener != nil {
+		pair.TestTrafficRoute = &cdTypes.TrafficRoute{
+			ListenerArns: []string{aws.ToString(t.testListener.ListenerArn)},
+		}
+	}
+	return &codedeploy.CreateDeploymentGroupInput{
+		ApplicationName:      aws.String(appName),
+		DeploymentGroupName:  aws.String(dgName),
+		ServiceRoleArn:       aws.String(opt.ServiceRoleArn),
+		DeploymentConfigName: aws.String(opt.DeploymentConfigName),
+		DeploymentStyle: &cdTypes.DeploymentStyle{
+			DeploymentType:   cdTypes.DeploymentTypeBlueGreen,
+			DeploymentOption: cdTypes.DeploymentOptionWithTrafficControl,
+		},
+		BlueGreenDeploymentConfiguration: &cdTypes.BlueGreenDeploymentConfiguration{
+			DeploymentReadyOption: &cdTypes.DeploymentReadyOption{
+				ActionOnTimeout: cdTypes.DeploymentReadyActionContinueDeployment,
+			},
+			TerminateBlueInstancesOnDeploymentSuccess: &cdTypes.BlueInstanceTerminationOption{
+				Action:                       cdTypes.InstanceActionTerminate,
+				TerminationWaitTimeInMinutes: 5,
+			},
+		},
+		EcsServices: []cdTypes.ECSService{
+			{ClusterName: aws.String(d.Cluster), ServiceName: aws.String(d.Service)},
+		},
+		LoadBalancerInfo: &cdTypes.LoadBalancerInfo{
+			TargetGroupPairInfoList: []cdTypes.TargetGroupPairInfo{pair},
+		},
+	}
+}
+
+// updateCodeDeployConfig sets codedeploy of the config file to the application and the deployment group.
+// Only YAML config files are rewritten. For the other formats, the fields to set are shown.
+func (d *App) updateCodeDeployConfig(appName, dgName string) error {
+	if cd := d.config.CodeDeploy; cd != nil && cd.ApplicationName == appName && cd.DeploymentGroupName == dgName {
+		return nil
+	}
+	path := d.config.path
+	ext := filepath.Ext(path)
+	if isConfigURL(path) || (ext != ymlExt && ext != yamlExt) {
+		d.Log("[NOTICE] set codedeploy in the config file %s manually", path)
+		fmt.Printf("codedeploy:\n  application_name: %s\n  deployment_group_name: %s\n", appName, dgName)
+		return nil
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, setCodeDeployConfig(src, appName, dgName), st.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write config file %s: %w", path, err)
+	}
+	d.Log("codedeploy in the config file %s is updated", path)
+	d.config.CodeDeploy = &ConfigCodeDeploy{ApplicationName: appName, DeploymentGroupName: dgName}
+	return nil
+}
+
+// setCodeDeployConfig replaces codedeploy of the YAML config file.
+// Comments and formatting of other lines are preserved.
+func setCodeDeployConfig(src []byte, appName, dgName string) []byte {
+	lines, _ := removeTopLevelKey(strings.Split(string(src), "\n"), yamlExt, "codedeploy")
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	lines = append(lines,
+		"codedeploy:",
+		"  application_name: "+appName,
+		"  deployment_group_name: "+dgName,
+		"",
+	)
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// codeDeployMigrationSteps returns the remaining steps to migrate the service to CodeDeploy.
+// The deployment controller of the service cannot be changed in place, so the service must be recreated.
+func codeDeployMigrationSteps(configPath, svdPath, appName, dgName string, codeDeployController, appExists, dgExists bool) []string {
+	var steps []string
+	if !appExists {
+		steps = append(steps, "Create the CodeDeploy application by --create-codedeploy --no-dry-run.")
+	}
+	if !codeDeployController {
+		steps = append(steps,
+			fmt.Sprintf(`Add "deploymentController": {"type": "CODE_DEPLOY"} to the service definition %s.`, svdPath),
+			fmt.Sprintf("Delete the service by `ecspresso delete --config %s --terminate`. The service is unavailable until it is recreated.", configPath),
+			fmt.Sprintf("Create the service by `ecspresso deploy --config %s`.", configPath),
+		)
+	}
+	if !dgExists {
+		steps = append(steps,
+			fmt.Sprintf("Create the deployment group by `ecspresso migrate-to-codedeploy --config %s --create-codedeploy --no-dry-run` with the same flags.", configPath),
+			fmt.Sprintf("Set codedeploy in the config file %s after the deployment group is created: application_name: %s, deployment_group_name: %s. The command above sets it to the YAML config file.", configPath, appName, dgName),
+		)
+	}
+	return steps
+}
@@ -0,0 +1,158 @@
+package ecspresso_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	elbv2Types "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+const (
+	testLBArn      = "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:loadbalancer/app/web/0123456789abcdef"
+	testBlueTGArn  = "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/web-blue/0123456789abcdef"
+	testGreenTGArn = "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/web-green/0123456789abcdef"
+)
+
+func testTargetGroup(name, arn, vpc string) elbv2Types.TargetGroup {
+	return elbv2Types.TargetGroup{
+		TargetGroupName:  aws.String(name),
+		TargetGroupArn:   aws.String(arn),
+		VpcId:            aws.String(vpc),
+		Protocol:         elbv2Types.ProtocolEnumHttp,
+		TargetType:       elbv2Types.TargetTypeEnumIp,
+		LoadBalancerArns: []string{testLBArn},
+	}
+}
+
+func testListener(port string, action elbv2Types.Action) elbv2Types.Listener {
+	return elbv2Types.Listener{
+		ListenerArn:     aws.String(testLBArn + "/" + port),
+		LoadBalancerArn: aws.String(testLBArn),
+		DefaultActions:  []elbv2Types.Action{action},
+	}
+}
+
+func TestCheckCodeDeployTargets(t *testing.T) {
+	blue := testTargetGroup("web-blue", testBlueTGArn, "vpc-1")
+	green := testTargetGroup("web-green", testGreenTGArn, "vpc-1")
+	forwardToBlue := elbv2Types.Action{Type: elbv2Types.ActionTypeEnumForward, TargetGroupArn: aws.String(testBlueTGArn)}
+	weighted := elbv2Types.Action{
+		Type: elbv2Types.ActionTypeEnumForward,
+		ForwardConfig: &elbv2Types.ForwardActionConfig{
+			TargetGroups: []elbv2Types.TargetGroupTuple{{TargetGroupArn: aws.String(testBlueTGArn)}},
+		},
+	}
+	forwardToGreen := elbv2Types.Action{Type: elbv2Types.ActionTypeEnumForward, TargetGroupArn: aws.String(testGreenTGArn)}
+
+	otherVPC := green
+	otherVPC.VpcId = aws.String("vpc-2")
+	otherProtocol := green
+	otherProtocol.Protocol = elbv2Types.ProtocolEnumHttps
+	notAssociated := green
+	notAssociated.LoadBalancerArns = nil
+	otherLBListener := testListener("8080", forwardToGreen)
+	otherLBListener.LoadBalancerArn = aws.String("arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:loadbalancer/app/other/0123456789abcdef")
+	testL := testListener("8080", forwardToGreen)
+
+	for _, tt := range []struct {
+		name         string
+		green        elbv2Types.TargetGroup
+		listener     elbv2Types.Listener
+		testListener *elbv2Types.Listener
+		errMsg       string
+	}{
+		{"valid", green, testListener("443", forwardToBlue), nil, ""},
+		{"weighted forward", green, testListener("443", weighted), nil, ""},
+		{"with test listener", green, testListener("443", forwardToBlue), &testL, ""},
+		{"other VPC", otherVPC, testListener("443", forwardToBlue), nil, "same VPC"},
+		{"other protocol", otherProtocol, testListener("443", forwardToBlue), nil, "same protocol"},
+		{"not associated", notAssociated, testListener("443", forwardToBlue), nil, "not associated"},
+		{"not forward to blue", green, testListener("443", forwardToGreen), nil, "must forward"},
+		{"test listener of other LB", green, testListener("443", forwardToBlue), &otherLBListener, "same load balancer"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ecspresso.CheckCodeDeployTargets(blue, tt.green, tt.listener, tt.testListener)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestMigrateToCodeDeployOption(t *testing.T) {
+	for _, tt := range []struct {
+		opt     ecspresso.MigrateToCodeDeployOption
+		isValid bool
+	}{
+		{ecspresso.MigrateToCodeDeployOption{DryRun: true, TargetGroupArn: testGreenTGArn, ListenerArn: testLBArn + "/443"}, true},
+		{ecspresso.MigrateToCodeDeployOption{DryRun: true, TargetGroupArn: testGreenTGArn, ListenerArn: testLBArn + "/443", CreateCodeDeploy: true}, true},
+		{ecspresso.MigrateToCodeDeployOption{DryRun: true, ListenerArn: testLBArn + "/443"}, false},
+		{ecspresso.MigrateToCodeDeployOption{DryRun: true, TargetGroupArn: testGreenTGArn}, false},
+		{ecspresso.MigrateToCodeDeployOption{TargetGroupArn: testGreenTGArn, ListenerArn: testLBArn + "/443", CreateCodeDeploy: true}, false},
+	} {
+		err := tt.opt.Validate()
+		if tt.isValid && err != nil {
+			t.Errorf("%#v unexpected error: %s", tt.opt, err)
+		} else if !tt.isValid && err == nil {
+			t.Errorf("%#v expected error, but got nil", tt.opt)
+		}
+	}
+
+	app, dg := ecspresso.MigrateToCodeDeployOption{}.CodeDeployNames("default", "web")
+	if app != "AppECS-default-web" || dg != "DgpECS-default-web" {
+		t.Errorf("unexpected default names %s %s", app, dg)
+	}
+	app, dg = ecspresso.MigrateToCodeDeployOption{ApplicationName: "myapp", DeploymentGroupName: "mydg"}.CodeDeployNames("default", "web")
+	if app != "myapp" || dg != "mydg" {
+		t.Errorf("unexpected names %s %s", app, dg)
+	}
+}
+
+func TestSetCodeDeployConfig(t *testing.T) {
+	src := `region: ap-northeast-1
+cluster: default
+codedeploy:
+  application_name: old
+  deployment_group_name: old
+service: web # the service
+service_definition: ecs-service-def.json
+
+`
+	expected := `region: ap-northeast-1
+cluster: default
+service: web # the service
+service_definition: ecs-service-def.json
+codedeploy:
+  application_name: AppECS-default-web
+  deployment_group_name: DgpECS-default-web
+`
+	got := string(ecspresso.SetCodeDeployConfig([]byte(src), "AppECS-default-web", "DgpECS-default-web"))
+	if got != expected {
+		t.Errorf("unexpected config:\n%s", got)
+	}
+}
+
+func TestCodeDeployMigrationSteps(t *testing.T) {
+	for _, tt := range []struct {
+		controller, app, dg bool
+		steps               int
+	}{
+		{false, false, false, 6},
+		{false, true, false, 5},
+		{true, true, false, 2},
+		{true, true, true, 0},
+	} {
+		steps := ecspresso.CodeDeployMigrationSteps("ecspresso.yml", "ecs-service-def.json", "app", "dg", tt.controller, tt.app, tt.dg)
+		if len(steps) != tt.steps {
+			t.Errorf("controller:%t app:%t dg:%t unexpected steps: %v", tt.controller, tt.app, tt.dg, steps)
+		}
+	}
+}
@@ -2,16 +2,21 @@ package ecspresso
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
 	"text/template"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/fujiwara/cfn-lookup/cfn"
 	"github.com/fujiwara/ssm-lookup/ssm"
 	"github.com/fujiwara/tfstate-lookup/tfstate"
+	"github.com/goccy/go-yaml"
 	"github.com/google/go-jsonnet"
 	"github.com/kayac/ecspresso/v2/secretsmanager"
 	"github.com/samber/lo"
@@ -19,6 +24,9 @@ import (
 
 var defaultPluginNames = []string{"ssm", "secretsmanager"}
 
+// pluginNames are the names of all the available plugins.
+var pluginNames = []string{"tfstate", "cloudformation", "ssm", "secretsmanager"}
+
 type ConfigPlugin struct {
 	Name       string         `yaml:"name" json:"name,omitempty"`
 	Config     map[string]any `yaml:"config" json:"config,omitempty"`
@@ -26,6 +34,9 @@ type ConfigPlugin struct {
 }
 
 func (p ConfigPlugin) Setup(ctx context.Context, c *Config) error {
+	if c.noAWS && p.requiresAWS() {
+		return setupPluginStub(ctx, p, c)
+	}
 	switch strings.ToLower(p.Name) {
 	case "tfstate":
 		return setupPluginTFState(ctx, p, c)
@@ -40,6 +51,34 @@ func (p ConfigPlugin) Setup(ctx context.Context, c *Config) error {
 	}
 }
 
+// requiresAWS reports whether functions of the plugin call AWS APIs.
+// tfstate plugin reading a remote state by url requires AWS (or other remote backends).
+func (p ConfigPlugin) requiresAWS() bool {
+	switch strings.ToLower(p.Name) {
+	case "cloudformation", "ssm", "secretsmanager":
+		return true
+	case "tfstate":
+		return p.Config["url"] != nil
+	default:
+		return false
+	}
+}
+
+// withBaseDir returns a copy of the plugin which has an absolute path resolved by dir.
+func (p ConfigPlugin) withBaseDir(dir string) ConfigPlugin {
+	path, ok := p.Config["path"].(string)
+	if !ok || filepath.IsAbs(path) {
+		return p
+	}
+	config := make(map[string]any, len(p.Config))
+	for k, v := range p.Config {
+		config[k] = v
+	}
+	config["path"] = filepath.Join(dir, path)
+	p.Config = config
+	return p
+}
+
 func (p ConfigPlugin) AppendFuncMap(c *Config, funcMap template.FuncMap) error {
 	modified := make(template.FuncMap, len(funcMap))
 	for funcName, f := range funcMap {
@@ -135,7 +174,7 @@ func setupPluginSSM(ctx context.Context, p ConfigPlugin, c *Config) error {
 }
 
 func setupPluginSecretsManager(ctx context.Context, p ConfigPlugin, c *Config) error {
-	lookup := secretsmanager.NewApp(c.awsv2Config)
+	lookup := secretsmanager.NewApp(c.awsv2Config, c.log)
 	if err := p.AppendFuncMap(c, lookup.FuncMap(ctx)); err != nil {
 		return err
 	}
@@ -144,3 +183,155 @@ func setupPluginSecretsManager(ctx context.Context, p ConfigPlugin, c *Config) e
 	}
 	return nil
 }
+
+// emptyTFState is used to enumerate functions of tfstate plugin without reading a state.
+const emptyTFState = `{"version":4,"resources":[]}`
+
+// pluginFuncs returns the functions of the plugin without reading any states.
+func pluginFuncs(ctx context.Context, name string, cfg aws.Config) (template.FuncMap, []*jsonnet.NativeFunction, error) {
+	switch strings.ToLower(name) {
+	case "tfstate":
+		lookup, err := tfstate.Read(ctx, strings.NewReader(emptyTFState))
+		if err != nil {
+			return nil, nil, err
+		}
+		return lookup.FuncMap(ctx), lookup.JsonnetNativeFuncs(ctx), nil
+	case "cloudformation":
+		lookup := cfn.New(cfg, &sync.Map{})
+		return lookup.FuncMap(ctx), lookup.JsonnetNativeFuncs(ctx), nil
+	case "ssm":
+		lookup := ssm.New(cfg, &sync.Map{})
+		return lookup.FuncMap(ctx), lookup.JsonnetNativeFuncs(ctx), nil
+	case "secretsmanager":
+		lookup := secretsmanager.NewApp(cfg, Log)
+		return lookup.FuncMap(ctx), lookup.JsonnetNativeFuncs(ctx), nil
+	default:
+		return nil, nil, fmt.Errorf("plugin %s is not available", name)
+	}
+}
+
+// setupPluginStub appends stubs of the plugin functions, which return a placeholder without calling AWS APIs.
+func setupPluginStub(ctx context.Context, p ConfigPlugin, c *Config) error {
+	funcMap, nativeFuncs, err := pluginFuncs(ctx, p.Name, c.awsv2Config)
+	if err != nil {
+		return err
+	}
+	Log("[DEBUG] functions of %s plugin are stubbed by --no-aws", p.Name)
+
+	stubs := make(template.FuncMap, len(funcMap))
+	for name := range funcMap {
+		name := name
+		stubs[name] = func(args ...any) string {
+			return noAWSPlaceholder(p.FuncPrefix+name, args)
+		}
+	}
+	for _, f := range nativeFuncs {
+		name := f.Name
+		f.Func = func(args []any) (any, error) {
+			return noAWSPlaceholder(p.FuncPrefix+name, args), nil
+		}
+	}
+	if err := p.AppendFuncMap(c, stubs); err != nil {
+		return err
+	}
+	if err := p.AppendJsonnetNativeFuncs(c, nativeFuncs); err != nil {
+		return err
+	}
+	return nil
+}
+
+// pluginFuncNames returns the names of template functions of each plugin (without func_prefix).
+func pluginFuncNames(ctx context.Context) (map[string][]string, error) {
+	names := make(map[string][]string, len(pluginNames))
+	for _, name := range pluginNames {
+		funcMap, _, err := pluginFuncs(ctx, name, aws.Config{})
+		if err != nil {
+			return nil, err
+		}
+		for fn := range funcMap {
+			names[name] = append(names[name], fn)
+		}
+	}
+	return names, nil
+}
+
+// deferredPluginFuncs returns template functions of the plugins,
+// which output the function calls as is to be expanded after the plugins are set up.
+// The functions are named without func_prefix for all the plugins,
+// and with func_prefix for the plugins configured with it.
+func deferredPluginFuncs(funcNames map[string][]string, plugins []ConfigPlugin) template.FuncMap {
+	deferred := template.FuncMap{}
+	add := func(prefix, name string) {
+		for _, fn := range funcNames[strings.ToLower(name)] {
+			fn := prefix + fn
+			deferred[fn] = func(args ...any) string {
+				return deferredFuncCall(fn, args)
+			}
+		}
+	}
+	for _, name := range pluginNames {
+		add("", name)
+	}
+	for _, p := range plugins {
+		if p.FuncPrefix != "" {
+			add(p.FuncPrefix, p.Name)
+		}
+	}
+	return deferred
+}
+
+// templateActionRe matches template actions, e.g. {{ tfstate `aws_ecs_cluster.main.name` }}.
+var templateActionRe = regexp.MustCompile(`{{.*?}}`)
+
+// configuredPlugins returns the plugins in the config source before it is rendered.
+// Template actions are removed to parse the source. Errors are ignored,
+// because the source is parsed again after rendered.
+func configuredPlugins(src []byte, ext string) []ConfigPlugin {
+	src = templateActionRe.ReplaceAll(src, nil)
+	var err error
+	switch ext {
+	case ymlExt, yamlExt:
+		src, err = yaml.YAMLToJSON(src)
+	case tomlExt:
+		src, err = tomlToJSON(src)
+	}
+	if err != nil {
+		return nil
+	}
+	var c struct {
+		Plugins []ConfigPlugin `json:"plugins"`
+	}
+	if err := json.Unmarshal(src, &c); err != nil {
+		return nil
+	}
+	return c.Plugins
+}
+
+// deferredFuncCall returns the template action calling the function, e.g. {{ tfstate `aws_ecs_cluster.main.name` }}.
+// String arguments are quoted by backquotes to be embedded in YAML, JSON and TOML strings.
+func deferredFuncCall(name string, args []any) string {
+	s := make([]string, 0, len(args)+1)
+	s = append(s, name)
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			if strings.Contains(v, "`") {
+				s = append(s, strconv.Quote(v))
+			} else {
+				s = append(s, "`"+v+"`")
+			}
+		default:
+			s = append(s, fmt.Sprint(v))
+		}
+	}
+	return "{{ " + strings.Join(s, " ") + " }}"
+}
+
+// noAWSPlaceholder returns a placeholder of the function call, e.g. "no-aws:ssm(/path/to/param)".
+func noAWSPlaceholder(name string, args []any) string {
+	s := make([]string, 0, len(args))
+	for _, arg := range args {
+		s = append(s, fmt.Sprint(arg))
+	}
+	return fmt.Sprintf("no-aws:%s(%s)", name, strings.Join(s, ","))
+}
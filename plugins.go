@@ -0,0 +1,103 @@
+package ecspresso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/google/go-jsonnet"
+	"github.com/olekukonko/tablewriter"
+)
+
+type PluginsOption struct {
+	List *PluginsListOption `cmd:"" help:"list plugins and functions registered by them"`
+}
+
+type PluginsListOption struct {
+	Output string `help:"output format" enum:"table,json" default:"table"`
+}
+
+// pluginInfo represents a plugin set up by the config and functions registered by it.
+type pluginInfo struct {
+	Name               string   `json:"name"`
+	Default            bool     `json:"default"`
+	FuncPrefix         string   `json:"func_prefix,omitempty"`
+	TemplateFuncs      []string `json:"template_funcs"`
+	JsonnetNativeFuncs []string `json:"jsonnet_native_funcs"`
+}
+
+func newPluginInfo(p ConfigPlugin, isDefault bool, funcMaps []template.FuncMap, nativeFuncs []*jsonnet.NativeFunction) pluginInfo {
+	info := pluginInfo{
+		Name:               p.Name,
+		Default:            isDefault,
+		FuncPrefix:         p.FuncPrefix,
+		TemplateFuncs:      []string{},
+		JsonnetNativeFuncs: []string{},
+	}
+	for _, fm := range funcMaps {
+		for name := range fm {
+			info.TemplateFuncs = append(info.TemplateFuncs, name)
+		}
+	}
+	for _, f := range nativeFuncs {
+		info.JsonnetNativeFuncs = append(info.JsonnetNativeFuncs, f.Name)
+	}
+	sort.Strings(info.TemplateFuncs)
+	sort.Strings(info.JsonnetNativeFuncs)
+	return info
+}
+
+func (info pluginInfo) Cols() []string {
+	kind := "configured"
+	if info.Default {
+		kind = "default"
+	}
+	return []string{
+		info.Name,
+		kind,
+		strings.Join(info.TemplateFuncs, "\n"),
+		strings.Join(info.JsonnetNativeFuncs, "\n"),
+	}
+}
+
+type pluginInfos []pluginInfo
+
+func (infos pluginInfos) Header() []string {
+	return []string{"Name", "Type", "Template Functions", "Jsonnet Native Functions"}
+}
+
+func (infos pluginInfos) OutputJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(infos)
+}
+
+func (infos pluginInfos) OutputTable(w io.Writer) error {
+	t := tablewriter.NewWriter(w)
+	t.SetHeader(infos.Header())
+	t.SetAutoWrapText(false)
+	t.SetRowLine(true)
+	for _, info := range infos {
+		t.Append(info.Cols())
+	}
+	t.Render()
+	return nil
+}
+
+func (d *App) Plugins(ctx context.Context, opt PluginsOption) error {
+	if opt.List == nil {
+		return fmt.Errorf("subcommand of plugins is required")
+	}
+	infos := pluginInfos(d.config.pluginInfos)
+	switch opt.List.Output {
+	case "json":
+		return infos.OutputJSON(os.Stdout)
+	default:
+		return infos.OutputTable(os.Stdout)
+	}
+}
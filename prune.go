@@ -0,0 +1,87 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const defaultPruneKeeps = 10
+
+type PruneOption struct {
+	DryRun bool `help:"dry run" default:"false"`
+	Keeps  *int `help:"number of the most recent revisions to keep. default 10 or prune.keeps in the config"`
+	Force  bool `help:"prune without confirmation" default:"false"`
+}
+
+func (opt PruneOption) DryRunString() string {
+	if opt.DryRun {
+		return dryRunStr
+	}
+	return ""
+}
+
+// ConfigPrune represents the defaults of pruning old revisions of the task definition.
+type ConfigPrune struct {
+	Keeps int `yaml:"keeps,omitempty" json:"keeps,omitempty"`
+}
+
+// Prune deregisters the revisions of the task definition older than the deployed one,
+// except the most recent revisions and the revisions in use.
+func (d *App) Prune(ctx context.Context, opt PruneOption) error {
+	ctx, cancel := d.Start(ctx)
+	defer cancel()
+	d.Log("Starting prune task definitions %s", opt.DryRunString())
+
+	keeps, err := d.pruneKeeps(opt.Keeps)
+	if err != nil {
+		return err
+	}
+	var family string
+	var base int32
+	if d.config.Service != "" {
+		sv, err := d.DescribeService(ctx)
+		if err != nil {
+			return err
+		}
+		family, base = taskDefinitionRevision(aws.ToString(sv.TaskDefinition))
+	} else {
+		td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
+		if err != nil {
+			return err
+		}
+		family = aws.ToString(td.Family)
+	}
+	inUse, err := d.inUseRevisions(ctx)
+	if err != nil {
+		return err
+	}
+	if err := d.deregisterOldRevisions(ctx, family, DeregisterOption{
+		DryRun:    opt.DryRun,
+		Keeps:     &keeps,
+		OlderThan: base,
+		Force:     opt.Force,
+	}, inUse); err != nil {
+		return err
+	}
+	if opt.DryRun {
+		d.Log("DRY RUN OK")
+	}
+	return nil
+}
+
+// pruneKeeps returns the number of revisions to keep by the flag, prune.keeps in the config, or the default.
+func (d *App) pruneKeeps(keeps *int) (int, error) {
+	switch {
+	case keeps != nil:
+		if *keeps <= 0 {
+			return 0, fmt.Errorf("the number of revisions to keep must be greater than 0: %d", *keeps)
+		}
+		return *keeps, nil
+	case d.config.Prune != nil && d.config.Prune.Keeps > 0:
+		return d.config.Prune.Keeps, nil
+	default:
+		return defaultPruneKeeps, nil
+	}
+}
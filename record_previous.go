@@ -0,0 +1,125 @@
+package ecspresso
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// previousRecordSSMPrefix is the prefix of the location to store the record in the SSM parameter.
+const previousRecordSSMPrefix = "ssm:"
+
+// PreviousRecord is the record of the task definition which was active before the deployment.
+// It is written by deploy --record-previous and read by rollback --from-record.
+type PreviousRecord struct {
+	Cluster        string    `json:"cluster"`
+	Service        string    `json:"service"`
+	TaskDefinition string    `json:"task_definition"`
+	RecordedAt     time.Time `json:"recorded_at"`
+}
+
+// parsePreviousRecordLocation returns the name of the SSM parameter or the file path of the location.
+// The location is "ssm:{parameter name}" or a file path.
+func parsePreviousRecordLocation(loc string) (param string, path string, err error) {
+	if loc == "" {
+		return "", "", errors.New("the location of the record is empty")
+	}
+	if strings.HasPrefix(loc, previousRecordSSMPrefix) {
+		name := strings.TrimPrefix(loc, previousRecordSSMPrefix)
+		if name == "" {
+			return "", "", fmt.Errorf("the SSM parameter name of the record is empty: %s", loc)
+		}
+		return name, "", nil
+	}
+	return "", loc, nil
+}
+
+// recordPrevious writes the record of the task definition of the service before the service is updated.
+func (d *App) recordPrevious(ctx context.Context, loc string, sv *Service) error {
+	rec := PreviousRecord{
+		Cluster:        d.Cluster,
+		Service:        d.Service,
+		TaskDefinition: aws.ToString(sv.TaskDefinition),
+		RecordedAt:     time.Now(),
+	}
+	if rec.TaskDefinition == "" {
+		return errors.New("the service has no task definition to record")
+	}
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	param, path, err := parsePreviousRecordLocation(loc)
+	if err != nil {
+		return err
+	}
+	if param != "" {
+		_, err = ssm.NewFromConfig(d.config.awsv2Config).PutParameter(ctx, &ssm.PutParameterInput{
+			Name:      aws.String(param),
+			Value:     aws.String(string(b)),
+			Type:      ssmTypes.ParameterTypeString,
+			Overwrite: aws.Bool(true),
+		})
+	} else {
+		err = os.WriteFile(path, append(b, '\n'), 0644)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record the previous task definition to %s: %w", loc, err)
+	}
+	d.Log("Recorded the previous task definition %s to %s", arnToName(rec.TaskDefinition), loc)
+	return nil
+}
+
+// readPreviousRecord reads the record written by deploy --record-previous.
+// The record must be of the service.
+func (d *App) readPreviousRecord(ctx context.Context, loc string) (*PreviousRecord, error) {
+	param, path, err := parsePreviousRecordLocation(loc)
+	if err != nil {
+		return nil, err
+	}
+	var b []byte
+	if param != "" {
+		out, err := ssm.NewFromConfig(d.config.awsv2Config).GetParameter(ctx, &ssm.GetParameterInput{
+			Name: aws.String(param),
+		})
+		if err != nil {
+			var notFound *ssmTypes.ParameterNotFound
+			if errors.As(err, &notFound) {
+				return nil, ErrNotFound(fmt.Sprintf("the record is not found: %s", loc))
+			}
+			return nil, fmt.Errorf("failed to get the record %s: %w", loc, err)
+		}
+		b = []byte(aws.ToString(out.Parameter.Value))
+	} else {
+		b, err = os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, ErrNotFound(fmt.Sprintf("the record is not found: %s", loc))
+			}
+			return nil, fmt.Errorf("failed to read the record %s: %w", loc, err)
+		}
+	}
+	return parsePreviousRecord(b, d.Cluster, d.Service)
+}
+
+func parsePreviousRecord(b []byte, cluster, service string) (*PreviousRecord, error) {
+	var rec PreviousRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, fmt.Errorf("invalid record: %w", err)
+	}
+	if rec.TaskDefinition == "" {
+		return nil, errors.New("invalid record: task_definition is empty")
+	}
+	if rec.Cluster != cluster || rec.Service != service {
+		return nil, fmt.Errorf("the record is of %s/%s, not of %s/%s", rec.Cluster, rec.Service, cluster, service)
+	}
+	return &rec, nil
+}
@@ -0,0 +1,60 @@
+package ecspresso_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestParsePreviousRecordLocation(t *testing.T) {
+	for _, tt := range []struct {
+		loc     string
+		param   string
+		path    string
+		isValid bool
+	}{
+		{"ssm:/ecspresso/previous/app", "/ecspresso/previous/app", "", true},
+		{"ssm:previous-app", "previous-app", "", true},
+		{"previous.json", "", "previous.json", true},
+		{"/tmp/previous.json", "", "/tmp/previous.json", true},
+		{"ssm:", "", "", false},
+		{"", "", "", false},
+	} {
+		param, path, err := ecspresso.ParsePreviousRecordLocation(tt.loc)
+		if !tt.isValid {
+			if err == nil {
+				t.Errorf("%s expected error, but got nil", tt.loc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s unexpected error: %s", tt.loc, err)
+			continue
+		}
+		if param != tt.param || path != tt.path {
+			t.Errorf("%s unexpected location: param=%s path=%s", tt.loc, param, path)
+		}
+	}
+}
+
+func TestParsePreviousRecord(t *testing.T) {
+	src := `{"cluster":"default","service":"app","task_definition":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:41","recorded_at":"2024-01-01T00:00:00Z"}`
+	rec, err := ecspresso.ParsePreviousRecord([]byte(src), "default", "app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.TaskDefinition != "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:41" {
+		t.Errorf("unexpected task definition: %s", rec.TaskDefinition)
+	}
+
+	if _, err := ecspresso.ParsePreviousRecord([]byte(src), "default", "other"); err == nil || !strings.Contains(err.Error(), "the record is of default/app") {
+		t.Errorf("unexpected error for the record of another service: %v", err)
+	}
+	if _, err := ecspresso.ParsePreviousRecord([]byte(`{"cluster":"default","service":"app"}`), "default", "app"); err == nil {
+		t.Error("expected an error for the record without task_definition")
+	}
+	if _, err := ecspresso.ParsePreviousRecord([]byte(`app:41`), "default", "app"); err == nil {
+		t.Error("expected an error for the invalid record")
+	}
+}
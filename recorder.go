@@ -0,0 +1,139 @@
+package ecspresso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	dynamodbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// DeployRecord represents an audit entry of a deployment.
+type DeployRecord struct {
+	Service        string    `json:"service"` // cluster/service
+	Time           time.Time `json:"time"`
+	User           string    `json:"user"`
+	Caller         string    `json:"caller,omitempty"` // ARN of the AWS caller identity
+	TaskDefinition string    `json:"task_definition,omitempty"`
+	DesiredCount   *int32    `json:"desired_count,omitempty"`
+	Version        string    `json:"version"` // ecspresso version
+	Status         string    `json:"status"`  // succeeded or failed
+	Error          string    `json:"error,omitempty"`
+}
+
+const (
+	deployRecordSucceeded = "succeeded"
+	deployRecordFailed    = "failed"
+)
+
+// dynamoDBItem converts the record to a DynamoDB item.
+// The table must have "service" (S) as a partition key and "time" (S) as a sort key.
+func (r *DeployRecord) dynamoDBItem() map[string]dynamodbTypes.AttributeValue {
+	str := func(s string) dynamodbTypes.AttributeValue {
+		return &dynamodbTypes.AttributeValueMemberS{Value: s}
+	}
+	item := map[string]dynamodbTypes.AttributeValue{
+		"service": str(r.Service),
+		"time":    str(r.Time.Format(time.RFC3339Nano)),
+		"user":    str(r.User),
+		"version": str(r.Version),
+		"status":  str(r.Status),
+	}
+	if r.Caller != "" {
+		item["caller"] = str(r.Caller)
+	}
+	if r.TaskDefinition != "" {
+		item["task_definition"] = str(r.TaskDefinition)
+	}
+	if r.DesiredCount != nil {
+		item["desired_count"] = &dynamodbTypes.AttributeValueMemberN{Value: strconv.Itoa(int(*r.DesiredCount))}
+	}
+	if r.Error != "" {
+		item["error"] = str(r.Error)
+	}
+	return item
+}
+
+func appendDeployRecordFile(path string, r *DeployRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open record file %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("failed to write record file %s: %w", path, err)
+	}
+	return nil
+}
+
+// putDeployRecordItem puts the record by DynamoDB PutItem API.
+func (d *App) putDeployRecordItem(ctx context.Context, table string, r *DeployRecord) error {
+	if _, err := dynamodb.NewFromConfig(d.config.awsv2Config).PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item:      r.dynamoDBItem(),
+	}); err != nil {
+		return fmt.Errorf("failed to put item to %s: %w", table, err)
+	}
+	return nil
+}
+
+func (d *App) newDeployRecord(ctx context.Context) *DeployRecord {
+	r := &DeployRecord{
+		Service: d.Cluster + "/" + d.Service,
+		Time:    time.Now(),
+		Version: Version,
+	}
+	if u, err := user.Current(); err == nil {
+		r.User = u.Username
+	}
+	if out, err := sts.NewFromConfig(d.config.awsv2Config).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err != nil {
+		d.Log("[WARNING] failed to get caller identity: %s", err)
+	} else {
+		r.Caller = aws.ToString(out.Arn)
+	}
+	return r
+}
+
+// recordDeploy records the result of the deployment.
+// A failure of recording returns an error only when opt.RecordFatal is true.
+func (d *App) recordDeploy(ctx context.Context, r *DeployRecord, opt DeployOption, deployErr error) error {
+	if r == nil {
+		return deployErr
+	}
+	r.Status = deployRecordSucceeded
+	if deployErr != nil {
+		r.Status = deployRecordFailed
+		r.Error = deployErr.Error()
+	}
+	var err error
+	switch {
+	case opt.RecordTable != "":
+		err = d.putDeployRecordItem(ctx, opt.RecordTable, r)
+	case opt.RecordFile != "":
+		err = appendDeployRecordFile(opt.RecordFile, r)
+	}
+	if err == nil {
+		d.Log("[INFO] the deployment is recorded")
+		return deployErr
+	}
+	if deployErr != nil {
+		d.Log("[WARNING] failed to record the deployment: %s", err)
+		return deployErr
+	}
+	if opt.RecordFatal {
+		return fmt.Errorf("failed to record the deployment: %w", err)
+	}
+	d.Log("[WARNING] failed to record the deployment: %s", err)
+	return nil
+}
@@ -0,0 +1,130 @@
+package ecspresso_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	dynamodbTypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestAppendDeployRecordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deploy.jsonl")
+	records := []*ecspresso.DeployRecord{
+		{
+			Service:        "default/app",
+			Time:           time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			User:           "alice",
+			TaskDefinition: "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:10",
+			DesiredCount:   aws.Int32(2),
+			Version:        "v2.0.0",
+			Status:         "succeeded",
+		},
+		{
+			Service: "default/app",
+			Time:    time.Date(2024, 1, 2, 4, 0, 0, 0, time.UTC),
+			User:    "bob",
+			Version: "v2.0.0",
+			Status:  "failed",
+			Error:   "service is not stable",
+		},
+	}
+	for _, r := range records {
+		if err := ecspresso.AppendDeployRecordFile(path, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []*ecspresso.DeployRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r ecspresso.DeployRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, &r)
+	}
+	if diff := cmp.Diff(records, got); diff != "" {
+		t.Errorf("unexpected records: %s", diff)
+	}
+}
+
+func TestDeployRecordDynamoDBItem(t *testing.T) {
+	r := &ecspresso.DeployRecord{
+		Service:      "default/app",
+		Time:         time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		User:         "alice",
+		DesiredCount: aws.Int32(2),
+		Version:      "v2.0.0",
+		Status:       "succeeded",
+	}
+	expected := map[string]dynamodbTypes.AttributeValue{
+		"service":       &dynamodbTypes.AttributeValueMemberS{Value: "default/app"},
+		"time":          &dynamodbTypes.AttributeValueMemberS{Value: "2024-01-02T03:04:05Z"},
+		"user":          &dynamodbTypes.AttributeValueMemberS{Value: "alice"},
+		"desired_count": &dynamodbTypes.AttributeValueMemberN{Value: "2"},
+		"version":       &dynamodbTypes.AttributeValueMemberS{Value: "v2.0.0"},
+		"status":        &dynamodbTypes.AttributeValueMemberS{Value: "succeeded"},
+	}
+	opt := cmpopts.IgnoreUnexported(dynamodbTypes.AttributeValueMemberS{}, dynamodbTypes.AttributeValueMemberN{})
+	if diff := cmp.Diff(expected, r.DynamoDBItem(), opt); diff != "" {
+		t.Errorf("unexpected item: %s", diff)
+	}
+}
+
+func TestPutDeployRecordItem(t *testing.T) {
+	var target string
+	var body map[string]interface{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.Header.Get("X-Amz-Target")
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &body)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_DYNAMODB", ts.URL)
+
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &ecspresso.DeployRecord{
+		Service: "default/app",
+		Time:    time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Version: "v2.0.0",
+		Status:  "succeeded",
+	}
+	if err := app.PutDeployRecordItem(ctx, "deployments", r); err != nil {
+		t.Fatal(err)
+	}
+	if target != "DynamoDB_20120810.PutItem" {
+		t.Errorf("unexpected target %s", target)
+	}
+	if body["TableName"] != "deployments" {
+		t.Errorf("unexpected table name %v", body["TableName"])
+	}
+	item, _ := body["Item"].(map[string]interface{})
+	if diff := cmp.Diff(map[string]interface{}{"S": "default/app"}, item["service"]); diff != "" {
+		t.Errorf("unexpected item: %s", diff)
+	}
+}
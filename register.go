@@ -2,12 +2,19 @@ package ecspresso
 
 import (
 	"context"
+	"fmt"
 	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 )
 
 type RegisterOption struct {
-	DryRun bool `help:"dry run" default:"false"`
-	Output bool `help:"output the registered task definition as JSON" default:"false"`
+	DryRun        bool   `help:"dry run" default:"false"`
+	Output        bool   `help:"output the registered task definition as JSON" default:"false"`
+	OutputArn     bool   `help:"output the ARN of the registered task definition" default:"false"`
+	OutputArnFile string `help:"write the ARN of the registered task definition to the file"`
 }
 
 func (opt RegisterOption) DryRunString() string {
@@ -17,7 +24,17 @@ func (opt RegisterOption) DryRunString() string {
 	return ""
 }
 
+func (opt RegisterOption) validate() error {
+	if opt.Output && opt.OutputArn {
+		return ErrConflictOptions("output and output-arn are exclusive")
+	}
+	return nil
+}
+
 func (d *App) Register(ctx context.Context, opt RegisterOption) error {
+	if err := opt.validate(); err != nil {
+		return err
+	}
 	ctx, cancel := d.Start(ctx)
 	defer cancel()
 
@@ -31,6 +48,14 @@ func (d *App) Register(ctx context.Context, opt RegisterOption) error {
 		if err := d.OutputJSONForAPI(os.Stdout, td); err != nil {
 			return err
 		}
+		name, err := d.nextTaskDefinitionName(ctx, aws.ToString(td.Family))
+		if err != nil {
+			return err
+		}
+		d.Log("task definition %s will be registered", name)
+		if opt.OutputArnFile != "" {
+			d.Log("%s is not written on dry run", opt.OutputArnFile)
+		}
 		d.Log("DRY RUN OK")
 		return nil
 	}
@@ -39,9 +64,65 @@ func (d *App) Register(ctx context.Context, opt RegisterOption) error {
 	if err != nil {
 		return err
 	}
+	tdArn := aws.ToString(newTd.TaskDefinitionArn)
+	if opt.OutputArnFile != "" {
+		if err := writeFileAtomic(opt.OutputArnFile, []byte(tdArn+"\n")); err != nil {
+			return err
+		}
+		d.Log("[INFO] the ARN of the task definition is written to %s", opt.OutputArnFile)
+	}
 
-	if opt.Output {
+	switch {
+	case opt.Output:
 		return d.OutputJSONForAPI(os.Stdout, newTd)
+	case opt.OutputArn:
+		fmt.Println(tdArn)
 	}
 	return nil
 }
+
+// nextTaskDefinitionName returns family:revision which the task definition will be registered as.
+// The revision may differ if another one is registered concurrently.
+func (d *App) nextTaskDefinitionName(ctx context.Context, family string) (string, error) {
+	var latest int32
+	// the revision numbers of the inactive and deleting revisions are not reused
+	for _, status := range []types.TaskDefinitionStatus{
+		types.TaskDefinitionStatusActive,
+		types.TaskDefinitionStatusInactive,
+		types.TaskDefinitionStatusDeleteInProgress,
+	} {
+		rev, err := d.latestRevision(ctx, family, status)
+		if err != nil {
+			return "", err
+		}
+		if rev > latest {
+			latest = rev
+		}
+	}
+	return fmt.Sprintf("%s:%d", family, latest+1), nil
+}
+
+// latestRevision returns the latest revision number of the family in the status, or 0 if no revisions are found.
+func (d *App) latestRevision(ctx context.Context, family string, status types.TaskDefinitionStatus) (int32, error) {
+	var nextToken *string
+	for {
+		res, err := d.ecs.ListTaskDefinitions(ctx, &ecs.ListTaskDefinitionsInput{
+			FamilyPrefix: aws.String(family),
+			Status:       status,
+			Sort:         types.SortOrderDesc,
+			NextToken:    nextToken,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to list task definitions: %w", err)
+		}
+		for _, a := range res.TaskDefinitionArns {
+			// FamilyPrefix also matches the other families which have the same prefix
+			if f, rev := taskDefinitionRevision(a); f == family {
+				return rev, nil
+			}
+		}
+		if nextToken = res.NextToken; nextToken == nil {
+			return 0, nil
+		}
+	}
+}
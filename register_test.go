@@ -0,0 +1,133 @@
+package ecspresso_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+var registerResponses = map[string]string{
+	"RegisterTaskDefinition": `{"taskDefinition":{"taskDefinitionArn":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/katsubushi:40","family":"katsubushi","revision":40,"status":"ACTIVE"}}`,
+	"ListTaskDefinitions":    `{"taskDefinitionArns":["arn:aws:ecs:ap-northeast-1:123456789012:task-definition/katsubushi:39"]}`,
+}
+
+func newRegisterApp(t *testing.T) (*ecspresso.App, *[]string) {
+	t.Helper()
+	var ops []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.SplitN(r.Header.Get("X-Amz-Target"), ".", 2)[1]
+		ops = append(ops, op)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if res, ok := registerResponses[op]; ok {
+			w.Write([]byte(res))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+
+	app, err := ecspresso.New(context.Background(), &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, &ops
+}
+
+func TestRegisterOutputArnFile(t *testing.T) {
+	app, _ := newRegisterApp(t)
+	path := filepath.Join(t.TempDir(), "taskdef-arn")
+	if err := app.Register(context.Background(), ecspresso.RegisterOption{OutputArnFile: path}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, expected := strings.TrimSpace(string(b)), "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/katsubushi:40"; got != expected {
+		t.Errorf("unexpected ARN in the file: expected %s got %s", expected, got)
+	}
+	// no temporary files are left
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("unexpected files: %v", entries)
+	}
+}
+
+func TestRegisterOutputArnFileDryRun(t *testing.T) {
+	app, ops := newRegisterApp(t)
+	path := filepath.Join(t.TempDir(), "taskdef-arn")
+	if err := app.Register(context.Background(), ecspresso.RegisterOption{OutputArnFile: path, DryRun: true}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the file must not be written on dry run: %v", err)
+	}
+	for _, op := range *ops {
+		if op == "RegisterTaskDefinition" {
+			t.Error("RegisterTaskDefinition must not be called on dry run")
+		}
+	}
+}
+
+func TestRegisterOptionConflicts(t *testing.T) {
+	app, _ := newRegisterApp(t)
+	err := app.Register(context.Background(), ecspresso.RegisterOption{Output: true, OutputArn: true})
+	if err == nil {
+		t.Error("output and output-arn must be exclusive")
+	}
+}
+
+func TestNextTaskDefinitionName(t *testing.T) {
+	arns := map[string][]string{
+		"ACTIVE": {
+			"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/katsubushi-worker:99",
+			"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/katsubushi:39",
+		},
+		"INACTIVE": {
+			"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/katsubushi:41",
+		},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Status string `json:"status"`
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		json.NewEncoder(w).Encode(map[string][]string{"taskDefinitionArns": arns[in.Status]})
+	}))
+	defer ts.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for family, expected := range map[string]string{
+		"katsubushi": "katsubushi:42", // next to the inactive revision
+		"katsu":      "katsu:1",       // prefix only
+	} {
+		name, err := app.NextTaskDefinitionName(ctx, family)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != expected {
+			t.Errorf("unexpected next revision of %s: expected %s got %s", family, expected, name)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/goccy/go-yaml"
@@ -14,11 +15,33 @@ import (
 type RenderOption struct {
 	Targets *[]string `arg:"" help:"target to render (config, service-definition, servicedef, task-definition, taskdef)" enum:"config,service-definition,servicedef,task-definition,taskdef"`
 	Jsonnet bool      `help:"render as jsonnet format" default:"false"`
+	NoAWS   bool      `name:"no-aws" help:"render without AWS. functions of plugins calling AWS APIs return a placeholder" default:"false"`
+	Output  string    `help:"write to the file instead of stdout"`
 }
 
+// Render renders the targets. The service and task definitions are rendered as they are sent to AWS,
+// with the tags and the ignore settings of the config applied.
 func (d *App) Render(ctx context.Context, opt RenderOption) error {
-	out := bufio.NewWriter(os.Stdout)
-	defer out.Flush()
+	if opt.Output == "" {
+		return d.render(os.Stdout, opt)
+	}
+	f, err := os.Create(opt.Output)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", opt.Output, err)
+	}
+	if err := d.render(f, opt); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", opt.Output, err)
+	}
+	d.Log("[INFO] rendered to %s", opt.Output)
+	return nil
+}
+
+func (d *App) render(w io.Writer, opt RenderOption) error {
+	out := bufio.NewWriter(w)
 	d.Log("[DEBUG] targets %v", opt.Targets)
 	for _, target := range *opt.Targets {
 		switch target {
@@ -60,6 +83,7 @@ func (d *App) Render(ctx context.Context, opt RenderOption) error {
 			if err != nil {
 				return err
 			}
+			d.prepareTaskDefinitionForRegister(td)
 			s := MustMarshalJSONStringForAPI(td)
 			if opt.Jsonnet {
 				s, err = formatter.Format(d.config.TaskDefinitionPath, s, formatter.DefaultOptions())
@@ -74,5 +98,5 @@ func (d *App) Render(ctx context.Context, opt RenderOption) error {
 			return fmt.Errorf("unknown target: %s", target)
 		}
 	}
-	return nil
+	return out.Flush()
 }
@@ -0,0 +1,55 @@
+package ecspresso_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestRenderDefinitions(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/render/ecspresso.jsonnet"}, ecspresso.WithoutAWS())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		target   string
+		expected string
+		v        any
+	}{
+		{target: "task-definition", expected: "tests/render/td.expected.json", v: &ecspresso.TaskDefinitionInput{}},
+		{target: "service-definition", expected: "tests/render/sv.expected.json", v: &ecspresso.Service{}},
+	}
+	for _, c := range cases {
+		t.Run(c.target, func(t *testing.T) {
+			output := filepath.Join(t.TempDir(), "out.json")
+			opt := ecspresso.RenderOption{Targets: &[]string{c.target}, Output: output}
+			if err := app.Render(ctx, opt); err != nil {
+				t.Fatal(err)
+			}
+			got, err := os.ReadFile(output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// the fixture is normalized in the same way as the rendered one
+			src, err := os.ReadFile(c.expected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ecspresso.UnmarshalJSONForStruct(src, c.v, c.expected); err != nil {
+				t.Fatal(err)
+			}
+			expected, err := ecspresso.MarshalJSONForAPI(c.v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(string(expected), string(got)); diff != "" {
+				t.Errorf("unexpected rendered %s: %s", c.target, diff)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
@@ -15,8 +16,44 @@ import (
 )
 
 type RevisionsOption struct {
-	Revision string `help:"revision number or 'current' or 'latest'" default:""`
-	Output   string `help:"output format (json, table, tsv)" default:"table" enum:"json,table,tsv"`
+	Revision        string `help:"revision number or 'current' or 'latest'" default:""`
+	Output          string `help:"output format (json, table, tsv)" default:"table" enum:"json,table,tsv"`
+	Since           string `help:"show revisions registered since the time (RFC3339 or duration e.g. 24h)" default:""`
+	SinceLastDeploy bool   `help:"show revisions registered since the last deployment of the service" default:"false"`
+}
+
+// parseSince parses the time in RFC3339 or the duration before now.
+func parseSince(s string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since %s. RFC3339 or duration is required", s)
+	}
+	return t, nil
+}
+
+func (d *App) revisionsSince(ctx context.Context, opt RevisionsOption) (time.Time, error) {
+	switch {
+	case opt.Since != "" && opt.SinceLastDeploy:
+		return time.Time{}, ErrConflictOptions("since and since-last-deploy are exclusive")
+	case opt.Since != "":
+		return parseSince(opt.Since, time.Now())
+	case opt.SinceLastDeploy:
+		sv, err := d.DescribeService(ctx)
+		if err != nil {
+			return time.Time{}, err
+		}
+		dp, ok := sv.PrimaryDeployment()
+		if !ok || dp.CreatedAt == nil {
+			return time.Time{}, ErrNotFound("the last deployment is not found")
+		}
+		d.Log("[INFO] the last deployment %s was created at %s", aws.ToString(dp.Id), dp.CreatedAt.In(time.Local).Format(time.RFC3339))
+		return *dp.CreatedAt, nil
+	default:
+		return time.Time{}, nil
+	}
 }
 
 type revision struct {
@@ -82,44 +119,82 @@ func (d *App) Revisions(ctx context.Context, opt RevisionsOption) error {
 		return d.dumpRevision(ctx, aws.ToString(td.Family), opt.Revision)
 	}
 
+	since, err := d.revisionsSince(ctx, opt)
+	if err != nil {
+		return err
+	}
+
 	inUse, err := d.inUseRevisions(ctx)
 	if err != nil {
 		return err
 	}
 
 	revs := revisions{}
+	if since.IsZero() {
+		err = d.listRevisions(ctx, aws.ToString(td.Family), types.SortOrderAsc, func(name string, _ string) (bool, error) {
+			revs = append(revs, revision{
+				Name:  name,
+				InUse: inUse[name],
+			})
+			return true, nil
+		})
+	} else {
+		// revisions are listed in descending order until registered before since
+		err = d.listRevisions(ctx, aws.ToString(td.Family), types.SortOrderDesc, func(name string, tdArn string) (bool, error) {
+			out, err := d.ecs.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+				TaskDefinition: aws.String(tdArn),
+			})
+			if err != nil {
+				return false, fmt.Errorf("failed to describe task definition %s: %w", name, err)
+			}
+			if at := out.TaskDefinition.RegisteredAt; at == nil || at.Before(since) {
+				return false, nil
+			}
+			revs = append(revisions{{Name: name, InUse: inUse[name]}}, revs...)
+			return true, nil
+		})
+	}
+	if err != nil {
+		return err
+	}
+	switch opt.Output {
+	case "json":
+		revs.OutputJSON(os.Stdout)
+	case "table":
+		revs.OutputTable(os.Stdout)
+	case "tsv":
+		revs.OutputTSV(os.Stdout)
+	}
+	return nil
+}
+
+// listRevisions calls fn for each revision of the family in the order until fn returns false.
+func (d *App) listRevisions(ctx context.Context, family string, order types.SortOrder, fn func(name string, tdArn string) (bool, error)) error {
 	var nextToken *string
 	for {
 		res, err := d.ecs.ListTaskDefinitions(ctx, &ecs.ListTaskDefinitionsInput{
-			FamilyPrefix: td.Family,
+			FamilyPrefix: aws.String(family),
 			NextToken:    nextToken,
+			Sort:         order,
 		})
 		if err != nil {
-			return fmt.Errorf("failed to list task definitions family %s: %w", aws.ToString(td.Family), err)
+			return fmt.Errorf("failed to list task definitions family %s: %w", family, err)
 		}
 		for _, a := range res.TaskDefinitionArns {
 			name, err := taskDefinitionToName(a)
 			if err != nil {
 				continue
 			}
-			revs = append(revs, revision{
-				Name:  name,
-				InUse: inUse[name],
-			})
+			if next, err := fn(name, a); err != nil {
+				return err
+			} else if !next {
+				return nil
+			}
 		}
 		if nextToken = res.NextToken; nextToken == nil {
-			break
+			return nil
 		}
 	}
-	switch opt.Output {
-	case "json":
-		revs.OutputJSON(os.Stdout)
-	case "table":
-		revs.OutputTable(os.Stdout)
-	case "tsv":
-		revs.OutputTSV(os.Stdout)
-	}
-	return nil
 }
 
 func (d *App) dumpRevision(ctx context.Context, family string, rv string) error {
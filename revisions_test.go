@@ -0,0 +1,37 @@
+package ecspresso_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, tt := range []struct {
+		s        string
+		expected time.Time
+		isError  bool
+	}{
+		{s: "24h", expected: time.Date(2024, 1, 1, 3, 4, 5, 0, time.UTC)},
+		{s: "90m", expected: time.Date(2024, 1, 2, 1, 34, 5, 0, time.UTC)},
+		{s: "2023-12-31T00:00:00Z", expected: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{s: "yesterday", isError: true},
+	} {
+		got, err := ecspresso.ParseSince(tt.s, now)
+		if tt.isError {
+			if err == nil {
+				t.Errorf("%s expected error, but got nil", tt.s)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s unexpected error: %s", tt.s, err)
+			continue
+		}
+		if !got.Equal(tt.expected) {
+			t.Errorf("%s expected %s, but got %s", tt.s, tt.expected, got)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"strings"
 	"time"
 
@@ -21,6 +22,11 @@ type RollbackOption struct {
 	DeregisterTaskDefinition bool   `help:"deregister the rolled-back task definition. not works with --no-wait" default:"true" negatable:""`
 	Wait                     bool   `help:"wait for the service stable" default:"true" negatable:""`
 	RollbackEvents           string `help:"roll back when specified events happened (DEPLOYMENT_FAILURE,DEPLOYMENT_STOP_ON_ALARM,DEPLOYMENT_STOP_ON_REQUEST,...) CodeDeploy only." default:""`
+	ToRevision               int64  `help:"roll back to the specified revision of the task definition instead of the previous one"`
+	ToArn                    string `help:"roll back to the task definition of the ARN or family:revision instead of the previous one" default:""`
+	FromRecord               string `help:"roll back to the task definition recorded by deploy --record-previous in the file or SSM parameter (ssm:{name})" default:""`
+	DeregisterAfterRollback  bool   `help:"deregister the revisions older than the rolled-back-to one, except the recent and in-use ones. not works with --no-wait" default:"false"`
+	DeregisterKeeps          *int   `help:"number of the most recent revisions to keep with --deregister-after-rollback. default 10 or prune.keeps in the config"`
 }
 
 func (opt RollbackOption) DryRunString() string {
@@ -31,12 +37,15 @@ func (opt RollbackOption) DryRunString() string {
 }
 
 func (d *App) Rollback(ctx context.Context, opt RollbackOption) error {
-	ctx, cancel := d.Start(ctx)
+	ctx, cancel := d.startPhase(ctx, timeoutPhaseRollback)
 	defer cancel()
 
 	if opt.DeregisterTaskDefinition && !opt.Wait {
 		return fmt.Errorf("--deregister-task-definition not works with --no-wait together. Please use --no-deregister-task-definition with --no-wait")
 	}
+	if opt.DeregisterAfterRollback && !opt.Wait {
+		return fmt.Errorf("--deregister-after-rollback not works with --no-wait together")
+	}
 
 	d.Log("Starting rollback %s", opt.DryRunString())
 	sv, err := d.DescribeServiceStatus(ctx, 0)
@@ -49,10 +58,19 @@ func (d *App) Rollback(ctx context.Context, opt RollbackOption) error {
 	if err != nil {
 		return err
 	}
-	targetArn, err := d.FindRollbackTarget(ctx, *sv.TaskDefinition)
+	targetArn, err := d.rollbackTarget(ctx, *sv.TaskDefinition, opt)
 	if err != nil {
 		return err
 	}
+	if arnToName(targetArn) == arnToName(*sv.TaskDefinition) {
+		d.Log("The task definition %s is already deployed. Nothing to roll back", arnToName(targetArn))
+		return nil
+	}
+	if opt.DryRun {
+		if err := d.showRollbackDiff(ctx, *sv.TaskDefinition, targetArn); err != nil {
+			return err
+		}
+	}
 	doWait, err := d.WaitFunc(sv, d.confirmPrimaryTD(targetArn))
 	if err != nil {
 		return err
@@ -68,6 +86,9 @@ func (d *App) Rollback(ctx context.Context, opt RollbackOption) error {
 		if err := d.rollbackTaskDefinition(ctx, rollbackedTdArn, opt); err != nil {
 			return err
 		}
+		if err := d.deregisterAfterRollback(ctx, targetArn, opt); err != nil {
+			return err
+		}
 		d.Log("DRY RUN OK")
 		return nil
 	}
@@ -79,16 +100,40 @@ func (d *App) Rollback(ctx context.Context, opt RollbackOption) error {
 
 	time.Sleep(delayForServiceChanged) // wait for service updated
 	if err := doWait(ctx, sv); err != nil {
-		if errors.As(err, &errNotFound) {
-			d.Log("[INFO] %s", err)
-			return d.rollbackTaskDefinition(ctx, rollbackedTdArn, opt)
+		if !errors.As(err, &errNotFound) {
+			return err
 		}
-		return err
+		d.Log("[INFO] %s", err)
+	} else {
+		d.Log("Service is stable now. Completed!")
 	}
 
-	d.Log("Service is stable now. Completed!")
+	if err := d.rollbackTaskDefinition(ctx, rollbackedTdArn, opt); err != nil {
+		return err
+	}
+	return d.deregisterAfterRollback(ctx, targetArn, opt)
+}
 
-	return d.rollbackTaskDefinition(ctx, rollbackedTdArn, opt)
+// deregisterAfterRollback deregisters the revisions older than the rolled-back-to one by --deregister-after-rollback.
+func (d *App) deregisterAfterRollback(ctx context.Context, targetArn string, opt RollbackOption) error {
+	if !opt.DeregisterAfterRollback {
+		return nil
+	}
+	keeps, err := d.pruneKeeps(opt.DeregisterKeeps)
+	if err != nil {
+		return err
+	}
+	inUse, err := d.inUseRevisions(ctx)
+	if err != nil {
+		return err
+	}
+	family, base := taskDefinitionRevision(targetArn)
+	return d.deregisterOldRevisions(ctx, family, DeregisterOption{
+		DryRun:    opt.DryRun,
+		Keeps:     &keeps,
+		OlderThan: base,
+		Force:     true,
+	}, inUse)
 }
 
 func (d *App) rollbackTaskDefinition(ctx context.Context, rollbackedTdArn string, opt RollbackOption) error {
@@ -169,6 +214,15 @@ func (d *App) RollbackByCodeDeploy(ctx context.Context, sv *Service, targetArn s
 		currentTdArn := *sv.TaskDefinition
 		d.Log("the deployment in progress is not found, creating a new deployment with %s %s", targetArn, opt.DryRunString())
 		if opt.DryRun {
+			spec, err := appspec.NewWithService(&sv.Service, targetArn)
+			if err != nil {
+				return "", fmt.Errorf("failed to create appspec: %w", err)
+			}
+			if d.config.AppSpec != nil {
+				spec.Hooks = d.config.AppSpec.Hooks
+			}
+			d.Log("[INFO] appspec of the deployment:")
+			fmt.Print(spec.String())
 			return currentTdArn, nil
 		}
 		if err := d.createDeployment(ctx, sv, targetArn, opt.RollbackEvents); err != nil {
@@ -197,10 +251,95 @@ func (d *App) RollbackByCodeDeploy(ctx context.Context, sv *Service, targetArn s
 	}
 }
 
+func (opt RollbackOption) validateTarget() error {
+	n := 0
+	for _, specified := range []bool{opt.ToRevision > 0, opt.ToArn != "", opt.FromRecord != ""} {
+		if specified {
+			n++
+		}
+	}
+	if n > 1 {
+		return ErrConflictOptions("to-revision, to-arn and from-record are exclusive")
+	}
+	return nil
+}
+
+func (d *App) rollbackTarget(ctx context.Context, currentArn string, opt RollbackOption) (string, error) {
+	if err := opt.validateTarget(); err != nil {
+		return "", err
+	}
+	switch {
+	case opt.FromRecord != "":
+		rec, err := d.readPreviousRecord(ctx, opt.FromRecord)
+		if err != nil {
+			return "", err
+		}
+		d.Log("[INFO] the task definition %s was recorded at %s", arnToName(rec.TaskDefinition), rec.RecordedAt.Local().Format(time.RFC3339))
+		if arnToName(rec.TaskDefinition) == arnToName(currentArn) {
+			return "", fmt.Errorf("the recorded task definition %s is already active", arnToName(rec.TaskDefinition))
+		}
+		return rec.TaskDefinition, nil
+	case opt.ToRevision > 0:
+		return d.validateRollbackTarget(ctx, currentArn, fmt.Sprintf("%s:%d", familyOfTaskDefinition(currentArn), opt.ToRevision))
+	case opt.ToArn != "":
+		return d.validateRollbackTarget(ctx, currentArn, opt.ToArn)
+	}
+	return d.FindRollbackTarget(ctx, currentArn)
+}
+
+// validateRollbackTarget returns the ARN of the task definition specified by --to-revision or --to-arn.
+// The task definition must be ACTIVE and of the same family as the current one.
+func (d *App) validateRollbackTarget(ctx context.Context, currentArn, target string) (string, error) {
+	family := familyOfTaskDefinition(currentArn)
+	if f := familyOfTaskDefinition(target); f != family {
+		return "", fmt.Errorf("rollback target %s is not of the family %s", arnToName(target), family)
+	}
+	out, err := d.ecs.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(target),
+	})
+	if err != nil {
+		var ce *types.ClientException
+		if errors.As(err, &ce) {
+			return "", ErrNotFound(fmt.Sprintf("rollback target %s is not found: %s", arnToName(target), aws.ToString(ce.Message)))
+		}
+		return "", fmt.Errorf("failed to describe task definition %s: %w", arnToName(target), err)
+	}
+	td := out.TaskDefinition
+	if td.Status != types.TaskDefinitionStatusActive {
+		return "", fmt.Errorf("rollback target %s is %s", arnToName(target), td.Status)
+	}
+	return aws.ToString(td.TaskDefinitionArn), nil
+}
+
+func familyOfTaskDefinition(tdArn string) string {
+	return strings.Split(arnToName(tdArn), ":")[0]
+}
+
+// showRollbackDiff shows the rollback target and the diff from the current task definition.
+func (d *App) showRollbackDiff(ctx context.Context, currentArn, targetArn string) error {
+	d.Log("rollback target: %s", arnToName(targetArn))
+	current, err := d.DescribeTaskDefinition(ctx, currentArn)
+	if err != nil {
+		return err
+	}
+	target, err := d.DescribeTaskDefinition(ctx, targetArn)
+	if err != nil {
+		return err
+	}
+	differ, err := diffTaskDefs(ctx, target, current, arnToName(targetArn), currentArn, &DiffOption{Unified: true, w: os.Stdout})
+	if err != nil {
+		return err
+	}
+	if !differ {
+		d.Log("no differences between %s and %s", arnToName(currentArn), arnToName(targetArn))
+	}
+	return nil
+}
+
 func (d *App) FindRollbackTarget(ctx context.Context, taskDefinitionArn string) (string, error) {
 	var found bool
 	var nextToken *string
-	family := strings.Split(arnToName(taskDefinitionArn), ":")[0]
+	family := familyOfTaskDefinition(taskDefinitionArn)
 	for {
 		out, err := d.ecs.ListTaskDefinitions(ctx,
 			&ecs.ListTaskDefinitionsInput{
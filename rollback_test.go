@@ -0,0 +1,137 @@
+package ecspresso_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+const rollbackTestTdArn = "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:%d"
+
+type rollbackServer struct {
+	mu      sync.Mutex
+	updated []string // task definitions of UpdateService
+}
+
+func newRollbackApp(t *testing.T) (*ecspresso.App, *rollbackServer) {
+	t.Helper()
+	s := &rollbackServer{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.SplitN(r.Header.Get("X-Amz-Target"), ".", 2)[1]
+		var in struct {
+			TaskDefinition string `json:"taskDefinition"`
+		}
+		b, _ := io.ReadAll(r.Body)
+		json.Unmarshal(b, &in)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch op {
+		case "DescribeServices":
+			fmt.Fprintf(w, `{"services":[{"serviceName":"test","clusterArn":"arn:aws:ecs:ap-northeast-1:123456789012:cluster/default2","status":"ACTIVE","desiredCount":1,"deploymentController":{"type":"ECS"},"taskDefinition":"`+rollbackTestTdArn+`"}],"failures":[]}`, 3)
+		case "DescribeTaskDefinition":
+			// revisions 1 to 3 exist, and 2 was deregistered
+			var rev int
+			if _, err := fmt.Sscanf(in.TaskDefinition[strings.LastIndex(in.TaskDefinition, "/")+1:], "test:%d", &rev); err != nil || rev < 1 || rev > 3 {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ClientException","message":"Unable to describe task definition."}`))
+				return
+			}
+			status := "ACTIVE"
+			if rev == 2 {
+				status = "INACTIVE"
+			}
+			fmt.Fprintf(w, `{"taskDefinition":{"taskDefinitionArn":"`+rollbackTestTdArn+`","family":"test","revision":%d,"status":"%s"}}`, rev, rev, status)
+		case "UpdateService":
+			s.mu.Lock()
+			s.updated = append(s.updated, in.TaskDefinition)
+			s.mu.Unlock()
+			w.Write([]byte(`{"service":{"serviceName":"test","status":"ACTIVE"}}`))
+		case "DescribeScalableTargets":
+			w.Write([]byte(`{"scalableTargets":[]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+	t.Setenv("AWS_ENDPOINT_URL_APPLICATION_AUTO_SCALING", ts.URL)
+
+	app, err := ecspresso.New(context.Background(), &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, s
+}
+
+func TestRollbackToTarget(t *testing.T) {
+	cases := []struct {
+		name    string
+		opt     ecspresso.RollbackOption
+		updated []string
+		isErr   bool
+	}{
+		{
+			name:    "to-revision N-2",
+			opt:     ecspresso.RollbackOption{ToRevision: 1},
+			updated: []string{fmt.Sprintf(rollbackTestTdArn, 1)},
+		},
+		{
+			name:    "to-arn N-2",
+			opt:     ecspresso.RollbackOption{ToArn: fmt.Sprintf(rollbackTestTdArn, 1)},
+			updated: []string{fmt.Sprintf(rollbackTestTdArn, 1)},
+		},
+		{
+			name: "to-revision of the current is no-op",
+			opt:  ecspresso.RollbackOption{ToRevision: 3},
+		},
+		{
+			name:  "nonexistent revision",
+			opt:   ecspresso.RollbackOption{ToRevision: 9},
+			isErr: true,
+		},
+		{
+			name:  "inactive revision",
+			opt:   ecspresso.RollbackOption{ToRevision: 2},
+			isErr: true,
+		},
+		{
+			name:  "another family",
+			opt:   ecspresso.RollbackOption{ToArn: "other:1"},
+			isErr: true,
+		},
+		{
+			name:  "exclusive",
+			opt:   ecspresso.RollbackOption{ToRevision: 1, ToArn: "test:1"},
+			isErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			app, s := newRollbackApp(t)
+			c.opt.Wait = false
+			c.opt.DeregisterTaskDefinition = false
+			err := app.Rollback(context.Background(), c.opt)
+			if c.isErr {
+				if err == nil {
+					t.Error("expected an error")
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if strings.Join(s.updated, ",") != strings.Join(c.updated, ",") {
+				t.Errorf("unexpected updates of the service: got %v, want %v", s.updated, c.updated)
+			}
+		})
+	}
+}
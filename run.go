@@ -3,18 +3,23 @@ package ecspresso
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/samber/lo"
 )
 
 type RunOption struct {
 	DryRun                 bool    `help:"dry run" default:"false"`
 	TaskDefinition         string  `name:"task-def" help:"task definition file for run task" default:""`
+	TaskDefinitionArn      string  `name:"task-definition-arn" help:"ARN or family:revision of the registered task definition to run without registering a new one" default:""`
 	Wait                   bool    `help:"wait for task to complete" default:"true" negatable:""`
 	TaskOverrideStr        string  `name:"overrides" help:"task override JSON string" default:""`
 	TaskOverrideFile       string  `name:"overrides-file" help:"task override JSON file path" default:""`
@@ -28,6 +33,53 @@ type RunOption struct {
 	Revision               *int64  `help:"revision of the task definition to run when --skip-task-definition" default:"0"`
 	ClientToken            *string `help:"unique token that identifies a request, useful for idempotency"`
 	EBSDeleteOnTermination *bool   `help:"whether to delete the EBS volume when the task is stopped" default:"true" negatable:""`
+	Cpu                    string  `help:"override the task cpu (e.g. 1024 or \"1 vCPU\")" default:""`
+	Memory                 string  `help:"override the task memory (e.g. 2048 or \"2 GB\")" default:""`
+	LogsOnFailure          bool    `help:"show logs of all containers with awslogs when the task failed" default:"false"`
+	PollLogs               bool    `help:"stream logs of all containers with awslogs and the status of the task while waiting for the task" default:"false"`
+	WaitForHealthy         bool    `help:"wait until the health status of the task becomes HEALTHY. requires --wait-until=running" default:"false"`
+	StopOnTimeout          bool    `help:"stop the task when waiting for the task timed out" default:"false"`
+	AvailabilityZone       string  `help:"run the task in the subnets of the availability zone (name or ID) in the service definition" default:""`
+	Group                  string  `help:"task group of the task (default: family:{family of the task definition})" default:""`
+	ReferenceID            string  `name:"reference-id" help:"reference ID of the task" default:""`
+	EnableECSManagedTags   *bool   `name:"enable-ecs-managed-tags" help:"add the ECS managed tags to the task (default: enableECSManagedTags in the service definition)" negatable:""`
+	Exec                   bool    `help:"open an ECS Exec session into the task after it starts running. enableExecuteCommand is enabled for the task" default:"false"`
+	ExecCommand            string  `help:"command to execute by --exec (default: sh)" default:""`
+	ExecContainer          string  `help:"container name to exec into. required when the task definition has multiple containers" default:""`
+	StopAfterExec          bool    `help:"stop the task when the session of --exec ended" default:"false"`
+}
+
+var (
+	taskGroupRegexp   = regexp.MustCompile(`^[a-zA-Z0-9_\-:./]{1,255}$`)
+	referenceIDRegexp = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`) // RE2 does not allow a repeat count over 1000
+)
+
+func (opt RunOption) validatePropagateTags() error {
+	switch opt.PropagateTags {
+	case "", "NONE", "SERVICE", "TASK_DEFINITION":
+		return nil
+	}
+	return fmt.Errorf("invalid propagate-tags %q: SERVICE, TASK_DEFINITION or NONE is allowed", opt.PropagateTags)
+}
+
+func (opt RunOption) validateGroupAndReferenceID() error {
+	if opt.Group != "" && !taskGroupRegexp.MatchString(opt.Group) {
+		return fmt.Errorf("invalid group %q: up to 255 letters, numbers, hyphens, underscores, colons, periods and slashes are allowed", opt.Group)
+	}
+	if opt.ReferenceID != "" && (len(opt.ReferenceID) > 1024 || !referenceIDRegexp.MatchString(opt.ReferenceID)) {
+		return fmt.Errorf("invalid reference-id %q: up to 1024 letters, numbers, hyphens and underscores are allowed", opt.ReferenceID)
+	}
+	return nil
+}
+
+// taskGroup returns the task group for the task definition.
+// The default group is the same as ECS uses when the group is not specified.
+func (opt RunOption) taskGroup(tdArn string) string {
+	if opt.Group != "" {
+		return opt.Group
+	}
+	family := strings.SplitN(arnToName(tdArn), ":", 2)[0]
+	return "family:" + family
 }
 
 func (opt RunOption) waitUntilRunning() bool {
@@ -42,9 +94,30 @@ func (opt RunOption) DryRunString() string {
 }
 
 func (d *App) Run(ctx context.Context, opt RunOption) error {
-	ctx, cancel := d.Start(ctx)
+	// the session of --exec is not limited by the timeout
+	execCtx := ctx
+	ctx, cancel := d.startPhase(ctx, timeoutPhaseRun)
 	defer cancel()
 
+	if opt.WaitForHealthy && !opt.waitUntilRunning() {
+		return errors.New("--wait-for-healthy requires --wait-until=running")
+	}
+	if opt.StopOnTimeout && !opt.Wait {
+		return ErrConflictOptions("--stop-on-timeout requires --wait")
+	}
+	if err := opt.validateGroupAndReferenceID(); err != nil {
+		return err
+	}
+	if err := opt.validatePropagateTags(); err != nil {
+		return err
+	}
+	if err := opt.validateExec(); err != nil {
+		return err
+	}
+	if err := opt.validatePollLogs(); err != nil {
+		return err
+	}
+
 	d.Log("Running task %s", opt.DryRunString())
 	ov := types.TaskOverride{}
 	if opt.TaskOverrideStr != "" {
@@ -60,6 +133,16 @@ func (d *App) Run(ctx context.Context, opt RunOption) error {
 			return fmt.Errorf("failed to read overrides-file %s: %w", ovFile, err)
 		}
 	}
+	if opt.Cpu != "" {
+		if ov.Cpu = toNumberCPU(opt.Cpu); ov.Cpu == nil {
+			return fmt.Errorf("invalid cpu: %s", opt.Cpu)
+		}
+	}
+	if opt.Memory != "" {
+		if ov.Memory = toNumberMemory(opt.Memory); ov.Memory == nil {
+			return fmt.Errorf("invalid memory: %s", opt.Memory)
+		}
+	}
 	d.Log("[DEBUG] Overrides")
 	d.LogJSON(ov)
 
@@ -76,8 +159,17 @@ func (d *App) Run(ctx context.Context, opt RunOption) error {
 	if err != nil {
 		return err
 	}
+	if err := d.validateTaskSizeOverride(td, &ov); err != nil {
+		return err
+	}
 	watchContainer := containerOf(td, &opt.WatchContainer)
 	d.Log("Watch container: %s", *watchContainer.Name)
+	var execContainer string
+	if opt.Exec {
+		if execContainer, err = execContainerName(td, opt.ExecContainer); err != nil {
+			return err
+		}
+	}
 
 	task, err := d.RunTask(ctx, tdArn, &ov, &opt)
 	if err != nil {
@@ -87,10 +179,52 @@ func (d *App) Run(ctx context.Context, opt RunOption) error {
 		d.Log("Run task invoked")
 		return nil
 	}
-	if err := d.WaitRunTask(ctx, task, watchContainer, time.Now(), opt.waitUntilRunning()); err != nil {
+	startedAt := time.Now()
+	if opt.PollLogs {
+		stopPolling := d.startPollingTaskLogs(ctx, task, td, startedAt)
+		err = d.waitTask(ctx, task, opt.waitUntilRunning())
+		stopPolling()
+	} else {
+		err = d.WaitRunTask(ctx, task, watchContainer, startedAt, opt.waitUntilRunning() || opt.Exec)
+	}
+	if err != nil {
+		if opt.StopOnTimeout && isWaitTimeout(ctx, err) {
+			d.stopTimedOutTask(task)
+		}
+		// with --poll-logs, the logs are shown already
+		if opt.LogsOnFailure && !opt.PollLogs {
+			d.Log("[WARNING] failed to wait for the task: %s", err)
+			// ctx for the run may be already done
+			lctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			d.showTaskLogs(lctx, task, td, startedAt)
+			cancel()
+		}
 		return err
 	}
+	if opt.Exec {
+		if err := d.waitExecuteCommandAgent(ctx, task, execContainer); err != nil {
+			if opt.StopOnTimeout && isWaitTimeout(ctx, err) {
+				d.stopTimedOutTask(task)
+			}
+			return err
+		}
+		return d.execRunTask(execCtx, task, execContainer, opt)
+	}
+	if opt.WaitForHealthy {
+		if err := d.waitTaskHealthy(ctx, task, td); err != nil {
+			if opt.StopOnTimeout && isWaitTimeout(ctx, err) {
+				d.stopTimedOutTask(task)
+			}
+			if opt.LogsOnFailure {
+				d.showTaskLogs(ctx, task, td, startedAt)
+			}
+			return err
+		}
+	}
 	if err := d.DescribeTaskStatus(ctx, task, watchContainer); err != nil {
+		if opt.LogsOnFailure {
+			d.showTaskLogs(ctx, task, td, startedAt)
+		}
 		return err
 	}
 	d.Log("Run task completed!")
@@ -110,6 +244,7 @@ func (d *App) RunTask(ctx context.Context, tdArn string, ov *types.TaskOverride,
 	if err != nil {
 		return nil, fmt.Errorf("failed to run task. invalid tags: %w", err)
 	}
+	tags = d.config.Tags.mergeTags(tags)
 
 	in := &ecs.RunTaskInput{
 		Cluster:                  aws.String(d.Cluster),
@@ -124,13 +259,25 @@ func (d *App) RunTask(ctx context.Context, tdArn string, ov *types.TaskOverride,
 		PlatformVersion:          sv.PlatformVersion,
 		Tags:                     tags,
 		EnableECSManagedTags:     sv.EnableECSManagedTags,
-		EnableExecuteCommand:     sv.EnableExecuteCommand,
+		EnableExecuteCommand:     sv.EnableExecuteCommand || opt.Exec,
 		ClientToken:              opt.ClientToken,
+		Group:                    aws.String(opt.taskGroup(tdArn)),
 		VolumeConfigurations: serviceVolumeConfigurationsToTask(
 			sv.VolumeConfigurations,
 			opt.EBSDeleteOnTermination,
 		),
 	}
+	if opt.ReferenceID != "" {
+		in.ReferenceId = aws.String(opt.ReferenceID)
+	}
+	if opt.EnableECSManagedTags != nil {
+		in.EnableECSManagedTags = *opt.EnableECSManagedTags
+	}
+	if az := opt.AvailabilityZone; az != "" {
+		if in.NetworkConfiguration, err = d.networkConfigurationInAvailabilityZone(ctx, sv.NetworkConfiguration, az); err != nil {
+			return nil, fmt.Errorf("failed to run task: %w", err)
+		}
+	}
 
 	switch opt.PropagateTags {
 	case "SERVICE":
@@ -142,7 +289,13 @@ func (d *App) RunTask(ctx context.Context, tdArn string, ov *types.TaskOverride,
 		}
 		d.Log("[DEBUG] propagate tags from service %s", *sv.ServiceArn)
 		d.LogJSON(out)
-		in.Tags = append(in.Tags, out.Tags...)
+		for _, tag := range out.Tags {
+			// --tags and the global tags (also applied to the service) take precedence
+			if _, found := lo.Find(in.Tags, func(t types.Tag) bool { return aws.ToString(t.Key) == aws.ToString(tag.Key) }); found {
+				continue
+			}
+			in.Tags = append(in.Tags, tag)
+		}
 	case "", "NONE":
 		// XXX ECS says > InvalidParameterException: Invalid value for propagateTags
 		// in.PropagateTags = types.PropagateTagsNone
@@ -217,7 +370,13 @@ func (d *App) waitTask(ctx context.Context, task *types.Task, untilRunning bool)
 		waiter := ecs.NewTasksRunningWaiter(d.ecs, func(o *ecs.TasksRunningWaiterOptions) {
 			o.MaxDelay = waiterMaxDelay
 		})
-		if err := waiter.Wait(ctx, d.DescribeTasksInput(task), d.Timeout()); err != nil {
+		if err := waiter.Wait(ctx, d.DescribeTasksInput(task), d.config.waiterTimeoutFor(timeoutPhaseRun)); err != nil {
+			// the waiter does not tell why the task is stopped
+			if out, derr := d.ecs.DescribeTasks(ctx, d.DescribeTasksInput(task)); derr == nil && len(out.Tasks) > 0 {
+				if serr := stoppedBeforeRunning(&out.Tasks[0]); serr != nil {
+					return serr
+				}
+			}
 			return err
 		}
 		d.Log("Task ID %s is running", id)
@@ -228,14 +387,128 @@ func (d *App) waitTask(ctx context.Context, task *types.Task, untilRunning bool)
 	waiter := ecs.NewTasksStoppedWaiter(d.ecs, func(o *ecs.TasksStoppedWaiterOptions) {
 		o.MaxDelay = waiterMaxDelay
 	})
-	if err := waiter.Wait(ctx, d.DescribeTasksInput(task), d.Timeout()); err != nil {
+	if err := waiter.Wait(ctx, d.DescribeTasksInput(task), d.config.waiterTimeoutFor(timeoutPhaseRun)); err != nil {
 		return fmt.Errorf("failed to wait task: %w", err)
 	}
 	return nil
 }
 
+// stoppedBeforeRunning returns the error with the stopped reason when the task is stopped while waiting until running.
+func stoppedBeforeRunning(task *types.Task) error {
+	if aws.ToString(task.LastStatus) != "STOPPED" {
+		return nil
+	}
+	id := arnToName(aws.ToString(task.TaskArn))
+	reason := aws.ToString(task.StoppedReason)
+	var ce *ErrContainerExit
+	if err := taskStoppedStatus(task, ""); errors.As(err, &ce) {
+		return fmt.Errorf("task ID %s is stopped before running: %s: %w", id, reason, err)
+	}
+	return fmt.Errorf("task ID %s is stopped before running: %s", id, reason)
+}
+
+// isWaitTimeout reports whether the error is caused by the timeout of waiting.
+// Waiters of the SDK return an error without a type when the max wait time is exceeded.
+func isWaitTimeout(ctx context.Context, err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return true
+	}
+	return strings.Contains(err.Error(), "exceeded max wait time")
+}
+
+func (d *App) stopTimedOutTask(task *types.Task) {
+	d.Log("Stopping the task because waiting for the task timed out")
+	d.stopTask(task, d.runTimeoutReason())
+}
+
+// runTimeoutReason returns the reason to stop the task, which tells the timeout of the run phase expired.
+func (d *App) runTimeoutReason() string {
+	return fmt.Sprintf("ecspresso run timed out after %s (%s)",
+		d.config.timeoutFor(timeoutPhaseRun), d.config.timeoutNameFor(timeoutPhaseRun))
+}
+
+func (d *App) stopTask(task *types.Task, reason string) {
+	// ctx for the run may be already done
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	id := arnToName(aws.ToString(task.TaskArn))
+	d.Log("Stopping task ID %s", id)
+	if _, err := d.ecs.StopTask(ctx, &ecs.StopTaskInput{
+		Cluster: aws.String(d.Cluster),
+		Task:    task.TaskArn,
+		Reason:  aws.String(reason),
+	}); err != nil {
+		d.Log("[WARNING] failed to stop task ID %s: %s", id, err)
+		return
+	}
+	d.Log("Task ID %s is stopping", id)
+}
+
+var taskHealthCheckInterval = 5 * time.Second
+
+// waitTaskHealthy waits until the health status of the task becomes HEALTHY.
+func (d *App) waitTaskHealthy(ctx context.Context, task *types.Task, td *TaskDefinitionInput) error {
+	if !lo.ContainsBy(td.ContainerDefinitions, func(c types.ContainerDefinition) bool {
+		return c.HealthCheck != nil
+	}) {
+		d.Log("[WARNING] no containers have healthCheck. skip waiting for the task to be healthy")
+		return nil
+	}
+	id := arnToName(aws.ToString(task.TaskArn))
+	d.Log("Waiting for task ID %s until healthy", id)
+	for {
+		out, err := d.ecs.DescribeTasks(ctx, d.DescribeTasksInput(task))
+		if err != nil {
+			return fmt.Errorf("failed to describe tasks: %w", err)
+		}
+		if len(out.Tasks) == 0 {
+			return ErrNotFound(fmt.Sprintf("task ID %s is not found", id))
+		}
+		healthy, err := taskHealth(&out.Tasks[0])
+		if err != nil {
+			return fmt.Errorf("task ID %s is not healthy: %w", id, err)
+		}
+		if healthy {
+			d.Log("Task ID %s is healthy", id)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("task ID %s is not healthy: %w", id, ctx.Err())
+		case <-time.After(taskHealthCheckInterval):
+		}
+	}
+}
+
+// taskHealth reports whether the task is healthy.
+// It returns an error when the task is unhealthy or stopped.
+func taskHealth(task *types.Task) (bool, error) {
+	if aws.ToString(task.LastStatus) == "STOPPED" {
+		return false, fmt.Errorf("task is stopped: %s", aws.ToString(task.StoppedReason))
+	}
+	switch task.HealthStatus {
+	case types.HealthStatusHealthy:
+		return true, nil
+	case types.HealthStatusUnhealthy:
+		var names []string
+		for _, c := range task.Containers {
+			if c.HealthStatus == types.HealthStatusUnhealthy {
+				names = append(names, aws.ToString(c.Name))
+			}
+		}
+		return false, fmt.Errorf("container %s is UNHEALTHY", strings.Join(names, ", "))
+	default:
+		return false, nil
+	}
+}
+
 func (d *App) taskDefinitionArnForRun(ctx context.Context, opt RunOption) (string, error) {
 	switch {
+	case opt.TaskDefinitionArn != "":
+		if opt.TaskDefinition != "" || opt.LatestTaskDefinition || *opt.Revision > 0 {
+			return "", ErrConflictOptions("task-definition-arn is exclusive with task-def, latest-task-definition and revision")
+		}
+		return d.registeredTaskDefinitionArn(ctx, opt.TaskDefinitionArn)
 	case *opt.Revision > 0:
 		if opt.LatestTaskDefinition {
 			return "", ErrConflictOptions("revision and latest-task-definition are exclusive")
@@ -294,6 +567,28 @@ func (d *App) taskDefinitionArnForRun(ctx context.Context, opt RunOption) (strin
 	}
 }
 
+// registeredTaskDefinitionArn returns the full ARN of the registered task definition.
+// The task definition must be ACTIVE.
+func (d *App) registeredTaskDefinitionArn(ctx context.Context, name string) (string, error) {
+	out, err := d.ecs.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+		TaskDefinition: aws.String(name),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe task definition %s: %w", name, err)
+	}
+	if err := requireActiveTaskDefinition(out.TaskDefinition); err != nil {
+		return "", err
+	}
+	return aws.ToString(out.TaskDefinition.TaskDefinitionArn), nil
+}
+
+func requireActiveTaskDefinition(td *types.TaskDefinition) error {
+	if td.Status != types.TaskDefinitionStatusActive {
+		return fmt.Errorf("task definition %s is %s. only ACTIVE task definitions can be run", arnToName(aws.ToString(td.TaskDefinitionArn)), td.Status)
+	}
+	return nil
+}
+
 func (d *App) resolveTaskdefinition(ctx context.Context) (family string, revision string, err error) {
 	if d.config.Service != "" {
 		d.Log("[DEBUG] loading service")
@@ -317,3 +612,110 @@ func (d *App) resolveTaskdefinition(ctx context.Context) (family string, revisio
 		return family, "", nil
 	}
 }
+
+// validateTaskSizeOverride validates the cpu and memory overrides for Fargate.
+// The values in the task definition are used for omitted overrides.
+func (d *App) validateTaskSizeOverride(td *TaskDefinitionInput, ov *types.TaskOverride) error {
+	if ov.Cpu == nil && ov.Memory == nil {
+		return nil
+	}
+	isFargateTask := len(td.RequiresCompatibilities) == 1 && td.RequiresCompatibilities[0] == types.CompatibilityFargate
+	isFargateService, err := d.isFargateService()
+	if err != nil {
+		return err
+	}
+	if !isFargateTask && !isFargateService {
+		return nil
+	}
+	cpu, memory := td.Cpu, td.Memory
+	if ov.Cpu != nil {
+		cpu = ov.Cpu
+	}
+	if ov.Memory != nil {
+		memory = ov.Memory
+	}
+	if cpu == nil || memory == nil {
+		return nil
+	}
+	return validateFargateTaskSize(aws.ToString(toNumberCPU(*cpu)), aws.ToString(toNumberMemory(*memory)))
+}
+
+func memoryInRange(min, max, step int) func(int) bool {
+	return func(m int) bool {
+		return min <= m && m <= max && (m-min)%step == 0
+	}
+}
+
+// https://docs.aws.amazon.com/AmazonECS/latest/developerguide/fargate-tasks-services.html#fargate-tasks-size
+var fargateTaskSizes = map[int]func(memory int) bool{
+	256:   func(m int) bool { return m == 512 || m == 1024 || m == 2048 },
+	512:   memoryInRange(1024, 4096, 1024),
+	1024:  memoryInRange(2048, 8192, 1024),
+	2048:  memoryInRange(4096, 16384, 1024),
+	4096:  memoryInRange(8192, 30720, 1024),
+	8192:  memoryInRange(16384, 61440, 4096),
+	16384: memoryInRange(32768, 122880, 8192),
+}
+
+func validateFargateTaskSize(cpu, memory string) error {
+	c, err := strconv.Atoi(cpu)
+	if err != nil {
+		return fmt.Errorf("invalid cpu: %s", cpu)
+	}
+	m, err := strconv.Atoi(memory)
+	if err != nil {
+		return fmt.Errorf("invalid memory: %s", memory)
+	}
+	valid, ok := fargateTaskSizes[c]
+	if !ok {
+		return fmt.Errorf("cpu %d is not supported on Fargate", c)
+	}
+	if !valid(m) {
+		return fmt.Errorf("cpu %d and memory %d is not a valid combination on Fargate", c, m)
+	}
+	return nil
+}
+
+// awslogsContainers returns the containers which send logs by the awslogs driver with a stream prefix.
+func awslogsContainers(td *TaskDefinitionInput) []types.ContainerDefinition {
+	var cs []types.ContainerDefinition
+	for _, c := range td.ContainerDefinitions {
+		lc := c.LogConfiguration
+		if lc == nil || lc.LogDriver != types.LogDriverAwslogs || lc.Options["awslogs-stream-prefix"] == "" {
+			continue
+		}
+		cs = append(cs, c)
+	}
+	return cs
+}
+
+// showTaskLogs shows logs of each container in the task prefixed with [container name].
+func (d *App) showTaskLogs(ctx context.Context, task *types.Task, td *TaskDefinitionInput, startedAt time.Time) {
+	for _, c := range awslogsContainers(td) {
+		c := c
+		logGroup, logStream := d.GetLogInfo(task, &c)
+		if err := d.printLogEvents(ctx, "["+aws.ToString(c.Name)+"] ", logGroup, logStream, startedAt); err != nil {
+			d.Log("[WARNING] failed to get logs of container %s: %s", aws.ToString(c.Name), err)
+		}
+	}
+}
+
+func (d *App) printLogEvents(ctx context.Context, prefix, logGroup, logStream string, startedAt time.Time) error {
+	ms := startedAt.UnixNano() / int64(time.Millisecond)
+	var nextToken *string
+	for {
+		in := d.GetLogEventsInput(logGroup, logStream, ms, nextToken)
+		in.StartFromHead = aws.Bool(true)
+		out, err := d.cwl.GetLogEvents(ctx, in)
+		if err != nil {
+			return err
+		}
+		for _, event := range out.Events {
+			fmt.Println(prefix + formatLogEvent(event))
+		}
+		if len(out.Events) == 0 || aws.ToString(out.NextForwardToken) == aws.ToString(nextToken) {
+			return nil
+		}
+		nextToken = out.NextForwardToken
+	}
+}
@@ -0,0 +1,123 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/samber/lo"
+)
+
+const executeCommandAgentName = "ExecuteCommandAgent"
+
+var executeCommandAgentCheckInterval = 3 * time.Second
+
+func (opt RunOption) validateExec() error {
+	if !opt.Exec {
+		if opt.ExecContainer != "" || opt.StopAfterExec {
+			return ErrConflictOptions("exec-container and stop-after-exec require exec")
+		}
+		return nil
+	}
+	if !opt.Wait {
+		return ErrConflictOptions("exec and no-wait are exclusive")
+	}
+	if opt.WaitForHealthy {
+		return ErrConflictOptions("exec and wait-for-healthy are exclusive")
+	}
+	if opt.Count != 1 {
+		return errors.New("--exec requires --count=1")
+	}
+	return nil
+}
+
+// execContainerName returns the name of the container to exec into.
+// The container must be specified when the task definition has multiple containers.
+func execContainerName(td *TaskDefinitionInput, name string) (string, error) {
+	if td.TaskRoleArn == nil || aws.ToString(td.TaskRoleArn) == "" {
+		return "", errors.New("ECS Exec requires taskRoleArn in the task definition, which allows ssmmessages actions")
+	}
+	names := lo.Map(td.ContainerDefinitions, func(c types.ContainerDefinition, _ int) string {
+		return aws.ToString(c.Name)
+	})
+	switch {
+	case name != "":
+		if !lo.Contains(names, name) {
+			return "", fmt.Errorf("container %s is not found in the task definition: %s", name, strings.Join(names, ", "))
+		}
+		return name, nil
+	case len(names) == 1:
+		return names[0], nil
+	default:
+		return "", fmt.Errorf("--exec-container is required because the task definition has multiple containers: %s", strings.Join(names, ", "))
+	}
+}
+
+// executeCommandAgentRunning reports whether the ExecuteCommandAgent of the container is running.
+func executeCommandAgentRunning(task *types.Task, container string) (bool, error) {
+	if aws.ToString(task.LastStatus) == "STOPPED" {
+		return false, fmt.Errorf("task is stopped: %s", aws.ToString(task.StoppedReason))
+	}
+	for _, c := range task.Containers {
+		if aws.ToString(c.Name) != container {
+			continue
+		}
+		for _, a := range c.ManagedAgents {
+			if a.Name == executeCommandAgentName {
+				return aws.ToString(a.LastStatus) == "RUNNING", nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// waitExecuteCommandAgent waits until the ExecuteCommandAgent of the container is running.
+// The agent starts after the task is running.
+func (d *App) waitExecuteCommandAgent(ctx context.Context, task *types.Task, container string) error {
+	id := arnToName(aws.ToString(task.TaskArn))
+	d.Log("Waiting for the execute command agent of container %s in task ID %s", container, id)
+	for {
+		out, err := d.ecs.DescribeTasks(ctx, d.DescribeTasksInput(task))
+		if err != nil {
+			return fmt.Errorf("failed to describe tasks: %w", err)
+		}
+		if len(out.Tasks) == 0 {
+			return ErrNotFound(fmt.Sprintf("task ID %s is not found", id))
+		}
+		running, err := executeCommandAgentRunning(&out.Tasks[0], container)
+		if err != nil {
+			return fmt.Errorf("task ID %s is not ready to exec: %w", id, err)
+		}
+		if running {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the execute command agent of task ID %s is not running: %w", id, ctx.Err())
+		case <-time.After(executeCommandAgentCheckInterval):
+		}
+	}
+}
+
+// execRunTask opens an ECS Exec session into the container of the task.
+// ctx should not be limited by the timeout, like exec command.
+func (d *App) execRunTask(ctx context.Context, task *types.Task, container string, opt RunOption) error {
+	id := arnToName(aws.ToString(task.TaskArn))
+	if opt.StopAfterExec {
+		defer d.stopTask(task, "ecspresso run --exec session ended")
+	}
+	command := opt.ExecCommand
+	if command == "" {
+		command = "sh"
+	}
+	d.Log("Executing %s in container %s of task ID %s", command, container, id)
+	return d.Exec(ctx, ExecOption{
+		ID:        id,
+		Command:   command,
+		Container: container,
+	})
+}
@@ -0,0 +1,151 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	logsTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// pollLogsInterval is the interval to poll the logs and the status of the task by --poll-logs.
+var pollLogsInterval = 5 * time.Second
+
+func (opt RunOption) validatePollLogs() error {
+	if !opt.PollLogs {
+		return nil
+	}
+	if !opt.Wait {
+		return ErrConflictOptions("poll-logs and no-wait are exclusive")
+	}
+	if opt.Exec {
+		return ErrConflictOptions("poll-logs and exec are exclusive")
+	}
+	return nil
+}
+
+// taskLogTail is the position of the log stream of a container tailed by --poll-logs.
+type taskLogTail struct {
+	container string
+	logGroup  string
+	logStream string
+	nextToken *string
+	available bool
+}
+
+// isLogStreamNotFound reports whether the error is caused by the log stream not created yet.
+// The log stream is created after the container started, so it is not an error while polling.
+func isLogStreamNotFound(err error) bool {
+	var notFound *logsTypes.ResourceNotFoundException
+	return errors.As(err, &notFound)
+}
+
+// startPollingTaskLogs starts streaming the logs of all the containers with awslogs in the task
+// and the changes of the task status, until the task stops.
+// The returned function stops the polling after printing the remaining logs.
+func (d *App) startPollingTaskLogs(ctx context.Context, task *types.Task, td *TaskDefinitionInput, startedAt time.Time) func() {
+	var tails []*taskLogTail
+	for _, c := range awslogsContainers(td) {
+		c := c
+		logGroup, logStream := d.GetLogInfo(task, &c)
+		tails = append(tails, &taskLogTail{
+			container: aws.ToString(c.Name),
+			logGroup:  logGroup,
+			logStream: logStream,
+		})
+	}
+	if len(tails) == 0 {
+		d.Log("[WARNING] no containers send logs by awslogs with awslogs-stream-prefix. only the status of the task is polled")
+	}
+
+	pollCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(pollLogsInterval)
+		defer ticker.Stop()
+		var lastStatus string
+		for {
+			status, err := d.describeTaskLastStatus(pollCtx, task)
+			if err != nil {
+				if pollCtx.Err() != nil {
+					return
+				}
+				d.Log("[WARNING] failed to describe the task: %s", err)
+			} else if status != lastStatus {
+				d.Log("Task ID %s is %s", arnToName(aws.ToString(task.TaskArn)), status)
+				lastStatus = status
+			}
+			for _, t := range tails {
+				d.pollTaskLog(pollCtx, t, startedAt)
+			}
+			if status == "STOPPED" {
+				return
+			}
+			select {
+			case <-pollCtx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+		// ctx for the run may be already done
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer flushCancel()
+		for _, t := range tails {
+			d.pollTaskLog(flushCtx, t, startedAt)
+		}
+	}
+}
+
+func (d *App) describeTaskLastStatus(ctx context.Context, task *types.Task) (string, error) {
+	out, err := d.ecs.DescribeTasks(ctx, d.DescribeTasksInput(task))
+	if err != nil {
+		return "", err
+	}
+	if len(out.Tasks) == 0 {
+		return "", ErrNotFound(fmt.Sprintf("task ID %s is not found", arnToName(aws.ToString(task.TaskArn))))
+	}
+	return aws.ToString(out.Tasks[0].LastStatus), nil
+}
+
+// pollTaskLog prints the new events of the log stream prefixed with [container name].
+// The log stream which is not created yet is retried at the next poll.
+func (d *App) pollTaskLog(ctx context.Context, t *taskLogTail, startedAt time.Time) {
+	ms := startedAt.UnixNano() / int64(time.Millisecond)
+	for {
+		in := d.GetLogEventsInput(t.logGroup, t.logStream, ms, t.nextToken)
+		in.StartFromHead = aws.Bool(true)
+		out, err := d.cwl.GetLogEvents(ctx, in)
+		if err != nil {
+			switch {
+			case ctx.Err() != nil:
+			case isLogStreamNotFound(err):
+				d.Log("[DEBUG] log stream %s is not available yet", t.logStream)
+			default:
+				d.Log("[WARNING] failed to get logs of container %s: %s", t.container, err)
+			}
+			return
+		}
+		if !t.available {
+			d.Log("[DEBUG] log stream %s is available", t.logStream)
+			t.available = true
+		}
+		for _, event := range out.Events {
+			fmt.Println("[" + t.container + "] " + formatLogEvent(event))
+		}
+		if len(out.Events) == 0 || aws.ToString(out.NextForwardToken) == aws.ToString(t.nextToken) {
+			return
+		}
+		t.nextToken = out.NextForwardToken
+	}
+}
@@ -2,10 +2,23 @@ package ecspresso_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
+	logsTypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/aws/smithy-go/middleware"
+	"github.com/google/go-cmp/cmp"
 	"github.com/kayac/ecspresso/v2"
 )
 
@@ -51,6 +64,14 @@ var testTaskDefinitionArnForRunSuite = map[string][]taskDefinitionArnForRunSuite
 			opts: []string{"--task-def=tests/run-test-td.json"},
 			td:   "family test will be registered",
 		},
+		{
+			opts: []string{"--task-definition-arn=katsubushi:42"},
+			td:   "katsubushi:42",
+		},
+		{
+			opts:     []string{"--task-definition-arn=katsubushi:42", "--latest-task-definition"},
+			raiseErr: true, // task-definition-arn and latest-task-definition are exclusive
+		},
 	},
 	"tests/run-without-sv.yaml": {
 		{
@@ -82,6 +103,14 @@ var testTaskDefinitionArnForRunSuite = map[string][]taskDefinitionArnForRunSuite
 			opts: []string{"--task-def=tests/run-test-td.json"},
 			td:   "family test will be registered",
 		},
+		{
+			opts: []string{"--task-definition-arn=arn:aws:ecs:ap-northeast-1:123456789012:task-definition/katsubushi:42"},
+			td:   "katsubushi:42",
+		},
+		{
+			opts:     []string{"--task-definition-arn=katsubushi:42", "--task-def=tests/run-test-td.json"},
+			raiseErr: true, // task-definition-arn and task-def are exclusive
+		},
 	},
 }
 
@@ -127,3 +156,481 @@ func TestTaskDefinitionArnForRun(t *testing.T) {
 		}
 	}
 }
+
+func TestRequireActiveTaskDefinition(t *testing.T) {
+	td := &types.TaskDefinition{
+		TaskDefinitionArn: aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/migrate:3"),
+		Status:            types.TaskDefinitionStatusActive,
+	}
+	if err := ecspresso.RequireActiveTaskDefinition(td); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	td.Status = types.TaskDefinitionStatusInactive
+	err := ecspresso.RequireActiveTaskDefinition(td)
+	if err == nil {
+		t.Fatal("expected error for INACTIVE task definition, but got nil")
+	}
+	if want := "task definition migrate:3 is INACTIVE"; !strings.Contains(err.Error(), want) {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestValidateFargateTaskSize(t *testing.T) {
+	for _, tt := range []struct {
+		cpu     string
+		memory  string
+		isValid bool
+	}{
+		{"256", "512", true},
+		{"256", "1536", false},
+		{"512", "4096", true},
+		{"1024", "1024", false},
+		{"4096", "30720", true},
+		{"8192", "20480", true},
+		{"8192", "18432", false},
+		{"16384", "122880", true},
+		{"128", "512", false},
+		{"foo", "512", false},
+	} {
+		err := ecspresso.ValidateFargateTaskSize(tt.cpu, tt.memory)
+		if tt.isValid && err != nil {
+			t.Errorf("cpu %s memory %s unexpected error: %s", tt.cpu, tt.memory, err)
+		}
+		if !tt.isValid && err == nil {
+			t.Errorf("cpu %s memory %s expected error, but got nil", tt.cpu, tt.memory)
+		}
+	}
+}
+
+func TestAwslogsContainers(t *testing.T) {
+	awslogs := func(prefix string) *types.LogConfiguration {
+		return &types.LogConfiguration{
+			LogDriver: types.LogDriverAwslogs,
+			Options: map[string]string{
+				"awslogs-group":         "/ecs/app",
+				"awslogs-stream-prefix": prefix,
+			},
+		}
+	}
+	td := &ecspresso.TaskDefinitionInput{
+		ContainerDefinitions: []types.ContainerDefinition{
+			{Name: aws.String("app"), LogConfiguration: awslogs("app")},
+			{Name: aws.String("no-prefix"), LogConfiguration: awslogs("")},
+			{Name: aws.String("firelens"), LogConfiguration: &types.LogConfiguration{LogDriver: types.LogDriverAwsfirelens}},
+			{Name: aws.String("no-logs")},
+			{Name: aws.String("sidecar"), LogConfiguration: awslogs("sidecar")},
+		},
+	}
+	var names []string
+	for _, c := range ecspresso.AwslogsContainers(td) {
+		names = append(names, aws.ToString(c.Name))
+	}
+	if diff := cmp.Diff([]string{"app", "sidecar"}, names); diff != "" {
+		t.Errorf("unexpected containers: %s", diff)
+	}
+}
+
+func TestTaskHealth(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		task    types.Task
+		healthy bool
+		errMsg  string
+	}{
+		{
+			name:    "healthy",
+			task:    types.Task{LastStatus: aws.String("RUNNING"), HealthStatus: types.HealthStatusHealthy},
+			healthy: true,
+		},
+		{
+			name: "unknown",
+			task: types.Task{LastStatus: aws.String("RUNNING"), HealthStatus: types.HealthStatusUnknown},
+		},
+		{
+			name: "unhealthy",
+			task: types.Task{
+				LastStatus:   aws.String("RUNNING"),
+				HealthStatus: types.HealthStatusUnhealthy,
+				Containers: []types.Container{
+					{Name: aws.String("app"), HealthStatus: types.HealthStatusUnhealthy},
+					{Name: aws.String("sidecar"), HealthStatus: types.HealthStatusHealthy},
+				},
+			},
+			errMsg: "container app is UNHEALTHY",
+		},
+		{
+			name:   "stopped",
+			task:   types.Task{LastStatus: aws.String("STOPPED"), StoppedReason: aws.String("Essential container in task exited")},
+			errMsg: "task is stopped: Essential container in task exited",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			healthy, err := ecspresso.TaskHealth(&tt.task)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Errorf("expected error %q, but got %v", tt.errMsg, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if healthy != tt.healthy {
+				t.Errorf("expected healthy %v, but got %v", tt.healthy, healthy)
+			}
+		})
+	}
+}
+
+func TestIsWaitTimeout(t *testing.T) {
+	ctx := context.Background()
+	expired, cancel := context.WithTimeout(ctx, 0)
+	defer cancel()
+	<-expired.Done()
+	for _, tt := range []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		expected bool
+	}{
+		{name: "waiter", ctx: ctx, err: fmt.Errorf("failed to wait task: %w", errors.New("exceeded max wait time for TasksStopped waiter")), expected: true},
+		{name: "deadline", ctx: ctx, err: fmt.Errorf("task is not healthy: %w", context.DeadlineExceeded), expected: true},
+		{name: "expired context", ctx: expired, err: errors.New("operation error ECS: DescribeTasks"), expected: true},
+		{name: "other", ctx: ctx, err: errors.New("task failed to start"), expected: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ecspresso.IsWaitTimeout(tt.ctx, tt.err); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRunOptionGroupAndReferenceID(t *testing.T) {
+	tdArn := "arn:aws:ecs:ap-northeast-1:123456789012:task-definition/migrate:3"
+	for _, tt := range []struct {
+		opt     ecspresso.RunOption
+		group   string
+		isValid bool
+	}{
+		{ecspresso.RunOption{}, "family:migrate", true},
+		{ecspresso.RunOption{Group: "migration"}, "migration", true},
+		{ecspresso.RunOption{Group: "service:my-app/v1.2"}, "service:my-app/v1.2", true},
+		{ecspresso.RunOption{Group: "with space"}, "", false},
+		{ecspresso.RunOption{Group: strings.Repeat("a", 256)}, "", false},
+		{ecspresso.RunOption{ReferenceID: "release_42-a"}, "family:migrate", true},
+		{ecspresso.RunOption{ReferenceID: "release:42"}, "", false},
+		{ecspresso.RunOption{ReferenceID: strings.Repeat("a", 1024)}, "family:migrate", true},
+		{ecspresso.RunOption{ReferenceID: strings.Repeat("a", 1025)}, "", false},
+	} {
+		err := tt.opt.ValidateGroupAndReferenceID()
+		if !tt.isValid {
+			if err == nil {
+				t.Errorf("%#v expected error, but got nil", tt.opt)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%#v unexpected error: %s", tt.opt, err)
+		}
+		if g := tt.opt.TaskGroup(tdArn); g != tt.group {
+			t.Errorf("expected group %s, got %s", tt.group, g)
+		}
+	}
+}
+
+func TestRunOptionPropagateTags(t *testing.T) {
+	for _, tt := range []struct {
+		value   string
+		isValid bool
+	}{
+		{"", true},
+		{"NONE", true},
+		{"SERVICE", true},
+		{"TASK_DEFINITION", true},
+		{"service", false},
+		{"TASK", false},
+	} {
+		err := ecspresso.RunOption{PropagateTags: tt.value}.ValidatePropagateTags()
+		if tt.isValid && err != nil {
+			t.Errorf("%s unexpected error: %s", tt.value, err)
+		}
+		if !tt.isValid && err == nil {
+			t.Errorf("%s expected error, but got nil", tt.value)
+		}
+	}
+}
+
+func TestRunOptionExec(t *testing.T) {
+	for _, tt := range []struct {
+		opt     ecspresso.RunOption
+		isValid bool
+	}{
+		{ecspresso.RunOption{Count: 1}, true},
+		{ecspresso.RunOption{Count: 1, Wait: true, Exec: true}, true},
+		{ecspresso.RunOption{Count: 1, Wait: true, Exec: true, ExecContainer: "app", StopAfterExec: true}, true},
+		{ecspresso.RunOption{Count: 1, Wait: false, Exec: true}, false},
+		{ecspresso.RunOption{Count: 2, Wait: true, Exec: true}, false},
+		{ecspresso.RunOption{Count: 1, Wait: true, Exec: true, WaitForHealthy: true}, false},
+		{ecspresso.RunOption{Count: 1, Wait: true, StopAfterExec: true}, false},
+	} {
+		err := tt.opt.ValidateExec()
+		if tt.isValid && err != nil {
+			t.Errorf("%#v unexpected error: %s", tt.opt, err)
+		} else if !tt.isValid && err == nil {
+			t.Errorf("%#v expected error, but got nil", tt.opt)
+		}
+	}
+}
+
+func TestRunOptionPollLogs(t *testing.T) {
+	for _, tt := range []struct {
+		opt     ecspresso.RunOption
+		isValid bool
+	}{
+		{ecspresso.RunOption{}, true},
+		{ecspresso.RunOption{Wait: true, PollLogs: true}, true},
+		{ecspresso.RunOption{Wait: true, PollLogs: true, LogsOnFailure: true}, true},
+		{ecspresso.RunOption{Wait: false, PollLogs: true}, false},
+		{ecspresso.RunOption{Count: 1, Wait: true, PollLogs: true, Exec: true}, false},
+	} {
+		err := tt.opt.ValidatePollLogs()
+		if tt.isValid && err != nil {
+			t.Errorf("%#v unexpected error: %s", tt.opt, err)
+		} else if !tt.isValid && err == nil {
+			t.Errorf("%#v expected error, but got nil", tt.opt)
+		}
+	}
+}
+
+func TestIsLogStreamNotFound(t *testing.T) {
+	notFound := &logsTypes.ResourceNotFoundException{Message: aws.String("The specified log stream does not exist.")}
+	if !ecspresso.IsLogStreamNotFound(fmt.Errorf("operation error: %w", notFound)) {
+		t.Error("expected the log stream is not found")
+	}
+	if ecspresso.IsLogStreamNotFound(errors.New("throttling")) {
+		t.Error("unexpected the log stream is not found")
+	}
+}
+
+func TestExecContainerName(t *testing.T) {
+	single := &ecspresso.TaskDefinitionInput{
+		TaskRoleArn:          aws.String("arn:aws:iam::123456789012:role/task"),
+		ContainerDefinitions: []types.ContainerDefinition{{Name: aws.String("app")}},
+	}
+	multi := &ecspresso.TaskDefinitionInput{
+		TaskRoleArn:          aws.String("arn:aws:iam::123456789012:role/task"),
+		ContainerDefinitions: []types.ContainerDefinition{{Name: aws.String("app")}, {Name: aws.String("sidecar")}},
+	}
+	noRole := &ecspresso.TaskDefinitionInput{
+		ContainerDefinitions: []types.ContainerDefinition{{Name: aws.String("app")}},
+	}
+	for _, tt := range []struct {
+		td       *ecspresso.TaskDefinitionInput
+		name     string
+		expected string
+		isValid  bool
+	}{
+		{single, "", "app", true},
+		{single, "app", "app", true},
+		{single, "web", "", false},
+		{multi, "sidecar", "sidecar", true},
+		{multi, "", "", false},
+		{noRole, "app", "", false},
+	} {
+		name, err := ecspresso.ExecContainerName(tt.td, tt.name)
+		if !tt.isValid {
+			if err == nil {
+				t.Errorf("%s expected error, but got nil", tt.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s unexpected error: %s", tt.name, err)
+		} else if name != tt.expected {
+			t.Errorf("expected container %s, got %s", tt.expected, name)
+		}
+	}
+}
+
+func TestExecuteCommandAgentRunning(t *testing.T) {
+	task := func(status string, agent string) *types.Task {
+		return &types.Task{
+			LastStatus: aws.String(status),
+			Containers: []types.Container{
+				{
+					Name: aws.String("app"),
+					ManagedAgents: []types.ManagedAgent{
+						{Name: types.ManagedAgentNameExecuteCommandAgent, LastStatus: aws.String(agent)},
+					},
+				},
+			},
+		}
+	}
+	if ok, err := ecspresso.ExecuteCommandAgentRunning(task("RUNNING", "RUNNING"), "app"); !ok || err != nil {
+		t.Errorf("expected running, got %v %v", ok, err)
+	}
+	if ok, err := ecspresso.ExecuteCommandAgentRunning(task("RUNNING", "PENDING"), "app"); ok || err != nil {
+		t.Errorf("expected pending, got %v %v", ok, err)
+	}
+	if ok, err := ecspresso.ExecuteCommandAgentRunning(task("RUNNING", "RUNNING"), "sidecar"); ok || err != nil {
+		t.Errorf("expected not running for the other container, got %v %v", ok, err)
+	}
+	if _, err := ecspresso.ExecuteCommandAgentRunning(task("STOPPED", "STOPPED"), "app"); err == nil {
+		t.Error("expected an error for the stopped task")
+	}
+}
+
+func TestTaskStoppedStatus(t *testing.T) {
+	started := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	container := func(name string, code *int32) types.Container {
+		return types.Container{Name: aws.String(name), ExitCode: code}
+	}
+	cases := []struct {
+		name     string
+		task     types.Task
+		exitCode int
+		errMsg   string
+	}{
+		{
+			name: "running",
+			task: types.Task{LastStatus: aws.String("RUNNING"), StartedAt: &started,
+				Containers: []types.Container{container("app", nil), container("sidecar", nil)}},
+		},
+		{
+			name: "stopped successfully",
+			task: types.Task{LastStatus: aws.String("STOPPED"), StartedAt: &started,
+				Containers: []types.Container{container("app", aws.Int32(0)), container("sidecar", aws.Int32(0))}},
+		},
+		{
+			name: "watch container exited non-zero",
+			task: types.Task{LastStatus: aws.String("STOPPED"), StartedAt: &started,
+				Containers: []types.Container{container("sidecar", aws.Int32(0)), container("app", aws.Int32(3))}},
+			exitCode: 3,
+			errMsg:   "container: app, exit code: 3",
+		},
+		{
+			name: "other container exited non-zero",
+			task: types.Task{LastStatus: aws.String("STOPPED"), StartedAt: &started,
+				Containers: []types.Container{container("app", aws.Int32(0)), container("sidecar", aws.Int32(2))}},
+			exitCode: 2,
+			errMsg:   "container: sidecar, exit code: 2",
+		},
+		{
+			name: "failed to start",
+			task: types.Task{LastStatus: aws.String("STOPPED"), StopCode: types.TaskStopCodeTaskFailedToStart,
+				StoppedReason: aws.String("ResourceInitializationError: unable to pull secrets"),
+				Containers:    []types.Container{container("app", nil)}},
+			exitCode: 1,
+			errMsg:   "task failed to start: ResourceInitializationError",
+		},
+		{
+			name: "stopped before running",
+			task: types.Task{LastStatus: aws.String("STOPPED"), StopCode: types.TaskStopCodeEssentialContainerExited,
+				StoppedReason: aws.String("Essential container in task exited"),
+				Containers:    []types.Container{container("app", nil)}},
+			exitCode: 1,
+			errMsg:   "task is stopped before running: Essential container in task exited",
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := ecspresso.TaskStoppedStatus(&c.task, "app")
+			if c.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.errMsg) {
+				t.Errorf("unexpected error: %v, expected to contain %s", err, c.errMsg)
+			}
+			if code := ecspresso.ExitCodeOf(err); code != c.exitCode {
+				t.Errorf("unexpected exit code: %d, expected %d", code, c.exitCode)
+			}
+		})
+	}
+}
+
+func TestStoppedBeforeRunning(t *testing.T) {
+	running := &types.Task{TaskArn: aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task/default/abcdef"), LastStatus: aws.String("RUNNING")}
+	if err := ecspresso.StoppedBeforeRunning(running); err != nil {
+		t.Errorf("unexpected error for the running task: %s", err)
+	}
+
+	stopped := &types.Task{
+		TaskArn:       aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task/default/abcdef"),
+		LastStatus:    aws.String("STOPPED"),
+		StoppedReason: aws.String("CannotPullContainerError: pull image manifest has been retried"),
+		Containers:    []types.Container{{Name: aws.String("app")}},
+	}
+	err := ecspresso.StoppedBeforeRunning(stopped)
+	if err == nil || !strings.Contains(err.Error(), "task ID abcdef is stopped before running: CannotPullContainerError") {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	stopped.Containers[0].ExitCode = aws.Int32(127)
+	err = ecspresso.StoppedBeforeRunning(stopped)
+	if code := ecspresso.ExitCodeOf(err); code != 127 {
+		t.Errorf("unexpected exit code: %d %v", code, err)
+	}
+}
+
+func TestRunTaskSizeOverrides(t *testing.T) {
+	var mu sync.Mutex
+	var runTaskInputs []map[string]any
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.SplitN(r.Header.Get("X-Amz-Target"), ".", 2)[1]
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch op {
+		case "DescribeTaskDefinition":
+			w.Write([]byte(`{"taskDefinition":{"taskDefinitionArn":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/katsubushi:39","family":"katsubushi","revision":39,"status":"ACTIVE","requiresCompatibilities":["FARGATE"],"cpu":"256","memory":"512","containerDefinitions":[{"name":"katsubushi"}]}}`))
+		case "RunTask":
+			var in map[string]any
+			b, _ := io.ReadAll(r.Body)
+			json.Unmarshal(b, &in)
+			mu.Lock()
+			runTaskInputs = append(runTaskInputs, in)
+			mu.Unlock()
+			w.Write([]byte(`{"tasks":[{"taskArn":"arn:aws:ecs:ap-northeast-1:123456789012:task/default2/abcdef"}],"failures":[]}`))
+		default:
+			w.Write([]byte(`{}`))
+		}
+	}))
+	defer ts.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/run-with-sv.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opt := ecspresso.RunOption{
+		TaskDefinitionArn: "katsubushi:39",
+		Revision:          ptr(int64(0)),
+		Count:             1,
+		Cpu:               "1 vCPU",
+		Memory:            "4096",
+	}
+	if err := app.Run(ctx, opt); err != nil {
+		t.Fatal(err)
+	}
+	if len(runTaskInputs) != 1 {
+		t.Fatalf("RunTask must be called once: %d", len(runTaskInputs))
+	}
+	ov, _ := runTaskInputs[0]["overrides"].(map[string]any)
+	if ov["cpu"] != "1024" || ov["memory"] != "4096" {
+		t.Errorf("unexpected overrides of RunTask: %v", runTaskInputs[0]["overrides"])
+	}
+
+	// 1 vCPU does not allow 512 MiB of memory on Fargate
+	opt.Memory = "512"
+	if err := app.Run(ctx, opt); err == nil {
+		t.Error("an invalid Fargate task size must be rejected")
+	}
+	if len(runTaskInputs) != 1 {
+		t.Errorf("RunTask must not be called for an invalid task size")
+	}
+}
@@ -0,0 +1,210 @@
+package ecspresso_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+// the members of Application Auto Scaling are in PascalCase
+const scalableTargetResponse = `{"ScalableTargets":[{"ServiceNamespace":"ecs","ResourceId":"service/default2/test","ScalableDimension":"ecs:service:DesiredCount","MinCapacity":1,"MaxCapacity":4,"SuspendedState":{"DynamicScalingInSuspended":false,"DynamicScalingOutSuspended":false,"ScheduledScalingSuspended":false}}]}`
+
+type scaleRequest struct {
+	op   string
+	body map[string]any
+}
+
+// newScaleApp returns the app calling the mock ECS and Application Auto Scaling, and the requests to them.
+func newScaleApp(t *testing.T, scalableTargets string) (*ecspresso.App, func() []scaleRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var reqs []scaleRequest
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op := strings.SplitN(r.Header.Get("X-Amz-Target"), ".", 2)[1]
+		b, _ := io.ReadAll(r.Body)
+		body := map[string]any{}
+		json.Unmarshal(b, &body)
+		mu.Lock()
+		reqs = append(reqs, scaleRequest{op: op, body: body})
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		switch op {
+		case "DescribeScalableTargets":
+			w.Write([]byte(scalableTargets))
+		default:
+			if res, ok := deployNoWaitResponses[op]; ok {
+				w.Write([]byte(res))
+				return
+			}
+			w.Write([]byte(`{}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+	t.Setenv("AWS_ENDPOINT_URL_APPLICATION_AUTO_SCALING", ts.URL)
+
+	app, err := ecspresso.New(context.Background(), &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app, func() []scaleRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]scaleRequest{}, reqs...)
+	}
+}
+
+func findScaleRequests(reqs []scaleRequest, op string) []scaleRequest {
+	var found []scaleRequest
+	for _, r := range reqs {
+		if r.op == op {
+			found = append(found, r)
+		}
+	}
+	return found
+}
+
+func TestScaleAutoScalingCapacity(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	app, requests := newScaleApp(t, scalableTargetResponse)
+	opt := ecspresso.ScaleOption{
+		DesiredCount:   ptr(int32(-1)),
+		AutoScalingMin: ptr(int32(3)),
+		AutoScalingMax: ptr(int32(10)),
+	}
+	if err := app.Deploy(ctx, opt.DeployOption()); err != nil {
+		t.Fatal(err)
+	}
+	regs := findScaleRequests(requests(), "RegisterScalableTarget")
+	if len(regs) != 1 {
+		t.Fatalf("RegisterScalableTarget must be called once, got %d", len(regs))
+	}
+	body := regs[0].body
+	if body["MinCapacity"] != float64(3) || body["MaxCapacity"] != float64(10) {
+		t.Errorf("unexpected capacities: %v", body)
+	}
+	if _, ok := body["SuspendedState"]; ok {
+		t.Errorf("the suspended state must not be changed: %v", body)
+	}
+	// the desired count is left alone
+	for _, r := range findScaleRequests(requests(), "UpdateService") {
+		if r.body["desiredCount"] != nil && r.body["desiredCount"] != float64(2) {
+			t.Errorf("unexpected desired count: %v", r.body)
+		}
+	}
+}
+
+func TestScaleAutoScalingCapacityWithoutScalableTarget(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	app, requests := newScaleApp(t, `{"ScalableTargets":[]}`)
+	opt := ecspresso.ScaleOption{
+		DesiredCount:   ptr(int32(-1)),
+		AutoScalingMax: ptr(int32(10)),
+	}
+	err := app.Deploy(ctx, opt.DeployOption())
+	var notFound ecspresso.ErrNotFound
+	if !errors.As(err, &notFound) {
+		t.Fatalf("ErrNotFound expected, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "register a scalable target") {
+		t.Errorf("the error must suggest to register a scalable target: %s", err)
+	}
+	if n := len(findScaleRequests(requests(), "UpdateService")); n > 0 {
+		t.Errorf("the service must not be updated: %d", n)
+	}
+}
+
+func TestScaleAutoScalingCapacityValidation(t *testing.T) {
+	cases := []struct {
+		desired  int32
+		min, max *int32
+		valid    bool
+	}{
+		{desired: 5, min: ptr(int32(3)), max: ptr(int32(10)), valid: true},
+		{desired: -1, min: ptr(int32(3)), max: ptr(int32(10)), valid: true},
+		{desired: 3, min: ptr(int32(3)), max: ptr(int32(3)), valid: true},
+		{desired: 2, min: ptr(int32(3)), max: ptr(int32(10))},
+		{desired: 11, min: ptr(int32(3)), max: ptr(int32(10))},
+		{desired: -1, min: ptr(int32(10)), max: ptr(int32(3))},
+		{desired: 11, max: ptr(int32(10))},
+	}
+	for _, c := range cases {
+		opt := ecspresso.ScaleOption{DesiredCount: ptr(c.desired), AutoScalingMin: c.min, AutoScalingMax: c.max}
+		err := opt.DeployOption().ValidateAutoScaling()
+		if c.valid && err != nil {
+			t.Errorf("unexpected error for %#v: %s", c, err)
+		} else if !c.valid && err == nil {
+			t.Errorf("error expected for %#v", c)
+		}
+	}
+}
+
+func TestScaleSuspendResumeAutoScaling(t *testing.T) {
+	suspended := strings.ReplaceAll(scalableTargetResponse, "Suspended\":false", "Suspended\":true")
+	cases := []struct {
+		name     string
+		targets  string
+		opt      ecspresso.ScaleOption
+		register bool
+		expected bool
+	}{
+		{name: "suspend", targets: scalableTargetResponse, opt: ecspresso.ScaleOption{SuspendAutoScaling: ptr(true)}, register: true, expected: true},
+		{name: "resume", targets: suspended, opt: ecspresso.ScaleOption{ResumeAutoScaling: ptr(true)}, register: true, expected: false},
+		{name: "already suspended", targets: suspended, opt: ecspresso.ScaleOption{SuspendAutoScaling: ptr(true)}},
+		{name: "already resumed", targets: scalableTargetResponse, opt: ecspresso.ScaleOption{ResumeAutoScaling: ptr(true)}},
+		{name: "no scalable target", targets: `{"ScalableTargets":[]}`, opt: ecspresso.ScaleOption{SuspendAutoScaling: ptr(true)}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			app, requests := newScaleApp(t, c.targets)
+			c.opt.DesiredCount = ptr(int32(-1))
+			if err := app.Deploy(ctx, c.opt.DeployOption()); err != nil {
+				t.Fatal(err)
+			}
+			regs := findScaleRequests(requests(), "RegisterScalableTarget")
+			if !c.register {
+				if len(regs) > 0 {
+					t.Errorf("RegisterScalableTarget must not be called: %v", regs[0].body)
+				}
+				return
+			}
+			if len(regs) != 1 {
+				t.Fatalf("RegisterScalableTarget must be called once, got %d", len(regs))
+			}
+			state, ok := regs[0].body["SuspendedState"].(map[string]any)
+			if !ok {
+				t.Fatalf("SuspendedState is not set: %v", regs[0].body)
+			}
+			for _, key := range []string{"DynamicScalingInSuspended", "DynamicScalingOutSuspended", "ScheduledScalingSuspended"} {
+				if state[key] != c.expected {
+					t.Errorf("%s must be %v, got %v", key, c.expected, state[key])
+				}
+			}
+			if _, ok := regs[0].body["MinCapacity"]; ok {
+				t.Errorf("the capacities must not be changed: %v", regs[0].body)
+			}
+		})
+	}
+}
+
+func TestScaleSuspendAndResumeConflict(t *testing.T) {
+	opt := ecspresso.ScaleOption{DesiredCount: ptr(int32(-1)), SuspendAutoScaling: ptr(true), ResumeAutoScaling: ptr(true)}
+	if err := opt.DeployOption().ValidateAutoScaling(); err == nil {
+		t.Error("suspend and resume must be exclusive")
+	}
+}
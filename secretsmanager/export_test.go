@@ -4,7 +4,9 @@ import "sync"
 
 func MockNewApp(client secretsmanagerClient) *App {
 	return &App{
-		svc:   client,
-		cache: &sync.Map{},
+		svc:    client,
+		cache:  &sync.Map{},
+		values: &sync.Map{},
+		Warnf:  func(string, ...any) {},
 	}
 }
@@ -2,8 +2,12 @@ package secretsmanager
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"log"
+	"os"
+	"strconv"
 	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -14,11 +18,19 @@ import (
 
 type secretsmanagerClient interface {
 	DescribeSecret(ctx context.Context, input *secretsmanager.DescribeSecretInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.DescribeSecretOutput, error)
+	GetSecretValue(ctx context.Context, input *secretsmanager.GetSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
 }
 
+// AllowInlineSecretsEnv is the environment variable to allow secretsmanager_unsafe_value function.
+const AllowInlineSecretsEnv = "ECSPRESSO_ALLOW_INLINE_SECRETS"
+
 type App struct {
-	svc   secretsmanagerClient
-	cache *sync.Map
+	svc    secretsmanagerClient
+	cache  *sync.Map // ARNs by the secret IDs
+	values *sync.Map // secret strings by the secret IDs
+
+	// Warnf is called when a secret value is inlined.
+	Warnf func(format string, v ...any)
 }
 
 func (a *App) ResolveArn(ctx context.Context, id string) (string, error) {
@@ -36,20 +48,114 @@ func (a *App) ResolveArn(ctx context.Context, id string) (string, error) {
 	return arn, nil
 }
 
-func NewApp(awsCfg aws.Config) *App {
+// UnsafeValue returns the secret string of the secret.
+// It is allowed only when ECSPRESSO_ALLOW_INLINE_SECRETS environment variable is true,
+// because the value is written into the rendered definitions as plain text.
+func (a *App) UnsafeValue(ctx context.Context, id string) (string, error) {
+	if err := allowInlineSecrets("secretsmanager_unsafe_value", id); err != nil {
+		return "", err
+	}
+	v, err := a.secretString(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	a.Warnf("[WARNING] the value of secret %s is inlined as plain text. do not use it in production and do not commit the rendered definitions", id)
+	return v, nil
+}
+
+// Value returns the secret string of the secret, or the value of the key when key is not empty.
+// The secret must be a JSON object to get the value of the key.
+// The value is never included in the errors and the logs.
+func (a *App) Value(ctx context.Context, id, key string) (any, error) {
+	v, err := a.value(ctx, id, key)
+	if err != nil {
+		return nil, err
+	}
+	a.Warnf("[WARNING] the value of secret %s is inlined as plain text. do not commit the rendered definitions", id)
+	return v, nil
+}
+
+// ConfigValue is Value for the config file of ecspresso.
+// The values in the config file are not written into the definitions, so they are not warned.
+func (a *App) ConfigValue(ctx context.Context, id, key string) (any, error) {
+	return a.value(ctx, id, key)
+}
+
+func (a *App) value(ctx context.Context, id, key string) (any, error) {
+	v, err := a.secretString(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return v, nil
+	}
+	var obj map[string]any
+	if err := json.Unmarshal([]byte(v), &obj); err != nil {
+		// the error of json may contain a part of the value
+		return nil, fmt.Errorf("secret %s is not a JSON object to get key %s", id, key)
+	}
+	kv, ok := obj[key]
+	if !ok {
+		return nil, fmt.Errorf("key %s is not found in secret %s", key, id)
+	}
+	return kv, nil
+}
+
+// KeyArg returns the key argument of the secretsmanager native function.
+// Native functions of Jsonnet cannot have optional parameters, so null is accepted as no key, the same as "".
+func KeyArg(arg any) (string, error) {
+	switch k := arg.(type) {
+	case string:
+		return k, nil
+	case nil:
+		return "", nil
+	default:
+		return "", fmt.Errorf("secretsmanager: key must be string or null")
+	}
+}
+
+func allowInlineSecrets(fn, id string) error {
+	if ok, _ := strconv.ParseBool(os.Getenv(AllowInlineSecretsEnv)); !ok {
+		return fmt.Errorf("%s is not allowed: the value of secret %s would be exposed in the rendered definitions. set %s=1 to allow it explicitly", fn, id, AllowInlineSecretsEnv)
+	}
+	return nil
+}
+
+// secretString returns the secret string of the secret. The values are cached by the secret ID.
+func (a *App) secretString(ctx context.Context, id string) (string, error) {
+	if v, ok := a.values.Load(id); ok {
+		return v.(string), nil
+	}
+	res, err := a.svc.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: &id,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to get secret value: %w", err)
+	}
+	if res.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no secret string", id)
+	}
+	a.values.Store(id, *res.SecretString)
+	return *res.SecretString, nil
+}
+
+// NewApp returns App. warnf is called when a secret value is inlined.
+func NewApp(awsCfg aws.Config, warnf func(format string, v ...any)) *App {
 	return &App{
-		svc:   secretsmanager.NewFromConfig(awsCfg),
-		cache: &sync.Map{},
+		svc:    secretsmanager.NewFromConfig(awsCfg),
+		cache:  &sync.Map{},
+		values: &sync.Map{},
+		Warnf:  warnf,
 	}
 }
 
 func FuncMap(ctx context.Context, cfg aws.Config) template.FuncMap {
-	app := NewApp(cfg)
+	app := NewApp(cfg, log.Printf)
 	return app.FuncMap(ctx)
 }
 
 func JsonnetNativeFuncs(ctx context.Context, cfg aws.Config) ([]*jsonnet.NativeFunction, error) {
-	app := NewApp(cfg)
+	app := NewApp(cfg, log.Printf)
 	return app.JsonnetNativeFuncs(ctx), nil
 }
 
@@ -58,6 +164,9 @@ func (a *App) FuncMap(ctx context.Context) template.FuncMap {
 		"secretsmanager_arn": func(id string) (string, error) {
 			return a.ResolveArn(ctx, id)
 		},
+		"secretsmanager_unsafe_value": func(id string) (string, error) {
+			return a.UnsafeValue(ctx, id)
+		},
 	}
 	return funcs
 }
@@ -75,5 +184,31 @@ func (a *App) JsonnetNativeFuncs(ctx context.Context) []*jsonnet.NativeFunction
 				return a.ResolveArn(ctx, id)
 			},
 		},
+		{
+			Name:   "secretsmanager_unsafe_value",
+			Params: []ast.Identifier{"id"},
+			Func: func(args []any) (any, error) {
+				id, ok := args[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("secretsmanager_unsafe_value: id must be string")
+				}
+				return a.UnsafeValue(ctx, id)
+			},
+		},
+		{
+			Name:   "secretsmanager",
+			Params: []ast.Identifier{"id", "key"},
+			Func: func(args []any) (any, error) {
+				id, ok := args[0].(string)
+				if !ok {
+					return nil, fmt.Errorf("secretsmanager: id must be string")
+				}
+				key, err := KeyArg(args[1])
+				if err != nil {
+					return nil, err
+				}
+				return a.Value(ctx, id, key)
+			},
+		},
 	}
 }
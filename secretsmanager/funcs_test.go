@@ -13,7 +13,9 @@ import (
 	sm "github.com/kayac/ecspresso/v2/secretsmanager"
 )
 
-type mockSecretsManagerClient struct{}
+type mockSecretsManagerClient struct {
+	getSecretValueCalls int
+}
 
 var arnFmt = "arn:aws:secretsmanager:us-west-1:123456789012:secret:%s-deadbeef"
 
@@ -23,6 +25,20 @@ func (m *mockSecretsManagerClient) DescribeSecret(ctx context.Context, input *se
 	}, nil
 }
 
+func (m *mockSecretsManagerClient) GetSecretValue(ctx context.Context, input *secretsmanager.GetSecretValueInput, opts ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error) {
+	m.getSecretValueCalls++
+	if *input.SecretId == "json-secret" {
+		return &secretsmanager.GetSecretValueOutput{
+			ARN:          aws.String(fmt.Sprintf(arnFmt, *input.SecretId)),
+			SecretString: aws.String(`{"username":"admin","port":5432}`),
+		}, nil
+	}
+	return &secretsmanager.GetSecretValueOutput{
+		ARN:          aws.String(fmt.Sprintf(arnFmt, *input.SecretId)),
+		SecretString: aws.String("value of " + *input.SecretId),
+	}, nil
+}
+
 func TestJsonnetNativeFuncs(t *testing.T) {
 	app := sm.MockNewApp(&mockSecretsManagerClient{})
 	funcs := app.JsonnetNativeFuncs(context.Background())
@@ -44,3 +60,115 @@ func TestJsonnetNativeFuncs(t *testing.T) {
 		t.Fatalf("expected secretsmanager_arn function to return %s, got %s", expect, out)
 	}
 }
+
+func TestUnsafeValue(t *testing.T) {
+	app := sm.MockNewApp(&mockSecretsManagerClient{})
+	ctx := context.Background()
+
+	t.Setenv(sm.AllowInlineSecretsEnv, "")
+	if _, err := app.UnsafeValue(ctx, "my-secret"); err == nil {
+		t.Fatal("expected error without " + sm.AllowInlineSecretsEnv)
+	}
+	t.Setenv(sm.AllowInlineSecretsEnv, "0")
+	if _, err := app.UnsafeValue(ctx, "my-secret"); err == nil {
+		t.Fatal("expected error with " + sm.AllowInlineSecretsEnv + "=0")
+	}
+
+	t.Setenv(sm.AllowInlineSecretsEnv, "1")
+	var warned bool
+	app.Warnf = func(string, ...any) { warned = true }
+	vm := jsonnet.MakeVM()
+	for _, f := range app.JsonnetNativeFuncs(ctx) {
+		vm.NativeFunction(f)
+	}
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `std.native('secretsmanager_unsafe_value')('my-secret')`)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if s := strings.TrimSuffix(out, "\n"); s != `"value of my-secret"` {
+		t.Errorf("unexpected value %s", s)
+	}
+	if !warned {
+		t.Error("expected a warning for the inlined secret")
+	}
+}
+
+func TestValue(t *testing.T) {
+	client := &mockSecretsManagerClient{}
+	app := sm.MockNewApp(client)
+	ctx := context.Background()
+
+	// only secretsmanager_unsafe_value requires ECSPRESSO_ALLOW_INLINE_SECRETS
+	t.Setenv(sm.AllowInlineSecretsEnv, "")
+	var warnings []string
+	app.Warnf = func(format string, v ...any) { warnings = append(warnings, fmt.Sprintf(format, v...)) }
+	vm := jsonnet.MakeVM()
+	for _, f := range app.JsonnetNativeFuncs(ctx) {
+		vm.NativeFunction(f)
+	}
+	out, err := vm.EvaluateAnonymousSnippet("test.jsonnet", `
+		local secretsmanager = std.native('secretsmanager');
+		{
+			whole: secretsmanager('my-secret', ''),
+			null_key: secretsmanager('my-secret', null),
+			username: secretsmanager('json-secret', 'username'),
+			port: secretsmanager('json-secret', 'port'),
+		}
+	`)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]any{"whole": "value of my-secret", "null_key": "value of my-secret", "username": "admin", "port": float64(5432)}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Errorf("unexpected %s: %v", k, got[k])
+		}
+	}
+	// json-secret is fetched once
+	if client.getSecretValueCalls != 2 {
+		t.Errorf("unexpected GetSecretValue calls: %d", client.getSecretValueCalls)
+	}
+	for _, w := range warnings {
+		if strings.Contains(w, "admin") || strings.Contains(w, "value of my-secret") {
+			t.Errorf("the value is logged: %s", w)
+		}
+	}
+
+	for _, tt := range []struct{ id, key string }{
+		{"my-secret", "username"},   // not a JSON object
+		{"json-secret", "password"}, // key not found
+	} {
+		_, err := app.Value(ctx, tt.id, tt.key)
+		if err == nil {
+			t.Errorf("%s %s expected error, but got nil", tt.id, tt.key)
+			continue
+		}
+		if strings.Contains(err.Error(), "value of my-secret") || strings.Contains(err.Error(), "admin") {
+			t.Errorf("the value is exposed in the error: %s", err)
+		}
+	}
+}
+
+func TestConfigValue(t *testing.T) {
+	app := sm.MockNewApp(&mockSecretsManagerClient{})
+	ctx := context.Background()
+
+	// the config file does not require ECSPRESSO_ALLOW_INLINE_SECRETS
+	t.Setenv(sm.AllowInlineSecretsEnv, "")
+	var warned bool
+	app.Warnf = func(string, ...any) { warned = true }
+	v, err := app.ConfigValue(ctx, "json-secret", "username")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != "admin" {
+		t.Errorf("unexpected value %v", v)
+	}
+	if warned {
+		t.Error("the value in the config file must not be warned as inlined")
+	}
+}
@@ -0,0 +1,91 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// serviceSelectorCommands are the subcommands which accept --service-selector.
+var serviceSelectorCommands = []string{"status", "deploy", "scale"}
+
+// parseServiceSelector parses the selector in the format Key=Value.
+func parseServiceSelector(s string) (types.Tag, error) {
+	tags, err := parseTags(s)
+	if err != nil {
+		return types.Tag{}, fmt.Errorf("invalid service selector: %w", err)
+	}
+	if len(tags) != 1 {
+		return types.Tag{}, fmt.Errorf("invalid service selector %q: Key=Value is required", s)
+	}
+	return tags[0], nil
+}
+
+// selectServiceByTag returns the ARN of the only service which has the tag.
+// tagsByArn is the tags of the services keyed by the ARNs.
+func selectServiceByTag(tagsByArn map[string][]types.Tag, selector types.Tag) (string, error) {
+	var matched []string
+	for arn, tags := range tagsByArn {
+		for _, tag := range tags {
+			if aws.ToString(tag.Key) == aws.ToString(selector.Key) && aws.ToString(tag.Value) == aws.ToString(selector.Value) {
+				matched = append(matched, arn)
+				break
+			}
+		}
+	}
+	sort.Strings(matched)
+	sel := aws.ToString(selector.Key) + "=" + aws.ToString(selector.Value)
+	switch len(matched) {
+	case 0:
+		return "", ErrNotFound(fmt.Sprintf("no service matches the selector %s", sel))
+	case 1:
+		return matched[0], nil
+	default:
+		names := make([]string, 0, len(matched))
+		for _, arn := range matched {
+			names = append(names, arnToName(arn))
+		}
+		return "", fmt.Errorf("%d services match the selector %s: %s. exactly one match is required", len(matched), sel, strings.Join(names, ", "))
+	}
+}
+
+// selectService resolves the service of the app by the selector of the tag, instead of the service name.
+func (d *App) selectService(ctx context.Context, s string) error {
+	selector, err := parseServiceSelector(s)
+	if err != nil {
+		return err
+	}
+	tagsByArn := map[string][]types.Tag{}
+	p := ecs.NewListServicesPaginator(d.ecs, &ecs.ListServicesInput{
+		Cluster: aws.String(d.Cluster),
+	})
+	for p.HasMorePages() {
+		out, err := p.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list services: %w", err)
+		}
+		for _, arn := range out.ServiceArns {
+			tags, err := d.ecs.ListTagsForResource(ctx, &ecs.ListTagsForResourceInput{
+				ResourceArn: aws.String(arn),
+			})
+			if err != nil {
+				return fmt.Errorf("failed to list tags for service %s: %w", arnToName(arn), err)
+			}
+			tagsByArn[arn] = tags.Tags
+		}
+	}
+	arn, err := selectServiceByTag(tagsByArn, selector)
+	if err != nil {
+		return err
+	}
+	name := arnToName(arn)
+	d.Log("[INFO] service %s is selected by %s", name, s)
+	d.Service = name
+	d.config.Service = name
+	return nil
+}
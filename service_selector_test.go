@@ -0,0 +1,81 @@
+package ecspresso_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestParseServiceSelector(t *testing.T) {
+	tag, err := ecspresso.ParseServiceSelector("app=web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aws.ToString(tag.Key) != "app" || aws.ToString(tag.Value) != "web" {
+		t.Errorf("unexpected selector: %s=%s", aws.ToString(tag.Key), aws.ToString(tag.Value))
+	}
+	for _, s := range []string{"app", "=web", "app=web,env=prod", ","} {
+		if _, err := ecspresso.ParseServiceSelector(s); err == nil {
+			t.Errorf("%s expected error, but got nil", s)
+		}
+	}
+}
+
+func TestSelectServiceByTag(t *testing.T) {
+	tag := func(k, v string) types.Tag {
+		return types.Tag{Key: aws.String(k), Value: aws.String(v)}
+	}
+	arn := func(name string) string {
+		return "arn:aws:ecs:ap-northeast-1:123456789012:service/default/" + name
+	}
+	tagsByArn := map[string][]types.Tag{
+		arn("web-1a2b"):    {tag("app", "web"), tag("env", "prod")},
+		arn("worker-3c4d"): {tag("app", "worker"), tag("env", "prod")},
+		arn("batch-5e6f"):  nil,
+	}
+
+	got, err := ecspresso.SelectServiceByTag(tagsByArn, tag("app", "web"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != arn("web-1a2b") {
+		t.Errorf("unexpected service: %s", got)
+	}
+
+	_, err = ecspresso.SelectServiceByTag(tagsByArn, tag("app", "api"))
+	var notFound ecspresso.ErrNotFound
+	if !errors.As(err, &notFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	_, err = ecspresso.SelectServiceByTag(tagsByArn, tag("env", "prod"))
+	if err == nil || !strings.Contains(err.Error(), "2 services match the selector env=prod: web-1a2b, worker-3c4d") {
+		t.Errorf("unexpected error for multiple matches: %v", err)
+	}
+}
+
+func TestParseCLIv2ServiceSelector(t *testing.T) {
+	for _, args := range [][]string{
+		{"diff", "--service-selector", "app=web"},
+		{"status", "--service-selector", "app=web", "--service", "web"},
+		{"deploy", "--service-selector", "app"},
+	} {
+		if _, _, _, err := ecspresso.ParseCLIv2(args); err == nil {
+			t.Errorf("%v expected error, but got nil", args)
+		}
+	}
+	for _, sub := range []string{"status", "deploy", "scale"} {
+		_, opts, _, err := ecspresso.ParseCLIv2([]string{sub, "--service-selector", "app=web"})
+		if err != nil {
+			t.Errorf("%s unexpected error: %s", sub, err)
+			continue
+		}
+		if opts.ServiceSelector != "app=web" {
+			t.Errorf("unexpected service selector: %s", opts.ServiceSelector)
+		}
+	}
+}
@@ -1,14 +1,317 @@
 package ecspresso
 
-import "context"
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	cdTypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/samber/lo"
+)
 
 type StatusOption struct {
-	Events int `help:"show events num" default:"10"`
+	Events int    `help:"show events num" default:"10"`
+	Output string `help:"output format (text, json, prometheus, dot)" enum:"text,json,prometheus,dot" default:"text"`
+}
+
+// ServiceStatus is the status of the service written by status --output json.
+type ServiceStatus struct {
+	Exists         bool               `json:"exists"`
+	Cluster        string             `json:"cluster"`
+	Service        string             `json:"service"`
+	Status         string             `json:"status"`
+	TaskDefinition string             `json:"task_definition"`
+	DesiredCount   int32              `json:"desired_count"`
+	RunningCount   int32              `json:"running_count"`
+	PendingCount   int32              `json:"pending_count"`
+	Deployments    []DeploymentStatus `json:"deployments"`
+}
+
+// DeploymentStatus is the status of a deployment of the service in ServiceStatus.
+type DeploymentStatus struct {
+	ID                 string     `json:"id"`
+	Status             string     `json:"status"`
+	TaskDefinition     string     `json:"task_definition"`
+	DesiredCount       int32      `json:"desired_count"`
+	RunningCount       int32      `json:"running_count"`
+	PendingCount       int32      `json:"pending_count"`
+	FailedTasks        int32      `json:"failed_tasks"`
+	RolloutState       string     `json:"rollout_state"`
+	RolloutStateReason string     `json:"rollout_state_reason"`
+	CreatedAt          *time.Time `json:"created_at"`
+	UpdatedAt          *time.Time `json:"updated_at"`
+}
+
+func newServiceStatus(sv *Service) *ServiceStatus {
+	st := &ServiceStatus{
+		Exists:         true,
+		Cluster:        arnToName(aws.ToString(sv.ClusterArn)),
+		Service:        aws.ToString(sv.ServiceName),
+		Status:         aws.ToString(sv.Status),
+		TaskDefinition: aws.ToString(sv.TaskDefinition),
+		DesiredCount:   sv.Service.DesiredCount,
+		RunningCount:   sv.RunningCount,
+		PendingCount:   sv.PendingCount,
+		Deployments:    make([]DeploymentStatus, 0, len(sv.Deployments)),
+	}
+	for _, dep := range sv.Deployments {
+		st.Deployments = append(st.Deployments, DeploymentStatus{
+			ID:                 aws.ToString(dep.Id),
+			Status:             aws.ToString(dep.Status),
+			TaskDefinition:     aws.ToString(dep.TaskDefinition),
+			DesiredCount:       dep.DesiredCount,
+			RunningCount:       dep.RunningCount,
+			PendingCount:       dep.PendingCount,
+			FailedTasks:        dep.FailedTasks,
+			RolloutState:       string(dep.RolloutState),
+			RolloutStateReason: aws.ToString(dep.RolloutStateReason),
+			CreatedAt:          dep.CreatedAt,
+			UpdatedAt:          dep.UpdatedAt,
+		})
+	}
+	return st
+}
+
+// outputStatusJSON writes the status of the service as JSON.
+// The service which does not exist yet is not an error, but exists is false.
+func (d *App) outputStatusJSON(ctx context.Context, w io.Writer) error {
+	var st *ServiceStatus
+	sv, err := d.DescribeService(ctx)
+	if err != nil {
+		if !errors.As(err, &errNotFound) {
+			return err
+		}
+		st = &ServiceStatus{
+			Cluster:     d.Cluster,
+			Service:     d.Service,
+			Deployments: []DeploymentStatus{},
+		}
+	} else {
+		st = newServiceStatus(sv)
+	}
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal status: %w", err)
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
 }
 
 func (d *App) Status(ctx context.Context, opt StatusOption) error {
 	ctx, cancel := d.Start(ctx)
 	defer cancel()
-	_, err := d.DescribeServiceStatus(ctx, opt.Events)
-	return err
+	switch opt.Output {
+	case "json":
+		return d.outputStatusJSON(ctx, os.Stdout)
+	case "prometheus", "dot":
+		sv, err := d.DescribeService(ctx)
+		if err != nil {
+			return err
+		}
+		if opt.Output == "dot" {
+			_, err = io.WriteString(os.Stdout, formatDOT(sv))
+		} else {
+			_, err = io.WriteString(os.Stdout, formatPrometheusMetrics(sv))
+		}
+		return err
+	}
+	sv, err := d.DescribeServiceStatus(ctx, opt.Events)
+	if err != nil {
+		return err
+	}
+	if sv.isCodeDeploy() {
+		// the status of ECS is already shown
+		if err := d.describeCodeDeployStatus(ctx); err != nil {
+			d.Log("[WARNING] failed to describe CodeDeploy deployment: %s", err)
+		}
+	}
+	return nil
+}
+
+func (d *App) describeCodeDeployStatus(ctx context.Context) error {
+	dp, err := d.findDeploymentInfo(ctx)
+	if err != nil {
+		return err
+	}
+	ld, err := d.codedeploy.ListDeployments(ctx, &codedeploy.ListDeploymentsInput{
+		ApplicationName:     dp.ApplicationName,
+		DeploymentGroupName: dp.DeploymentGroupName,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list deployments: %w", err)
+	}
+	fmt.Fprintln(d.stdout, "CodeDeploy:")
+	if len(ld.Deployments) == 0 {
+		fmt.Fprintln(d.stdout, spcIndent+"no deployments")
+		return nil
+	}
+	out, err := d.codedeploy.GetDeployment(ctx, &codedeploy.GetDeploymentInput{
+		DeploymentId: &ld.Deployments[0], // latest deployment
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get deployment: %w", err)
+	}
+	var target *cdTypes.DeploymentTarget
+	if tout, err := d.codedeploy.GetDeploymentTarget(ctx, &codedeploy.GetDeploymentTargetInput{
+		DeploymentId: &ld.Deployments[0],
+		TargetId:     aws.String(d.Cluster + ":" + d.Service),
+	}); err != nil {
+		d.Log("[WARNING] failed to get deployment target: %s", err)
+	} else {
+		target = tout.DeploymentTarget
+	}
+	for _, line := range formatCodeDeployStatus(out.DeploymentInfo, target, d.config.Region) {
+		fmt.Fprintln(d.stdout, spcIndent+line)
+	}
+	return nil
+}
+
+func formatCodeDeployStatus(dep *cdTypes.DeploymentInfo, target *cdTypes.DeploymentTarget, region string) []string {
+	id := aws.ToString(dep.DeploymentId)
+	lines := []string{
+		fmt.Sprintf("Deployment: %s %s", id, dep.Status),
+	}
+	if dep.CreateTime != nil {
+		lines = append(lines, fmt.Sprintf("CreatedAt: %s", dep.CreateTime.Format(EventTimeFormat)))
+	}
+	if dep.Status == cdTypes.DeploymentStatusReady {
+		lines = append(lines, "Waiting for approval: the traffic will be rerouted after continuing the deployment")
+	}
+	if info := dep.ErrorInformation; info != nil {
+		lines = append(lines, fmt.Sprintf("Error: %s %s", info.Code, aws.ToString(info.Message)))
+	}
+	if target != nil && target.EcsTarget != nil {
+		var weights []string
+		for _, ts := range target.EcsTarget.TaskSetsInfo {
+			weights = append(weights, fmt.Sprintf("%s(%s) %.0f%%", ts.TaskSetLabel, aws.ToString(ts.Status), ts.TrafficWeight))
+		}
+		if len(weights) > 0 {
+			lines = append(lines, "Traffic: "+strings.Join(weights, ", "))
+		}
+		for _, ev := range target.EcsTarget.LifecycleEvents {
+			if ev.Status == cdTypes.LifecycleEventStatusPending {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s", aws.ToString(ev.LifecycleEventName), ev.Status))
+		}
+	}
+	lines = append(lines, "URL: "+fmt.Sprintf(CodeDeployConsoleURLFmt, region, id, region))
+	return lines
+}
+
+// rolloutStates are the values of ecspresso_deployment_rollout_state state label.
+var rolloutStates = []types.DeploymentRolloutState{
+	types.DeploymentRolloutStateCompleted,
+	types.DeploymentRolloutStateFailed,
+	types.DeploymentRolloutStateInProgress,
+}
+
+// formatPrometheusMetrics formats the service status in the Prometheus text exposition format.
+func formatPrometheusMetrics(sv *Service) string {
+	var b strings.Builder
+	labels := fmt.Sprintf(`cluster="%s",service="%s"`,
+		escapePrometheusLabel(arnToName(aws.ToString(sv.ClusterArn))),
+		escapePrometheusLabel(aws.ToString(sv.ServiceName)),
+	)
+	gauge := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+
+	gauge("ecspresso_service_desired_count", "Desired count of tasks of the service.")
+	fmt.Fprintf(&b, "ecspresso_service_desired_count{%s} %d\n", labels, sv.Service.DesiredCount)
+	gauge("ecspresso_service_running_count", "Running count of tasks of the service.")
+	fmt.Fprintf(&b, "ecspresso_service_running_count{%s} %d\n", labels, sv.RunningCount)
+	gauge("ecspresso_service_pending_count", "Pending count of tasks of the service.")
+	fmt.Fprintf(&b, "ecspresso_service_pending_count{%s} %d\n", labels, sv.PendingCount)
+	gauge("ecspresso_service_deployments", "Number of deployments of the service.")
+	fmt.Fprintf(&b, "ecspresso_service_deployments{%s} %d\n", labels, len(sv.Deployments))
+	gauge("ecspresso_service_info", "Task definition of the service.")
+	fmt.Fprintf(&b, "ecspresso_service_info{%s,task_definition=\"%s\"} 1\n", labels,
+		escapePrometheusLabel(arnToName(aws.ToString(sv.TaskDefinition))))
+
+	for _, dep := range sv.Deployments {
+		if aws.ToString(dep.Status) != "PRIMARY" || dep.RolloutState == "" {
+			continue
+		}
+		gauge("ecspresso_deployment_rollout_state", "Rollout state of the PRIMARY deployment. 1 for the current state.")
+		for _, state := range rolloutStates {
+			var v int
+			if dep.RolloutState == state {
+				v = 1
+			}
+			fmt.Fprintf(&b, "ecspresso_deployment_rollout_state{%s,state=\"%s\"} %d\n", labels, state, v)
+		}
+		break
+	}
+	return b.String()
+}
+
+var prometheusLabelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapePrometheusLabel(s string) string {
+	return prometheusLabelReplacer.Replace(s)
+}
+
+var dotStringReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// dotQuote quotes s as a string of Graphviz DOT language. "\n" in s is a line break of the label.
+func dotQuote(s string) string {
+	return `"` + dotStringReplacer.Replace(s) + `"`
+}
+
+// formatDOT formats the deployments and task sets of the service in Graphviz DOT language.
+func formatDOT(sv *Service) string {
+	var b strings.Builder
+	b.WriteString("digraph ecspresso {\n  rankdir=LR;\n  node [shape=box];\n")
+	svNode := dotQuote("service")
+	fmt.Fprintf(&b, "  %s [shape=ellipse, label=%s];\n", svNode, dotQuote(fmt.Sprintf(
+		"%s/%s\ndesired:%d running:%d pending:%d",
+		arnToName(aws.ToString(sv.ClusterArn)), aws.ToString(sv.ServiceName),
+		sv.Service.DesiredCount, sv.RunningCount, sv.PendingCount,
+	)))
+
+	var tds []string
+	tdNode := func(arn string) string {
+		name := arnToName(arn)
+		if !lo.Contains(tds, name) {
+			tds = append(tds, name)
+		}
+		return dotQuote("taskdef:" + name)
+	}
+	for _, dep := range sv.Deployments {
+		node := dotQuote("deployment:" + aws.ToString(dep.Id))
+		label := fmt.Sprintf("%s %s\ndesired:%d pending:%d running:%d",
+			aws.ToString(dep.Status), aws.ToString(dep.Id),
+			dep.DesiredCount, dep.PendingCount, dep.RunningCount,
+		)
+		if dep.RolloutState != "" {
+			label += "\n" + string(dep.RolloutState)
+		}
+		fmt.Fprintf(&b, "  %s [label=%s];\n", node, dotQuote(label))
+		fmt.Fprintf(&b, "  %s -> %s;\n", svNode, node)
+		fmt.Fprintf(&b, "  %s -> %s;\n", node, tdNode(aws.ToString(dep.TaskDefinition)))
+	}
+	for _, ts := range sv.TaskSets {
+		node := dotQuote("taskset:" + aws.ToString(ts.Id))
+		label := fmt.Sprintf("%s %s\ndesired:%d pending:%d running:%d\n%s",
+			aws.ToString(ts.Status), aws.ToString(ts.Id),
+			ts.ComputedDesiredCount, ts.PendingCount, ts.RunningCount, ts.StabilityStatus,
+		)
+		fmt.Fprintf(&b, "  %s [label=%s];\n", node, dotQuote(label))
+		fmt.Fprintf(&b, "  %s -> %s;\n", svNode, node)
+		fmt.Fprintf(&b, "  %s -> %s;\n", node, tdNode(aws.ToString(ts.TaskDefinition)))
+	}
+	for _, name := range tds {
+		fmt.Fprintf(&b, "  %s [shape=note, label=%s];\n", dotQuote("taskdef:"+name), dotQuote(name))
+	}
+	b.WriteString("}\n")
+	return b.String()
 }
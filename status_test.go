@@ -0,0 +1,232 @@
+package ecspresso_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cdTypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestFormatCodeDeployStatus(t *testing.T) {
+	created := time.Date(2019, 10, 15, 22, 47, 9, 0, time.UTC)
+	dep := &cdTypes.DeploymentInfo{
+		DeploymentId: aws.String("d-XXXXXXXXX"),
+		Status:       cdTypes.DeploymentStatusReady,
+		CreateTime:   &created,
+	}
+	target := &cdTypes.DeploymentTarget{
+		EcsTarget: &cdTypes.ECSTarget{
+			TaskSetsInfo: []cdTypes.ECSTaskSet{
+				{TaskSetLabel: cdTypes.TargetLabelBlue, Status: aws.String("PRIMARY"), TrafficWeight: 100},
+				{TaskSetLabel: cdTypes.TargetLabelGreen, Status: aws.String("ACTIVE"), TrafficWeight: 0},
+			},
+			LifecycleEvents: []cdTypes.LifecycleEvent{
+				{LifecycleEventName: aws.String("BeforeInstall"), Status: cdTypes.LifecycleEventStatusSucceeded},
+				{LifecycleEventName: aws.String("AllowTraffic"), Status: cdTypes.LifecycleEventStatusPending},
+			},
+		},
+	}
+	lines := ecspresso.FormatCodeDeployStatus(dep, target, "ap-northeast-1")
+	expected := []string{
+		"Deployment: d-XXXXXXXXX Ready",
+		"CreatedAt: " + created.Format(ecspresso.EventTimeFormat),
+		"Waiting for approval: the traffic will be rerouted after continuing the deployment",
+		"Traffic: Blue(PRIMARY) 100%, Green(ACTIVE) 0%",
+		"BeforeInstall: Succeeded",
+		"URL: https://ap-northeast-1.console.aws.amazon.com/codesuite/codedeploy/deployments/d-XXXXXXXXX?region=ap-northeast-1",
+	}
+	if diff := cmp.Diff(expected, lines); diff != "" {
+		t.Errorf("unexpected status lines: %s", diff)
+	}
+
+	// without the deployment target
+	dep.Status = cdTypes.DeploymentStatusSucceeded
+	lines = ecspresso.FormatCodeDeployStatus(dep, nil, "ap-northeast-1")
+	if len(lines) != 3 || lines[0] != "Deployment: d-XXXXXXXXX Succeeded" {
+		t.Errorf("unexpected status lines: %v", lines)
+	}
+}
+
+func TestFormatPrometheusMetrics(t *testing.T) {
+	sv := &ecspresso.Service{
+		Service: types.Service{
+			ClusterArn:     aws.String("arn:aws:ecs:ap-northeast-1:123456789012:cluster/default"),
+			ServiceName:    aws.String("app"),
+			TaskDefinition: aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:39"),
+			DesiredCount:   2,
+			RunningCount:   1,
+			PendingCount:   1,
+			Deployments: []types.Deployment{
+				{Status: aws.String("PRIMARY"), RolloutState: types.DeploymentRolloutStateInProgress},
+				{Status: aws.String("ACTIVE"), RolloutState: types.DeploymentRolloutStateCompleted},
+			},
+		},
+	}
+	expected := `# HELP ecspresso_service_desired_count Desired count of tasks of the service.
+# TYPE ecspresso_service_desired_count gauge
+ecspresso_service_desired_count{cluster="default",service="app"} 2
+# HELP ecspresso_service_running_count Running count of tasks of the service.
+# TYPE ecspresso_service_running_count gauge
+ecspresso_service_running_count{cluster="default",service="app"} 1
+# HELP ecspresso_service_pending_count Pending count of tasks of the service.
+# TYPE ecspresso_service_pending_count gauge
+ecspresso_service_pending_count{cluster="default",service="app"} 1
+# HELP ecspresso_service_deployments Number of deployments of the service.
+# TYPE ecspresso_service_deployments gauge
+ecspresso_service_deployments{cluster="default",service="app"} 2
+# HELP ecspresso_service_info Task definition of the service.
+# TYPE ecspresso_service_info gauge
+ecspresso_service_info{cluster="default",service="app",task_definition="app:39"} 1
+# HELP ecspresso_deployment_rollout_state Rollout state of the PRIMARY deployment. 1 for the current state.
+# TYPE ecspresso_deployment_rollout_state gauge
+ecspresso_deployment_rollout_state{cluster="default",service="app",state="COMPLETED"} 0
+ecspresso_deployment_rollout_state{cluster="default",service="app",state="FAILED"} 0
+ecspresso_deployment_rollout_state{cluster="default",service="app",state="IN_PROGRESS"} 1
+`
+	if diff := cmp.Diff(expected, ecspresso.FormatPrometheusMetrics(sv)); diff != "" {
+		t.Errorf("unexpected metrics: %s", diff)
+	}
+}
+
+func TestEscapePrometheusLabel(t *testing.T) {
+	if s := ecspresso.EscapePrometheusLabel("a\\b\"c\nd"); s != `a\\b\"c\nd` {
+		t.Errorf("unexpected escaped label: %s", s)
+	}
+}
+
+func TestFormatDOT(t *testing.T) {
+	sv := &ecspresso.Service{
+		Service: types.Service{
+			ClusterArn:   aws.String("arn:aws:ecs:ap-northeast-1:123456789012:cluster/default"),
+			ServiceName:  aws.String("app"),
+			DesiredCount: 2,
+			RunningCount: 3,
+			PendingCount: 1,
+			Deployments: []types.Deployment{
+				{
+					Id:             aws.String("ecs-svc/2"),
+					Status:         aws.String("PRIMARY"),
+					TaskDefinition: aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:40"),
+					DesiredCount:   2,
+					PendingCount:   1,
+					RunningCount:   1,
+					RolloutState:   types.DeploymentRolloutStateInProgress,
+				},
+				{
+					Id:             aws.String("ecs-svc/1"),
+					Status:         aws.String("ACTIVE"),
+					TaskDefinition: aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:39"),
+					DesiredCount:   0,
+					RunningCount:   2,
+				},
+			},
+			TaskSets: []types.TaskSet{
+				{
+					Id:                   aws.String("ecs-svc/3"),
+					Status:               aws.String("ACTIVE"),
+					TaskDefinition:       aws.String("arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app:40"),
+					ComputedDesiredCount: 1,
+					StabilityStatus:      types.StabilityStatusStabilizing,
+				},
+			},
+		},
+	}
+	expected := `digraph ecspresso {
+  rankdir=LR;
+  node [shape=box];
+  "service" [shape=ellipse, label="default/app\ndesired:2 running:3 pending:1"];
+  "deployment:ecs-svc/2" [label="PRIMARY ecs-svc/2\ndesired:2 pending:1 running:1\nIN_PROGRESS"];
+  "service" -> "deployment:ecs-svc/2";
+  "deployment:ecs-svc/2" -> "taskdef:app:40";
+  "deployment:ecs-svc/1" [label="ACTIVE ecs-svc/1\ndesired:0 pending:0 running:2"];
+  "service" -> "deployment:ecs-svc/1";
+  "deployment:ecs-svc/1" -> "taskdef:app:39";
+  "taskset:ecs-svc/3" [label="ACTIVE ecs-svc/3\ndesired:1 pending:0 running:0\nSTABILIZING"];
+  "service" -> "taskset:ecs-svc/3";
+  "taskset:ecs-svc/3" -> "taskdef:app:40";
+  "taskdef:app:40" [shape=note, label="app:40"];
+  "taskdef:app:39" [shape=note, label="app:39"];
+}
+`
+	if diff := cmp.Diff(expected, ecspresso.FormatDOT(sv)); diff != "" {
+		t.Errorf("unexpected dot: %s", diff)
+	}
+}
+
+func newStatusApp(t *testing.T, describeServices string) *ecspresso.App {
+	t.Helper()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if strings.HasSuffix(r.Header.Get("X-Amz-Target"), ".DescribeServices") {
+			w.Write([]byte(describeServices))
+			return
+		}
+		w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(ts.Close)
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_ECS", ts.URL)
+
+	app, err := ecspresso.New(context.Background(), &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func TestOutputStatusJSON(t *testing.T) {
+	app := newStatusApp(t, `{"services":[{"serviceName":"test","clusterArn":"arn:aws:ecs:ap-northeast-1:123456789012:cluster/default2","status":"ACTIVE","desiredCount":2,"runningCount":1,"pendingCount":1,"taskDefinition":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:2","deployments":[{"id":"ecs-svc/2","status":"PRIMARY","taskDefinition":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:2","desiredCount":2,"runningCount":1,"pendingCount":1,"rolloutState":"IN_PROGRESS"},{"id":"ecs-svc/1","status":"ACTIVE","taskDefinition":"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/test:1","desiredCount":0,"runningCount":1,"rolloutState":"COMPLETED"}]}],"failures":[]}`)
+	b := new(bytes.Buffer)
+	if err := app.OutputStatusJSON(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	var st map[string]any
+	if err := json.Unmarshal(b.Bytes(), &st); err != nil {
+		t.Fatalf("invalid JSON: %s\n%s", err, b.String())
+	}
+	for _, key := range []string{"exists", "cluster", "service", "status", "task_definition", "desired_count", "running_count", "pending_count", "deployments"} {
+		if _, ok := st[key]; !ok {
+			t.Errorf("%s is not found in %s", key, b.String())
+		}
+	}
+	if st["exists"] != true || st["cluster"] != "default2" || st["running_count"] != float64(1) {
+		t.Errorf("unexpected status: %s", b.String())
+	}
+	deps, _ := st["deployments"].([]any)
+	if len(deps) != 2 {
+		t.Fatalf("unexpected deployments: %s", b.String())
+	}
+	primary := deps[0].(map[string]any)
+	if primary["status"] != "PRIMARY" || primary["rollout_state"] != "IN_PROGRESS" || primary["id"] != "ecs-svc/2" {
+		t.Errorf("unexpected primary deployment: %v", primary)
+	}
+}
+
+func TestOutputStatusJSONNotExists(t *testing.T) {
+	app := newStatusApp(t, `{"services":[],"failures":[{"arn":"arn:aws:ecs:ap-northeast-1:123456789012:service/default2/test","reason":"MISSING"}]}`)
+	b := new(bytes.Buffer)
+	if err := app.OutputStatusJSON(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	var st map[string]any
+	if err := json.Unmarshal(b.Bytes(), &st); err != nil {
+		t.Fatalf("invalid JSON: %s\n%s", err, b.String())
+	}
+	if st["exists"] != false || st["service"] != "test" {
+		t.Errorf("unexpected status: %s", b.String())
+	}
+	if deps, ok := st["deployments"].([]any); !ok || len(deps) != 0 {
+		t.Errorf("deployments must be an empty array: %s", b.String())
+	}
+}
@@ -0,0 +1,55 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2Types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// describeSubnets describes the subnets by EC2 DescribeSubnets API.
+func (d *App) describeSubnets(ctx context.Context, ids []string) ([]ec2Types.Subnet, error) {
+	out, err := ec2.NewFromConfig(d.config.awsv2Config).DescribeSubnets(ctx, &ec2.DescribeSubnetsInput{
+		SubnetIds: ids,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe subnets: %w", err)
+	}
+	return out.Subnets, nil
+}
+
+// subnetsInAvailabilityZone returns IDs of the subnets in the availability zone.
+// az is a name (e.g. us-east-1a) or an ID (e.g. use1-az1) of the availability zone.
+func subnetsInAvailabilityZone(subnets []ec2Types.Subnet, az string) []string {
+	var ids []string
+	for _, s := range subnets {
+		if aws.ToString(s.AvailabilityZone) == az || aws.ToString(s.AvailabilityZoneId) == az {
+			ids = append(ids, aws.ToString(s.SubnetId))
+		}
+	}
+	return ids
+}
+
+// networkConfigurationInAvailabilityZone returns a copy of the network configuration
+// which has only the subnets in the availability zone.
+func (d *App) networkConfigurationInAvailabilityZone(ctx context.Context, nc *types.NetworkConfiguration, az string) (*types.NetworkConfiguration, error) {
+	if nc == nil || nc.AwsvpcConfiguration == nil || len(nc.AwsvpcConfiguration.Subnets) == 0 {
+		return nil, fmt.Errorf("--availability-zone requires subnets in networkConfiguration.awsvpcConfiguration of the service definition")
+	}
+	subnets, err := d.describeSubnets(ctx, nc.AwsvpcConfiguration.Subnets)
+	if err != nil {
+		return nil, err
+	}
+	ids := subnetsInAvailabilityZone(subnets, az)
+	if len(ids) == 0 {
+		return nil, fmt.Errorf("no subnets in the availability zone %s: %s", az, strings.Join(nc.AwsvpcConfiguration.Subnets, ", "))
+	}
+	d.Log("Subnets in %s: %s", az, strings.Join(ids, ", "))
+	vpc := *nc.AwsvpcConfiguration
+	vpc.Subnets = ids
+	return &types.NetworkConfiguration{AwsvpcConfiguration: &vpc}, nil
+}
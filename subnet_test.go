@@ -0,0 +1,91 @@
+package ecspresso_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+const describeSubnetsResponse = `<?xml version="1.0" encoding="UTF-8"?>
+<DescribeSubnetsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/">
+  <requestId>7a62c49f-347e-4fc4-9331-6e8eEXAMPLE</requestId>
+  <subnetSet>
+    <item>
+      <subnetId>subnet-aaa</subnetId>
+      <availabilityZone>ap-northeast-1a</availabilityZone>
+      <availabilityZoneId>apne1-az4</availabilityZoneId>
+    </item>
+    <item>
+      <subnetId>subnet-ccc</subnetId>
+      <availabilityZone>ap-northeast-1c</availabilityZone>
+      <availabilityZoneId>apne1-az1</availabilityZoneId>
+    </item>
+    <item>
+      <subnetId>subnet-aaa2</subnetId>
+      <availabilityZone>ap-northeast-1a</availabilityZone>
+      <availabilityZoneId>apne1-az4</availabilityZoneId>
+    </item>
+  </subnetSet>
+</DescribeSubnetsResponse>`
+
+func TestNetworkConfigurationInAvailabilityZone(t *testing.T) {
+	var form map[string][]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+		}
+		form = r.PostForm
+		w.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+		w.Write([]byte(describeSubnetsResponse))
+	}))
+	defer ts.Close()
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIAEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_ENDPOINT_URL_EC2", ts.URL)
+
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/test.yaml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc := &types.NetworkConfiguration{
+		AwsvpcConfiguration: &types.AwsVpcConfiguration{
+			Subnets:        []string{"subnet-aaa", "subnet-ccc", "subnet-aaa2"},
+			SecurityGroups: []string{"sg-111"},
+			AssignPublicIp: types.AssignPublicIpDisabled,
+		},
+	}
+	for _, az := range []string{"ap-northeast-1a", "apne1-az4"} {
+		got, err := app.NetworkConfigurationInAvailabilityZone(ctx, nc, az)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"subnet-aaa", "subnet-aaa2"}, got.AwsvpcConfiguration.Subnets); diff != "" {
+			t.Errorf("unexpected subnets in %s: %s", az, diff)
+		}
+		if diff := cmp.Diff([]string{"sg-111"}, got.AwsvpcConfiguration.SecurityGroups); diff != "" {
+			t.Errorf("unexpected security groups: %s", diff)
+		}
+		if got.AwsvpcConfiguration.AssignPublicIp != types.AssignPublicIpDisabled {
+			t.Errorf("unexpected assignPublicIp %s", got.AwsvpcConfiguration.AssignPublicIp)
+		}
+	}
+	if form["Action"][0] != "DescribeSubnets" || form["SubnetId.3"][0] != "subnet-aaa2" {
+		t.Errorf("unexpected request %v", form)
+	}
+	if n := len(nc.AwsvpcConfiguration.Subnets); n != 3 {
+		t.Errorf("the original network configuration must not be modified: %d subnets", n)
+	}
+
+	if _, err := app.NetworkConfigurationInAvailabilityZone(ctx, nc, "ap-northeast-1d"); err == nil {
+		t.Error("expected error for the availability zone without subnets")
+	}
+	if _, err := app.NetworkConfigurationInAvailabilityZone(ctx, &types.NetworkConfiguration{}, "ap-northeast-1a"); err == nil {
+		t.Error("expected error for the service without awsvpcConfiguration")
+	}
+}
@@ -0,0 +1,88 @@
+package ecspresso
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+)
+
+// resolveTargetGroupNames resolves loadBalancers[].targetGroupName in the service definition to targetGroupArn.
+// When both of targetGroupName and targetGroupArn are defined, targetGroupArn is used.
+func (d *App) resolveTargetGroupNames(ctx context.Context, src []byte) ([]byte, error) {
+	return replaceTargetGroupNames(src, func(i int, name string) (string, error) {
+		arn, err := d.targetGroupArnByName(ctx, name)
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve loadBalancers[%d].targetGroupName: %w", i, err)
+		}
+		d.Log("[DEBUG] loadBalancers[%d] targetGroupName %s is resolved to %s", i, name, arn)
+		return arn, nil
+	})
+}
+
+// stripTargetGroupNames removes loadBalancers[].targetGroupName in the service definition without resolving.
+func (d *App) stripTargetGroupNames(src []byte) ([]byte, error) {
+	return replaceTargetGroupNames(src, func(i int, name string) (string, error) {
+		d.Log("[DEBUG] loadBalancers[%d] targetGroupName %s is not resolved", i, name)
+		return "", nil
+	})
+}
+
+// replaceTargetGroupNames removes loadBalancers[].targetGroupName and sets targetGroupArn returned by resolve.
+// resolve is not called when targetGroupArn is defined. An empty ARN is not set.
+func replaceTargetGroupNames(src []byte, resolve func(i int, name string) (string, error)) ([]byte, error) {
+	if !bytes.Contains(src, []byte(`"targetGroupName"`)) {
+		return src, nil
+	}
+	var def map[string]interface{}
+	if err := json.Unmarshal(src, &def); err != nil {
+		return nil, err
+	}
+	lbs, ok := def["loadBalancers"].([]interface{})
+	if !ok {
+		return src, nil
+	}
+	var replaced bool
+	for i, v := range lbs {
+		lb, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, ok := lb["targetGroupName"].(string)
+		if !ok {
+			continue
+		}
+		delete(lb, "targetGroupName")
+		replaced = true
+		if arn, ok := lb["targetGroupArn"].(string); ok && arn != "" {
+			Log("[DEBUG] loadBalancers[%d] targetGroupArn %s is used instead of targetGroupName %s", i, arn, name)
+			continue
+		}
+		arn, err := resolve(i, name)
+		if err != nil {
+			return nil, err
+		}
+		if arn != "" {
+			lb["targetGroupArn"] = arn
+		}
+	}
+	if !replaced {
+		return src, nil
+	}
+	return json.Marshal(def)
+}
+
+func (d *App) targetGroupArnByName(ctx context.Context, name string) (string, error) {
+	out, err := d.elbv2.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
+		Names: []string{name},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to describe target group %s: %w", name, err)
+	}
+	if len(out.TargetGroups) == 0 {
+		return "", ErrNotFound(fmt.Sprintf("target group %s is not found", name))
+	}
+	return *out.TargetGroups[0].TargetGroupArn, nil
+}
@@ -0,0 +1,80 @@
+package ecspresso_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestResolveTargetGroupNamesWithoutLookup(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// no targetGroupName
+	src := []byte(`{"loadBalancers":[{"targetGroupArn":"arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/app/0123456789abcdef","containerName":"app","containerPort":80}]}`)
+	b, err := app.ResolveTargetGroupNames(ctx, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(src) {
+		t.Errorf("unexpected changes: %s", b)
+	}
+
+	// targetGroupArn is preferred to targetGroupName
+	src = []byte(`{"loadBalancers":[{"targetGroupName":"app","targetGroupArn":"arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/app/0123456789abcdef","containerName":"app","containerPort":80}]}`)
+	b, err = app.ResolveTargetGroupNames(ctx, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var def map[string]interface{}
+	if err := json.Unmarshal(b, &def); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"loadBalancers": []interface{}{
+			map[string]interface{}{
+				"targetGroupArn": "arn:aws:elasticloadbalancing:ap-northeast-1:123456789012:targetgroup/app/0123456789abcdef",
+				"containerName":  "app",
+				"containerPort":  float64(80),
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, def); diff != "" {
+		t.Errorf("unexpected resolved definition: %s", diff)
+	}
+}
+
+func TestStripTargetGroupNames(t *testing.T) {
+	ctx := context.Background()
+	app, err := ecspresso.New(ctx, &ecspresso.CLIOptions{ConfigFilePath: "tests/td-config.yml"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// targetGroupName is removed without lookup
+	src := []byte(`{"loadBalancers":[{"targetGroupName":"app","containerName":"app","containerPort":80}]}`)
+	b, err := app.StripTargetGroupNames(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var def map[string]interface{}
+	if err := json.Unmarshal(b, &def); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"loadBalancers": []interface{}{
+			map[string]interface{}{
+				"containerName": "app",
+				"containerPort": float64(80),
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, def); diff != "" {
+		t.Errorf("unexpected stripped definition: %s", diff)
+	}
+}
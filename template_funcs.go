@@ -0,0 +1,29 @@
+package ecspresso
+
+import (
+	"fmt"
+	"os"
+	"text/template"
+)
+
+// DefaultTemplateFuncs returns the template functions available in all files read by go-config.
+// They replace env and must_env of go-config, and must_env fails with an error instead of a panic.
+func DefaultTemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"env": func(name string, defaults ...string) string {
+			if v := os.Getenv(name); v != "" {
+				return v
+			}
+			if len(defaults) > 0 {
+				return defaults[0]
+			}
+			return ""
+		},
+		"must_env": func(name string) (string, error) {
+			if v, ok := os.LookupEnv(name); ok {
+				return v, nil
+			}
+			return "", fmt.Errorf("must_env: environment variable %s is not set", name)
+		},
+	}
+}
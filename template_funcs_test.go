@@ -0,0 +1,103 @@
+package ecspresso_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+var testCaseTemplateFuncs = []struct {
+	name        string
+	src         string
+	env         map[string]string
+	expected    string
+	errExpected string
+}{
+	{
+		name:     "env set",
+		src:      `{{ env "ECSPRESSO_TEST_REGION" "us-east-1" }}`,
+		env:      map[string]string{"ECSPRESSO_TEST_REGION": "ap-northeast-1"},
+		expected: "ap-northeast-1",
+	},
+	{
+		name:     "env unset",
+		src:      `{{ env "ECSPRESSO_TEST_REGION" "us-east-1" }}`,
+		expected: "us-east-1",
+	},
+	{
+		name:     "env unset without default",
+		src:      `{{ env "ECSPRESSO_TEST_REGION" }}`,
+		expected: "",
+	},
+	{
+		name:     "must_env set",
+		src:      `{{ must_env "ECSPRESSO_TEST_IMAGE_TAG" }}`,
+		env:      map[string]string{"ECSPRESSO_TEST_IMAGE_TAG": "v1.2.3"},
+		expected: "v1.2.3",
+	},
+	{
+		name:        "must_env unset",
+		src:         `{{ must_env "ECSPRESSO_TEST_IMAGE_TAG" }}`,
+		errExpected: "must_env: environment variable ECSPRESSO_TEST_IMAGE_TAG is not set",
+	},
+}
+
+func TestDefaultTemplateFuncs(t *testing.T) {
+	for _, c := range testCaseTemplateFuncs {
+		t.Run(c.name, func(t *testing.T) {
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+			tmpl := template.Must(template.New(c.name).Funcs(ecspresso.DefaultTemplateFuncs()).Parse(c.src))
+			var b bytes.Buffer
+			err := tmpl.Execute(&b, nil)
+			if c.errExpected != "" {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				if !strings.Contains(err.Error(), c.errExpected) {
+					t.Errorf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != c.expected {
+				t.Errorf("expected %q, got %q", c.expected, b.String())
+			}
+		})
+	}
+}
+
+func TestLoadConfigWithMustEnvUnset(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "ecspresso.yml")
+	src := "region: '{{ env `ECSPRESSO_TEST_REGION` `ap-northeast-1` }}'\ncluster: default\nservice: '{{ must_env `ECSPRESSO_TEST_SERVICE` }}'\ntask_definition: td.json\n"
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	t.Setenv("ECSPRESSO_TEST_SERVICE", "web")
+	conf, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, path, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Region != "ap-northeast-1" || conf.Service != "web" {
+		t.Errorf("unexpected region and service %s %s", conf.Region, conf.Service)
+	}
+
+	os.Unsetenv("ECSPRESSO_TEST_SERVICE")
+	if _, err := ecspresso.NewConfigLoader(nil, nil).Load(ctx, path, ""); err == nil {
+		t.Error("expected error for unset ECSPRESSO_TEST_SERVICE")
+	} else if !strings.Contains(err.Error(), "ECSPRESSO_TEST_SERVICE is not set") {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
@@ -0,0 +1,3 @@
+{
+  region: 'ap-northeast-1',
+}
@@ -0,0 +1,104 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const tracerName = "github.com/kayac/ecspresso/v2"
+
+type tracerKey struct{}
+
+var noopTracer = noop.NewTracerProvider().Tracer(tracerName)
+
+// envCarrier is a propagation.TextMapCarrier reading TRACEPARENT and TRACESTATE environment variables.
+type envCarrier struct{}
+
+func (envCarrier) Get(key string) string {
+	return os.Getenv(strings.ToUpper(key))
+}
+
+func (envCarrier) Set(key, value string) {}
+
+func (envCarrier) Keys() []string {
+	return []string{"traceparent", "tracestate"}
+}
+
+// otelEnabled reports whether tracing is enabled by the option or OTEL_* environment variables.
+func otelEnabled(opt bool) bool {
+	if opt {
+		return true
+	}
+	for _, name := range []string{"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"} {
+		if os.Getenv(name) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// setupTracing installs a TracerProvider which exports spans by OTLP over HTTP when tracing is enabled.
+// The exporter is configured by OTEL_EXPORTER_OTLP_* environment variables.
+// The returned function flushes the spans and shuts down the provider, it must be called on exit.
+func setupTracing(ctx context.Context, enabled bool) (context.Context, func(context.Context) error, error) {
+	if !otelEnabled(enabled) {
+		return ctx, func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return ctx, nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+	// OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES take precedence
+	resource, err := sdkresource.New(ctx,
+		sdkresource.WithAttributes(attribute.String("service.name", "ecspresso"), attribute.String("service.version", Version)),
+		sdkresource.WithFromEnv(),
+	)
+	if err != nil {
+		return ctx, nil, fmt.Errorf("failed to create OpenTelemetry resource: %w", err)
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource),
+	)
+	otel.SetTracerProvider(tp)
+	return withTracer(ctx, tp.Tracer(tracerName)), tp.Shutdown, nil
+}
+
+// withTracer returns a context which has the tracer and the parent trace context propagated from the environment.
+func withTracer(ctx context.Context, tracer trace.Tracer) context.Context {
+	ctx = propagation.TraceContext{}.Extract(ctx, envCarrier{})
+	return context.WithValue(ctx, tracerKey{}, tracer)
+}
+
+func tracerFromContext(ctx context.Context) trace.Tracer {
+	if t, ok := ctx.Value(tracerKey{}).(trace.Tracer); ok {
+		return t
+	}
+	return noopTracer
+}
+
+// startSpan starts a span named name by the tracer in ctx.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return tracerFromContext(ctx).Start(ctx, name)
+}
+
+// endSpan ends the span with the status of err.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
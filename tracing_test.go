@@ -0,0 +1,80 @@
+package ecspresso_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/kayac/ecspresso/v2"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func TestWithTracerPropagation(t *testing.T) {
+	t.Setenv("TRACEPARENT", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	// disabled
+	_, span := ecspresso.StartSpan(context.Background(), "test")
+	if span.SpanContext().IsValid() {
+		t.Errorf("span must not be valid when tracing is disabled: %v", span.SpanContext())
+	}
+	span.End()
+
+	// enabled
+	tp := sdktrace.NewTracerProvider()
+	defer tp.Shutdown(context.Background())
+	ctx := ecspresso.WithTracer(context.Background(), tp.Tracer("test"))
+	_, span = ecspresso.StartSpan(ctx, "test")
+	defer span.End()
+	if s := span.SpanContext().TraceID().String(); s != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("unexpected trace id: %s", s)
+	}
+}
+
+func TestSetupTracingExportsSpans(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", ts.URL)
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+
+	ctx, shutdown, err := ecspresso.SetupTracing(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := ecspresso.StartSpan(ctx, "test")
+	if !span.SpanContext().IsValid() {
+		t.Error("span must be valid when tracing is enabled")
+	}
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(paths) == 0 || paths[0] != "/v1/traces" {
+		t.Errorf("spans must be exported to /v1/traces, got %v", paths)
+	}
+}
+
+func TestSetupTracingDisabled(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	ctx, shutdown, err := ecspresso.SetupTracing(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shutdown(context.Background())
+	_, span := ecspresso.StartSpan(ctx, "test")
+	defer span.End()
+	if span.SpanContext().IsValid() {
+		t.Errorf("span must not be valid when tracing is disabled: %v", span.SpanContext())
+	}
+}
@@ -0,0 +1,200 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codedeploy"
+	cdTypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/mattn/go-shellwords"
+)
+
+var (
+	deploymentReadyCheckInterval = 10 * time.Second
+	trafficValidationInterval    = 5 * time.Second
+	trafficValidationTimeout     = 3 * time.Minute
+)
+
+// trafficValidation validates the replacement task set of the CodeDeploy deployment once.
+type trafficValidation func(ctx context.Context, deploymentID string) error
+
+// trafficValidation returns nil without --pause-before-traffic.
+func (opt DeployOption) trafficValidation() (trafficValidation, error) {
+	switch {
+	case !opt.PauseBeforeTraffic:
+		if opt.ValidateURL != "" || opt.ValidateCommand != "" {
+			return nil, ErrConflictOptions("validate-url and validate-command require pause-before-traffic")
+		}
+		return nil, nil
+	case !opt.Wait:
+		return nil, ErrConflictOptions("pause-before-traffic and no-wait are exclusive")
+	case opt.ValidateURL != "" && opt.ValidateCommand != "":
+		return nil, ErrConflictOptions("validate-url and validate-command are exclusive")
+	case opt.ValidateURL != "":
+		return httpTrafficValidation(opt.ValidateURL), nil
+	case opt.ValidateCommand != "":
+		args, err := shellwords.Parse(opt.ValidateCommand)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validate command: %w", err)
+		}
+		if len(args) == 0 {
+			return nil, fmt.Errorf("invalid validate command: %s", opt.ValidateCommand)
+		}
+		return commandTrafficValidation(args), nil
+	default:
+		return nil, errors.New("--pause-before-traffic requires --validate-url or --validate-command")
+	}
+}
+
+// httpTrafficValidation requests the URL and expects a 2xx status.
+func httpTrafficValidation(u string) trafficValidation {
+	return func(ctx context.Context, _ string) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return fmt.Errorf("invalid validate url: %w", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to request %s: %w", u, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || 300 <= resp.StatusCode {
+			return fmt.Errorf("%s returned status %d", u, resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// commandTrafficValidation runs the command and expects exit status 0.
+// CODEDEPLOY_DEPLOYMENT_ID environment variable is set for the command.
+func commandTrafficValidation(args []string) trafficValidation {
+	return func(ctx context.Context, deploymentID string) error {
+		cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+		cmd.Env = append(os.Environ(), "CODEDEPLOY_DEPLOYMENT_ID="+deploymentID)
+		cmd.Stdout = os.Stderr
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("validate command failed: %w", err)
+		}
+		return nil
+	}
+}
+
+// requireDeploymentReadyWait checks the deployment group waits for rerouting traffic until ContinueDeployment.
+func (d *App) requireDeploymentReadyWait(ctx context.Context) error {
+	dp, err := d.findDeploymentInfo(ctx)
+	if err != nil {
+		return err
+	}
+	if bg := dp.BlueGreenDeploymentConfiguration; bg != nil && bg.DeploymentReadyOption != nil {
+		if ro := bg.DeploymentReadyOption; ro.ActionOnTimeout == cdTypes.DeploymentReadyActionStopDeployment {
+			d.Log("[INFO] the deployment will be stopped unless the validation completes in %d minutes", ro.WaitTimeInMinutes)
+			return nil
+		}
+	}
+	return fmt.Errorf(
+		"--pause-before-traffic requires actionOnTimeout STOP_DEPLOYMENT in deploymentReadyOption of the deployment group %s",
+		aws.ToString(dp.DeploymentGroupName),
+	)
+}
+
+// waitForCodeDeployWithValidation waits for the replacement task set of the deployment to be ready,
+// validates it, and then continues the deployment to shift traffic or stops it with rollback.
+func (d *App) waitForCodeDeployWithValidation(validate trafficValidation) waitFunc {
+	return func(ctx context.Context, sv *Service) error {
+		dpID, err := d.findCodeDeployDeploymentInProgress(ctx)
+		if err != nil {
+			return err
+		}
+		d.Log("Waiting for the replacement task set of the deployment %s to be ready", dpID)
+		if err := d.waitDeploymentReady(ctx, dpID); err != nil {
+			return err
+		}
+
+		d.Log("Validating the replacement task set before shifting traffic")
+		if verr := d.validateReplacement(ctx, dpID, validate); verr != nil {
+			d.Log("Validation failed. Stopping the deployment %s with rollback", dpID)
+			// ctx for the deployment may be already done
+			sctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			if _, err := d.codedeploy.StopDeployment(sctx, &codedeploy.StopDeploymentInput{
+				DeploymentId:        aws.String(dpID),
+				AutoRollbackEnabled: aws.Bool(true),
+			}); err != nil {
+				return fmt.Errorf("failed to stop the deployment %s: %w (validation failed: %s)", dpID, err, verr)
+			}
+			return fmt.Errorf("the deployment %s is stopped and rolled back: %w", dpID, verr)
+		}
+
+		d.Log("Validation passed. Continuing the deployment %s to shift traffic", dpID)
+		if _, err := d.codedeploy.ContinueDeployment(ctx, &codedeploy.ContinueDeploymentInput{
+			DeploymentId:       aws.String(dpID),
+			DeploymentWaitType: cdTypes.DeploymentWaitTypeReadyWait,
+		}); err != nil {
+			return fmt.Errorf("failed to continue the deployment %s: %w", dpID, err)
+		}
+		return d.WaitForCodeDeploy(ctx, sv)
+	}
+}
+
+// waitDeploymentReady waits until the deployment becomes Ready, which is waiting for ContinueDeployment.
+func (d *App) waitDeploymentReady(ctx context.Context, dpID string) error {
+	poller := newWaitPoller(ctx, deploymentReadyCheckInterval)
+	for {
+		out, err := d.codedeploy.GetDeployment(ctx, &codedeploy.GetDeploymentInput{
+			DeploymentId: aws.String(dpID),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to get deployment %s: %w", dpID, err)
+		}
+		done, err := deploymentReady(out.DeploymentInfo)
+		if done || err != nil {
+			return err
+		}
+		d.Log("[DEBUG] deployment %s is %s", dpID, out.DeploymentInfo.Status)
+		if err := poller.wait(ctx); err != nil {
+			return fmt.Errorf("the deployment %s is not ready: %w", dpID, err)
+		}
+	}
+}
+
+// deploymentReady returns true when the deployment is Ready,
+// and an error when the deployment is finished without waiting for the validation.
+func deploymentReady(dep *cdTypes.DeploymentInfo) (bool, error) {
+	id := aws.ToString(dep.DeploymentId)
+	switch dep.Status {
+	case cdTypes.DeploymentStatusReady:
+		return true, nil
+	case cdTypes.DeploymentStatusSucceeded:
+		return false, fmt.Errorf("the deployment %s succeeded before the validation. traffic is already shifted", id)
+	case cdTypes.DeploymentStatusFailed, cdTypes.DeploymentStatusStopped:
+		if info := dep.ErrorInformation; info != nil {
+			return false, fmt.Errorf("the deployment %s is %s: %s %s", id, dep.Status, info.Code, aws.ToString(info.Message))
+		}
+		return false, fmt.Errorf("the deployment %s is %s", id, dep.Status)
+	}
+	return false, nil
+}
+
+func (d *App) validateReplacement(ctx context.Context, dpID string, validate trafficValidation) error {
+	vctx, cancel := context.WithTimeout(ctx, trafficValidationTimeout)
+	defer cancel()
+	for {
+		err := validate(vctx, dpID)
+		if err == nil {
+			return nil
+		}
+		d.Log("[INFO] validation: %s", err)
+		select {
+		case <-vctx.Done():
+			return fmt.Errorf("the replacement task set is not valid: %w", err)
+		case <-time.After(trafficValidationInterval):
+		}
+	}
+}
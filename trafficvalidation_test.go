@@ -0,0 +1,74 @@
+package ecspresso_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cdTypes "github.com/aws/aws-sdk-go-v2/service/codedeploy/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestValidateTraffic(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	tests := []struct {
+		opt     ecspresso.DeployOption
+		isError bool
+	}{
+		{opt: ecspresso.DeployOption{PauseBeforeTraffic: true, Wait: true, ValidateURL: ts.URL + "/health"}},
+		{opt: ecspresso.DeployOption{PauseBeforeTraffic: true, Wait: true, ValidateURL: ts.URL + "/unhealthy"}, isError: true},
+		{opt: ecspresso.DeployOption{PauseBeforeTraffic: true, Wait: true, ValidateCommand: `sh -c 'test "$CODEDEPLOY_DEPLOYMENT_ID" = d-EXAMPLE'`}},
+		{opt: ecspresso.DeployOption{PauseBeforeTraffic: true, Wait: true, ValidateCommand: "false"}, isError: true},
+		{opt: ecspresso.DeployOption{PauseBeforeTraffic: true, Wait: true, ValidateURL: ts.URL, ValidateCommand: "true"}, isError: true},
+		{opt: ecspresso.DeployOption{PauseBeforeTraffic: true, Wait: true}, isError: true},
+		{opt: ecspresso.DeployOption{PauseBeforeTraffic: true, Wait: false, ValidateCommand: "true"}, isError: true},
+		{opt: ecspresso.DeployOption{Wait: true, ValidateCommand: "true"}, isError: true},
+		{opt: ecspresso.DeployOption{Wait: true}},
+	}
+	for i, tt := range tests {
+		err := ecspresso.ValidateTraffic(ctx, tt.opt, "d-EXAMPLE")
+		if tt.isError && err == nil {
+			t.Errorf("[%d] expected error, but got nil", i)
+		} else if !tt.isError && err != nil {
+			t.Errorf("[%d] unexpected error: %s", i, err)
+		}
+	}
+}
+
+func TestDeploymentReady(t *testing.T) {
+	tests := []struct {
+		status  cdTypes.DeploymentStatus
+		ready   bool
+		isError bool
+	}{
+		{status: cdTypes.DeploymentStatusCreated},
+		{status: cdTypes.DeploymentStatusInProgress},
+		{status: cdTypes.DeploymentStatusReady, ready: true},
+		{status: cdTypes.DeploymentStatusSucceeded, isError: true},
+		{status: cdTypes.DeploymentStatusFailed, isError: true},
+		{status: cdTypes.DeploymentStatusStopped, isError: true},
+	}
+	for _, tt := range tests {
+		ready, err := ecspresso.DeploymentReady(&cdTypes.DeploymentInfo{
+			DeploymentId: aws.String("d-EXAMPLE"),
+			Status:       tt.status,
+		})
+		if ready != tt.ready {
+			t.Errorf("%s: expected ready %t, got %t", tt.status, tt.ready, ready)
+		}
+		if tt.isError && err == nil {
+			t.Errorf("%s: expected error, but got nil", tt.status)
+		} else if !tt.isError && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.status, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package ecspresso
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -39,10 +40,63 @@ func (d *App) readDefinitionFile(path string) ([]byte, error) {
 			return nil, err
 		}
 		return d.loader.ReadWithEnvBytes([]byte(jsonStr))
+	case tomlExt:
+		src, err := d.readTemplateFile(path)
+		if err != nil {
+			return nil, err
+		}
+		b, err := tomlToJSON(src)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		return b, nil
+	}
+	return d.readTemplateFile(path)
+}
+
+// readTemplateFile reads the file and expands the template.
+func (d *App) readTemplateFile(path string) ([]byte, error) {
+	if delims := d.config.TemplateDelimiters; len(delims) == 2 {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if src, err = convertTemplateDelims(src, delims[0], delims[1]); err != nil {
+			return nil, fmt.Errorf("failed to convert template delimiters in %s: %w", path, err)
+		}
+		return d.loader.ReadWithEnvBytes(src)
 	}
 	return d.loader.ReadWithEnv(path)
 }
 
+// convertTemplateDelims converts template actions delimited by left and right to the default delimiters.
+// Literal "{{" and "}}" are escaped to be output as is.
+func convertTemplateDelims(src []byte, left, right string) ([]byte, error) {
+	s := string(src)
+	var b strings.Builder
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, left):
+			end := strings.Index(s[len(left):], right)
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed template action %s", left)
+			}
+			b.WriteString("{{" + s[len(left):len(left)+end] + "}}")
+			s = s[len(left)+end+len(right):]
+		case strings.HasPrefix(s, "{{"):
+			b.WriteString(`{{"{{"}}`)
+			s = s[2:]
+		case strings.HasPrefix(s, "}}"):
+			b.WriteString(`{{"}}"}}`)
+			s = s[2:]
+		default:
+			b.WriteByte(s[0])
+			s = s[1:]
+		}
+	}
+	return []byte(b.String()), nil
+}
+
 func parseTags(s string) ([]types.Tag, error) {
 	tags := make([]types.Tag, 0)
 	if s == "" {
@@ -140,3 +194,28 @@ func serviceVolumeConfigurationsToTask(vcs []types.ServiceVolumeConfiguration, d
 	}
 	return tvc
 }
+
+// writeFileAtomic writes the data to a temporary file in the same directory and renames it to the path,
+// so the readers of the path never see a partially written file.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file for %s: %w", path, err)
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp) // no-op after renamed
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", tmp, err)
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		return fmt.Errorf("failed to chmod %s: %w", tmp, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %w", tmp, path, err)
+	}
+	return nil
+}
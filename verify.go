@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
 	"regexp"
 	"strconv"
 	"strings"
@@ -26,7 +28,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/fatih/color"
+	goVersion "github.com/hashicorp/go-version"
 	"github.com/kayac/ecspresso/v2/registry"
+	"github.com/samber/lo"
 )
 
 type verifier struct {
@@ -52,7 +56,7 @@ func newVerifier(execCfg, appCfg *aws.Config, opt *VerifyOption) *verifier {
 		ecr: map[string]*ecr.Client{
 			execCfg.Region: ecr.NewFromConfig(*execCfg),
 		},
-		s3:        s3.NewFromConfig(*appCfg),
+		s3:        s3.NewFromConfig(*execCfg, s3PathStyle(*execCfg)), // environment files are read by the execution role
 		opt:       opt,
 		isAssumed: execCfg != appCfg,
 		execCfg:   execCfg,
@@ -71,6 +75,9 @@ func (v *verifier) ecrClient(region string) *ecr.Client {
 }
 
 func (v *verifier) existsSecretValue(ctx context.Context, from string) error {
+	if err := v.opt.skipped("secret"); err != nil {
+		return err
+	}
 	if !v.opt.GetSecrets {
 		return ErrSkipVerify(fmt.Sprintf("get a secret value for %s", from))
 	}
@@ -129,8 +136,11 @@ func (v *verifier) existsSecretValue(ctx context.Context, from string) error {
 	return nil
 }
 
-func (v *verifier) existsEnvironmentFile(ctx context.Context, envFile types.EnvironmentFile) error {
-	if envFile.Type != types.EnvironmentFileTypeS3 {
+func (v *verifier) existsEnvironmentFile(ctx context.Context, container string, envFile types.EnvironmentFile) error {
+	if err := v.opt.skipped("environment-file"); err != nil {
+		return err
+	}
+	if envFile.Type != "" && envFile.Type != types.EnvironmentFileTypeS3 {
 		return ErrSkipVerify("unsupported environment file type: " + string(envFile.Type))
 	}
 	s3arn := aws.ToString(envFile.Value)
@@ -150,7 +160,7 @@ func (v *verifier) existsEnvironmentFile(ctx context.Context, envFile types.Envi
 		Bucket: &bucket,
 		Key:    &key,
 	}); err != nil {
-		return fmt.Errorf("failed to head s3 object %s: %w", s3arn, err)
+		return fmt.Errorf("container %s can not read environment file s3://%s/%s: %w", container, bucket, key, err)
 	}
 	return nil
 }
@@ -172,6 +182,7 @@ func (d *App) newAssumedVerifier(ctx context.Context, cfg aws.Config, executionR
 	d.Log("[INFO] success to assume role: %s", aws.ToString(executionRole))
 	ec := aws.Config{}
 	ec.Region = d.config.Region
+	ec.BaseEndpoint = cfg.BaseEndpoint
 	ec.Credentials = credentials.NewStaticCredentialsProvider(
 		aws.ToString(out.Credentials.AccessKeyId),
 		aws.ToString(out.Credentials.SecretAccessKey),
@@ -182,16 +193,60 @@ func (d *App) newAssumedVerifier(ctx context.Context, cfg aws.Config, executionR
 
 // VerifyOption represents options for Verify()
 type VerifyOption struct {
-	GetSecrets bool `help:"get secrets from ParameterStore or SecretsManager" default:"true" negatable:""`
-	PutLogs    bool `help:"put logs to CloudWatchLogs" default:"true" negatable:""`
-	Cache      bool `help:"use cache" default:"true" negatable:""`
+	GetSecrets bool     `help:"get secrets from ParameterStore or SecretsManager" default:"true" negatable:""`
+	PutLogs    bool     `help:"put logs to CloudWatchLogs" default:"true" negatable:""`
+	Cache      bool     `help:"use cache" default:"true" negatable:""`
+	Skip       []string `help:"skip checks (role,image,secret,log,environment-file,load-balancer,network,platform-version,cluster,deployment-controller)"`
+
+	CheckPermissions bool `help:"check IAM permissions of the current identity required by the commands" default:"false"`
+}
+
+// verifyChecks are the names of checks which can be skipped by VerifyOption.Skip.
+var verifyChecks = []string{
+	"role",
+	"image",
+	"secret",
+	"log",
+	"environment-file",
+	"load-balancer",
+	"network",
+	"platform-version",
+	"cluster",
+	"deployment-controller",
+}
+
+func (opt *VerifyOption) validate() error {
+	for _, check := range opt.Skip {
+		if !lo.Contains(verifyChecks, check) {
+			return fmt.Errorf("unknown check %s to skip. available checks are %s", check, strings.Join(verifyChecks, ","))
+		}
+	}
+	return nil
+}
+
+// skipped returns ErrSkipVerify when the check is skipped.
+func (opt *VerifyOption) skipped(check string) error {
+	if lo.Contains(opt.Skip, check) {
+		return ErrSkipVerify(fmt.Sprintf("%s check is skipped", check))
+	}
+	return nil
 }
 
 type verifyResourceFunc func(context.Context) error
 
 // Verify verifies service / task definitions related resources are valid.
 func (d *App) Verify(ctx context.Context, opt VerifyOption) error {
+	return d.verify(ctx, opt, false)
+}
+
+// verify verifies resources. When keepGoing is true, it continues to verify after failures and reports all of them.
+func (d *App) verify(ctx context.Context, opt VerifyOption, keepGoing bool) error {
+	if err := opt.validate(); err != nil {
+		return err
+	}
 	initVerifyState(opt.Cache)
+	verifyState.keepGoing = keepGoing
+	verifyState.w = d.stdout
 
 	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
 	if err != nil {
@@ -213,22 +268,39 @@ func (d *App) Verify(ctx context.Context, opt VerifyOption) error {
 		{name: "TaskDefinition", fn: d.verifyTaskDefinition},
 		{name: "ServiceDefinition", fn: d.verifyServiceDefinition},
 		{name: "Cluster", fn: d.verifyCluster},
+		{name: "DeploymentController", fn: d.verifyDeploymentController},
+	}
+	if opt.CheckPermissions {
+		resources = append(resources, struct {
+			name string
+			fn   verifyResourceFunc
+		}{name: "Permissions", fn: d.verifyPermissions})
 	}
 	for _, r := range resources {
 		if err := verifyResource(ctx, r.name, r.fn); err != nil {
 			return err
 		}
 	}
+	if n := len(verifyState.failures); n > 0 {
+		msgs := lo.Map(verifyState.failures, func(err error, _ int) string {
+			return err.Error()
+		})
+		return fmt.Errorf("%d verification(s) failed: %s", n, strings.Join(msgs, "; "))
+	}
 	d.Log("Verify OK!")
 	return nil
 }
 
 var verifyState = struct {
-	cache verifyCache
-	level int
+	cache     verifyCache
+	level     int
+	keepGoing bool
+	failures  []error
+	w         io.Writer
 }{
 	cache: nil,
 	level: 0,
+	w:     os.Stdout,
 }
 
 func initVerifyState(cache bool) {
@@ -238,6 +310,8 @@ func initVerifyState(cache bool) {
 		verifyState.cache = verifyCache(nil)
 	}
 	verifyState.level = 0
+	verifyState.keepGoing = false
+	verifyState.failures = nil
 }
 
 type verifyCache map[string]error
@@ -259,10 +333,11 @@ func verifyResource(ctx context.Context, name string, verifyFunc func(context.Co
 	defer func() { verifyState.level-- }()
 	indent := strings.Repeat("  ", verifyState.level)
 	print := func(f string, args ...interface{}) {
-		fmt.Printf(indent+f+"\n", args...)
+		fmt.Fprintf(verifyState.w, indent+f+"\n", args...)
 	}
 	print("%s", name)
 	var cached string
+	failures := len(verifyState.failures)
 	verifyErr, hit := verifyState.cache.Do(ctx, name, verifyFunc)
 	if hit {
 		cached = color.CyanString("(cached)")
@@ -273,13 +348,26 @@ func verifyResource(ctx context.Context, name string, verifyFunc func(context.Co
 			return nil
 		}
 		print("--> [%s]%s %s", color.RedString("NG"), cached, color.RedString(verifyErr.Error()))
-		return fmt.Errorf("verify %s failed: %w", name, verifyErr)
+		err := fmt.Errorf("verify %s failed: %w", name, verifyErr)
+		if verifyState.keepGoing {
+			verifyState.failures = append(verifyState.failures, err)
+			return nil
+		}
+		return err
+	}
+	if n := len(verifyState.failures) - failures; n > 0 {
+		// nested resources failed
+		print("--> [%s]%s %s", color.RedString("NG"), cached, color.RedString("%d nested verification(s) failed", n))
+		return nil
 	}
 	print("--> [%s]%s", color.GreenString("OK"), cached)
 	return nil
 }
 
 func (d *App) verifyCluster(ctx context.Context) error {
+	if err := d.verifier.opt.skipped("cluster"); err != nil {
+		return err
+	}
 	cluster := d.config.Cluster
 	out, err := d.ecs.DescribeClusters(ctx, &ecs.DescribeClustersInput{
 		Clusters: []string{cluster},
@@ -292,6 +380,26 @@ func (d *App) verifyCluster(ctx context.Context) error {
 	return nil
 }
 
+func (d *App) verifyDeploymentController(ctx context.Context) error {
+	if err := d.verifier.opt.skipped("deployment-controller"); err != nil {
+		return err
+	}
+	live, err := d.DescribeService(ctx)
+	if err != nil {
+		if errors.As(err, &errNotFound) {
+			return ErrSkipVerify(err.Error())
+		}
+		return err
+	}
+	var def *Service
+	if d.config.ServiceDefinitionPath != "" {
+		if def, err = d.LoadServiceDefinition(d.config.ServiceDefinitionPath); err != nil {
+			return err
+		}
+	}
+	return checkDeploymentController(live, d.config, def)
+}
+
 func (d *App) verifyServiceDefinition(ctx context.Context) error {
 	if d.config.ServiceDefinitionPath == "" {
 		return ErrSkipVerify("no ServiceDefinition")
@@ -305,19 +413,23 @@ func (d *App) verifyServiceDefinition(ctx context.Context) error {
 		return err
 	}
 
-	// networkMode
-	if td.NetworkMode == types.NetworkModeAwsvpc {
-		if sv.NetworkConfiguration == nil || sv.NetworkConfiguration.AwsvpcConfiguration == nil {
-			return errors.New(
-				`networkConfiguration.awsvpcConfiguration required for the taskDefinition networkMode=awsvpc`,
-			)
+	// networkMode and launch configurations
+	if err := verifyResource(ctx, "NetworkConfiguration", func(context.Context) error {
+		if err := d.verifier.opt.skipped("network"); err != nil {
+			return err
 		}
+		return verifyNetworkConfiguration(sv, td)
+	}); err != nil {
+		return err
 	}
 
 	// LB
 	for i, lb := range sv.LoadBalancers {
 		name := fmt.Sprintf("LoadBalancer[%d]", i)
 		err := verifyResource(ctx, name, func(context.Context) error {
+			if err := d.verifier.opt.skipped("load-balancer"); err != nil {
+				return err
+			}
 			out, err := d.elbv2.DescribeTargetGroups(ctx, &elasticloadbalancingv2.DescribeTargetGroupsInput{
 				TargetGroupArns: []string{*lb.TargetGroupArn},
 			})
@@ -355,6 +467,19 @@ func (d *App) verifyServiceDefinition(ctx context.Context) error {
 		return errors.New("service has no load balancers, but healthCheckGracePeriodSeconds is defined")
 	}
 
+	if pv := aws.ToString(sv.PlatformVersion); pv != "" && pv != "LATEST" {
+		name := fmt.Sprintf("PlatformVersion[%s]", pv)
+		err := verifyResource(ctx, name, func(context.Context) error {
+			if err := d.verifier.opt.skipped("platform-version"); err != nil {
+				return err
+			}
+			return verifyPlatformVersion(pv, td)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
 	for i, vc := range sv.VolumeConfigurations {
 		name := fmt.Sprintf("VolumeConfigurations[%d]", i)
 		err := verifyResource(ctx, name, func(context.Context) error {
@@ -373,6 +498,150 @@ func (d *App) verifyServiceDefinition(ctx context.Context) error {
 	return nil
 }
 
+// networkConfigurationConflicts returns contradictions between network and launch configurations
+// in the service definition and the task definition, with suggestions to fix them.
+func networkConfigurationConflicts(sv *Service, td *TaskDefinitionInput) []string {
+	var msgs []string
+	var awsvpc *types.AwsVpcConfiguration
+	if sv.NetworkConfiguration != nil {
+		awsvpc = sv.NetworkConfiguration.AwsvpcConfiguration
+	}
+	fargate := sv.LaunchType == types.LaunchTypeFargate
+	for _, s := range sv.CapacityProviderStrategy {
+		if name := aws.ToString(s.CapacityProvider); name == "FARGATE" || name == "FARGATE_SPOT" {
+			fargate = true
+		}
+	}
+
+	if td.NetworkMode == types.NetworkModeAwsvpc {
+		if awsvpc == nil {
+			msgs = append(msgs, "networkConfiguration.awsvpcConfiguration is required for networkMode awsvpc. define subnets and securityGroups in networkConfiguration.awsvpcConfiguration")
+		}
+		for _, c := range td.ContainerDefinitions {
+			for _, pm := range c.PortMappings {
+				if pm.HostPort != nil && aws.ToInt32(pm.HostPort) != aws.ToInt32(pm.ContainerPort) {
+					msgs = append(msgs, fmt.Sprintf("hostPort %d of container %s must be equal to containerPort %d for networkMode awsvpc. remove hostPort or set it to the same value", aws.ToInt32(pm.HostPort), aws.ToString(c.Name), aws.ToInt32(pm.ContainerPort)))
+				}
+			}
+		}
+	} else {
+		mode := td.NetworkMode
+		if mode == "" {
+			mode = types.NetworkModeBridge
+		}
+		if awsvpc != nil {
+			msgs = append(msgs, fmt.Sprintf("networkConfiguration.awsvpcConfiguration is not supported for networkMode %s. remove networkConfiguration or set networkMode to awsvpc", mode))
+		}
+		if fargate {
+			msgs = append(msgs, fmt.Sprintf("Fargate requires networkMode awsvpc, but %s. set networkMode to awsvpc", mode))
+		}
+	}
+
+	if sv.LaunchType != "" && len(sv.CapacityProviderStrategy) > 0 {
+		msgs = append(msgs, "launchType and capacityProviderStrategy are exclusive. remove one of them")
+	}
+	if sv.LaunchType == types.LaunchTypeFargate && len(td.RequiresCompatibilities) > 0 &&
+		!lo.Contains(td.RequiresCompatibilities, types.CompatibilityFargate) {
+		msgs = append(msgs, "launchType FARGATE requires the task definition compatible with FARGATE. add FARGATE to requiresCompatibilities")
+	}
+	// the default capacity provider strategy of the cluster may be Fargate
+	explicitEC2 := sv.LaunchType == types.LaunchTypeEc2 || sv.LaunchType == types.LaunchTypeExternal ||
+		(len(sv.CapacityProviderStrategy) > 0 && !fargate)
+	if awsvpc != nil && awsvpc.AssignPublicIp == types.AssignPublicIpEnabled && explicitEC2 {
+		msgs = append(msgs, "assignPublicIp ENABLED is supported only for Fargate. set assignPublicIp to DISABLED or use Fargate")
+	}
+	return msgs
+}
+
+// verifyNetworkConfiguration verifies network and launch configurations are consistent between the service and the task definition.
+func verifyNetworkConfiguration(sv *Service, td *TaskDefinitionInput) error {
+	if msgs := networkConfigurationConflicts(sv, td); len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, ", "))
+	}
+	return nil
+}
+
+// platformFeature is a feature of the task definition which requires a Fargate platform version.
+type platformFeature struct {
+	name     string
+	required string
+}
+
+// fargatePlatformFeatures returns the features used in the task definition which require a Linux Fargate platform version.
+// https://docs.aws.amazon.com/AmazonECS/latest/developerguide/platform-linux-fargate.html
+func fargatePlatformFeatures(td *TaskDefinitionInput) []platformFeature {
+	var features []platformFeature
+	for _, v := range td.Volumes {
+		if v.EfsVolumeConfiguration != nil {
+			features = append(features, platformFeature{
+				name:     fmt.Sprintf("efsVolumeConfiguration of volume %s", aws.ToString(v.Name)),
+				required: "1.4.0",
+			})
+		}
+	}
+	if td.EphemeralStorage != nil {
+		features = append(features, platformFeature{name: "ephemeralStorage", required: "1.4.0"})
+	}
+	if rp := td.RuntimePlatform; rp != nil && rp.CpuArchitecture == types.CPUArchitectureArm64 {
+		features = append(features, platformFeature{name: "runtimePlatform.cpuArchitecture ARM64", required: "1.4.0"})
+	}
+	for _, c := range td.ContainerDefinitions {
+		name := aws.ToString(c.Name)
+		if len(c.EnvironmentFiles) > 0 {
+			features = append(features, platformFeature{
+				name:     fmt.Sprintf("environmentFiles of container %s", name),
+				required: "1.4.0",
+			})
+		}
+		if lp := c.LinuxParameters; lp != nil && lp.Capabilities != nil && lo.Contains(lp.Capabilities.Add, "SYS_PTRACE") {
+			features = append(features, platformFeature{
+				name:     fmt.Sprintf("SYS_PTRACE capability of container %s", name),
+				required: "1.4.0",
+			})
+		}
+		if rp := c.RestartPolicy; rp != nil && aws.ToBool(rp.Enabled) {
+			features = append(features, platformFeature{
+				name:     fmt.Sprintf("restartPolicy of container %s", name),
+				required: "1.4.0",
+			})
+		}
+		if c.FirelensConfiguration != nil {
+			features = append(features, platformFeature{
+				name:     fmt.Sprintf("firelensConfiguration of container %s", name),
+				required: "1.3.0",
+			})
+		}
+		if len(c.Secrets) > 0 {
+			features = append(features, platformFeature{
+				name:     fmt.Sprintf("secrets of container %s", name),
+				required: "1.3.0",
+			})
+		}
+	}
+	return features
+}
+
+// verifyPlatformVersion verifies the pinned Fargate platform version supports the features used in the task definition.
+func verifyPlatformVersion(platformVersion string, td *TaskDefinitionInput) error {
+	if rp := td.RuntimePlatform; rp != nil && rp.OperatingSystemFamily != "" && rp.OperatingSystemFamily != types.OSFamilyLinux {
+		return ErrSkipVerify(fmt.Sprintf("platform version of %s is not verified", rp.OperatingSystemFamily))
+	}
+	pv, err := goVersion.NewVersion(platformVersion)
+	if err != nil {
+		return fmt.Errorf("invalid platform version %s: %w", platformVersion, err)
+	}
+	var msgs []string
+	for _, f := range fargatePlatformFeatures(td) {
+		if pv.LessThan(goVersion.Must(goVersion.NewVersion(f.required))) {
+			msgs = append(msgs, fmt.Sprintf("%s requires platform version %s or later", f.name, f.required))
+		}
+	}
+	if len(msgs) > 0 {
+		return errors.New(strings.Join(msgs, ", "))
+	}
+	return nil
+}
+
 func (d *App) verifyTaskDefinition(ctx context.Context) error {
 	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
 	if err != nil {
@@ -398,6 +667,15 @@ func (d *App) verifyTaskDefinition(ctx context.Context) error {
 		}
 	}
 
+	if pc := td.ProxyConfiguration; pc != nil {
+		name := aws.ToString(pc.ContainerName)
+		if !lo.ContainsBy(td.ContainerDefinitions, func(c types.ContainerDefinition) bool {
+			return aws.ToString(c.Name) == name
+		}) {
+			d.Log("[WARNING] proxyConfiguration.containerName %s is not defined in containerDefinitions", name)
+		}
+	}
+
 	for _, c := range td.ContainerDefinitions {
 		name := fmt.Sprintf("ContainerDefinition[%s]", aws.ToString(c.Name))
 		err := verifyResource(ctx, name, func(ctx context.Context) error {
@@ -524,6 +802,9 @@ func NormalizePlatform(p *types.RuntimePlatform, isFargate bool) (arch, os strin
 }
 
 func (d *App) verifyImage(ctx context.Context, image string) error {
+	if err := d.verifier.opt.skipped("image"); err != nil {
+		return err
+	}
 	if image == "" {
 		return errors.New("image is not defined")
 	}
@@ -571,12 +852,16 @@ func (d *App) verifyContainer(ctx context.Context, c *types.ContainerDefinition,
 	for _, envFile := range c.EnvironmentFiles {
 		name := fmt.Sprintf("EnvironmentFile[%s %s]", envFile.Type, aws.ToString(envFile.Value))
 		if err := verifyResource(ctx, name, func(ctx context.Context) error {
-			return d.verifier.existsEnvironmentFile(ctx, envFile)
+			return d.verifier.existsEnvironmentFile(ctx, aws.ToString(c.Name), envFile)
 		}); err != nil {
 			return err
 		}
 	}
 
+	for _, w := range restartPolicyWarnings(c.RestartPolicy) {
+		d.Log("[WARNING] restartPolicy of container %s: %s", aws.ToString(c.Name), w)
+	}
+
 	if td.NetworkMode == types.NetworkModeAwsvpc {
 		for _, pm := range c.PortMappings {
 			if pm.HostPort != nil && aws.ToInt32(pm.ContainerPort) != aws.ToInt32(pm.HostPort) {
@@ -588,7 +873,32 @@ func (d *App) verifyContainer(ctx context.Context, c *types.ContainerDefinition,
 	return nil
 }
 
+// restartPolicyWarnings returns warnings for invalid combinations of the container restart policy.
+// https://docs.aws.amazon.com/AmazonECS/latest/developerguide/container-restart-policy.html
+func restartPolicyWarnings(rp *types.ContainerRestartPolicy) []string {
+	if rp == nil {
+		return nil
+	}
+	var warnings []string
+	if !aws.ToBool(rp.Enabled) {
+		if len(rp.IgnoredExitCodes) > 0 || rp.RestartAttemptPeriod != nil {
+			warnings = append(warnings, "ignoredExitCodes and restartAttemptPeriod are ignored because enabled is not true")
+		}
+		return warnings
+	}
+	if n := len(rp.IgnoredExitCodes); n > 50 {
+		warnings = append(warnings, fmt.Sprintf("ignoredExitCodes can have up to 50 exit codes, but %d", n))
+	}
+	if p := rp.RestartAttemptPeriod; p != nil && (*p < 60 || 1800 < *p) {
+		warnings = append(warnings, fmt.Sprintf("restartAttemptPeriod must be between 60 and 1800 seconds, but %d", *p))
+	}
+	return warnings
+}
+
 func (d *App) verifyLogConfiguration(ctx context.Context, c *types.ContainerDefinition) error {
+	if err := d.verifier.opt.skipped("log"); err != nil {
+		return err
+	}
 	options := c.LogConfiguration.Options
 	d.Log("[DEBUG] LogConfiguration[awslogs] options=%v", options)
 	group, region, prefix := options["awslogs-group"], options["awslogs-region"], options["awslogs-stream-prefix"]
@@ -667,6 +977,9 @@ func extractRoleName(roleArn string) (string, error) {
 }
 
 func (d *App) verifyRole(ctx context.Context, roleArn string) error {
+	if err := d.verifier.opt.skipped("role"); err != nil {
+		return err
+	}
 	roleName, err := extractRoleName(roleArn)
 	if err != nil {
 		return err
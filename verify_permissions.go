@@ -0,0 +1,227 @@
+package ecspresso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/samber/lo"
+)
+
+type iamSimulatePrincipalPolicyAPI interface {
+	SimulatePrincipalPolicy(ctx context.Context, params *iam.SimulatePrincipalPolicyInput, optFns ...func(*iam.Options)) (*iam.SimulatePrincipalPolicyOutput, error)
+}
+
+const passRoleAction = "iam:PassRole"
+
+type requiredPermission struct {
+	command string
+	actions []string
+}
+
+// requiredPermissions are the IAM actions called by the commands, checked by verify --check-permissions.
+// iam:PassRole is simulated for the task role and the task execution role in the task definition.
+var requiredPermissions = []requiredPermission{
+	{command: "deploy", actions: []string{"ecs:DescribeServices", "ecs:DescribeTaskDefinition", "ecs:RegisterTaskDefinition", "ecs:UpdateService", passRoleAction}},
+	{command: "diff", actions: []string{"ecs:DescribeServices", "ecs:DescribeTaskDefinition"}},
+	{command: "exec", actions: []string{"ecs:DescribeTasks", "ecs:ListTasks", "ecs:ExecuteCommand"}},
+	{command: "rollback", actions: []string{"ecs:DescribeServices", "ecs:DescribeTaskDefinition", "ecs:ListTaskDefinitions", "ecs:UpdateService"}},
+	{command: "run", actions: []string{"ecs:DescribeTaskDefinition", "ecs:RegisterTaskDefinition", "ecs:RunTask", "ecs:DescribeTasks", passRoleAction}},
+	{command: "scale", actions: []string{"ecs:DescribeServices", "ecs:UpdateService"}},
+	{command: "status", actions: []string{"ecs:DescribeServices", "ecs:ListTasks", "ecs:DescribeTasks"}},
+}
+
+// permissionResourceArns returns the ARNs of the resources to simulate by the actions which support resource-level permissions.
+// The actions not in the result are simulated for all resources.
+func permissionResourceArns(partition, region, account, cluster, service, family string) map[string][]string {
+	arnOf := func(resource string) string {
+		return arn.ARN{
+			Partition: partition,
+			Service:   "ecs",
+			Region:    region,
+			AccountID: account,
+			Resource:  resource,
+		}.String()
+	}
+	resources := map[string][]string{
+		"ecs:ExecuteCommand": {arnOf("cluster/" + cluster)},
+	}
+	if service != "" {
+		serviceArn := arnOf("service/" + cluster + "/" + service)
+		resources["ecs:DescribeServices"] = []string{serviceArn}
+		resources["ecs:UpdateService"] = []string{serviceArn}
+	}
+	if family != "" {
+		resources["ecs:RunTask"] = []string{arnOf("task-definition/" + family + ":*")}
+	}
+	return resources
+}
+
+// commandPermissions represents the actions not allowed for a command.
+type commandPermissions struct {
+	Command string
+	Missing []string
+}
+
+// principalArnOf returns the ARN of the IAM user or role to simulate for the caller identity.
+// An assumed role session is resolved to the role. The path of the role is not included in the session ARN,
+// so a role with a path can not be simulated.
+func principalArnOf(callerArn string) (string, error) {
+	a, err := arn.Parse(callerArn)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse caller arn %s: %w", callerArn, err)
+	}
+	switch a.Service {
+	case "iam":
+		if strings.HasPrefix(a.Resource, "user/") || strings.HasPrefix(a.Resource, "role/") {
+			return callerArn, nil
+		}
+	case "sts":
+		if rs := strings.Split(a.Resource, "/"); len(rs) == 3 && rs[0] == "assumed-role" {
+			a.Service = "iam"
+			a.Resource = "role/" + rs[1]
+			return a.String(), nil
+		}
+	}
+	return "", fmt.Errorf("permissions of %s can not be simulated", callerArn)
+}
+
+// simulatePermissions simulates the required actions of the commands for the principal,
+// and returns the actions not allowed grouped by the command.
+// The actions in resources are simulated for the resources, and the others for all resources.
+func simulatePermissions(ctx context.Context, client iamSimulatePrincipalPolicyAPI, principal string, resources map[string][]string, roles []string) ([]commandPermissions, error) {
+	actions := lo.Without(lo.Uniq(lo.FlatMap(requiredPermissions, func(p requiredPermission, _ int) []string {
+		return p.actions
+	})), passRoleAction)
+	sort.Strings(actions)
+
+	// simulate the actions for the same resources at once
+	groups := lo.GroupBy(actions, func(action string) string {
+		return strings.Join(resources[action], ",")
+	})
+	keys := lo.Keys(groups)
+	sort.Strings(keys) // "" (all resources) comes first
+	denied := map[string]bool{}
+	for _, key := range keys {
+		group := groups[key]
+		d, err := simulateDeniedActions(ctx, client, principal, group, resources[group[0]])
+		if err != nil {
+			return nil, err
+		}
+		for action := range d {
+			denied[action] = true
+		}
+	}
+	if len(roles) > 0 {
+		d, err := simulateDeniedActions(ctx, client, principal, []string{passRoleAction}, roles)
+		if err != nil {
+			return nil, err
+		}
+		for action := range d {
+			denied[action] = true
+		}
+	}
+
+	results := make([]commandPermissions, 0, len(requiredPermissions))
+	for _, p := range requiredPermissions {
+		missing := lo.Filter(p.actions, func(action string, _ int) bool {
+			return denied[action]
+		})
+		results = append(results, commandPermissions{Command: p.command, Missing: missing})
+	}
+	return results, nil
+}
+
+func simulateDeniedActions(ctx context.Context, client iamSimulatePrincipalPolicyAPI, principal string, actions, resources []string) (map[string]bool, error) {
+	denied := map[string]bool{}
+	input := &iam.SimulatePrincipalPolicyInput{
+		PolicySourceArn: aws.String(principal),
+		ActionNames:     actions,
+		ResourceArns:    resources,
+	}
+	for {
+		out, err := client.SimulatePrincipalPolicy(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to simulate principal policy of %s: %w", principal, err)
+		}
+		for _, r := range out.EvaluationResults {
+			if r.EvalDecision != iamTypes.PolicyEvaluationDecisionTypeAllowed {
+				denied[aws.ToString(r.EvalActionName)] = true
+			}
+		}
+		if !out.IsTruncated {
+			return denied, nil
+		}
+		input.Marker = out.Marker
+	}
+}
+
+// cannotSimulate reports whether the caller is not allowed to simulate, or the principal is not found.
+func cannotSimulate(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.ErrorCode() {
+	case "AccessDenied", "NoSuchEntity":
+		return true
+	}
+	return false
+}
+
+func (d *App) verifyPermissions(ctx context.Context) error {
+	td, err := d.LoadTaskDefinition(d.config.TaskDefinitionPath)
+	if err != nil {
+		return err
+	}
+	out, err := sts.NewFromConfig(d.config.awsv2Config).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	principal, err := principalArnOf(aws.ToString(out.Arn))
+	if err != nil {
+		d.Log("[WARNING] %s. skip checking permissions", err)
+		return ErrSkipVerify(err.Error())
+	}
+	roles := lo.Filter([]string{aws.ToString(td.ExecutionRoleArn), aws.ToString(td.TaskRoleArn)}, func(s string, _ int) bool {
+		return s != ""
+	})
+	pa, err := arn.Parse(principal)
+	if err != nil {
+		return fmt.Errorf("failed to parse principal arn %s: %w", principal, err)
+	}
+	resources := permissionResourceArns(pa.Partition, d.config.Region, aws.ToString(out.Account), arnToName(d.config.Cluster), d.config.Service, aws.ToString(td.Family))
+	results, err := simulatePermissions(ctx, d.iam, principal, resources, lo.Uniq(roles))
+	if err != nil {
+		if cannotSimulate(err) {
+			d.Log("[WARNING] %s. skip checking permissions", err)
+			return ErrSkipVerify(fmt.Sprintf("permissions of %s can not be simulated", principal))
+		}
+		return err
+	}
+
+	var failed []string
+	for _, r := range results {
+		r := r
+		if err := verifyResource(ctx, fmt.Sprintf("Command[%s]", r.Command), func(context.Context) error {
+			if len(r.Missing) > 0 {
+				return fmt.Errorf("%s is not allowed for %s", strings.Join(r.Missing, ", "), principal)
+			}
+			return nil
+		}); err != nil {
+			failed = append(failed, r.Command)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%s would fail by missing permissions", strings.Join(failed, ", "))
+	}
+	return nil
+}
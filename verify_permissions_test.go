@@ -0,0 +1,168 @@
+package ecspresso_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/smithy-go"
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+type mockIAMSimulator struct {
+	denied []string
+	err    error
+	inputs []*iam.SimulatePrincipalPolicyInput
+}
+
+func (m *mockIAMSimulator) SimulatePrincipalPolicy(ctx context.Context, params *iam.SimulatePrincipalPolicyInput, optFns ...func(*iam.Options)) (*iam.SimulatePrincipalPolicyOutput, error) {
+	m.inputs = append(m.inputs, params)
+	if m.err != nil {
+		return nil, m.err
+	}
+	out := &iam.SimulatePrincipalPolicyOutput{}
+	for _, action := range params.ActionNames {
+		decision := iamTypes.PolicyEvaluationDecisionTypeAllowed
+		for _, d := range m.denied {
+			if d == action {
+				decision = iamTypes.PolicyEvaluationDecisionTypeImplicitDeny
+			}
+		}
+		out.EvaluationResults = append(out.EvaluationResults, iamTypes.EvaluationResult{
+			EvalActionName: aws.String(action),
+			EvalDecision:   decision,
+		})
+	}
+	return out, nil
+}
+
+func TestPrincipalArnOf(t *testing.T) {
+	cases := []struct {
+		caller string
+		want   string
+		err    bool
+	}{
+		{caller: "arn:aws:iam::123456789012:user/alice", want: "arn:aws:iam::123456789012:user/alice"},
+		{caller: "arn:aws:iam::123456789012:role/deployer", want: "arn:aws:iam::123456789012:role/deployer"},
+		{caller: "arn:aws:sts::123456789012:assumed-role/deployer/session", want: "arn:aws:iam::123456789012:role/deployer"},
+		{caller: "arn:aws:iam::123456789012:root", err: true},
+		{caller: "arn:aws:sts::123456789012:federated-user/bob", err: true},
+		{caller: "deployer", err: true},
+	}
+	for _, c := range cases {
+		got, err := ecspresso.PrincipalArnOf(c.caller)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: error expected, got %s", c.caller, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error %s", c.caller, err)
+		} else if got != c.want {
+			t.Errorf("%s: expected %s got %s", c.caller, c.want, got)
+		}
+	}
+}
+
+func TestSimulatePermissions(t *testing.T) {
+	ctx := context.Background()
+	principal := "arn:aws:iam::123456789012:role/deployer"
+	roles := []string{"arn:aws:iam::123456789012:role/ecsTaskExecutionRole"}
+	m := &mockIAMSimulator{denied: []string{"ecs:UpdateService", "iam:PassRole"}}
+	results, err := ecspresso.SimulatePermissions(ctx, m, principal, nil, roles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := map[string][]string{}
+	for _, r := range results {
+		if len(r.Missing) > 0 {
+			missing[r.Command] = r.Missing
+		}
+	}
+	expected := map[string][]string{
+		"deploy":   {"ecs:UpdateService", "iam:PassRole"},
+		"rollback": {"ecs:UpdateService"},
+		"run":      {"iam:PassRole"},
+		"scale":    {"ecs:UpdateService"},
+	}
+	if diff := cmp.Diff(expected, missing); diff != "" {
+		t.Errorf("unexpected missing permissions: %s", diff)
+	}
+
+	if len(m.inputs) != 2 {
+		t.Fatalf("SimulatePrincipalPolicy must be called twice, got %d", len(m.inputs))
+	}
+	if diff := cmp.Diff([]string{"iam:PassRole"}, m.inputs[1].ActionNames); diff != "" {
+		t.Errorf("unexpected actions to pass role: %s", diff)
+	}
+	if diff := cmp.Diff(roles, m.inputs[1].ResourceArns); diff != "" {
+		t.Errorf("iam:PassRole must be simulated for the roles: %s", diff)
+	}
+	for _, action := range m.inputs[0].ActionNames {
+		if action == "iam:PassRole" {
+			t.Error("iam:PassRole must not be simulated for all resources")
+		}
+	}
+}
+
+func TestSimulatePermissionsForResources(t *testing.T) {
+	ctx := context.Background()
+	principal := "arn:aws:iam::123456789012:role/deployer"
+	resources := ecspresso.PermissionResourceArns("aws", "ap-northeast-1", "123456789012", "default", "app", "app-task")
+	if diff := cmp.Diff(map[string][]string{
+		"ecs:ExecuteCommand":   {"arn:aws:ecs:ap-northeast-1:123456789012:cluster/default"},
+		"ecs:DescribeServices": {"arn:aws:ecs:ap-northeast-1:123456789012:service/default/app"},
+		"ecs:UpdateService":    {"arn:aws:ecs:ap-northeast-1:123456789012:service/default/app"},
+		"ecs:RunTask":          {"arn:aws:ecs:ap-northeast-1:123456789012:task-definition/app-task:*"},
+	}, resources); diff != "" {
+		t.Errorf("unexpected resources: %s", diff)
+	}
+
+	m := &mockIAMSimulator{}
+	if _, err := ecspresso.SimulatePermissions(ctx, m, principal, resources, nil); err != nil {
+		t.Fatal(err)
+	}
+	simulated := map[string][]string{}
+	for _, in := range m.inputs {
+		for _, action := range in.ActionNames {
+			if _, ok := simulated[action]; ok {
+				t.Errorf("%s is simulated twice", action)
+			}
+			simulated[action] = in.ResourceArns
+		}
+	}
+	for action, arns := range simulated {
+		if diff := cmp.Diff(resources[action], arns); diff != "" {
+			t.Errorf("unexpected resources of %s: %s", action, diff)
+		}
+	}
+	if len(m.inputs) != 4 {
+		t.Errorf("SimulatePrincipalPolicy must be called for each resource, got %d", len(m.inputs))
+	}
+
+	resources = ecspresso.PermissionResourceArns("aws", "ap-northeast-1", "123456789012", "default", "", "app-task")
+	if _, ok := resources["ecs:UpdateService"]; ok {
+		t.Error("ecs:UpdateService must be simulated for all resources without the service")
+	}
+}
+
+func TestSimulatePermissionsNotPermitted(t *testing.T) {
+	m := &mockIAMSimulator{
+		err: &smithy.GenericAPIError{Code: "AccessDenied", Message: "not authorized to perform: iam:SimulatePrincipalPolicy"},
+	}
+	_, err := ecspresso.SimulatePermissions(context.Background(), m, "arn:aws:iam::123456789012:user/alice", nil, nil)
+	if err == nil {
+		t.Fatal("error expected")
+	}
+	if !ecspresso.CannotSimulate(err) {
+		t.Errorf("AccessDenied must be degraded to a warning: %s", err)
+	}
+	if ecspresso.CannotSimulate(fmt.Errorf("failed to simulate: %w", &smithy.GenericAPIError{Code: "Throttling"})) {
+		t.Error("Throttling must not be degraded")
+	}
+}
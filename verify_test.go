@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -268,3 +269,180 @@ func TestVerifierIsAssumed(t *testing.T) {
 		}
 	}
 }
+
+func TestVerifyKeepGoing(t *testing.T) {
+	color.NoColor = true
+	ecspresso.InitVerifyState(false)
+	ecspresso.SetVerifyKeepGoing(true)
+	defer ecspresso.InitVerifyState(false)
+
+	out := extractStdout(t, func() {
+		err := ecspresso.VerifyResource(context.TODO(), "parent", func(ctx context.Context) error {
+			for _, name := range []string{"ng1", "ok", "ng2"} {
+				name := name
+				if err := ecspresso.VerifyResource(ctx, name, func(_ context.Context) error {
+					if strings.HasPrefix(name, "ng") {
+						return errors.New(name + " failed")
+					}
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			t.Error("unexpected error on keep going", err)
+		}
+	})
+	if n := len(ecspresso.VerifyFailures()); n != 2 {
+		t.Errorf("unexpected failures %d", n)
+	}
+	if !bytes.Contains(out, []byte("[NG] 2 nested verification(s) failed")) {
+		t.Errorf("unexpected output for parent resource: %s", out)
+	}
+}
+
+func TestVerifyOptionSkipped(t *testing.T) {
+	opt := &ecspresso.VerifyOption{Skip: []string{"image", "log"}}
+	for _, check := range []string{"image", "log"} {
+		if err := opt.Skipped(check); err == nil {
+			t.Errorf("%s must be skipped", check)
+		}
+	}
+	if err := opt.Skipped("role"); err != nil {
+		t.Errorf("role must not be skipped: %s", err)
+	}
+}
+
+func TestVerifyPlatformVersion(t *testing.T) {
+	td := &ecspresso.TaskDefinitionInput{
+		Volumes: []types.Volume{
+			{
+				Name:                   aws.String("data"),
+				EfsVolumeConfiguration: &types.EFSVolumeConfiguration{FileSystemId: aws.String("fs-12345678")},
+			},
+		},
+		ContainerDefinitions: []types.ContainerDefinition{
+			{
+				Name:    aws.String("app"),
+				Secrets: []types.Secret{{Name: aws.String("FOO"), ValueFrom: aws.String("/foo")}},
+			},
+		},
+	}
+	if err := ecspresso.VerifyPlatformVersion("1.4.0", td); err != nil {
+		t.Errorf("unexpected error for 1.4.0: %s", err)
+	}
+	err := ecspresso.VerifyPlatformVersion("1.3.0", td)
+	if err == nil {
+		t.Fatal("expected error for 1.3.0")
+	}
+	if !strings.Contains(err.Error(), "efsVolumeConfiguration of volume data requires platform version 1.4.0") {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if strings.Contains(err.Error(), "secrets") {
+		t.Errorf("secrets must be supported by 1.3.0: %s", err)
+	}
+	if err := ecspresso.VerifyPlatformVersion("1.2.0", td); err == nil || !strings.Contains(err.Error(), "secrets of container app requires platform version 1.3.0") {
+		t.Errorf("unexpected error for 1.2.0: %v", err)
+	}
+
+	td.RuntimePlatform = &types.RuntimePlatform{OperatingSystemFamily: types.OSFamilyWindowsServer2019Core}
+	if err := ecspresso.VerifyPlatformVersion("1.0.0", td); !errors.As(err, new(ecspresso.ErrSkipVerify)) {
+		t.Errorf("windows must be skipped: %v", err)
+	}
+}
+
+func TestRestartPolicyWarnings(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		policy   *types.ContainerRestartPolicy
+		warnings int
+	}{
+		{name: "nil", policy: nil},
+		{name: "enabled", policy: &types.ContainerRestartPolicy{Enabled: aws.Bool(true), IgnoredExitCodes: []int32{0}, RestartAttemptPeriod: aws.Int32(60)}},
+		{name: "disabled with options", policy: &types.ContainerRestartPolicy{Enabled: aws.Bool(false), IgnoredExitCodes: []int32{0}}, warnings: 1},
+		{name: "too short period", policy: &types.ContainerRestartPolicy{Enabled: aws.Bool(true), RestartAttemptPeriod: aws.Int32(30)}, warnings: 1},
+		{name: "too long period", policy: &types.ContainerRestartPolicy{Enabled: aws.Bool(true), RestartAttemptPeriod: aws.Int32(3600)}, warnings: 1},
+		{name: "too many exit codes", policy: &types.ContainerRestartPolicy{Enabled: aws.Bool(true), IgnoredExitCodes: make([]int32, 51)}, warnings: 1},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := ecspresso.RestartPolicyWarnings(tt.policy); len(w) != tt.warnings {
+				t.Errorf("expected %d warnings, but %v", tt.warnings, w)
+			}
+		})
+	}
+}
+
+func TestNetworkConfigurationConflicts(t *testing.T) {
+	awsvpc := &types.NetworkConfiguration{
+		AwsvpcConfiguration: &types.AwsVpcConfiguration{
+			Subnets:        []string{"subnet-12345678"},
+			AssignPublicIp: types.AssignPublicIpEnabled,
+		},
+	}
+	portMappings := func(host, container int32) []types.ContainerDefinition {
+		return []types.ContainerDefinition{{
+			Name:         aws.String("app"),
+			PortMappings: []types.PortMapping{{HostPort: aws.Int32(host), ContainerPort: aws.Int32(container)}},
+		}}
+	}
+	for _, tt := range []struct {
+		name      string
+		sv        types.Service
+		td        ecspresso.TaskDefinitionInput
+		conflicts []string
+	}{
+		{
+			name: "fargate",
+			sv:   types.Service{LaunchType: types.LaunchTypeFargate, NetworkConfiguration: awsvpc},
+			td:   ecspresso.TaskDefinitionInput{NetworkMode: types.NetworkModeAwsvpc, RequiresCompatibilities: []types.Compatibility{types.CompatibilityFargate}},
+		},
+		{
+			name: "bridge on EC2",
+			sv:   types.Service{LaunchType: types.LaunchTypeEc2},
+			td:   ecspresso.TaskDefinitionInput{NetworkMode: types.NetworkModeBridge, ContainerDefinitions: portMappings(0, 80)},
+		},
+		{
+			name: "default capacity provider strategy",
+			sv:   types.Service{NetworkConfiguration: awsvpc},
+			td:   ecspresso.TaskDefinitionInput{NetworkMode: types.NetworkModeAwsvpc},
+		},
+		{
+			name:      "awsvpc without networkConfiguration",
+			sv:        types.Service{},
+			td:        ecspresso.TaskDefinitionInput{NetworkMode: types.NetworkModeAwsvpc, ContainerDefinitions: portMappings(8080, 80)},
+			conflicts: []string{"networkConfiguration.awsvpcConfiguration is required", "hostPort 8080 of container app"},
+		},
+		{
+			name:      "bridge with awsvpcConfiguration on Fargate",
+			sv:        types.Service{CapacityProviderStrategy: []types.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE_SPOT")}}, NetworkConfiguration: awsvpc},
+			td:        ecspresso.TaskDefinitionInput{},
+			conflicts: []string{"not supported for networkMode bridge", "Fargate requires networkMode awsvpc"},
+		},
+		{
+			name:      "launchType with capacityProviderStrategy",
+			sv:        types.Service{LaunchType: types.LaunchTypeFargate, CapacityProviderStrategy: []types.CapacityProviderStrategyItem{{CapacityProvider: aws.String("FARGATE")}}, NetworkConfiguration: awsvpc},
+			td:        ecspresso.TaskDefinitionInput{NetworkMode: types.NetworkModeAwsvpc, RequiresCompatibilities: []types.Compatibility{types.CompatibilityEc2}},
+			conflicts: []string{"launchType and capacityProviderStrategy are exclusive", "add FARGATE to requiresCompatibilities"},
+		},
+		{
+			name:      "public IP on EC2",
+			sv:        types.Service{LaunchType: types.LaunchTypeEc2, NetworkConfiguration: awsvpc},
+			td:        ecspresso.TaskDefinitionInput{NetworkMode: types.NetworkModeAwsvpc},
+			conflicts: []string{"assignPublicIp ENABLED is supported only for Fargate"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs := ecspresso.NetworkConfigurationConflicts(&ecspresso.Service{Service: tt.sv}, &tt.td)
+			if len(msgs) != len(tt.conflicts) {
+				t.Fatalf("expected %d conflicts, but %v", len(tt.conflicts), msgs)
+			}
+			for i, want := range tt.conflicts {
+				if !strings.Contains(msgs[i], want) {
+					t.Errorf("expected %q in %q", want, msgs[i])
+				}
+			}
+		})
+	}
+}
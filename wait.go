@@ -53,7 +53,7 @@ func (d *App) WaitFunc(sv *Service, confirm confirmFunc) (waitFunc, error) {
 
 func (d *App) confirmPrimaryTD(tdArn string) confirmFunc {
 	return func(ctx context.Context) error {
-		sv, err := d.DescribeService(ctx)
+		sv, err := d.DescribeService(withoutDescribeCache(ctx))
 		if err != nil {
 			return err
 		}
@@ -74,7 +74,7 @@ type WaitOption struct {
 }
 
 func (d *App) Wait(ctx context.Context, opt WaitOption) error {
-	ctx, cancel := d.Start(ctx)
+	ctx, cancel := d.startPhase(ctx, timeoutPhaseWait)
 	defer cancel()
 
 	d.Log("Waiting for the service stable")
@@ -105,26 +105,21 @@ func (d *App) WaitServiceStable(ctx context.Context, sv *Service) error {
 	waitCtx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	tick := time.NewTicker(10 * time.Second)
+	// the status is shown at the same intervals as polling by --wait-backoff
+	poller := newWaitPoller(waitCtx, showServiceStatusInterval)
 	st := &showState{lastEventAt: time.Now()}
 	go func() {
 		for {
-			select {
-			case <-waitCtx.Done():
+			if err := poller.wait(waitCtx); err != nil {
 				return
-			case <-tick.C:
-				if err := d.showServiceStatus(waitCtx, st); err != nil {
-					d.Log("[WARNING] %s", err.Error())
-					continue
-				}
+			}
+			if err := d.showServiceStatus(waitCtx, st); err != nil {
+				d.Log("[WARNING] %s", err.Error())
 			}
 		}
 	}()
 
-	waiter := ecs.NewServicesStableWaiter(d.ecs, func(o *ecs.ServicesStableWaiterOptions) {
-		o.MaxDelay = waiterMaxDelay
-	})
-	if err := waiter.Wait(ctx, d.DescribeServicesInput(), d.Timeout()); err != nil {
+	if err := d.waitPrimaryDeploymentStable(ctx); err != nil {
 		return fmt.Errorf("failed to wait for service stable: %w", err)
 	}
 	cancel() // stop the showServiceStatus
@@ -137,12 +132,194 @@ func (d *App) WaitServiceStable(ctx context.Context, sv *Service) error {
 	return nil
 }
 
+// waitPrimaryDeploymentStable waits until the PRIMARY deployment of the service becomes stable.
+// ACTIVE deployments (draining tasks of the previous deployments) are not waited for.
+func (d *App) waitPrimaryDeploymentStable(ctx context.Context) error {
+	ctx, cancel := d.startPhase(ctx, timeoutPhaseWait)
+	defer cancel()
+	poller := newWaitPoller(ctx, waiterMaxDelay)
+	for {
+		out, err := d.ecs.DescribeServices(ctx, d.DescribeServicesInput())
+		if err != nil {
+			return fmt.Errorf("failed to describe service: %w", err)
+		}
+		if len(out.Services) == 0 {
+			return ErrNotFound(fmt.Sprintf("service %s is not found", d.Service))
+		}
+		stable, err := primaryDeploymentStable(out.Services[0])
+		if err != nil {
+			return err
+		}
+		if stable {
+			return nil
+		}
+		if err := poller.wait(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// primaryDeploymentStable reports whether the PRIMARY deployment of the service is stable.
+// The rolloutState of the PRIMARY deployment is used if available, otherwise the task counts are compared.
+func primaryDeploymentStable(sv types.Service) (bool, error) {
+	switch status := aws.ToString(sv.Status); status {
+	case "DRAINING", "INACTIVE":
+		return false, fmt.Errorf("service %s is %s", aws.ToString(sv.ServiceName), status)
+	}
+	for _, dep := range sv.Deployments {
+		if aws.ToString(dep.Status) != "PRIMARY" {
+			continue
+		}
+		switch dep.RolloutState {
+		case types.DeploymentRolloutStateFailed:
+			return false, fmt.Errorf("deployment %s failed: %s", aws.ToString(dep.Id), aws.ToString(dep.RolloutStateReason))
+		case types.DeploymentRolloutStateCompleted:
+			return true, nil
+		case types.DeploymentRolloutStateInProgress:
+			return false, nil
+		default: // rolloutState is not available
+			return dep.RunningCount == dep.DesiredCount && dep.PendingCount == 0, nil
+		}
+	}
+	return false, nil
+}
+
+// waitMinRunningFunc returns a waitFunc which waits until the running tasks of the PRIMARY deployment
+// reach the percent of the desired count, instead of waiting for the service stable.
+// When healthy is true, only the tasks with HEALTHY health status are counted.
+func (d *App) waitMinRunningFunc(percent int32, healthy bool) waitFunc {
+	return func(ctx context.Context, sv *Service) error {
+		d.Log("Waiting for %d%% of the desired tasks running...", percent)
+		ctx, cancel := d.startPhase(ctx, timeoutPhaseWait)
+		defer cancel()
+		poller := newWaitPoller(ctx, waiterMaxDelay)
+		for {
+			out, err := d.ecs.DescribeServices(ctx, d.DescribeServicesInput())
+			if err != nil {
+				return fmt.Errorf("failed to describe service: %w", err)
+			}
+			if len(out.Services) == 0 {
+				return ErrNotFound(fmt.Sprintf("service %s is not found", d.Service))
+			}
+			dep, err := primaryDeploymentMinRunning(out.Services[0], percent)
+			if err != nil {
+				return err
+			}
+			if dep != nil && healthy {
+				n, err := d.countHealthyTasks(ctx, aws.ToString(dep.Id))
+				if err != nil {
+					return err
+				}
+				d.Log("[DEBUG] %d of %d tasks are healthy", n, dep.DesiredCount)
+				if !minRunningReached(n, dep.DesiredCount, percent) {
+					dep = nil
+				}
+			}
+			if dep != nil {
+				d.Log("%d of %d tasks are running", dep.RunningCount, dep.DesiredCount)
+				return nil
+			}
+			if err := poller.wait(ctx); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// primaryDeploymentMinRunning returns the PRIMARY deployment of the service
+// if its running tasks reach the percent of the desired count. Otherwise it returns nil.
+// A failed rollout of the PRIMARY deployment is an error.
+func primaryDeploymentMinRunning(sv types.Service, percent int32) (*types.Deployment, error) {
+	switch status := aws.ToString(sv.Status); status {
+	case "DRAINING", "INACTIVE":
+		return nil, fmt.Errorf("service %s is %s", aws.ToString(sv.ServiceName), status)
+	}
+	for _, dep := range sv.Deployments {
+		if aws.ToString(dep.Status) != "PRIMARY" {
+			continue
+		}
+		switch dep.RolloutState {
+		case types.DeploymentRolloutStateFailed:
+			return nil, fmt.Errorf("deployment %s failed: %s", aws.ToString(dep.Id), aws.ToString(dep.RolloutStateReason))
+		case types.DeploymentRolloutStateCompleted:
+			return &dep, nil
+		}
+		if minRunningReached(dep.RunningCount, dep.DesiredCount, percent) {
+			return &dep, nil
+		}
+		return nil, nil
+	}
+	return nil, nil
+}
+
+func minRunningReached(running, desired, percent int32) bool {
+	return int64(running)*100 >= int64(desired)*int64(percent)
+}
+
+// countHealthyTasks counts the running tasks with HEALTHY health status started by the deployment.
+func (d *App) countHealthyTasks(ctx context.Context, deploymentID string) (int32, error) {
+	var n int32
+	tp := ecs.NewListTasksPaginator(
+		d.ecs,
+		&ecs.ListTasksInput{
+			Cluster:       &d.config.Cluster,
+			StartedBy:     &deploymentID,
+			DesiredStatus: types.DesiredStatusRunning,
+		},
+	)
+	for tp.HasMorePages() {
+		to, err := tp.NextPage(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("failed to list tasks: %w", err)
+		}
+		if len(to.TaskArns) == 0 {
+			continue
+		}
+		out, err := d.ecs.DescribeTasks(ctx, &ecs.DescribeTasksInput{
+			Cluster: &d.config.Cluster,
+			Tasks:   to.TaskArns,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to describe tasks: %w", err)
+		}
+		for _, task := range out.Tasks {
+			if aws.ToString(task.LastStatus) == "RUNNING" && task.HealthStatus == types.HealthStatusHealthy {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
 func (d *App) WaitForCodeDeploy(ctx context.Context, sv *Service) error {
 	d.Log("[DEBUG] wait for CodeDeploy")
-	dp, err := d.findDeploymentInfo(ctx)
+	dpID, err := d.findCodeDeployDeploymentInProgress(ctx)
 	if err != nil {
 		return err
 	}
+	d.Log("Waiting for a deployment successful ID: " + dpID)
+	go d.codeDeployProgressBar(ctx, dpID)
+
+	waiter := codedeploy.NewDeploymentSuccessfulWaiter(d.codedeploy, func(o *codedeploy.DeploymentSuccessfulWaiterOptions) {
+		o.MaxDelay = waiterMaxDelay
+		if p, ok := ctx.Value(waitPollingKey{}).(*waitPolling); ok && p.Backoff {
+			// the waiter polls with exponential backoff and jitter by itself
+			o.MinDelay, o.MaxDelay = p.Min, p.Max
+		}
+	})
+	return waiter.Wait(
+		ctx,
+		&codedeploy.GetDeploymentInput{DeploymentId: &dpID},
+		d.config.waiterTimeoutFor(timeoutPhaseWait),
+	)
+}
+
+// findCodeDeployDeploymentInProgress returns the ID of the latest deployment in progress of the service.
+func (d *App) findCodeDeployDeploymentInProgress(ctx context.Context) (string, error) {
+	dp, err := d.findDeploymentInfo(ctx)
+	if err != nil {
+		return "", err
+	}
 	out, err := d.codedeploy.ListDeployments(
 		ctx,
 		&codedeploy.ListDeploymentsInput{
@@ -157,26 +334,17 @@ func (d *App) WaitForCodeDeploy(ctx context.Context, sv *Service) error {
 		},
 	)
 	if err != nil {
-		return err
+		return "", err
 	}
 	if len(out.Deployments) == 0 {
-		return ErrNotFound("No deployments found in progress on CodeDeploy")
+		return "", ErrNotFound("No deployments found in progress on CodeDeploy")
 	}
-
-	dpID := out.Deployments[0]
-	d.Log("Waiting for a deployment successful ID: " + dpID)
-	go d.codeDeployProgressBar(ctx, dpID)
-
-	waiter := codedeploy.NewDeploymentSuccessfulWaiter(d.codedeploy, func(o *codedeploy.DeploymentSuccessfulWaiterOptions) {
-		o.MaxDelay = waiterMaxDelay
-	})
-	return waiter.Wait(
-		ctx,
-		&codedeploy.GetDeploymentInput{DeploymentId: &dpID},
-		d.Timeout(),
-	)
+	return out.Deployments[0], nil
 }
 
+// showServiceStatusInterval is the interval to show the status while waiting without --wait-backoff.
+const showServiceStatusInterval = 10 * time.Second
+
 type showState struct {
 	lastEventAt     time.Time
 	deploymentsHash []byte
@@ -198,7 +366,7 @@ func (d *App) showServiceStatus(ctx context.Context, st *showState) error {
 	})
 	for _, event := range sv.Events {
 		if (*event.CreatedAt).After(st.lastEventAt) {
-			fmt.Println(formatEvent(event))
+			fmt.Fprintln(d.stdout, formatEvent(event))
 			st.lastEventAt = *event.CreatedAt
 		}
 	}
@@ -226,14 +394,13 @@ func (d *App) codeDeployProgressBar(ctx context.Context, dpID string) error {
 	bar := progressbar.NewOptions(100,
 		progressbar.OptionSetDescription("Traffic shifted"),
 		progressbar.OptionSetWidth(20),
+		progressbar.OptionSetWriter(d.stdout),
 	)
-	t := time.NewTicker(10 * time.Second)
+	poller := newWaitPoller(ctx, showServiceStatusInterval)
 	lcEvents := map[string]cdTypes.LifecycleEventStatus{}
 	for {
-		select {
-		case <-ctx.Done():
+		if err := poller.wait(ctx); err != nil {
 			return nil
-		case <-t.C:
 		}
 		out, err := d.codedeploy.GetDeploymentTarget(ctx, &codedeploy.GetDeploymentTargetInput{
 			DeploymentId: &dpID,
@@ -265,14 +432,14 @@ func (d *App) codeDeployProgressBar(ctx context.Context, dpID string) error {
 		}
 	}
 	bar.Set(100)
-	fmt.Println()
+	fmt.Fprintln(d.stdout)
 	return nil
 }
 
 func (d *App) WaitTaskSetStable(ctx context.Context, sv *Service) error {
 	var prev types.StabilityStatus
 	for {
-		sv, err := d.DescribeService(ctx)
+		sv, err := d.DescribeService(withoutDescribeCache(ctx))
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,112 @@
+package ecspresso
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+var (
+	defaultWaitIntervalMin = 5 * time.Second
+	defaultWaitIntervalMax = time.Minute
+)
+
+// waitPolling is the policy of the intervals to poll the status in the wait loops.
+// The fixed interval of each loop is used unless Backoff is true.
+type waitPolling struct {
+	Backoff bool
+	Min     time.Duration
+	Max     time.Duration
+}
+
+type waitPollingKey struct{}
+
+func withWaitPolling(ctx context.Context, p *waitPolling) context.Context {
+	if p == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, waitPollingKey{}, p)
+}
+
+// waitPolling returns the polling policy by --wait-backoff, --wait-interval-min and --wait-interval-max.
+// It returns nil when the fixed interval is used.
+func (opt DeployOption) waitPolling() (*waitPolling, error) {
+	if !opt.WaitBackoff {
+		if opt.WaitIntervalMin != 0 || opt.WaitIntervalMax != 0 {
+			return nil, ErrConflictOptions("wait-interval-min and wait-interval-max require wait-backoff")
+		}
+		return nil, nil
+	}
+	p := &waitPolling{
+		Backoff: true,
+		Min:     defaultWaitIntervalMin,
+		Max:     defaultWaitIntervalMax,
+	}
+	if opt.WaitIntervalMin < 0 || opt.WaitIntervalMax < 0 {
+		return nil, fmt.Errorf("--wait-interval-min and --wait-interval-max must not be negative")
+	}
+	if opt.WaitIntervalMin > 0 {
+		p.Min = opt.WaitIntervalMin
+	}
+	if opt.WaitIntervalMax > 0 {
+		p.Max = opt.WaitIntervalMax
+	}
+	if p.Min > p.Max {
+		return nil, fmt.Errorf("--wait-interval-min %s must not be greater than --wait-interval-max %s", p.Min, p.Max)
+	}
+	return p, nil
+}
+
+// waitPoller computes the interval before the next poll in a wait loop.
+type waitPoller struct {
+	fixed   time.Duration
+	policy  *waitPolling
+	attempt int
+	rand    *rand.Rand
+}
+
+// newWaitPoller returns a waitPoller with the polling policy in ctx.
+// fixed is the interval used without the backoff.
+func newWaitPoller(ctx context.Context, fixed time.Duration) *waitPoller {
+	p, _ := ctx.Value(waitPollingKey{}).(*waitPolling)
+	return &waitPoller{
+		fixed:  fixed,
+		policy: p,
+		rand:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// next returns the interval before the next poll.
+// With the backoff, the upper bound grows exponentially from Min up to Max,
+// and the interval is chosen randomly between Min and the upper bound.
+func (p *waitPoller) next() time.Duration {
+	if p.policy == nil || !p.policy.Backoff {
+		return p.fixed
+	}
+	floor, ceil := p.policy.Min, p.policy.Max
+	upper := floor
+	for i := 0; i < p.attempt && upper < ceil; i++ {
+		upper *= 2
+	}
+	if upper > ceil || upper <= 0 { // upper <= 0 when overflowed
+		upper = ceil
+	}
+	p.attempt++
+	if upper <= floor {
+		return floor
+	}
+	return floor + time.Duration(p.rand.Int63n(int64(upper-floor)+1))
+}
+
+// wait sleeps for the next interval. It returns ctx.Err() when ctx is done.
+func (p *waitPoller) wait(ctx context.Context) error {
+	t := time.NewTimer(p.next())
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,83 @@
+package ecspresso_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestWaitPollIntervalsFixed(t *testing.T) {
+	ds, err := ecspresso.WaitPollIntervals(ecspresso.DeployOption{}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range ds {
+		if d != 15*time.Second {
+			t.Errorf("unexpected fixed interval: %s", d)
+		}
+	}
+}
+
+func TestWaitPollIntervalsBackoff(t *testing.T) {
+	opt := ecspresso.DeployOption{
+		WaitBackoff:     true,
+		WaitIntervalMin: 2 * time.Second,
+		WaitIntervalMax: 20 * time.Second,
+	}
+	ds, err := ecspresso.WaitPollIntervals(opt, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ds[0] != 2*time.Second {
+		t.Errorf("the first interval must be the minimum: %s", ds[0])
+	}
+	for i, d := range ds {
+		upper := 2 * time.Second << i
+		if upper > 20*time.Second || upper <= 0 {
+			upper = 20 * time.Second
+		}
+		if d < 2*time.Second || d > upper {
+			t.Errorf("interval #%d %s is out of range [2s, %s]", i, d, upper)
+		}
+	}
+}
+
+func TestWaitPollIntervalsDefaults(t *testing.T) {
+	ds, err := ecspresso.WaitPollIntervals(ecspresso.DeployOption{WaitBackoff: true}, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range ds {
+		if d < 5*time.Second || d > time.Minute {
+			t.Errorf("interval %s is out of range [5s, 1m]", d)
+		}
+	}
+}
+
+func TestWaitPollIntervalsInvalid(t *testing.T) {
+	for _, opt := range []ecspresso.DeployOption{
+		{WaitIntervalMin: time.Second},
+		{WaitIntervalMax: time.Minute},
+		{WaitBackoff: true, WaitIntervalMin: time.Minute, WaitIntervalMax: time.Second},
+		{WaitBackoff: true, WaitIntervalMin: -time.Second},
+	} {
+		if _, err := ecspresso.WaitPollIntervals(opt, 1); err == nil {
+			t.Errorf("%#v expected error, but got nil", opt)
+		}
+	}
+}
+
+func TestWaitPollCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := ecspresso.WaitPoll(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("wait must return immediately when the context is canceled")
+	}
+}
@@ -0,0 +1,217 @@
+package ecspresso_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestPrimaryDeploymentStable(t *testing.T) {
+	deployment := func(status string, state types.DeploymentRolloutState, desired, running, pending int32) types.Deployment {
+		return types.Deployment{
+			Id:           aws.String("ecs-svc/" + status),
+			Status:       aws.String(status),
+			RolloutState: state,
+			DesiredCount: desired,
+			RunningCount: running,
+			PendingCount: pending,
+		}
+	}
+	for _, tt := range []struct {
+		name        string
+		deployments []types.Deployment
+		stable      bool
+		isError     bool
+	}{
+		{
+			name: "primary completed while active is draining",
+			deployments: []types.Deployment{
+				deployment("PRIMARY", types.DeploymentRolloutStateCompleted, 2, 2, 0),
+				deployment("ACTIVE", types.DeploymentRolloutStateCompleted, 0, 2, 0),
+			},
+			stable: true,
+		},
+		{
+			name: "primary in progress while active is running",
+			deployments: []types.Deployment{
+				deployment("ACTIVE", types.DeploymentRolloutStateCompleted, 2, 2, 0),
+				deployment("PRIMARY", types.DeploymentRolloutStateInProgress, 2, 1, 1),
+			},
+			stable: false,
+		},
+		{
+			name: "primary failed",
+			deployments: []types.Deployment{
+				deployment("PRIMARY", types.DeploymentRolloutStateFailed, 2, 0, 0),
+				deployment("ACTIVE", types.DeploymentRolloutStateCompleted, 2, 2, 0),
+			},
+			isError: true,
+		},
+		{
+			name: "no rollout state, counts reached",
+			deployments: []types.Deployment{
+				deployment("PRIMARY", "", 2, 2, 0),
+				deployment("ACTIVE", "", 0, 1, 0),
+			},
+			stable: true,
+		},
+		{
+			name: "no rollout state, pending tasks",
+			deployments: []types.Deployment{
+				deployment("PRIMARY", "", 2, 2, 1),
+			},
+			stable: false,
+		},
+		{
+			name: "no primary deployment",
+			deployments: []types.Deployment{
+				deployment("ACTIVE", types.DeploymentRolloutStateCompleted, 2, 2, 0),
+			},
+			stable: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := types.Service{
+				ServiceName: aws.String("test"),
+				Status:      aws.String("ACTIVE"),
+				Deployments: tt.deployments,
+			}
+			stable, err := ecspresso.PrimaryDeploymentStable(sv)
+			if tt.isError {
+				if err == nil {
+					t.Error("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if stable != tt.stable {
+				t.Errorf("expected stable %v, but got %v", tt.stable, stable)
+			}
+		})
+	}
+
+	if _, err := ecspresso.PrimaryDeploymentStable(types.Service{Status: aws.String("DRAINING")}); err == nil {
+		t.Error("expected error for the draining service")
+	}
+}
+
+func TestPrimaryDeploymentMinRunning(t *testing.T) {
+	deployment := func(status string, state types.DeploymentRolloutState, desired, running int32) types.Deployment {
+		return types.Deployment{
+			Id:           aws.String("ecs-svc/" + status),
+			Status:       aws.String(status),
+			RolloutState: state,
+			DesiredCount: desired,
+			RunningCount: running,
+		}
+	}
+	for _, tt := range []struct {
+		name        string
+		percent     int32
+		deployments []types.Deployment
+		reached     bool
+		isError     bool
+	}{
+		{
+			name:    "reached the percent while in progress",
+			percent: 80,
+			deployments: []types.Deployment{
+				deployment("PRIMARY", types.DeploymentRolloutStateInProgress, 10, 8),
+				deployment("ACTIVE", types.DeploymentRolloutStateCompleted, 0, 10),
+			},
+			reached: true,
+		},
+		{
+			name:    "not reached the percent",
+			percent: 80,
+			deployments: []types.Deployment{
+				deployment("PRIMARY", types.DeploymentRolloutStateInProgress, 10, 7),
+				deployment("ACTIVE", types.DeploymentRolloutStateCompleted, 0, 10),
+			},
+			reached: false,
+		},
+		{
+			name:    "running tasks of active deployment are not counted",
+			percent: 50,
+			deployments: []types.Deployment{
+				deployment("ACTIVE", types.DeploymentRolloutStateCompleted, 10, 10),
+				deployment("PRIMARY", types.DeploymentRolloutStateInProgress, 10, 0),
+			},
+			reached: false,
+		},
+		{
+			name:    "primary completed",
+			percent: 100,
+			deployments: []types.Deployment{
+				deployment("PRIMARY", types.DeploymentRolloutStateCompleted, 10, 10),
+			},
+			reached: true,
+		},
+		{
+			name:    "primary failed",
+			percent: 10,
+			deployments: []types.Deployment{
+				deployment("PRIMARY", types.DeploymentRolloutStateFailed, 10, 5),
+			},
+			isError: true,
+		},
+		{
+			name:    "no primary deployment",
+			percent: 10,
+			deployments: []types.Deployment{
+				deployment("ACTIVE", types.DeploymentRolloutStateCompleted, 10, 10),
+			},
+			reached: false,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			sv := types.Service{
+				ServiceName: aws.String("test"),
+				Status:      aws.String("ACTIVE"),
+				Deployments: tt.deployments,
+			}
+			dep, err := ecspresso.PrimaryDeploymentMinRunning(sv, tt.percent)
+			if tt.isError {
+				if err == nil {
+					t.Error("expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if reached := dep != nil; reached != tt.reached {
+				t.Errorf("expected reached %v, but got %v", tt.reached, reached)
+			}
+			if dep != nil && aws.ToString(dep.Status) != "PRIMARY" {
+				t.Errorf("expected PRIMARY deployment, but got %s", aws.ToString(dep.Status))
+			}
+		})
+	}
+}
+
+func TestValidateWaitForMinRunning(t *testing.T) {
+	for _, tt := range []struct {
+		opt     ecspresso.DeployOption
+		isError bool
+	}{
+		{opt: ecspresso.DeployOption{Wait: true}},
+		{opt: ecspresso.DeployOption{Wait: true, WaitForMinRunningPercent: 50}},
+		{opt: ecspresso.DeployOption{Wait: true, WaitForMinRunningPercent: 50, WaitForMinRunningHealthy: true}},
+		{opt: ecspresso.DeployOption{Wait: true, WaitForMinRunningPercent: 101}, isError: true},
+		{opt: ecspresso.DeployOption{Wait: true, WaitForMinRunningPercent: -1}, isError: true},
+		{opt: ecspresso.DeployOption{Wait: true, WaitForMinRunningHealthy: true}, isError: true},
+		{opt: ecspresso.DeployOption{Wait: false, WaitForMinRunningPercent: 50}, isError: true},
+	} {
+		err := tt.opt.ValidateWaitForMinRunning()
+		if tt.isError && err == nil {
+			t.Errorf("expected error for %#v", tt.opt)
+		} else if !tt.isError && err != nil {
+			t.Errorf("unexpected error for %#v: %s", tt.opt, err)
+		}
+	}
+}
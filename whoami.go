@@ -0,0 +1,83 @@
+package ecspresso
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+type WhoamiOption struct {
+	Output string `help:"output format" enum:"text,json" default:"text"`
+}
+
+// awsIdentity represents the AWS identity and region which ecspresso uses.
+type awsIdentity struct {
+	Account       string `json:"account"`
+	Arn           string `json:"arn"`
+	UserID        string `json:"user_id"`
+	Region        string `json:"region"`
+	Profile       string `json:"profile,omitempty"`
+	AssumeRoleARN string `json:"assume_role_arn,omitempty"`
+}
+
+func (id awsIdentity) OutputJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(id)
+}
+
+func (id awsIdentity) OutputText(w io.Writer) error {
+	fmt.Fprintln(w, "Account:", id.Account)
+	fmt.Fprintln(w, "Arn:", id.Arn)
+	fmt.Fprintln(w, "UserId:", id.UserID)
+	fmt.Fprintln(w, "Region:", id.Region)
+	if id.Profile != "" {
+		fmt.Fprintln(w, "Profile:", id.Profile)
+	}
+	if id.AssumeRoleARN != "" {
+		fmt.Fprintln(w, "AssumeRole:", id.AssumeRoleARN)
+	}
+	return nil
+}
+
+// awsProfile returns the name of the shared config profile to load the AWS config.
+// profile in the config (or --profile) takes precedence over the environment variables.
+func (c *Config) awsProfile() string {
+	if c.Profile != "" {
+		return c.Profile
+	}
+	if p := os.Getenv("AWS_PROFILE"); p != "" {
+		return p
+	}
+	return os.Getenv("AWS_DEFAULT_PROFILE")
+}
+
+func (d *App) Whoami(ctx context.Context, opt WhoamiOption) error {
+	ctx, cancel := d.Start(ctx)
+	defer cancel()
+
+	// the config is resolved after assuming the role by --assume-role-arn
+	out, err := sts.NewFromConfig(d.config.awsv2Config).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return fmt.Errorf("failed to get caller identity: %w", err)
+	}
+	id := awsIdentity{
+		Account:       aws.ToString(out.Account),
+		Arn:           aws.ToString(out.Arn),
+		UserID:        aws.ToString(out.UserId),
+		Region:        d.config.awsv2Config.Region,
+		Profile:       d.config.awsProfile(),
+		AssumeRoleARN: d.config.assumeRoleARN,
+	}
+	switch opt.Output {
+	case "json":
+		return id.OutputJSON(d.stdout)
+	default:
+		return id.OutputText(d.stdout)
+	}
+}
@@ -0,0 +1,69 @@
+package ecspresso_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/kayac/ecspresso/v2"
+)
+
+func TestAWSIdentityOutput(t *testing.T) {
+	id := ecspresso.AWSIdentity{
+		Account:       "123456789012",
+		Arn:           "arn:aws:sts::123456789012:assumed-role/deploy/session",
+		UserID:        "AROAEXAMPLE:session",
+		Region:        "ap-northeast-1",
+		AssumeRoleARN: "arn:aws:iam::123456789012:role/deploy",
+	}
+	b := new(bytes.Buffer)
+	if err := id.OutputText(b); err != nil {
+		t.Fatal(err)
+	}
+	expected := `Account: 123456789012
+Arn: arn:aws:sts::123456789012:assumed-role/deploy/session
+UserId: AROAEXAMPLE:session
+Region: ap-northeast-1
+AssumeRole: arn:aws:iam::123456789012:role/deploy
+`
+	if diff := cmp.Diff(expected, b.String()); diff != "" {
+		t.Errorf("unexpected text output: %s", diff)
+	}
+
+	b.Reset()
+	if err := id.OutputJSON(b); err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]string
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["account"] != "123456789012" || m["user_id"] != "AROAEXAMPLE:session" || m["region"] != "ap-northeast-1" {
+		t.Errorf("unexpected json output: %s", b.String())
+	}
+	if _, ok := m["profile"]; ok {
+		t.Errorf("empty profile must be omitted: %s", b.String())
+	}
+}
+
+func TestConfigAWSProfile(t *testing.T) {
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_DEFAULT_PROFILE", "")
+	if p := (&ecspresso.Config{}).AWSProfile(); p != "" {
+		t.Errorf("unexpected profile: %s", p)
+	}
+
+	t.Setenv("AWS_DEFAULT_PROFILE", "default-env")
+	if p := (&ecspresso.Config{}).AWSProfile(); p != "default-env" {
+		t.Errorf("unexpected profile: %s", p)
+	}
+	t.Setenv("AWS_PROFILE", "env")
+	if p := (&ecspresso.Config{}).AWSProfile(); p != "env" {
+		t.Errorf("unexpected profile: %s", p)
+	}
+	// profile in the config or --profile is used by the AWS config
+	if p := (&ecspresso.Config{Profile: "staging"}).AWSProfile(); p != "staging" {
+		t.Errorf("unexpected profile: %s", p)
+	}
+}